@@ -58,6 +58,9 @@ func SettingsFile() string {
 var syntax = `
 index = "$string" "indices*";
 github source = "$string" "sources*";
+search source = "$string" "sources*";
+file source = "$string" "sources*";
+git source = "$string" "sources*";
 
 choose _ = "$string" "choices*";
 `
@@ -120,7 +123,7 @@ func ReadSettings() (Settings, error) {
 			path := strings.Split(val, "/")
 			switch len(path) {
 			case 1:
-				c, err := crawl.MakeGitHubCrawler(path[0], "", "")
+				c, err := crawl.MakeGitHubCrawler(path[0], nil, "")
 				if err != nil {
 					return blank,
 						fmt.Errorf("Unable to create GitHub crawler from %s: %v",
@@ -128,7 +131,7 @@ func ReadSettings() (Settings, error) {
 				}
 				ret.Sources = append(ret.Sources, c)
 			case 2:
-				c, err := crawl.MakeGitHubCrawler(path[0], path[1], "")
+				c, err := crawl.MakeGitHubCrawler(path[0], []string{path[1]}, "")
 				if err != nil {
 					return blank,
 						fmt.Errorf("Unable to create GitHub crawler from %s: %v",
@@ -141,9 +144,37 @@ func ReadSettings() (Settings, error) {
 						val)
 			}
 
+		case "search":
+			// The value is a GitHub repository search query
+			c, err := crawl.MakeGitHubSearchCrawler(val, nil, "")
+			if err != nil {
+				return blank,
+					fmt.Errorf("Unable to create GitHub search crawler from %s: %v",
+						val, err)
+			}
+			ret.Sources = append(ret.Sources, c)
+
+		case "file":
+			c, err := crawl.MakeFileSystemCrawler(val)
+			if err != nil {
+				return blank,
+					fmt.Errorf("Unable to create file system crawler from %s: %v",
+						val, err)
+			}
+			ret.Sources = append(ret.Sources, c)
+
+		case "git":
+			c, err := crawl.MakeGitCrawler([]string{val})
+			if err != nil {
+				return blank,
+					fmt.Errorf("Unable to create git crawler from %s: %v",
+						val, err)
+			}
+			ret.Sources = append(ret.Sources, c)
+
 		default:
 			return blank,
-				fmt.Errorf("Unrecognized scheme in source %s, expected 'github'",
+				fmt.Errorf("Unrecognized scheme in source %s, expected 'github', 'search', 'file' or 'git'",
 					val)
 		}
 	}
@@ -0,0 +1,64 @@
+package crawl
+
+import (
+	"sync/atomic"
+
+	"github.com/google/go-github/github"
+
+	"github.com/impact/impact/parsing"
+)
+
+// This function returns the given tags without the lightweight ones if
+// only annotated tags are indexed (see SetAnnotatedOnly) along with the
+// number of lightweight tags that were dropped.  The tag listing doesn't
+// say whether a tag is annotated, so the object each tag refers to has to
+// be looked up.  Tags that don't represent versions are kept (without
+// looking them up) since they are skipped anyway.  Tags that can't be
+// looked up are dropped (with a warning) since they can't be shown to be
+// annotated.
+func (c GitHubCrawler) annotatedTags(client *GitHubClient, rname string, tags []github.RepositoryTag,
+	logger CrawlLogger) ([]github.RepositoryTag, int) {
+	if !c.annotatedOnly {
+		return tags, 0
+	}
+
+	ret := []github.RepositoryTag{}
+	lightweight := 0
+	for _, tag := range tags {
+		if tag.Name == nil {
+			ret = append(ret, tag)
+			continue
+		}
+		versionString, ok := mapTag(c.tagMapper, *tag.Name)
+		if !ok {
+			ret = append(ret, tag)
+			continue
+		}
+		if _, err := parsing.NormalizeWith(c.scheme, versionString); err != nil {
+			ret = append(ret, tag)
+			continue
+		}
+
+		obj, err := tagObject(client, c.user, rname, *tag.Name)
+		if err != nil {
+			if client.ctx.Err() != nil {
+				return ret, lightweight
+			}
+			logger.Warnf("Skipping tag %s of %s/%s, unable to tell whether it is annotated: %v",
+				*tag.Name, c.user, rname, err)
+			continue
+		}
+		if stringOf(obj.Type) != "tag" {
+			logger.Debugf("  %s: Skipping lightweight tag", *tag.Name)
+			lightweight++
+			continue
+		}
+		ret = append(ret, tag)
+	}
+
+	if lightweight > 0 {
+		atomic.AddInt64(&c.stats.TagsSkippedLightweight, int64(lightweight))
+		logger.Infof("  Skipped %d lightweight tags of %s/%s", lightweight, c.user, rname)
+	}
+	return ret, lightweight
+}
@@ -0,0 +1,71 @@
+package crawl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/github"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+)
+
+func TestAnnotatedOnly(t *testing.T) {
+	Convey("Testing indexing only annotated tags", t, func(c C) {
+		lookups := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			lookups++
+			switch r.URL.Path {
+			case "/repos/a/R/git/refs/tags/v1.0.0":
+				fmt.Fprint(w, `{"ref": "refs/tags/v1.0.0", "object": {"type": "tag", "sha": "t1"}}`)
+			case "/repos/a/R/git/refs/tags/v2.0.0":
+				fmt.Fprint(w, `{"ref": "refs/tags/v2.0.0", "object": {"type": "commit", "sha": "c2"}}`)
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		defer server.Close()
+
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		client := github.NewClient(nil)
+		base, err := url.Parse(server.URL + "/")
+		NoError(c, err)
+		client.BaseURL = base
+		gc := NewGitHubClient(client, 0, logger)
+		gc.SetRetries(0, 0)
+
+		tags := []github.RepositoryTag{}
+		for _, name := range []string{"v1.0.0", "v2.0.0", "v3.0.0", "latest"} {
+			tags = append(tags, github.RepositoryTag{Name: github.String(name)})
+		}
+		names := func(tags []github.RepositoryTag) []string {
+			ret := []string{}
+			for _, tag := range tags {
+				ret = append(ret, *tag.Name)
+			}
+			return ret
+		}
+
+		// By default, nothing needs to be looked up
+		cr, err := MakeGitHubCrawler("a", nil, "")
+		NoError(c, err)
+		kept, lightweight := cr.annotatedTags(gc, "R", tags, logger)
+		Equals(c, len(kept), len(tags))
+		Equals(c, lightweight, 0)
+		Equals(c, lookups, 0)
+
+		// Otherwise, only annotated tags (and tags that aren't versions)
+		// are kept
+		cr.SetAnnotatedOnly(true)
+		kept, lightweight = cr.annotatedTags(gc, "R", tags, logger)
+		Resembles(c, names(kept), []string{"v1.0.0", "latest"})
+		Equals(c, lightweight, 1)
+		Equals(c, lookups, 3)
+		Equals(c, cr.stats.TagsSkippedLightweight, int64(1))
+	})
+}
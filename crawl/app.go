@@ -0,0 +1,151 @@
+package crawl
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+// Installation tokens are refreshed this long before they expire (so a
+// token never expires in the middle of a request)
+var appTokenMargin = 5 * time.Minute
+
+// The gitHubApp type holds the credentials of a GitHub App installation.
+// These are used to obtain (short-lived) installation tokens.
+type gitHubApp struct {
+	appID          int64
+	installationID int64
+	key            *rsa.PrivateKey
+	// Base URL of the GitHub API
+	baseURL string
+}
+
+// This function parses a PEM encoded RSA private key (in either PKCS#1 or
+// PKCS#8 form, GitHub provides the former).
+func parsePrivateKey(privateKey []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(privateKey)
+	if block == nil {
+		return nil, fmt.Errorf("No PEM data found in private key")
+	}
+
+	key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	if err == nil {
+		return key, nil
+	}
+
+	parsed, perr := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if perr != nil {
+		return nil, fmt.Errorf("Unable to parse private key: %v", err)
+	}
+	rkey, ok := parsed.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("Private key is not an RSA key")
+	}
+	return rkey, nil
+}
+
+// The jwt method creates the (RS256 signed) JSON web token used to
+// authenticate as the app itself.  GitHub only accepts tokens that expire
+// within 10 minutes.
+func (a gitHubApp) jwt(now time.Time) (string, error) {
+	enc := base64.RawURLEncoding
+	header := enc.EncodeToString([]byte(`{"alg":"RS256","typ":"JWT"}`))
+
+	claims, err := json.Marshal(map[string]int64{
+		// Allow for some clock drift
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": a.appID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	unsigned := header + "." + enc.EncodeToString(claims)
+	sum := sha256.Sum256([]byte(unsigned))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, a.key, crypto.SHA256, sum[:])
+	if err != nil {
+		return "", fmt.Errorf("Unable to sign token for GitHub App %d: %v", a.appID, err)
+	}
+	return unsigned + "." + enc.EncodeToString(sig), nil
+}
+
+// The appTokenSource mints installation tokens as needed.  It is wrapped
+// by oauth2.ReuseTokenSource so that a new token is only requested once
+// the current one is close to expiring.
+type appTokenSource struct {
+	ctx context.Context
+	app gitHubApp
+}
+
+// The tokenSource method returns a token source that provides a valid
+// installation token for the entire crawl.
+func (a gitHubApp) tokenSource(ctx context.Context) oauth2.TokenSource {
+	return oauth2.ReuseTokenSource(nil, appTokenSource{ctx: ctx, app: a})
+}
+
+func (s appTokenSource) Token() (*oauth2.Token, error) {
+	jwt, err := s.app.jwt(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("%s/app/installations/%d/access_tokens",
+		strings.TrimSuffix(s.app.baseURL, "/"), s.app.installationID)
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(s.ctx)
+	req.Header.Set("Authorization", "Bearer "+jwt)
+	req.Header.Set("Accept", "application/vnd.github.machine-man-preview+json")
+
+	// As with the oauth2 package, use the HTTP client from the context
+	// (if there is one)
+	client := http.DefaultClient
+	if hc, ok := s.ctx.Value(oauth2.HTTPClient).(*http.Client); ok && hc != nil {
+		client = hc
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Error requesting installation token: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading installation token: %v", err)
+	}
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Error requesting installation token for installation %d: %s: %s",
+			s.app.installationID, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	var token struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	err = json.Unmarshal(body, &token)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse installation token: %v", err)
+	}
+
+	return &oauth2.Token{
+		AccessToken: token.Token,
+		Expiry:      token.ExpiresAt.Add(-appTokenMargin),
+	}, nil
+}
@@ -0,0 +1,81 @@
+package crawl
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+)
+
+func TestGitHubApp(t *testing.T) {
+	Convey("Testing GitHub App installation tokens", t, func(c C) {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		NoError(c, err)
+		pemKey := pem.EncodeToMemory(&pem.Block{
+			Type:  "RSA PRIVATE KEY",
+			Bytes: x509.MarshalPKCS1PrivateKey(key),
+		})
+
+		_, err = MakeGitHubAppCrawler("a", nil, 1, 2, []byte("not a key"))
+		IsError(c, err)
+
+		cr, err := MakeGitHubAppCrawler("a", nil, 1, 2, pemKey)
+		NoError(c, err)
+
+		minted := 0
+		lifetime := time.Hour
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			Equals(c, r.URL.Path, "/app/installations/2/access_tokens")
+
+			// Make sure the request was signed with the app's key
+			jwt := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			parts := strings.Split(jwt, ".")
+			Equals(c, len(parts), 3)
+			sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+			NoError(c, err)
+			sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+			NoError(c, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, sum[:], sig))
+
+			minted++
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprintf(w, `{"token": "token%d", "expires_at": "%s"}`, minted,
+				time.Now().Add(appTokenMargin+lifetime).Format(time.RFC3339))
+		}))
+		defer server.Close()
+
+		cr.app.baseURL = server.URL
+		ts := cr.app.tokenSource(context.Background())
+		token, err := ts.Token()
+		NoError(c, err)
+		Equals(c, token.AccessToken, "token1")
+
+		// The token is reused until it nears expiry
+		token, err = ts.Token()
+		NoError(c, err)
+		Equals(c, token.AccessToken, "token1")
+		Equals(c, minted, 1)
+
+		// An installation token that is about to expire is replaced
+		lifetime = 0
+		ts = cr.app.tokenSource(context.Background())
+		token, err = ts.Token()
+		NoError(c, err)
+		Equals(c, token.AccessToken, "token2")
+		token, err = ts.Token()
+		NoError(c, err)
+		Equals(c, token.AccessToken, "token3")
+	})
+}
@@ -0,0 +1,78 @@
+package crawl
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/go-github/github"
+)
+
+// The ArchivedPolicy type indicates whether a crawler should index
+// repositories that have been archived (i.e., made read-only) on GitHub.
+type ArchivedPolicy int
+
+const (
+	// Don't index archived repositories (the default)
+	SkipArchived ArchivedPolicy = iota
+	// Index archived repositories like any other
+	IncludeArchived
+	// Only index archived repositories (e.g., for an "attic" index)
+	OnlyArchived
+)
+
+// The version of go-github we use doesn't know about archived
+// repositories, so this is taken from the repository details separately
+// (for both the repository and, if it is a fork, its source).
+type archiveStatus struct {
+	Archived bool `json:"archived"`
+	Source   *struct {
+		Archived bool `json:"archived"`
+	} `json:"source"`
+}
+
+// This function fetches the complete details of a repository along with
+// whether it (and its source, if it is a fork) is archived.
+func getRepository(client *GitHubClient, owner string, rname string) (*github.Repository,
+	archiveStatus, error) {
+	status := archiveStatus{}
+	raw := bytes.Buffer{}
+	err := client.call(func() error {
+		raw.Reset()
+		req, err := client.client.NewRequest("GET", fmt.Sprintf("repos/%s/%s", owner, rname), nil)
+		if err != nil {
+			return err
+		}
+		_, err = client.client.Do(req, &raw)
+		return err
+	})
+	if err != nil {
+		return nil, status, err
+	}
+
+	repo := &github.Repository{}
+	err = json.Unmarshal(raw.Bytes(), repo)
+	if err != nil {
+		return nil, status, fmt.Errorf("Unable to parse details of %s/%s: %v", owner, rname, err)
+	}
+	err = json.Unmarshal(raw.Bytes(), &status)
+	if err != nil {
+		return nil, status, fmt.Errorf("Unable to parse details of %s/%s: %v", owner, rname, err)
+	}
+	return repo, status, nil
+}
+
+// This function determines whether a repository should be indexed given
+// whether it is archived (see SetArchivedPolicy).
+func (c GitHubCrawler) archivedAllowed(repo github.Repository, archived bool,
+	logger CrawlLogger) bool {
+	switch {
+	case archived && c.archived == SkipArchived:
+		logger.Infof("Skipping: %s, archived", stringOf(repo.HTMLURL))
+		return false
+	case !archived && c.archived == OnlyArchived:
+		logger.Infof("Skipping: %s, not archived", stringOf(repo.HTMLURL))
+		return false
+	}
+	return true
+}
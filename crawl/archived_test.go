@@ -0,0 +1,76 @@
+package crawl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/github"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+
+	"github.com/impact/impact/recorder"
+)
+
+func TestArchived(t *testing.T) {
+	Convey("Testing skipping archived repositories", t, func(c C) {
+		tags := map[string]int{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/users/a/repos":
+				fmt.Fprint(w, `[{"name": "Old"}, {"name": "New"}, {"name": "Fork", "fork": true}]`)
+			case "/repos/a/Old":
+				fmt.Fprint(w, `{"name": "Old", "archived": true}`)
+			case "/repos/a/New":
+				fmt.Fprint(w, `{"name": "New", "archived": false}`)
+			case "/repos/a/Fork":
+				fmt.Fprint(w, `{"name": "Fork", "fork": true, "source": {"name": "Source", "archived": true}}`)
+			case "/repos/a/Old/tags", "/repos/a/New/tags", "/repos/a/Fork/tags":
+				tags[r.URL.Path]++
+				fmt.Fprint(w, `[]`)
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		defer server.Close()
+
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		client := github.NewClient(nil)
+		base, err := url.Parse(server.URL + "/")
+		NoError(c, err)
+		client.BaseURL = base
+		gc := NewGitHubClient(client, 0, logger)
+		gc.SetRetries(0, 0)
+
+		repo, status, err := getRepository(gc, "a", "Fork")
+		NoError(c, err)
+		Equals(c, stringOf(repo.Source.Name), "Source")
+		IsFalse(c, status.Archived)
+		IsTrue(c, status.Source.Archived)
+
+		_, _, err = getRepository(gc, "a", "Missing")
+		IsError(c, err)
+
+		cr, err := MakeGitHubCrawler("a", nil, "")
+		NoError(c, err)
+
+		// By default, archived repositories (and forks of them) are skipped
+		NoError(c, cr.crawlUser(gc, recorder.NullRecorder{}, logger))
+		Resembles(c, tags, map[string]int{"/repos/a/New/tags": 1})
+
+		cr.SetArchivedPolicy(OnlyArchived)
+		NoError(c, cr.crawlUser(gc, recorder.NullRecorder{}, logger))
+		Resembles(c, tags, map[string]int{"/repos/a/New/tags": 1, "/repos/a/Old/tags": 1,
+			"/repos/a/Fork/tags": 1})
+
+		cr.SetArchivedPolicy(IncludeArchived)
+		NoError(c, cr.crawlUser(gc, recorder.NullRecorder{}, logger))
+		Resembles(c, tags, map[string]int{"/repos/a/New/tags": 2, "/repos/a/Old/tags": 2,
+			"/repos/a/Fork/tags": 2})
+	})
+}
@@ -0,0 +1,63 @@
+package crawl
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// The AssetPolicy type indicates whether a crawler should use the assets
+// attached to a GitHub release (rather than the archives GitHub generates
+// from the source) as the archives of a version.
+type AssetPolicy int
+
+const (
+	// Always use the source archives
+	IgnoreAssets AssetPolicy = iota
+	// Use the archive assets of the release for a tag (if any).  Source
+	// archives are then not used at all, so a version may end up with
+	// only a zipball (or only a tarball).
+	PreferAssets
+	// Like PreferAssets, but tags without archive assets are skipped
+	RequireAssets
+)
+
+// This function returns the download URLs of the tarball and zipball
+// assets (if any) attached to the release for the given tag.  A tag without
+// a release simply has no assets.
+func releaseAssets(client *GitHubClient, owner string, rname string, tag string,
+	logger CrawlLogger) (string, string) {
+	var release *github.RepositoryRelease
+	var resp *github.Response
+	err := client.call(func() (err error) {
+		release, resp, err = client.client.Repositories.GetReleaseByTag(owner, rname, tag)
+		return
+	})
+	if err != nil {
+		if resp == nil || resp.StatusCode != http.StatusNotFound {
+			logger.Warnf("Unable to get release for tag %s of %s/%s: %v", tag, owner, rname, err)
+		}
+		return "", ""
+	}
+	if release == nil {
+		return "", ""
+	}
+
+	tarurl := ""
+	zipurl := ""
+	for _, asset := range release.Assets {
+		name := strings.ToLower(stringOf(asset.Name))
+		url := stringOf(asset.BrowserDownloadURL)
+		if url == "" {
+			continue
+		}
+		switch {
+		case tarurl == "" && (strings.HasSuffix(name, ".tar.gz") || strings.HasSuffix(name, ".tgz")):
+			tarurl = url
+		case zipurl == "" && strings.HasSuffix(name, ".zip"):
+			zipurl = url
+		}
+	}
+	return tarurl, zipurl
+}
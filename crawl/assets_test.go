@@ -0,0 +1,76 @@
+package crawl
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/github"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+
+	"github.com/impact/impact/recorder"
+)
+
+func TestReleaseAssets(t *testing.T) {
+	Convey("Testing the use of release assets", t, func(c C) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/repos/a/Foo/releases/tags/v1.0.0":
+				fmt.Fprint(w, `{"tag_name": "v1.0.0", "assets": [
+  {"name": "notes.txt", "browser_download_url": "https://example.com/notes.txt"},
+  {"name": "Foo-1.0.0.ZIP", "browser_download_url": "https://example.com/Foo-1.0.0.zip"},
+  {"name": "Foo-1.0.0.tgz", "browser_download_url": "https://example.com/Foo-1.0.0.tgz"}
+]}`)
+			case "/repos/a/Foo/releases/tags/v1.1.0":
+				fmt.Fprint(w, `{"tag_name": "v1.1.0", "assets": []}`)
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		defer server.Close()
+
+		buf := bytes.Buffer{}
+		logger := StandardLogger(log.New(&buf, "", 0), FileLevel)
+		client := github.NewClient(nil)
+		base, err := url.Parse(server.URL + "/")
+		NoError(c, err)
+		client.BaseURL = base
+		gc := NewGitHubClient(client, 0, logger)
+		gc.SetRetries(0, 0)
+
+		tarurl, zipurl := releaseAssets(gc, "a", "Foo", "v1.0.0", logger)
+		Equals(c, tarurl, "https://example.com/Foo-1.0.0.tgz")
+		Equals(c, zipurl, "https://example.com/Foo-1.0.0.zip")
+
+		tarurl, zipurl = releaseAssets(gc, "a", "Foo", "v1.1.0", logger)
+		Equals(c, tarurl, "")
+		Equals(c, zipurl, "")
+
+		// A tag without a release isn't a problem
+		tarurl, zipurl = releaseAssets(gc, "a", "Foo", "v2.0.0", logger)
+		Equals(c, tarurl, "")
+		IsTrue(c, !strings.Contains(buf.String(), "Warning"))
+
+		// Tags without assets can be skipped
+		cr, err := MakeGitHubCrawler("a", nil, "")
+		NoError(c, err)
+		cr.SetAssetPolicy(RequireAssets)
+		tag := func(name string) github.RepositoryTag {
+			return github.RepositoryTag{
+				Name:   github.String(name),
+				Commit: &github.Commit{SHA: github.String("abcdef")},
+			}
+		}
+		cr.processTag(gc, recorder.NullRecorder{}, "Foo", github.Repository{}, tag("v1.1.0"), logger)
+		IsTrue(c, strings.Contains(buf.String(), "1.1.0: Ignoring, no release assets"))
+		cr.processTag(gc, recorder.NullRecorder{}, "Foo", github.Repository{}, tag("v1.0.0"), logger)
+		IsTrue(c, !strings.Contains(buf.String(), "1.0.0: Ignoring, no release assets"))
+	})
+}
@@ -0,0 +1,352 @@
+package crawl
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/impact/impact/dirinfo"
+)
+
+// The crawlCache type stores information from previous crawls so that
+// subsequent crawls can avoid repeating work when nothing has changed.
+// It records two things.  First, the ETag (and body) of each GitHub API
+// response so that later requests can be made conditionally (a response
+// of 304 Not Modified means the cached body can be reused).  Second, the
+// directory information extracted for each version (keyed by
+// user/repo/tag) along with the SHA it was extracted from and the
+// settings it was extracted with (see extractionSettings).  This allows
+// ExtractInfo to be skipped entirely for versions that haven't changed.
+//
+// All methods can safely be called on a nil cache (in which case nothing
+// is cached).
+type crawlCache struct {
+	filename string
+	mutex    sync.Mutex
+
+	Responses map[string]cachedResponse `json:"responses"` // key: request URL
+	Versions  map[string]cachedVersion  `json:"versions"`  // key: user/repo/tag
+}
+
+type cachedResponse struct {
+	ETag string `json:"etag"`
+	Body []byte `json:"body"`
+}
+
+type cachedVersion struct {
+	Sha string `json:"sha"`
+	// The settings Info was extracted with (see extractionSettings)
+	Settings string                `json:"settings,omitempty"`
+	Info     dirinfo.DirectoryInfo `json:"info"`
+	// Checksums of the tarball (if they were computed)
+	Checksums map[string]string `json:"checksums,omitempty"`
+	// Size of the tree of the commit (if it was computed)
+	Size int64 `json:"size,omitempty"`
+	// Release notes of the version (if they were fetched)
+	Notes string `json:"notes,omitempty"`
+	// Whether impact.json was signed by a trusted publisher (this isn't
+	// part of the JSON representation of Info)
+	Verified bool `json:"verified,omitempty"`
+}
+
+// This function loads the cache stored in the named file.  If the file
+// doesn't exist yet, an empty cache is returned.  Since the cache only
+// saves work, a file that can't be parsed (e.g., because it was written
+// by something else) is also treated as an empty cache, with a warning.
+func loadCache(filename string, logger CrawlLogger) (*crawlCache, error) {
+	cache := &crawlCache{
+		filename:  filename,
+		Responses: map[string]cachedResponse{},
+		Versions:  map[string]cachedVersion{},
+	}
+
+	raw, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return cache, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read cache file %s: %v", filename, err)
+	}
+
+	err = json.Unmarshal(raw, cache)
+	if err != nil {
+		logger.Warnf("Ignoring cache file %s, unable to parse it: %v", filename, err)
+		cache.Responses = nil
+		cache.Versions = nil
+	}
+	if cache.Responses == nil {
+		cache.Responses = map[string]cachedResponse{}
+	}
+	if cache.Versions == nil {
+		cache.Versions = map[string]cachedVersion{}
+	}
+	return cache, nil
+}
+
+// The save method writes the cache back to the file it was loaded from
+// (without ever leaving it incomplete, see replaceFile).
+func (c *crawlCache) save() error {
+	if c == nil {
+		return nil
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	raw, err := json.Marshal(c)
+	if err != nil {
+		return fmt.Errorf("Unable to serialize cache: %v", err)
+	}
+	err = replaceFile(c.filename, raw)
+	if err != nil {
+		return fmt.Errorf("Unable to write cache file %s: %v", c.filename, err)
+	}
+	return nil
+}
+
+// This function returns a fingerprint of the settings (other than the
+// commit itself) that the directory information of a version depends on,
+// i.e., whether only the headers of files are read and which publisher
+// keys are trusted.  The default settings have an empty fingerprint.
+func extractionSettings(headersOnly bool, keys PublisherKeys) string {
+	if !headersOnly && len(keys) == 0 {
+		return ""
+	}
+	encoded := []string{}
+	for _, key := range keys {
+		encoded = append(encoded, base64.StdEncoding.EncodeToString(key))
+	}
+	sort.Strings(encoded)
+
+	h := sha256.New()
+	fmt.Fprintf(h, "headers-only=%v\n", headersOnly)
+	for _, key := range encoded {
+		fmt.Fprintf(h, "key=%s\n", key)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// The version method returns the directory information previously
+// extracted for the given version, provided it was extracted from the
+// same SHA with the same settings (see extractionSettings).
+func (c *crawlCache) version(key string, sha string, settings string) (dirinfo.DirectoryInfo, bool) {
+	if c == nil || sha == "" {
+		return dirinfo.DirectoryInfo{}, false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	cv, exists := c.Versions[key]
+	if !exists || cv.Sha != sha || cv.Settings != settings {
+		return dirinfo.DirectoryInfo{}, false
+	}
+	cv.Info.Verified = cv.Verified
+	return cv.Info, true
+}
+
+func (c *crawlCache) setVersion(key string, sha string, settings string, di dirinfo.DirectoryInfo) {
+	if c == nil || sha == "" {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	cv := cachedVersion{Sha: sha, Settings: settings, Info: di, Verified: di.Verified}
+	// The same commit still has the same archive
+	if existing, exists := c.Versions[key]; exists && existing.Sha == sha {
+		cv.Checksums = existing.Checksums
+		cv.Size = existing.Size
+		cv.Notes = existing.Notes
+	}
+	c.Versions[key] = cv
+}
+
+// The checksums method returns the checksums of the tarball cached for
+// the given version, provided they were computed for the same SHA.
+func (c *crawlCache) checksums(key string, sha string) (map[string]string, bool) {
+	if c == nil || sha == "" {
+		return nil, false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	cv, exists := c.Versions[key]
+	if !exists || cv.Sha != sha || len(cv.Checksums) == 0 {
+		return nil, false
+	}
+	return cv.Checksums, true
+}
+
+// The setChecksums method caches the checksums of the tarball for the
+// given version (which must already be cached, see setVersion).
+func (c *crawlCache) setChecksums(key string, sha string, checksums map[string]string) {
+	if c == nil || sha == "" {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	cv, exists := c.Versions[key]
+	if !exists || cv.Sha != sha {
+		return
+	}
+	cv.Checksums = checksums
+	c.Versions[key] = cv
+}
+
+// The size method returns the size of the tree cached for the given
+// version, provided it was computed for the same SHA.
+func (c *crawlCache) size(key string, sha string) (int64, bool) {
+	if c == nil || sha == "" {
+		return 0, false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	cv, exists := c.Versions[key]
+	if !exists || cv.Sha != sha || cv.Size == 0 {
+		return 0, false
+	}
+	return cv.Size, true
+}
+
+// The setSize method caches the size of the tree for the given version
+// (which must already be cached, see setVersion).
+func (c *crawlCache) setSize(key string, sha string, size int64) {
+	if c == nil || sha == "" {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	cv, exists := c.Versions[key]
+	if !exists || cv.Sha != sha {
+		return
+	}
+	cv.Size = size
+	c.Versions[key] = cv
+}
+
+// The notes method returns the release notes cached for the given
+// version, provided they were fetched for the same SHA.
+func (c *crawlCache) notes(key string, sha string) (string, bool) {
+	if c == nil || sha == "" {
+		return "", false
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	cv, exists := c.Versions[key]
+	if !exists || cv.Sha != sha || cv.Notes == "" {
+		return "", false
+	}
+	return cv.Notes, true
+}
+
+// The setNotes method caches the release notes for the given version
+// (which must already be cached, see setVersion).
+func (c *crawlCache) setNotes(key string, sha string, notes string) {
+	if c == nil || sha == "" {
+		return
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	cv, exists := c.Versions[key]
+	if !exists || cv.Sha != sha {
+		return
+	}
+	cv.Notes = notes
+	c.Versions[key] = cv
+}
+
+func (c *crawlCache) response(key string) (cachedResponse, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	cr, exists := c.Responses[key]
+	return cr, exists
+}
+
+func (c *crawlCache) setResponse(key string, cr cachedResponse) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.Responses[key] = cr
+}
+
+// The client method returns an HTTP client that makes conditional
+// requests (using this cache) on top of the given client.
+func (c *crawlCache) client(base *http.Client) *http.Client {
+	if c == nil {
+		return base
+	}
+	cp := http.Client{}
+	if base != nil {
+		cp = *base
+	}
+	transport := cp.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	cp.Transport = &cacheTransport{cache: c, base: transport}
+	return &cp
+}
+
+// The cacheTransport adds an If-None-Match header to any GET request for
+// which a response has already been cached.  If the server responds with
+// 304 Not Modified, the cached body is returned instead.
+type cacheTransport struct {
+	cache *crawlCache
+	base  http.RoundTripper
+}
+
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != "GET" {
+		return t.base.RoundTrip(req)
+	}
+
+	key := req.URL.String()
+	cached, hit := t.cache.response(key)
+	if hit {
+		// A RoundTripper must not modify the original request
+		creq := new(http.Request)
+		*creq = *req
+		creq.Header = http.Header{}
+		for k, v := range req.Header {
+			creq.Header[k] = append([]string(nil), v...)
+		}
+		creq.Header.Set("If-None-Match", cached.ETag)
+		req = creq
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+
+	if hit && resp.StatusCode == http.StatusNotModified {
+		resp.Body.Close()
+		resp.StatusCode = http.StatusOK
+		resp.Status = "200 OK"
+		resp.Body = ioutil.NopCloser(bytes.NewReader(cached.Body))
+		resp.ContentLength = int64(len(cached.Body))
+		return resp, nil
+	}
+
+	etag := resp.Header.Get("ETag")
+	if resp.StatusCode == http.StatusOK && etag != "" {
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		t.cache.setResponse(key, cachedResponse{ETag: etag, Body: body})
+		resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	return resp, nil
+}
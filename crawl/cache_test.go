@@ -0,0 +1,129 @@
+package crawl
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+
+	"github.com/impact/impact/dirinfo"
+)
+
+func TestCache(t *testing.T) {
+	Convey("Testing crawl cache", t, func(c C) {
+		dir, err := ioutil.TempDir("", "impact")
+		NoError(c, err)
+		defer os.RemoveAll(dir)
+		filename := filepath.Join(dir, "cache.json")
+		logged := bytes.Buffer{}
+		logger := StandardLogger(log.New(&logged, "", 0), Quiet)
+
+		served := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("If-None-Match") == `"v1"` {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			served++
+			w.Header().Set("ETag", `"v1"`)
+			fmt.Fprint(w, "contents")
+		}))
+		defer server.Close()
+
+		get := func(cache *crawlCache) string {
+			resp, err := cache.client(nil).Get(server.URL)
+			NoError(c, err)
+			defer resp.Body.Close()
+			Equals(c, resp.StatusCode, http.StatusOK)
+			body, err := ioutil.ReadAll(resp.Body)
+			NoError(c, err)
+			return string(body)
+		}
+
+		// Nothing is cached to begin with
+		cache, err := loadCache(filename, logger)
+		NoError(c, err)
+		Equals(c, get(cache), "contents")
+		Equals(c, served, 1)
+
+		di := dirinfo.MakeDirectoryInfo()
+		di.OwnerURI = "https://github.com/a"
+		di.Verified = true
+		cache.setVersion("a/Foo/1.0.0", "abc", "", di)
+		NoError(c, cache.save())
+
+		// Once loaded again, the cached body is reused
+		cache, err = loadCache(filename, logger)
+		NoError(c, err)
+		Equals(c, get(cache), "contents")
+		Equals(c, served, 1)
+
+		cdi, found := cache.version("a/Foo/1.0.0", "abc", "")
+		IsTrue(c, found)
+		Equals(c, cdi.OwnerURI, "https://github.com/a")
+		IsTrue(c, cdi.Verified)
+
+		// A different SHA means the information is out of date
+		_, found = cache.version("a/Foo/1.0.0", "def", "")
+		IsTrue(c, !found)
+
+		// So do different settings (e.g., a publisher key that is no
+		// longer trusted)
+		k1, _, err := ed25519.GenerateKey(nil)
+		NoError(c, err)
+		k2, _, err := ed25519.GenerateKey(nil)
+		NoError(c, err)
+		trusted := extractionSettings(false, PublisherKeys{k1, k2})
+		Equals(c, extractionSettings(false, nil), "")
+		Equals(c, extractionSettings(false, PublisherKeys{k2, k1}), trusted)
+		IsTrue(c, extractionSettings(false, PublisherKeys{k1}) != trusted)
+		IsTrue(c, extractionSettings(true, PublisherKeys{k1, k2}) != trusted)
+		IsTrue(c, extractionSettings(true, nil) != "")
+
+		cache.setVersion("a/Foo/1.1.0", "abc", trusted, di)
+		_, found = cache.version("a/Foo/1.1.0", "abc", trusted)
+		IsTrue(c, found)
+		_, found = cache.version("a/Foo/1.1.0", "abc", extractionSettings(false, PublisherKeys{k1}))
+		IsTrue(c, !found)
+		_, found = cache.version("a/Foo/1.1.0", "abc", "")
+		IsTrue(c, !found)
+
+		// The cache is replaced as a whole (rather than rewritten in
+		// place), so nothing else is left behind
+		NoError(c, cache.save())
+		entries, err := ioutil.ReadDir(dir)
+		NoError(c, err)
+		Equals(c, len(entries), 1)
+
+		// A cache that can't be parsed (e.g., it was left incomplete) is
+		// treated as empty
+		NoError(c, ioutil.WriteFile(filename, []byte(`{"responses": {"x": `), 0644))
+		cache, err = loadCache(filename, logger)
+		NoError(c, err)
+		_, found = cache.version("a/Foo/1.0.0", "abc", "")
+		IsTrue(c, !found)
+		IsTrue(c, strings.Contains(logged.String(), "Ignoring cache file"))
+		Equals(c, get(cache), "contents")
+		NoError(c, cache.save())
+		cache, err = loadCache(filename, logger)
+		NoError(c, err)
+		Equals(c, len(cache.Responses), 1)
+
+		// A nil cache caches nothing
+		var none *crawlCache
+		none.setVersion("a/Foo/1.0.0", "abc", "", di)
+		_, found = none.version("a/Foo/1.0.0", "abc", "")
+		IsTrue(c, !found)
+		NoError(c, none.save())
+	})
+}
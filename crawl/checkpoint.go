@@ -0,0 +1,171 @@
+package crawl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// How often (at most) the checkpoint is written while crawling
+var checkpointInterval = 30 * time.Second
+
+// The checkpoint type keeps track of which repositories have been
+// completely processed so that an interrupted crawl can be resumed (see
+// SetCheckpoint).  Along with each repository, the time it was last
+// pushed to is recorded so that repositories that have changed since are
+// processed again.
+//
+// All methods can safely be called on a nil checkpoint (in which case
+// nothing is recorded) and from multiple goroutines.
+type checkpoint struct {
+	filename string
+	interval time.Duration
+	mutex    sync.Mutex
+	saved    time.Time
+
+	Repos map[string]string `json:"repos"` // key: user/repo, value: pushed at
+}
+
+// This function loads the checkpoint stored in the named file.  If the
+// file doesn't exist yet, an empty checkpoint is returned.
+func loadCheckpoint(filename string) (*checkpoint, error) {
+	cp := &checkpoint{
+		filename: filename,
+		interval: checkpointInterval,
+		saved:    time.Now(),
+		Repos:    map[string]string{},
+	}
+
+	raw, err := ioutil.ReadFile(filename)
+	if os.IsNotExist(err) {
+		return cp, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read checkpoint file %s: %v", filename, err)
+	}
+
+	err = json.Unmarshal(raw, cp)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse checkpoint file %s: %v", filename, err)
+	}
+	if cp.Repos == nil {
+		cp.Repos = map[string]string{}
+	}
+	return cp, nil
+}
+
+// This function identifies the state of a repository by when it was last
+// pushed to.
+func pushedAt(repo github.Repository) string {
+	if repo.PushedAt == nil {
+		return ""
+	}
+	return repo.PushedAt.Time.UTC().Format(time.RFC3339)
+}
+
+// The done method returns true if the given repository was completely
+// processed (and hasn't been pushed to since).
+func (cp *checkpoint) done(user string, repo github.Repository) bool {
+	if cp == nil {
+		return false
+	}
+	cp.mutex.Lock()
+	defer cp.mutex.Unlock()
+
+	pushed, exists := cp.Repos[user+"/"+stringOf(repo.Name)]
+	return exists && pushed == pushedAt(repo)
+}
+
+// The mark method records that the given repository has been completely
+// processed.  The checkpoint is saved if it hasn't been saved for a while.
+func (cp *checkpoint) mark(user string, repo github.Repository) error {
+	if cp == nil {
+		return nil
+	}
+	cp.mutex.Lock()
+	defer cp.mutex.Unlock()
+
+	cp.Repos[user+"/"+stringOf(repo.Name)] = pushedAt(repo)
+	if time.Since(cp.saved) < cp.interval {
+		return nil
+	}
+	return cp.write()
+}
+
+// The save method writes the checkpoint to the file it was loaded from.
+func (cp *checkpoint) save() error {
+	if cp == nil {
+		return nil
+	}
+	cp.mutex.Lock()
+	defer cp.mutex.Unlock()
+	return cp.write()
+}
+
+// The remove method deletes the checkpoint file (e.g., once a crawl has
+// completed successfully).
+func (cp *checkpoint) remove() error {
+	if cp == nil {
+		return nil
+	}
+	err := os.Remove(cp.filename)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Unable to remove checkpoint file %s: %v", cp.filename, err)
+	}
+	return nil
+}
+
+// This function writes the checkpoint (the mutex must already be held).
+// The checkpoint is written to a temporary file which then replaces the
+// checkpoint file so that the checkpoint file is never left incomplete
+// (e.g., if the crawl is killed while writing it).
+func (cp *checkpoint) write() error {
+	raw, err := json.Marshal(cp)
+	if err != nil {
+		return fmt.Errorf("Unable to serialize checkpoint: %v", err)
+	}
+
+	err = replaceFile(cp.filename, raw)
+	if err != nil {
+		return fmt.Errorf("Unable to write checkpoint file %s: %v", cp.filename, err)
+	}
+
+	cp.saved = time.Now()
+	return nil
+}
+
+// This function writes the given data to a temporary file (in the same
+// directory) which then replaces the named file.  This way, the named
+// file is never left incomplete (e.g., if the process is killed while
+// writing it).
+func replaceFile(filename string, raw []byte) error {
+	dir, base := filepath.Split(filename)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := ioutil.TempFile(dir, base+".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(raw)
+	if err == nil {
+		err = f.Sync()
+	}
+	cerr := f.Close()
+	if err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
@@ -0,0 +1,118 @@
+package crawl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-github/github"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+
+	"github.com/impact/impact/recorder"
+)
+
+func TestCheckpoint(t *testing.T) {
+	Convey("Testing resuming an interrupted crawl", t, func(c C) {
+		pushed := map[string]string{
+			"A": "2016-03-01T00:00:00Z",
+			"B": "2016-03-01T00:00:00Z",
+			"C": "2016-03-01T00:00:00Z",
+		}
+		details := map[string]int{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/users/a/repos":
+				fmt.Fprint(w, "[")
+				for i, name := range []string{"A", "B", "C"} {
+					if i > 0 {
+						fmt.Fprint(w, ",")
+					}
+					fmt.Fprintf(w, `{"name": "%s", "html_url": "https://github.com/a/%s", "pushed_at": "%s"}`,
+						name, name, pushed[name])
+				}
+				fmt.Fprint(w, "]")
+			case "/repos/a/B", "/repos/a/C":
+				name := path.Base(r.URL.Path)
+				details[name]++
+				fmt.Fprintf(w, `{"name": "%s"}`, name)
+			case "/repos/a/B/tags", "/repos/a/C/tags":
+				fmt.Fprint(w, `[]`)
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		defer server.Close()
+
+		dir, err := ioutil.TempDir("", "impact")
+		NoError(c, err)
+		defer os.RemoveAll(dir)
+		filename := filepath.Join(dir, "checkpoint.json")
+
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		client := github.NewClient(nil)
+		base, err := url.Parse(server.URL + "/")
+		NoError(c, err)
+		client.BaseURL = base
+		gc := NewGitHubClient(client, 0, logger)
+		gc.SetRetries(0, 0)
+
+		crawl := func(baseline Baseline) {
+			cr, err := MakeGitHubCrawler("a", nil, "")
+			NoError(c, err)
+			cr.SetCheckpoint(filename, baseline)
+			cr.failures = &repoErrors{}
+			cr.checkpoint, err = loadCheckpoint(filename)
+			NoError(c, err)
+			// Save after every repository
+			cr.checkpoint.interval = 0
+			NoError(c, cr.crawlUser(gc, recorder.NullRecorder{}, logger))
+		}
+
+		// The details of A can't be fetched, so it isn't done
+		crawl(&replayBaseline{})
+		cp, err := loadCheckpoint(filename)
+		NoError(c, err)
+		Equals(c, len(cp.Repos), 2)
+		Equals(c, cp.Repos["a/B"], "2016-03-01T00:00:00Z")
+		Equals(c, details["B"], 1)
+		Equals(c, details["C"], 1)
+
+		// Repositories that are done (and unchanged) are replayed instead
+		pushed["C"] = "2016-04-01T00:00:00Z"
+		baseline := &replayBaseline{}
+		crawl(baseline)
+		Resembles(c, baseline.replayed, []string{"https://github.com/a/B"})
+		Equals(c, details["B"], 1)
+		Equals(c, details["C"], 2)
+
+		// Without a baseline, everything is processed again
+		crawl(nil)
+		Equals(c, details["B"], 2)
+
+		// No temporary files are left behind
+		files, err := ioutil.ReadDir(dir)
+		NoError(c, err)
+		Equals(c, len(files), 1)
+
+		cp, err = loadCheckpoint(filename)
+		NoError(c, err)
+		NoError(c, cp.remove())
+		_, err = os.Stat(filename)
+		IsTrue(c, os.IsNotExist(err))
+		NoError(c, cp.remove())
+
+		var none *checkpoint
+		IsFalse(c, none.done("a", github.Repository{Name: github.String("B")}))
+		NoError(c, none.mark("a", github.Repository{Name: github.String("B")}))
+		NoError(c, none.save())
+	})
+}
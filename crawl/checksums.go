@@ -0,0 +1,75 @@
+package crawl
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/impact/impact/recorder"
+)
+
+// This function returns the checksums of the tarball of a version (keyed
+// by algorithm) if checksums were requested (see SetArchiveChecksums).
+// The tarball is streamed through the hash rather than stored.  Checksums
+// computed by a previous crawl (for the same SHA) are reused.  If the
+// tarball can't be downloaded, a warning is logged and nil is returned
+// (the version is still recorded, just without checksums).
+func (c GitHubCrawler) archiveChecksums(client *GitHubClient, key string, sha string,
+	tarurl string, logger CrawlLogger) map[string]string {
+	if !c.checksums || tarurl == "" {
+		return nil
+	}
+
+	sums, cached := c.cache.checksums(key, sha)
+	if cached {
+		logger.Debugf("    Using cached checksums for %s", key)
+		return sums
+	}
+
+	logger.Debugf("    Downloading %s to compute its checksum", tarurl)
+	var sum string
+	err := client.call(func() (err error) {
+		sum, err = downloadChecksum(client, c.download, tarurl)
+		return
+	})
+	if err != nil {
+		if client.ctx.Err() == nil {
+			logger.Warnf("Unable to compute checksum of %s: %v", tarurl, err)
+		}
+		return nil
+	}
+
+	sums = map[string]string{recorder.ChecksumSHA256: sum}
+	c.cache.setChecksums(key, sha, sums)
+	return sums
+}
+
+// This function downloads the given URL (with the given HTTP client or,
+// if nil, the default HTTP client) and returns the SHA-256 of its
+// contents in hex.
+func downloadChecksum(client *GitHubClient, hc *http.Client, url string) (string, error) {
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := hc.Do(req.WithContext(client.ctx))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+
+	h := sha256.New()
+	_, err = io.Copy(h, resp.Body)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,71 @@
+package crawl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/impact/impact/dirinfo"
+	"github.com/impact/impact/recorder"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+)
+
+func TestArchiveChecksums(t *testing.T) {
+	Convey("Testing recording checksums of archives", t, func(c C) {
+		downloads := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/a/Foo/tar.gz/v1.0.0" {
+				http.NotFound(w, r)
+				return
+			}
+			downloads++
+			fmt.Fprint(w, "hello")
+		}))
+		defer server.Close()
+
+		dir, err := ioutil.TempDir("", "impact")
+		NoError(c, err)
+		defer os.RemoveAll(dir)
+
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		gc := NewGitHubClient(nil, 0, logger)
+		gc.SetRetries(0, 0)
+
+		cr, err := MakeGitHubCrawler("a", nil, "")
+		NoError(c, err)
+		cr.cache, err = loadCache(filepath.Join(dir, "cache.json"), logger)
+		NoError(c, err)
+		cr.cache.setVersion("a/Foo/1.0.0", "abc", "", dirinfo.MakeDirectoryInfo())
+		tarurl := server.URL + "/a/Foo/tar.gz/v1.0.0"
+
+		// Nothing is downloaded unless requested
+		IsTrue(c, cr.archiveChecksums(gc, "a/Foo/1.0.0", "abc", tarurl, logger) == nil)
+		Equals(c, downloads, 0)
+
+		cr.SetArchiveChecksums(true)
+		sums := cr.archiveChecksums(gc, "a/Foo/1.0.0", "abc", tarurl, logger)
+		Resembles(c, sums, map[string]string{
+			recorder.ChecksumSHA256: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+		})
+		Equals(c, downloads, 1)
+
+		// The checksums are cached along with the rest of the version
+		// (as long as the SHA is the same)
+		cr.cache.setVersion("a/Foo/1.0.0", "abc", "", dirinfo.MakeDirectoryInfo())
+		Resembles(c, cr.archiveChecksums(gc, "a/Foo/1.0.0", "abc", tarurl, logger), sums)
+		Equals(c, downloads, 1)
+		cr.cache.setVersion("a/Foo/1.0.0", "def", "", dirinfo.MakeDirectoryInfo())
+		Resembles(c, cr.archiveChecksums(gc, "a/Foo/1.0.0", "def", tarurl, logger), sums)
+		Equals(c, downloads, 2)
+
+		// Archives that can't be downloaded just don't have checksums
+		IsTrue(c, cr.archiveChecksums(gc, "a/Foo/2.0.0", "ghi", server.URL+"/missing", logger) == nil)
+	})
+}
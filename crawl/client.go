@@ -0,0 +1,423 @@
+package crawl
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// The GitHubClient type wraps a GitHub client so that every request made
+// while crawling is subject to the same handling of rate limits and
+// transient errors.  When GitHub reports that the rate limit has been
+// exceeded, the request is retried once the rate limit resets.  Requests
+// that fail because of server or network errors are retried (with
+// exponential backoff).
+type GitHubClient struct {
+	client *github.Client
+	logger CrawlLogger
+	// Context that all requests (and waits) are subject to
+	ctx context.Context
+	// HTTP client the GitHub client was created with (used to create
+	// clients subject to other contexts, see withContext)
+	base *http.Client
+	// Client whose rate limit waits (and number of requests) are shared
+	// by this client (if any)
+	parent *GitHubClient
+
+	// Maximum total time to spend waiting for rate limits to reset (zero
+	// means there is no maximum)
+	maxWait time.Duration
+
+	// Number of times to retry requests that fail with transient errors
+	retries int
+	// Delay before the first retry (this doubles with each retry)
+	baseDelay time.Duration
+
+	mutex  sync.Mutex
+	waited time.Duration
+	until  time.Time
+
+	// Number of requests made (including retries)
+	calls int64
+
+	// Contents of files already downloaded
+	blobs *blobCache
+
+	// Limits the number of requests made at once (once a secondary rate
+	// limit has been exceeded)
+	limiter *requestLimiter
+
+	// Where failed requests and rate limit waits are counted (if anywhere)
+	metrics *CrawlMetrics
+
+	// The timer of the repository being processed (if any), which is
+	// paused while waiting for rate limits (see waitOut)
+	timer *repoTimer
+}
+
+func NewGitHubClient(client *github.Client, maxWait time.Duration,
+	logger CrawlLogger) *GitHubClient {
+	return &GitHubClient{
+		client:    client,
+		logger:    logger,
+		ctx:       context.Background(),
+		blobs:     newBlobCache(),
+		limiter:   &requestLimiter{},
+		maxWait:   maxWait,
+		retries:   defaultRetries,
+		baseDelay: defaultRetryDelay,
+	}
+}
+
+// Default number of retries (and delay before the first retry) for
+// requests that fail with transient errors
+var defaultRetries = 3
+var defaultRetryDelay = time.Second
+
+// The SetRetries method specifies how many times requests that fail with
+// transient errors are retried and how long to wait before the first retry.
+func (gc *GitHubClient) SetRetries(retries int, baseDelay time.Duration) {
+	gc.retries = retries
+	gc.baseDelay = baseDelay
+}
+
+// The Calls method returns the number of requests made by this client
+// (including retries).
+func (gc *GitHubClient) Calls() int64 {
+	return atomic.LoadInt64(&gc.shared().calls)
+}
+
+// This function returns the client that keeps track of rate limit waits
+// (and the number of requests made) for this client.
+func (gc *GitHubClient) shared() *GitHubClient {
+	if gc.parent != nil {
+		return gc.parent
+	}
+	return gc
+}
+
+// The withContext method returns a client that makes the same requests
+// as this one, but subject to the given context (which should be derived
+// from the context of this client so that cancelling the crawl still
+// cancels all requests).  Rate limit waits and the number of requests
+// made are shared with this client.
+func (gc *GitHubClient) withContext(ctx context.Context) *GitHubClient {
+	var client *github.Client
+	if gc.client != nil {
+		client = github.NewClient(contextClient(ctx, gc.base))
+		client.BaseURL = gc.client.BaseURL
+		client.UploadURL = gc.client.UploadURL
+		client.UserAgent = gc.client.UserAgent
+	}
+	return &GitHubClient{
+		client:    client,
+		logger:    gc.logger,
+		ctx:       ctx,
+		base:      gc.base,
+		parent:    gc.shared(),
+		blobs:     gc.blobs,
+		limiter:   gc.limiter,
+		metrics:   gc.metrics,
+		timer:     gc.timer,
+		maxWait:   gc.maxWait,
+		retries:   gc.retries,
+		baseDelay: gc.baseDelay,
+	}
+}
+
+// The SetContext method specifies a context that all requests made by
+// this client are subject to.  Once the context is done, waiting for
+// retries stops and ctx.Err() is returned instead.  Note that the context
+// must also be attached to the underlying HTTP client (see contextClient)
+// for requests already in progress to be cancelled.
+func (gc *GitHubClient) SetContext(ctx context.Context) {
+	gc.ctx = ctx
+}
+
+// The sleep method waits for the given duration unless the context is
+// done first (in which case ctx.Err() is returned).
+func (gc *GitHubClient) sleep(d time.Duration) error {
+	if d <= 0 {
+		return gc.ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-gc.ctx.Done():
+		return gc.ctx.Err()
+	}
+}
+
+// The waitOut method waits for a rate limit to reset (see sleep).  Such
+// waits don't count towards the timeout of the repository being
+// processed (if any).
+func (gc *GitHubClient) waitOut(d time.Duration) error {
+	gc.timer.pause()
+	defer gc.timer.resume()
+	return gc.sleep(d)
+}
+
+// This function determines whether an error is likely to be transient
+// (i.e., a server or network error) and worth retrying.  Client errors
+// (4xx responses) are not retried.
+func transient(err error) bool {
+	switch e := err.(type) {
+	case *github.ErrorResponse:
+		return e.Response != nil && e.Response.StatusCode >= 500
+	case net.Error:
+		return true
+	}
+	return false
+}
+
+// How long to wait after exceeding a secondary rate limit if GitHub
+// doesn't say (see secondaryLimit)
+var defaultRetryAfter = time.Minute
+
+// This function determines whether an error indicates that a secondary
+// (or abuse) rate limit was exceeded.  Unlike the primary rate limit,
+// these are exceeded by making too many requests at once and GitHub
+// indicates how long to wait before retrying (with a Retry-After header).
+// The version of go-github we use reports these as an ordinary
+// ErrorResponse, so they are recognized by their status, headers and
+// message.
+func secondaryLimit(err error) (time.Duration, bool) {
+	e, ok := err.(*github.ErrorResponse)
+	if !ok || e.Response == nil {
+		return 0, false
+	}
+	status := e.Response.StatusCode
+	if status != http.StatusForbidden && status != http.StatusTooManyRequests {
+		return 0, false
+	}
+	retry := e.Response.Header.Get("Retry-After")
+	msg := strings.ToLower(e.Message + " " + e.DocumentationURL)
+	if retry == "" && !strings.Contains(msg, "abuse") && !strings.Contains(msg, "secondary rate limit") {
+		return 0, false
+	}
+
+	seconds, perr := strconv.Atoi(retry)
+	if perr == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second, true
+	}
+	if date, derr := http.ParseTime(retry); derr == nil {
+		return date.Sub(time.Now()), true
+	}
+	return defaultRetryAfter, true
+}
+
+// The requestLimiter type limits how many requests are made at once.
+// Initially, there is no limit but each time a secondary rate limit is
+// exceeded, the limit is halved (see reduce).  It can safely be used from
+// multiple goroutines.
+type requestLimiter struct {
+	mutex  sync.Mutex
+	cond   *sync.Cond
+	active int // Requests currently being made
+	limit  int // Zero means there is no limit
+}
+
+// The acquire method waits until another request can be made.
+func (l *requestLimiter) acquire() {
+	if l == nil {
+		return
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	for l.limit > 0 && l.active >= l.limit {
+		l.cond.Wait()
+	}
+	l.active++
+}
+
+// The release method indicates that a request (see acquire) is done.
+func (l *requestLimiter) release() {
+	if l == nil {
+		return
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.active--
+	if l.cond != nil {
+		l.cond.Broadcast()
+	}
+}
+
+// The reduce method halves the number of requests that can be made at
+// once (but always allows at least one).  If there was no limit yet, the
+// number of requests currently being made (including the one that
+// exceeded the secondary rate limit) is halved.  It returns the new limit.
+func (l *requestLimiter) reduce() int {
+	if l == nil {
+		return 0
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if l.cond == nil {
+		l.cond = sync.NewCond(&l.mutex)
+	}
+	current := l.limit
+	if current == 0 {
+		current = l.active + 1
+	}
+	l.limit = current / 2
+	if l.limit < 1 {
+		l.limit = 1
+	}
+	return l.limit
+}
+
+// This function determines whether an error means that what was requested
+// doesn't exist (which is expected, e.g., when looking for files that may
+// not be present).
+func notFound(err error) bool {
+	e, ok := err.(*github.ErrorResponse)
+	return ok && e.Response != nil && e.Response.StatusCode == http.StatusNotFound
+}
+
+// This function computes how long to wait before the given retry (counting
+// from zero).  The delay doubles with each retry and includes some random
+// jitter so that concurrent requests don't all retry at the same time.
+func (gc *GitHubClient) backoff(attempt int) time.Duration {
+	delay := gc.baseDelay << uint(attempt)
+	if delay <= 0 {
+		return 0
+	}
+	return delay + time.Duration(rand.Int63n(int64(delay)))
+}
+
+// The call method invokes the given function (which is expected to make
+// a single request via the GitHub client) and retries it as long as
+// it fails because the rate limit was exceeded.  Requests that fail
+// with transient errors are retried up to the configured number of times.
+func (gc *GitHubClient) call(f func() error) error {
+	attempt := 0
+	for {
+		if gc.ctx.Err() != nil {
+			return gc.ctx.Err()
+		}
+		atomic.AddInt64(&gc.shared().calls, 1)
+		gc.limiter.acquire()
+		err := f()
+		gc.limiter.release()
+
+		// Secondary rate limits are exceeded by making too many requests
+		// at once, so fewer requests are made from now on
+		if retryAfter, secondary := secondaryLimit(err); secondary {
+			reset := time.Now().Add(retryAfter)
+			if !gc.reserve(reset) {
+				return fmt.Errorf("Secondary rate limit exceeded (retry after %v) and maximum wait of %v reached: %v",
+					retryAfter, gc.maxWait, err)
+			}
+			limit := gc.limiter.reduce()
+			gc.logger.Warnf("Secondary rate limit exceeded, waiting %v to retry (making at most %d requests at once)",
+				retryAfter, limit)
+			gc.metrics.rateLimitSleep(retryAfter)
+			serr := gc.waitOut(retryAfter)
+			if serr != nil {
+				return serr
+			}
+			continue
+		}
+
+		rerr, ok := err.(*github.RateLimitError)
+		if !ok {
+			if err != nil && !notFound(err) {
+				gc.metrics.apiError()
+			}
+			if transient(err) && attempt < gc.retries {
+				delay := gc.backoff(attempt)
+				gc.logger.Warnf("Request failed (%v), retrying in %v", err, delay)
+				serr := gc.sleep(delay)
+				if serr != nil {
+					return serr
+				}
+				attempt++
+				continue
+			}
+			return err
+		}
+
+		reset := rerr.Rate.Reset.Time
+		if !gc.reserve(reset) {
+			return fmt.Errorf("Rate limit exceeded (resets at %v) and maximum wait of %v reached: %v",
+				reset, gc.maxWait, err)
+		}
+
+		gc.logger.Infof("Rate limit exceeded, waiting until %v to retry", reset)
+		wait := reset.Sub(time.Now())
+		gc.metrics.rateLimitSleep(wait)
+		serr := gc.waitOut(wait)
+		if serr != nil {
+			return serr
+		}
+	}
+}
+
+// The reserve method accounts for the time spent waiting until the given
+// reset time.  Since several waits may overlap (when crawling concurrently),
+// only time not already covered by a previous wait is counted.  It returns
+// false if waiting would exceed the maximum wait time.
+func (gc *GitHubClient) reserve(reset time.Time) bool {
+	// Waits are accounted for by the shared client (see withContext)
+	gc = gc.shared()
+	gc.mutex.Lock()
+	defer gc.mutex.Unlock()
+
+	start := time.Now()
+	if gc.until.After(start) {
+		start = gc.until
+	}
+
+	extra := reset.Sub(start)
+	if extra < 0 {
+		extra = 0
+	}
+
+	if gc.maxWait > 0 && gc.waited+extra > gc.maxWait {
+		return false
+	}
+
+	gc.waited = gc.waited + extra
+	if reset.After(gc.until) {
+		gc.until = reset
+	}
+	return true
+}
+
+// This function returns an HTTP client that attaches the given context to
+// every request made with the base client (so that requests in progress
+// are cancelled when the context is done).  Everything else about the
+// base client (e.g., its timeout) is kept.
+func contextClient(ctx context.Context, base *http.Client) *http.Client {
+	cp := http.Client{}
+	if base != nil {
+		cp = *base
+	}
+	transport := cp.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	cp.Transport = &contextTransport{ctx: ctx, base: transport}
+	return &cp
+}
+
+type contextTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.base.RoundTrip(req.WithContext(t.ctx))
+}
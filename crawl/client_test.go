@@ -0,0 +1,238 @@
+package crawl
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+
+	"github.com/impact/impact/recorder"
+)
+
+func errorResponse(status int) error {
+	return &github.ErrorResponse{
+		Response: &http.Response{StatusCode: status},
+	}
+}
+
+func TestRetries(t *testing.T) {
+	Convey("Testing retries of transient errors", t, func(c C) {
+		IsTrue(c, transient(errorResponse(502)))
+		IsTrue(c, transient(errorResponse(503)))
+		IsTrue(c, !transient(errorResponse(404)))
+		IsTrue(c, !transient(errors.New("No file named package.mo found")))
+		IsTrue(c, !transient(nil))
+		IsTrue(c, transient(&url.Error{Op: "Get", URL: "https://api.github.com", Err: errors.New("timeout")}))
+
+		gc := NewGitHubClient(nil, 0, StandardLogger(log.New(ioutil.Discard, "", 0), Quiet))
+		gc.SetRetries(2, 0)
+
+		calls := 0
+		err := gc.call(func() error {
+			calls++
+			return errorResponse(502)
+		})
+		IsError(c, err)
+		Equals(c, calls, 3)
+
+		calls = 0
+		err = gc.call(func() error {
+			calls++
+			return errorResponse(404)
+		})
+		IsError(c, err)
+		Equals(c, calls, 1)
+
+		gc.SetRetries(3, time.Second)
+		for i := 0; i < 3; i++ {
+			d := gc.backoff(i)
+			IsTrue(c, d >= time.Second<<uint(i))
+			IsTrue(c, d < 2*time.Second<<uint(i))
+		}
+	})
+}
+
+func secondaryLimitError(retryAfter string, message string) error {
+	header := http.Header{}
+	if retryAfter != "" {
+		header.Set("Retry-After", retryAfter)
+	}
+	return &github.ErrorResponse{
+		Response: &http.Response{StatusCode: http.StatusForbidden, Header: header},
+		Message:  message,
+	}
+}
+
+func TestSecondaryRateLimits(t *testing.T) {
+	Convey("Testing handling of secondary rate limits", t, func(c C) {
+		d, ok := secondaryLimit(secondaryLimitError("2", ""))
+		IsTrue(c, ok)
+		Equals(c, d, 2*time.Second)
+		d, ok = secondaryLimit(secondaryLimitError("", "You have exceeded a secondary rate limit."))
+		IsTrue(c, ok)
+		Equals(c, d, defaultRetryAfter)
+		d, ok = secondaryLimit(secondaryLimitError("", "You have triggered an abuse detection mechanism."))
+		IsTrue(c, ok)
+		Equals(c, d, defaultRetryAfter)
+		_, ok = secondaryLimit(secondaryLimitError("", "Resource not accessible"))
+		IsTrue(c, !ok)
+		tooMany := secondaryLimitError("5", "").(*github.ErrorResponse)
+		tooMany.Response.StatusCode = http.StatusTooManyRequests
+		d, ok = secondaryLimit(tooMany)
+		IsTrue(c, ok)
+		Equals(c, d, 5*time.Second)
+		_, ok = secondaryLimit(errorResponse(404))
+		IsTrue(c, !ok)
+		_, ok = secondaryLimit(nil)
+		IsTrue(c, !ok)
+
+		// The request is retried (and fewer requests are made at once)
+		gc := NewGitHubClient(nil, 0, StandardLogger(log.New(ioutil.Discard, "", 0), Quiet))
+		calls := 0
+		err := gc.call(func() error {
+			calls++
+			if calls == 1 {
+				return secondaryLimitError("0", "")
+			}
+			return nil
+		})
+		NoError(c, err)
+		Equals(c, calls, 2)
+		Equals(c, gc.limiter.limit, 1)
+
+		// Each time, the limit is halved
+		l := &requestLimiter{}
+		for i := 0; i < 5; i++ {
+			l.acquire()
+		}
+		Equals(c, l.reduce(), 3)
+		Equals(c, l.reduce(), 1)
+		Equals(c, l.reduce(), 1)
+		for i := 0; i < 5; i++ {
+			l.release()
+		}
+		Equals(c, l.active, 0)
+
+		// Waiting is subject to the maximum wait
+		gc = NewGitHubClient(nil, time.Second, StandardLogger(log.New(ioutil.Discard, "", 0), Quiet))
+		err = gc.call(func() error {
+			return secondaryLimitError("3600", "")
+		})
+		IsError(c, err)
+	})
+}
+
+func TestCancellation(t *testing.T) {
+	Convey("Testing cancellation of requests", t, func(c C) {
+		ctx, cancel := context.WithCancel(context.Background())
+		gc := NewGitHubClient(nil, 0, StandardLogger(log.New(ioutil.Discard, "", 0), Quiet))
+		gc.SetRetries(3, time.Hour)
+		gc.SetContext(ctx)
+
+		// Cancelling interrupts the wait before a retry
+		calls := 0
+		go func() {
+			time.Sleep(10 * time.Millisecond)
+			cancel()
+		}()
+		err := gc.call(func() error {
+			calls++
+			return errorResponse(502)
+		})
+		Equals(c, err, context.Canceled)
+		Equals(c, calls, 1)
+
+		// No further requests are made once cancelled
+		err = gc.call(func() error {
+			calls++
+			return nil
+		})
+		Equals(c, err, context.Canceled)
+		Equals(c, calls, 1)
+	})
+}
+
+// This transport sends every request to the given server (instead of
+// GitHub) and records what was requested
+type redirectTransport struct {
+	target   *url.URL
+	requests []string
+	auth     []string
+}
+
+func (t *redirectTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.requests = append(t.requests, req.URL.Path)
+	t.auth = append(t.auth, req.Header.Get("Authorization"))
+	r := new(http.Request)
+	*r = *req
+	u := *req.URL
+	u.Scheme = t.target.Scheme
+	u.Host = t.target.Host
+	r.URL = &u
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestHTTPClient(t *testing.T) {
+	Convey("Testing crawling with a custom HTTP client", t, func(c C) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/users/a/repos":
+				w.Write([]byte("[]"))
+			case "/users/slow/repos":
+				select {
+				case <-r.Context().Done():
+				case <-time.After(5 * time.Second):
+				}
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		defer server.Close()
+
+		target, err := url.Parse(server.URL)
+		NoError(c, err)
+		transport := &redirectTransport{target: target}
+
+		cr, err := MakeGitHubCrawler("a", nil, "secret")
+		NoError(c, err)
+		cr.SetHTTPClient(&http.Client{Transport: transport})
+		err = cr.Crawl(recorder.NullRecorder{}, Quiet, log.New(ioutil.Discard, "", 0))
+		NoError(c, err)
+
+		// Authentication is added on top of the client
+		Resembles(c, transport.requests, []string{"/users/a/repos"})
+		Resembles(c, transport.auth, []string{"Bearer secret"})
+
+		// Everything else about the client (e.g., its timeout) is kept
+		cr, err = MakeGitHubCrawler("slow", nil, "secret")
+		NoError(c, err)
+		cr.SetHTTPClient(&http.Client{Transport: transport, Timeout: 100 * time.Millisecond})
+		cr.SetRetries(0, 0)
+		start := time.Now()
+		err = cr.Crawl(recorder.NullRecorder{}, Quiet, log.New(ioutil.Discard, "", 0))
+		IsError(c, err)
+		IsTrue(c, time.Since(start) < 2*time.Second)
+
+		jar, err := cookiejar.New(nil)
+		NoError(c, err)
+		redirects := func(req *http.Request, via []*http.Request) error { return nil }
+		hc := contextClient(context.Background(), &http.Client{Timeout: time.Minute, Jar: jar,
+			CheckRedirect: redirects})
+		Equals(c, hc.Timeout, time.Minute)
+		IsTrue(c, hc.Jar == jar)
+		NotNil(c, hc.CheckRedirect)
+		hc = contextClient(context.Background(), nil)
+		Equals(c, hc.Timeout, time.Duration(0))
+	})
+}
@@ -0,0 +1,75 @@
+package crawl
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/impact/impact/recorder"
+)
+
+// The CrawlErrors type collects the errors reported by the individual
+// crawlers within a CombinedCrawler.
+type CrawlErrors []error
+
+func (e CrawlErrors) Error() string {
+	if len(e) == 1 {
+		return e[0].Error()
+	}
+	msgs := []string{}
+	for _, err := range e {
+		msgs = append(msgs, err.Error())
+	}
+	return fmt.Sprintf("%d crawlers failed: %s", len(e), strings.Join(msgs, "; "))
+}
+
+// The CombinedCrawler runs several crawlers, one after the other, against
+// the same recorder.  This allows libraries from different sources (e.g.,
+// GitHub, GitLab and the file system) to be combined into a single index.
+type CombinedCrawler struct {
+	crawlers []Crawler
+	// Whether to stop as soon as any crawler fails
+	failFast bool
+}
+
+// The Crawl method runs each crawler in turn.  Unless failFast is set, a
+// failure in one crawler doesn't prevent the remaining crawlers from
+// running.  All errors are returned together (as CrawlErrors).
+func (c CombinedCrawler) Crawl(r recorder.Recorder, verbosity Verbosity, logger *log.Logger) error {
+	errs := CrawlErrors{}
+	for _, cr := range c.crawlers {
+		err := cr.Crawl(r, verbosity, logger)
+		if err == nil {
+			continue
+		}
+		logger.Printf("Error crawling %s: %v", cr.String(), err)
+		errs = append(errs, fmt.Errorf("Error crawling %s: %v", cr.String(), err))
+		if c.failFast {
+			break
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func (c CombinedCrawler) String() string {
+	names := []string{}
+	for _, cr := range c.crawlers {
+		names = append(names, cr.String())
+	}
+	return fmt.Sprintf("combined(%s)", strings.Join(names, ", "))
+}
+
+// The MakeCombinedCrawler function creates a crawler that runs all the
+// given crawlers.  If failFast is true, crawling stops with the first
+// crawler that fails.
+func MakeCombinedCrawler(crawlers []Crawler, failFast bool) CombinedCrawler {
+	return CombinedCrawler{
+		crawlers: crawlers,
+		failFast: failFast,
+	}
+}
+
+var _ Crawler = (*CombinedCrawler)(nil)
@@ -0,0 +1,63 @@
+package crawl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+
+	"github.com/impact/impact/recorder"
+)
+
+// A crawler that just counts how many times it was run
+type countingCrawler struct {
+	name string
+	err  error
+	runs *int
+}
+
+func (cc countingCrawler) Crawl(r recorder.Recorder, verbosity Verbosity, logger *log.Logger) error {
+	*cc.runs++
+	return cc.err
+}
+
+func (cc countingCrawler) String() string {
+	return cc.name
+}
+
+func TestCombined(t *testing.T) {
+	Convey("Testing combined crawler", t, func(c C) {
+		logger := log.New(ioutil.Discard, "", 0)
+		runs := 0
+		crawlers := []Crawler{
+			countingCrawler{name: "a", runs: &runs},
+			countingCrawler{name: "b", err: fmt.Errorf("b failed"), runs: &runs},
+			countingCrawler{name: "c", err: fmt.Errorf("c failed"), runs: &runs},
+		}
+
+		cr := MakeCombinedCrawler(crawlers, false)
+		Equals(c, cr.String(), "combined(a, b, c)")
+
+		err := cr.Crawl(recorder.NullRecorder{}, Quiet, logger)
+		IsError(c, err)
+		errs, ok := err.(CrawlErrors)
+		IsTrue(c, ok)
+		Equals(c, len(errs), 2)
+		Equals(c, runs, 3)
+
+		runs = 0
+		cr = MakeCombinedCrawler(crawlers, true)
+		err = cr.Crawl(recorder.NullRecorder{}, Quiet, logger)
+		IsError(c, err)
+		Equals(c, len(err.(CrawlErrors)), 1)
+		Equals(c, runs, 2)
+
+		runs = 0
+		cr = MakeCombinedCrawler(crawlers[:1], false)
+		NoError(c, cr.Crawl(recorder.NullRecorder{}, Quiet, logger))
+		Equals(c, runs, 1)
+	})
+}
@@ -0,0 +1,256 @@
+package crawl
+
+import (
+	"encoding/base64"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+
+	"github.com/google/go-github/github"
+)
+
+// An entry represents a single file or directory found in a repository.
+type entry struct {
+	Name  string // Name of the file or directory
+	Path  string // Path of the file or directory (relative to the repository root)
+	IsDir bool   // Whether this entry is a directory
+}
+
+// The contents interface provides access to the files stored in a
+// repository (at a specific version).  This allows the same heuristics
+// to be applied regardless of where the repository is hosted.
+type contents interface {
+	// Read the contents of the file at the given path
+	ReadFile(path string) ([]byte, error)
+	// List the entries in the directory at the given path ("." is the
+	// root of the repository)
+	ReadDir(path string) ([]entry, error)
+}
+
+// The prefixReader interface is implemented by contents that can read
+// just the start of a file (which is cheaper than reading all of it).
+type prefixReader interface {
+	// Read (at most) the first n bytes of the file at the given path.  The
+	// flag returned is true if that is the whole file.
+	ReadPrefix(path string, n int) ([]byte, bool, error)
+}
+
+// The headerContents type provides access to the contents of a repository
+// when only the header of each package (i.e., its name, version and uses
+// annotation) is needed.  Only the first limit bytes of each package.mo
+// are read when possible (see readPackage).
+type headerContents struct {
+	contents
+	limit int
+}
+
+// The ReadHeader method reads (at most) the first limit bytes of the file
+// at the given path.  The flag returned is true if that is the whole file.
+func (h headerContents) ReadHeader(p string) ([]byte, bool, error) {
+	pr, ok := h.contents.(prefixReader)
+	if !ok {
+		data, err := h.ReadFile(p)
+		return data, true, err
+	}
+	return pr.ReadPrefix(p, h.limit)
+}
+
+// The prefixBuffer type keeps (at most) the first limit bytes written to
+// it.  Writing anything beyond that fails (which stops the download).
+type prefixBuffer struct {
+	limit     int
+	data      []byte
+	truncated bool
+}
+
+func (b *prefixBuffer) Write(p []byte) (int, error) {
+	space := b.limit - len(b.data)
+	if len(p) > space {
+		b.data = append(b.data, p[:space]...)
+		b.truncated = true
+		return space, fmt.Errorf("Only the first %d bytes are needed", b.limit)
+	}
+	b.data = append(b.data, p...)
+	return len(p), nil
+}
+
+// This provides access to the contents of a GitHub repository
+type gitHubContents struct {
+	client *GitHubClient
+	user   string
+	repo   string
+	opts   *github.RepositoryContentGetOptions
+}
+
+// The blobCache type holds the contents of files (keyed by blob SHA) that
+// have already been downloaded.  Since many versions of a library share
+// identical files, this avoids downloading the same file repeatedly.
+type blobCache struct {
+	mutex sync.Mutex
+	blobs map[string][]byte
+}
+
+func newBlobCache() *blobCache {
+	return &blobCache{
+		blobs: map[string][]byte{},
+	}
+}
+
+func (b *blobCache) get(sha string) ([]byte, bool) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	data, exists := b.blobs[sha]
+	return data, exists
+}
+
+func (b *blobCache) set(sha string, data []byte) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	b.blobs[sha] = data
+}
+
+// This function returns the (blob) SHA of the file at the given path.
+func (g gitHubContents) blobSHA(p string) (string, error) {
+	// Find the SHA by listing the directory the file is in
+	dir := path.Dir(p)
+	name := path.Base(p)
+
+	var dcon []*github.RepositoryContent
+	err := g.client.call(func() (err error) {
+		_, dcon, _, err = g.client.client.Repositories.GetContents(g.user, g.repo, dir, g.opts)
+		return
+	})
+	if err != nil {
+		return "", err
+	}
+
+	for _, con := range dcon {
+		if con.Name == nil || *con.Name != name || con.SHA == nil {
+			continue
+		}
+		return *con.SHA, nil
+	}
+	return "", fmt.Errorf("No file named %s found in %s", name, dir)
+}
+
+func (g gitHubContents) ReadFile(p string) ([]byte, error) {
+	sha, err := g.blobSHA(p)
+	if err != nil {
+		return nil, err
+	}
+
+	data, cached := g.client.blobs.get(sha)
+	if cached {
+		return data, nil
+	}
+
+	var blob *github.Blob
+	err = g.client.call(func() (err error) {
+		blob, _, err = g.client.client.Git.GetBlob(g.user, g.repo, sha)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data, err = decodeBlob(blob)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to decode contents of %s: %v", p, err)
+	}
+	g.client.blobs.set(sha, data)
+	return data, nil
+}
+
+// The media type used to get the raw contents of a blob
+const rawMediaType = "application/vnd.github.v3.raw"
+
+// The ReadPrefix method downloads (at most) the first n bytes of a file.
+// Only that range of the file is requested (and, in case the range is
+// ignored, the download is stopped once that much has been read).
+func (g gitHubContents) ReadPrefix(p string, n int) ([]byte, bool, error) {
+	sha, err := g.blobSHA(p)
+	if err != nil {
+		return nil, false, err
+	}
+
+	data, cached := g.client.blobs.get(sha)
+	if cached {
+		if len(data) > n {
+			return data[:n], false, nil
+		}
+		return data, true, nil
+	}
+
+	buf := &prefixBuffer{limit: n}
+	err = g.client.call(func() error {
+		buf = &prefixBuffer{limit: n}
+		req, err := g.client.client.NewRequest("GET",
+			fmt.Sprintf("repos/%s/%s/git/blobs/%s", g.user, g.repo, sha), nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", rawMediaType)
+		// One more byte than needed is requested to tell whether there is
+		// anything beyond the first n bytes
+		req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", n))
+		_, err = g.client.client.Do(req, buf)
+		return err
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	// If all of the file was read, there is no need to read it again
+	if !buf.truncated {
+		g.client.blobs.set(sha, buf.data)
+	}
+	return buf.data, !buf.truncated, nil
+}
+
+// This function returns the (decoded) contents of a blob
+func decodeBlob(blob *github.Blob) ([]byte, error) {
+	if blob == nil || blob.Content == nil {
+		return nil, fmt.Errorf("Blob has no content")
+	}
+	switch stringOf(blob.Encoding) {
+	case "base64":
+		// GitHub breaks the encoded content into lines
+		return base64.StdEncoding.DecodeString(strings.Replace(*blob.Content, "\n", "", -1))
+	case "utf-8", "":
+		return []byte(*blob.Content), nil
+	default:
+		return nil, fmt.Errorf("Unknown encoding '%s'", *blob.Encoding)
+	}
+}
+
+func (g gitHubContents) ReadDir(path string) ([]entry, error) {
+	var dcon []*github.RepositoryContent
+	err := g.client.call(func() (err error) {
+		_, dcon, _, err = g.client.client.Repositories.GetContents(g.user, g.repo, path, g.opts)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []entry{}
+	for _, con := range dcon {
+		if con.Name == nil || con.Type == nil {
+			continue
+		}
+		path := *con.Name
+		if con.Path != nil {
+			path = *con.Path
+		}
+		ret = append(ret, entry{
+			Name:  *con.Name,
+			Path:  path,
+			IsDir: *con.Type == "dir",
+		})
+	}
+	return ret, nil
+}
+
+var _ contents = (*gitHubContents)(nil)
+var _ prefixReader = (*gitHubContents)(nil)
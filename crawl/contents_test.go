@@ -0,0 +1,106 @@
+package crawl
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/github"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+)
+
+func TestBlobCache(t *testing.T) {
+	Convey("Testing caching of file contents", t, func(c C) {
+		blobs := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case strings.HasSuffix(r.URL.Path, "/contents/"), strings.HasSuffix(r.URL.Path, "/contents/."):
+				fmt.Fprint(w, `[{"name": "package.mo", "path": "package.mo", "type": "file", "sha": "abc123"}]`)
+			case strings.HasSuffix(r.URL.Path, "/git/blobs/abc123"):
+				blobs++
+				content := base64.StdEncoding.EncodeToString([]byte("within;\npackage Foo\nend Foo;"))
+				fmt.Fprintf(w, `{"sha": "abc123", "encoding": "base64", "content": "%s\n"}`, content)
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		defer server.Close()
+
+		client := github.NewClient(nil)
+		base, err := url.Parse(server.URL + "/")
+		NoError(c, err)
+		client.BaseURL = base
+		gc := NewGitHubClient(client, 0, StandardLogger(log.New(ioutil.Discard, "", 0), Quiet))
+
+		for _, ref := range []string{"v1.0.0", "v1.1.0"} {
+			src := gitHubContents{
+				client: gc,
+				user:   "a",
+				repo:   "Foo",
+				opts:   &github.RepositoryContentGetOptions{Ref: ref},
+			}
+			data, err := src.ReadFile("package.mo")
+			NoError(c, err)
+			Equals(c, string(data), "within;\npackage Foo\nend Foo;")
+		}
+		// The same blob is only downloaded once
+		Equals(c, blobs, 1)
+
+		src := gitHubContents{client: gc, user: "a", repo: "Foo"}
+		_, err = src.ReadFile("missing.mo")
+		IsError(c, err)
+	})
+}
+
+func TestReadPrefix(t *testing.T) {
+	Convey("Testing reading just the start of a file", t, func(c C) {
+		code := "within;\npackage Foo\n  annotation(uses(Modelica(version=\"3.2.2\")));\nend Foo;"
+		ranges := []string{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case strings.HasSuffix(r.URL.Path, "/contents/"), strings.HasSuffix(r.URL.Path, "/contents/."):
+				fmt.Fprint(w, `[{"name": "package.mo", "path": "package.mo", "type": "file", "sha": "abc123"}]`)
+			case strings.HasSuffix(r.URL.Path, "/git/blobs/abc123"):
+				Equals(c, r.Header.Get("Accept"), rawMediaType)
+				ranges = append(ranges, r.Header.Get("Range"))
+				// The whole file is sent (as if the range were ignored)
+				fmt.Fprint(w, code)
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		defer server.Close()
+
+		client := github.NewClient(nil)
+		base, err := url.Parse(server.URL + "/")
+		NoError(c, err)
+		client.BaseURL = base
+		gc := NewGitHubClient(client, 0, StandardLogger(log.New(ioutil.Discard, "", 0), Quiet))
+		src := gitHubContents{client: gc, user: "a", repo: "Foo"}
+
+		data, complete, err := src.ReadPrefix("package.mo", 20)
+		NoError(c, err)
+		IsFalse(c, complete)
+		Equals(c, string(data), code[:20])
+		Resembles(c, ranges, []string{"bytes=0-20"})
+
+		// Once all of the file has been read, it doesn't need to be read again
+		data, complete, err = src.ReadPrefix("package.mo", 1024)
+		NoError(c, err)
+		IsTrue(c, complete)
+		Equals(c, string(data), code)
+		data, complete, err = src.ReadPrefix("package.mo", 20)
+		NoError(c, err)
+		IsFalse(c, complete)
+		Equals(c, string(data), code[:20])
+		Equals(c, len(ranges), 2)
+	})
+}
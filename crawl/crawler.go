@@ -6,6 +6,15 @@ import (
 )
 
 type Crawler interface {
-	Crawl(r recorder.Recorder, verbose bool, logger *log.Logger) error
+	Crawl(r recorder.Recorder, verbosity Verbosity, logger *log.Logger) error
 	String() string
 }
+
+// A Baseline provides the results of a previous crawl (e.g., an existing
+// index).  This allows parts of a crawl to be skipped without losing what
+// was previously recorded.
+type Baseline interface {
+	// Record everything previously recorded for the repository with the
+	// given URI.  Returns false if nothing was found for that repository.
+	Replay(uri string, r recorder.Recorder) bool
+}
@@ -1,42 +1,32 @@
 package crawl
 
 import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/blang/semver"
+	"github.com/google/go-github/github"
 
 	. "github.com/smartystreets/goconvey/convey"
 	. "github.com/xogeny/xconvey"
 
+	"github.com/impact/impact/dirinfo"
+	"github.com/impact/impact/parsing"
 	"github.com/impact/impact/recorder"
 )
 
-type NullRecorder struct {
-}
-
-func (nr NullRecorder) GetLibrary(name string, owner string,
-	uri string) recorder.LibraryRecorder {
-	return nr
-}
-
-func (nr NullRecorder) SetStars(int)                 {}
-func (nr NullRecorder) SetEmail(string)              {}
-func (nr NullRecorder) SetDescription(string)        {}
-func (nr NullRecorder) SetHomepage(string)           {}
-func (nr NullRecorder) SetRepository(string, string) {}
-
-func (nr NullRecorder) AddVersion(v semver.Version) recorder.VersionRecorder {
-	return nr
-}
-
-func (nr NullRecorder) SetHash(hash string)                                  {}
-func (nr NullRecorder) SetPath(path string, file bool)                       {}
-func (nr NullRecorder) SetTarballURL(url string)                             {}
-func (nr NullRecorder) SetZipballURL(url string)                             {}
-func (nr NullRecorder) AddDependency(library string, version semver.Version) {}
-
 func TestGitHub(t *testing.T) {
 	// Don't test if we are doing CI testing...
 	if testing.Short() {
@@ -46,13 +36,541 @@ func TestGitHub(t *testing.T) {
 
 	Convey("Testing GitHub crawler", t, func(c C) {
 		logger := log.New(os.Stdout, "impact: ", 0)
-		cr, err := MakeGitHubCrawler("modelica-3rdparty", "", "")
+		cr, err := MakeGitHubCrawler("modelica-3rdparty", nil, "")
+		NoError(c, err)
+		err = cr.Crawl(recorder.NullRecorder{}, Quiet, logger)
+		NoError(c, err)
+	})
+}
+
+func TestIncompleteRepository(t *testing.T) {
+	Convey("Testing repositories with missing information", t, func(c C) {
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		cr, err := MakeGitHubCrawler("modelica-3rdparty", nil, "")
+		NoError(c, err)
+
+		rec := &versionsRecorder{versions: map[string][]string{}}
+
+		// No client is needed because these should all be skipped before
+		// any requests are made
+		err = cr.processRepo(nil, rec, github.Repository{}, logger)
+		NoError(c, err)
+
+		found := candidate{version: "1.0.0", sha: "abcdef"}
+		cr.processVersion(nil, rec, "Foo", github.Repository{}, found, logger)
+		cr.processVersion(nil, rec, "Foo", github.Repository{
+			Name: github.String("Foo"),
+		}, found, logger)
+		cr.processVersion(nil, rec, "Foo", github.Repository{
+			Name:  github.String("Foo"),
+			Owner: &github.User{},
+		}, found, logger)
+
+		Equals(c, len(rec.versions), 0)
+	})
+}
+
+func TestEmptyRepositories(t *testing.T) {
+	Convey("Testing reporting of repositories without versions", t, func(c C) {
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		cr, err := MakeGitHubCrawler("modelica-3rdparty", nil, "")
 		NoError(c, err)
-		err = cr.Crawl(NullRecorder{}, false, logger)
+		cr.empty = &emptyRepositories{}
+
+		gc := NewGitHubClient(nil, 0, logger)
+		tag := func(name string) github.RepositoryTag {
+			return github.RepositoryTag{
+				Name:   github.String(name),
+				Commit: &github.Commit{SHA: github.String("abcdef")},
+			}
+		}
+
+		cr.processTags(gc, recorder.NullRecorder{}, "NoTags", github.Repository{}, nil, logger)
+		cr.processTags(gc, recorder.NullRecorder{}, "BadTags", github.Repository{},
+			[]github.RepositoryTag{tag("latest"), tag("stable")}, logger)
+		// The repository has no name, so nothing will be recorded
+		cr.processTags(gc, recorder.NullRecorder{}, "Unrecorded", github.Repository{},
+			[]github.RepositoryTag{tag("v1.0.0")}, logger)
+
+		Equals(c, cr.empty.reasons["modelica-3rdparty/NoTags"], "no tags")
+		Equals(c, cr.empty.reasons["modelica-3rdparty/BadTags"], "2 tags, none with a semantic version")
+		Equals(c, cr.empty.reasons["modelica-3rdparty/Unrecorded"],
+			"1 tags with semantic versions, none recorded")
+
+		Equals(c, cr.stats.TagsProcessed, int64(3))
+		Equals(c, cr.stats.VersionsRecorded, int64(0))
+		IsTrue(c, strings.Contains(cr.stats.String(), "tags_processed=3 "))
+	})
+}
+
+func TestTagConcurrency(t *testing.T) {
+	Convey("Testing concurrent processing of tags", t, func(c C) {
+		logger := synchronizedLogger(StandardLogger(log.New(ioutil.Discard, "", 0), Quiet))
+		cr, err := MakeGitHubCrawler("modelica-3rdparty", nil, "")
 		NoError(c, err)
+		cr.empty = &emptyRepositories{}
+		cr.SetTagConcurrency(4)
+
+		tags := []github.RepositoryTag{}
+		for i := 0; i < 20; i++ {
+			tags = append(tags, github.RepositoryTag{
+				Name:   github.String(fmt.Sprintf("v1.%d.0", i)),
+				Commit: &github.Commit{SHA: github.String("abcdef")},
+			})
+		}
+		// Tags that aren't semantic versions are counted too
+		tags = append(tags, github.RepositoryTag{
+			Name:   github.String("latest"),
+			Commit: &github.Commit{SHA: github.String("abcdef")},
+		})
+
+		gc := NewGitHubClient(nil, 0, logger)
+		// The repository has no name, so nothing will be recorded
+		cr.processTags(gc, recorder.Synchronized(recorder.NullRecorder{}), "Foo",
+			github.Repository{}, tags, logger)
+
+		Equals(c, cr.stats.TagsProcessed, int64(21))
+		Equals(c, cr.empty.reasons["modelica-3rdparty/Foo"],
+			"20 tags with semantic versions, none recorded")
 	})
 }
 
-var _ recorder.Recorder = (*NullRecorder)(nil)
-var _ recorder.LibraryRecorder = (*NullRecorder)(nil)
-var _ recorder.VersionRecorder = (*NullRecorder)(nil)
+func TestVersionRange(t *testing.T) {
+	Convey("Testing restricting versions to a range", t, func(c C) {
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		cr, err := MakeGitHubCrawler("modelica-3rdparty", nil, "")
+		NoError(c, err)
+
+		IsError(c, cr.SetVersionRange(">=2.0 <x"))
+		NoError(c, cr.SetVersionRange(">= 2.0 <4"))
+		IsTrue(c, cr.versionRange(semver.MustParse("3.2.1")))
+		IsTrue(c, !cr.versionRange(semver.MustParse("4.0.0")))
+
+		// Versions outside the range are skipped before anything is fetched
+		repo := github.Repository{
+			Name:  github.String("Foo"),
+			Owner: &github.User{Login: github.String("a")},
+		}
+		rec := &versionsRecorder{versions: map[string][]string{}}
+		IsTrue(c, !cr.processVersion(nil, rec, "Foo", repo, candidate{version: "1.9.9"}, logger))
+		IsTrue(c, !cr.processVersion(nil, rec, "Foo", repo, candidate{version: "4.0"}, logger))
+		Equals(c, len(rec.versions), 0)
+
+		NoError(c, cr.SetVersionRange(""))
+		IsTrue(c, cr.versionRange == nil)
+	})
+}
+
+// The replayBaseline records which repositories were replayed
+type replayBaseline struct {
+	replayed []string
+}
+
+func (b *replayBaseline) Replay(uri string, r recorder.Recorder) bool {
+	b.replayed = append(b.replayed, uri)
+	return true
+}
+
+func TestSince(t *testing.T) {
+	Convey("Testing skipping repositories that haven't changed", t, func(c C) {
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		cr, err := MakeGitHubCrawler("modelica-3rdparty", nil, "")
+		NoError(c, err)
+
+		since := time.Date(2016, 3, 1, 0, 0, 0, 0, time.UTC)
+		baseline := &replayBaseline{}
+		cr.SetSince(since, baseline)
+
+		old := github.Repository{
+			Name:     github.String("Old"),
+			HTMLURL:  github.String("https://github.com/modelica-3rdparty/Old"),
+			PushedAt: &github.Timestamp{Time: since.Add(-time.Hour)},
+		}
+		IsTrue(c, cr.unchanged(old))
+		IsTrue(c, !cr.unchanged(github.Repository{
+			PushedAt: &github.Timestamp{Time: since.Add(time.Hour)},
+		}))
+		IsTrue(c, !cr.unchanged(github.Repository{}))
+
+		// No client is needed since the repository is taken from the baseline
+		NoError(c, cr.processRepo(nil, recorder.NullRecorder{}, old, logger))
+		Equals(c, len(baseline.replayed), 1)
+		Equals(c, baseline.replayed[0], "https://github.com/modelica-3rdparty/Old")
+		Equals(c, cr.stats.ReposUnchanged, int64(1))
+	})
+}
+
+func TestMinStars(t *testing.T) {
+	Convey("Testing minimum number of stars", t, func(c C) {
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		cr, err := MakeGitHubCrawler("modelica-3rdparty", nil, "")
+		NoError(c, err)
+
+		popular := github.Repository{StargazersCount: github.Int(10)}
+		unpopular := github.Repository{StargazersCount: github.Int(2)}
+		unknown := github.Repository{}
+
+		IsTrue(c, cr.enoughStars(unpopular, logger))
+		IsTrue(c, cr.enoughStars(unknown, logger))
+
+		cr.SetMinStars(5)
+		IsTrue(c, cr.enoughStars(popular, logger))
+		IsTrue(c, !cr.enoughStars(unpopular, logger))
+		IsTrue(c, !cr.enoughStars(unknown, logger))
+	})
+}
+
+func TestMatchFullName(t *testing.T) {
+	Convey("Testing matching of the pattern against owner/repo", t, func(c C) {
+		cr, err := MakeMultiGitHubCrawler([]string{"acme", "other"}, []string{"^acme/modelica-.*"}, "")
+		NoError(c, err)
+		Equals(c, cr.String(), "github://acme,other/^acme/modelica-.*")
+		IsTrue(c, !cr.matches("modelica-lib"))
+
+		cr.SetMatchFullName(true)
+		Equals(c, cr.String(), "github://acme,other/^acme/modelica-.* (matching owner/repo)")
+		IsTrue(c, cr.matches("modelica-lib"))
+		IsTrue(c, !cr.matches("other-lib"))
+
+		cr.user = "other"
+		IsTrue(c, !cr.matches("modelica-lib"))
+	})
+}
+
+func TestPatterns(t *testing.T) {
+	Convey("Testing include and exclude patterns", t, func(c C) {
+		cr, err := MakeGitHubCrawler("acme", []string{"^Buildings$", "^modelica-"}, "")
+		NoError(c, err)
+		Equals(c, cr.String(), "github://acme/{^Buildings$,^modelica-}")
+		IsTrue(c, cr.matches("Buildings"))
+		IsTrue(c, cr.matches("modelica-lib"))
+		IsTrue(c, !cr.matches("BuildingsExtra"))
+
+		err = cr.SetExcludePatterns([]string{"-old$"})
+		NoError(c, err)
+		Equals(c, cr.String(), "github://acme/{^Buildings$,^modelica-} excluding -old$")
+		IsTrue(c, cr.matches("modelica-lib"))
+		IsTrue(c, !cr.matches("modelica-lib-old"))
+
+		// Invalid patterns are reported
+		err = cr.SetExcludePatterns([]string{"("})
+		IsError(c, err)
+		_, err = MakeGitHubCrawler("acme", []string{".+", "["}, "")
+		IsError(c, err)
+
+		// Without any patterns, everything is crawled
+		cr, err = MakeGitHubCrawler("acme", nil, "")
+		NoError(c, err)
+		Equals(c, cr.String(), "github://acme/.+")
+		IsTrue(c, cr.matches("anything"))
+	})
+}
+
+func TestOwnerURIs(t *testing.T) {
+	Convey("Testing overriding the owner of mirrored repositories", t, func(c C) {
+		server := httptest.NewServer(http.NotFoundHandler())
+		defer server.Close()
+
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		client := github.NewClient(nil)
+		base, err := url.Parse(server.URL + "/")
+		NoError(c, err)
+		client.BaseURL = base
+		gc := NewGitHubClient(client, 0, logger)
+		gc.SetRetries(0, 0)
+
+		dir, err := ioutil.TempDir("", "impact")
+		NoError(c, err)
+		defer os.RemoveAll(dir)
+
+		owner := func(repo string, owners map[string]string) string {
+			cr, err := MakeGitHubCrawler("mirror", nil, "")
+			NoError(c, err)
+			cr.SetOwnerURIs(owners)
+			cr.cache, err = loadCache(filepath.Join(dir, "cache.json"), logger)
+			NoError(c, err)
+
+			// Use cached information so that no contents are needed
+			di := dirinfo.MakeDirectoryInfo()
+			di.OwnerURI = "https://github.com/mirror"
+			di.Libraries = []*dirinfo.LocalLibrary{{Name: repo, Path: repo}}
+			cr.cache.setVersion("mirror/"+repo+"/1.0.0", "abc", "", di)
+
+			m := recorder.NewMemoryRecorder()
+			IsTrue(c, cr.processVersion(gc, m, repo, github.Repository{
+				Name:    github.String(repo),
+				Owner:   &github.User{Login: github.String("mirror")},
+				HTMLURL: github.String("https://github.com/mirror/" + repo),
+			}, candidate{version: "1.0.0", sha: "abc"}, logger))
+			Equals(c, len(m.Libraries), 1)
+			return m.Libraries[0].OwnerURI
+		}
+
+		owners := map[string]string{"Mirror/Foo": "https://github.com/upstream"}
+		Equals(c, owner("Foo", owners), "https://github.com/upstream")
+		Equals(c, owner("Bar", owners), "https://github.com/mirror")
+		Equals(c, owner("Foo", nil), "https://github.com/mirror")
+	})
+}
+
+func TestMaxVersions(t *testing.T) {
+	Convey("Testing indexing only the newest versions", t, func(c C) {
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		cr, err := MakeGitHubCrawler("a", nil, "")
+		NoError(c, err)
+		cr.SetIncludePrereleases(false)
+
+		tags := []github.RepositoryTag{}
+		for _, name := range []string{"v1.10.0", "v0.9", "latest", "v2.0.0", "v1.2.0",
+			"1.10.0", "v3.0.0-rc1", "v1.9.0"} {
+			tags = append(tags, github.RepositoryTag{Name: github.String(name)})
+		}
+		names := func(tags []github.RepositoryTag) []string {
+			ret := []string{}
+			for _, tag := range tags {
+				ret = append(ret, *tag.Name)
+			}
+			return ret
+		}
+
+		// By default, all tags are processed
+		Equals(c, len(cr.newestTags("Foo", tags, logger)), len(tags))
+
+		// Otherwise, the newest versions are kept regardless of the order
+		// of the tags (and tags that aren't indexed anyway are kept)
+		cr.SetMaxVersionsPerLibrary(2)
+		Resembles(c, names(cr.newestTags("Foo", tags, logger)),
+			[]string{"v1.10.0", "latest", "v2.0.0", "1.10.0", "v3.0.0-rc1"})
+
+		cr.SetMaxVersionsPerLibrary(5)
+		Equals(c, len(cr.newestTags("Foo", tags, logger)), len(tags))
+	})
+}
+
+func TestVersionScheme(t *testing.T) {
+	Convey("Testing indexing versions that aren't semantic versions", t, func(c C) {
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		cr, err := MakeGitHubCrawler("a", nil, "")
+		NoError(c, err)
+		cr.SetIncludePrereleases(false)
+		cr.SetMaxVersionsPerLibrary(2)
+
+		tags := []github.RepositoryTag{}
+		for _, name := range []string{"2023-03-01", "2022-12-01", "latest", "2023-01-15"} {
+			tags = append(tags, github.RepositoryTag{Name: github.String(name)})
+		}
+		names := func(tags []github.RepositoryTag) []string {
+			ret := []string{}
+			for _, tag := range tags {
+				ret = append(ret, *tag.Name)
+			}
+			return ret
+		}
+
+		// As semantic versions, none of these are versions (so none of
+		// them count towards the maximum)
+		Equals(c, len(cr.newestTags("Foo", tags, logger)), len(tags))
+		IsFalse(c, isPrerelease(nil, "2023-01-15"))
+
+		// As calendar versions, they are ordered by date
+		cr.SetVersionScheme(parsing.CalendarVersions)
+		IsFalse(c, isPrerelease(cr.scheme, "2023-01-15"))
+		Resembles(c, names(cr.newestTags("Foo", tags, logger)),
+			[]string{"2023-03-01", "latest", "2023-01-15"})
+	})
+}
+
+func TestPagination(t *testing.T) {
+	Convey("Testing pagination of repository listings", t, func(c C) {
+		listings := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/users/a/repos" {
+				http.NotFound(w, r)
+				return
+			}
+			listings++
+			Equals(c, r.URL.Query().Get("per_page"), "2")
+			switch r.URL.Query().Get("page") {
+			case "1":
+				w.Header().Set("Link", fmt.Sprintf(`<http://%s/users/a/repos?page=2&per_page=2>; rel="next"`, r.Host))
+				fmt.Fprint(w, `[{"name": "A"}, {"name": "B"}]`)
+			default:
+				// A full last page
+				fmt.Fprint(w, `[{"name": "C"}, {"name": "D"}]`)
+			}
+		}))
+		defer server.Close()
+
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		client := github.NewClient(nil)
+		base, err := url.Parse(server.URL + "/")
+		NoError(c, err)
+		client.BaseURL = base
+		gc := NewGitHubClient(client, 0, logger)
+		gc.SetRetries(0, 0)
+
+		cr, err := MakeGitHubCrawler("a", nil, "")
+		NoError(c, err)
+		cr.SetPagination(2, 1)
+		err = cr.crawlUser(gc, recorder.NullRecorder{}, logger)
+		NoError(c, err)
+		Equals(c, listings, 2)
+		Equals(c, cr.stats.ReposExamined, int64(4))
+	})
+}
+
+func TestPaginationFailures(t *testing.T) {
+	Convey("Testing pages of repository listings that can't be listed", t, func(c C) {
+		requests := map[string]int{}
+		broken := map[string]bool{"2": true}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/users/a/repos" {
+				http.NotFound(w, r)
+				return
+			}
+			page := r.URL.Query().Get("page")
+			requests[page]++
+			if broken[page] {
+				http.Error(w, "Broken", http.StatusBadGateway)
+				return
+			}
+			switch page {
+			case "1", "2":
+				next, _ := strconv.Atoi(page)
+				w.Header().Set("Link", fmt.Sprintf(`<http://%s/users/a/repos?page=%d&per_page=2>; rel="next", `+
+					`<http://%s/users/a/repos?page=3&per_page=2>; rel="last"`, r.Host, next+1, r.Host))
+				fmt.Fprintf(w, `[{"name": "A%s"}, {"name": "B%s"}]`, page, page)
+			default:
+				fmt.Fprint(w, `[{"name": "C"}]`)
+			}
+		}))
+		defer server.Close()
+
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		client := github.NewClient(nil)
+		base, err := url.Parse(server.URL + "/")
+		NoError(c, err)
+		client.BaseURL = base
+		gc := NewGitHubClient(client, 0, logger)
+		gc.SetRetries(1, 0)
+
+		// Pages that still fail after retrying are skipped
+		cr, err := MakeGitHubCrawler("a", nil, "")
+		NoError(c, err)
+		cr.SetPagination(2, 1)
+		cr.failures = &repoErrors{}
+		repos, err := cr.listRepos(gc, logger)
+		NoError(c, err)
+		names := []string{}
+		for _, repo := range repos {
+			names = append(names, *repo.Name)
+		}
+		Resembles(c, names, []string{"A1", "B1", "C"})
+		Equals(c, requests["2"], 2)
+		failures := cr.failures.list()
+		Equals(c, len(failures), 1)
+		Equals(c, failures[0].Phase, PhaseListing)
+		Equals(c, failures[0].Repo, "")
+		IsTrue(c, strings.HasPrefix(failures[0].Err.Error(), "Page 2: "))
+
+		// Without a first page, there is nothing to continue with
+		broken["1"] = true
+		_, err = cr.listRepos(gc, logger)
+		IsError(c, err)
+	})
+}
+
+func TestRepoErrors(t *testing.T) {
+	Convey("Testing reporting of repositories that couldn't be processed", t, func(c C) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/users/a/repos":
+				fmt.Fprint(w, `[{"name": "A"}, {"name": "B"}, {"name": "C"}]`)
+			case "/repos/a/B", "/repos/a/C":
+				fmt.Fprintf(w, `{"name": "%s"}`, path.Base(r.URL.Path))
+			case "/repos/a/B/tags":
+				http.Error(w, "Broken", http.StatusInternalServerError)
+			case "/repos/a/C/tags":
+				fmt.Fprint(w, `[]`)
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		defer server.Close()
+
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		client := github.NewClient(nil)
+		base, err := url.Parse(server.URL + "/")
+		NoError(c, err)
+		client.BaseURL = base
+		gc := NewGitHubClient(client, 0, logger)
+		gc.SetRetries(0, 0)
+
+		cr, err := MakeGitHubCrawler("a", nil, "")
+		NoError(c, err)
+		cr.failures = &repoErrors{}
+		err = cr.crawlUser(gc, recorder.NullRecorder{}, logger)
+		NoError(c, err)
+		Equals(c, cr.stats.ReposExamined, int64(3))
+
+		failures := cr.failures.list()
+		Equals(c, len(failures), 2)
+		Equals(c, failures[0].Repo, "A")
+		Equals(c, failures[0].Phase, PhaseDetails)
+		Equals(c, failures[1].Repo, "B")
+		Equals(c, failures[1].Phase, PhaseTags)
+
+		data, err := json.Marshal(failures[0])
+		NoError(c, err)
+		IsTrue(c, strings.HasPrefix(string(data), `{"user":"a","repo":"A","phase":"details","error":"GET `))
+	})
+}
+
+func TestTokenFile(t *testing.T) {
+	Convey("Testing reading the token from a file", t, func(c C) {
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		dir, err := ioutil.TempDir("", "impact")
+		NoError(c, err)
+		defer os.RemoveAll(dir)
+
+		name := filepath.Join(dir, "token")
+		Equals(c, readTokenFile("", logger), "")
+		Equals(c, readTokenFile(name, logger), "")
+
+		writeFile(c, name, "  \n")
+		Equals(c, readTokenFile(name, logger), "")
+
+		writeFile(c, name, "abc123\n")
+		Equals(c, readTokenFile(name, logger), "abc123")
+	})
+}
+
+func TestGeneratorVersion(t *testing.T) {
+	Convey("Testing recording when and by what a crawl was done", t, func(c C) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/users/a/repos" {
+				fmt.Fprint(w, `[]`)
+				return
+			}
+			http.NotFound(w, r)
+		}))
+		defer server.Close()
+
+		target, err := url.Parse(server.URL)
+		NoError(c, err)
+
+		cr, err := MakeGitHubCrawler("a", nil, "")
+		NoError(c, err)
+		cr.SetHTTPClient(&http.Client{Transport: &redirectTransport{target: target}})
+		cr.SetRetries(0, 0)
+		cr.SetGeneratorVersion("1.2.3")
+
+		before := time.Now()
+		mr := recorder.NewMemoryRecorder()
+		err = cr.Crawl(recorder.Synchronized(mr), Quiet, log.New(ioutil.Discard, "", 0))
+		NoError(c, err)
+		Equals(c, mr.GeneratorVersion, "1.2.3")
+		IsFalse(c, mr.GeneratedAt.Before(before))
+		IsFalse(c, mr.GeneratedAt.After(time.Now()))
+	})
+}
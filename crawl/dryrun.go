@@ -0,0 +1,97 @@
+package crawl
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/blang/semver"
+
+	"github.com/impact/impact/recorder"
+)
+
+// The dryRunRecorder is used in place of the real recorder when doing a
+// dry run.  Nothing is recorded, but every library version that would
+// have been recorded is reported (and counted).
+type dryRunRecorder struct {
+	logger CrawlLogger
+	// Versions found for each library (keyed by library name and URI)
+	libraries map[string]map[string]bool
+}
+
+func newDryRunRecorder(logger CrawlLogger) *dryRunRecorder {
+	return &dryRunRecorder{
+		logger:    logger,
+		libraries: map[string]map[string]bool{},
+	}
+}
+
+func (d *dryRunRecorder) GetLibrary(name string, uri string,
+	owner_uri string) recorder.LibraryRecorder {
+	key := fmt.Sprintf("%s (%s)", name, uri)
+	versions, exists := d.libraries[key]
+	if !exists {
+		versions = map[string]bool{}
+		d.libraries[key] = versions
+	}
+	return &dryRunLibrary{
+		d:        d,
+		name:     key,
+		versions: versions,
+	}
+}
+
+// The summary method reports the total number of libraries and versions
+// that would have been recorded.
+func (d *dryRunRecorder) summary() {
+	versions := 0
+	for _, v := range d.libraries {
+		versions = versions + len(v)
+	}
+	d.logger.Infof("Dry run: %d libraries and %d versions would be recorded",
+		len(d.libraries), versions)
+}
+
+type dryRunLibrary struct {
+	d        *dryRunRecorder
+	name     string
+	versions map[string]bool
+}
+
+func (l *dryRunLibrary) SetDescription(desc string)              {}
+func (l *dryRunLibrary) SetHomepage(url string)                  {}
+func (l *dryRunLibrary) SetRepository(url string, format string) {}
+func (l *dryRunLibrary) SetStars(stars int)                      {}
+func (l *dryRunLibrary) SetForks(forks int)                      {}
+func (l *dryRunLibrary) SetOpenIssues(issues int)                {}
+func (l *dryRunLibrary) SetEmail(email string)                   {}
+func (l *dryRunLibrary) SetMaintainers([]recorder.Maintainer)    {}
+func (l *dryRunLibrary) SetLicense(license string)               {}
+
+func (l *dryRunLibrary) HasVersion(v semver.Version) bool {
+	return l.versions[v.String()]
+}
+
+func (l *dryRunLibrary) AddVersion(v semver.Version) recorder.VersionRecorder {
+	l.d.logger.Infof("    Would record %s version %s", l.name, v.String())
+	l.versions[v.String()] = true
+	return dryRunVersion{}
+}
+
+type dryRunVersion struct{}
+
+func (v dryRunVersion) SetHash(hash string)                                  {}
+func (v dryRunVersion) SetTarballURL(url string)                             {}
+func (v dryRunVersion) AddMirror(url string)                                 {}
+func (v dryRunVersion) SetZipballURL(url string)                             {}
+func (v dryRunVersion) SetArchiveChecksum(algo string, hex string)           {}
+func (v dryRunVersion) SetSize(bytes int64)                                  {}
+func (v dryRunVersion) SetReleaseDate(date time.Time)                        {}
+func (v dryRunVersion) SetVerified(verified bool)                            {}
+func (v dryRunVersion) SetReleaseNotes(notes string)                         {}
+func (v dryRunVersion) SetTagName(tag string)                                {}
+func (v dryRunVersion) SetPath(path string, file bool)                       {}
+func (v dryRunVersion) SetModelicaCompat(version string)                     {}
+func (v dryRunVersion) SetBuildStatus(status string, details string)         {}
+func (v dryRunVersion) AddDependency(library string, version semver.Version) {}
+
+var _ recorder.Recorder = (*dryRunRecorder)(nil)
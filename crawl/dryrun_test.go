@@ -0,0 +1,33 @@
+package crawl
+
+import (
+	"bytes"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/blang/semver"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+)
+
+func TestDryRun(t *testing.T) {
+	Convey("Testing dry run recorder", t, func(c C) {
+		buf := bytes.Buffer{}
+		dr := newDryRunRecorder(StandardLogger(log.New(&buf, "", 0), Quiet))
+
+		foo := dr.GetLibrary("Foo", "https://github.com/a/Foo", "https://github.com/a")
+		foo.AddVersion(semver.MustParse("1.0.0"))
+		foo.AddVersion(semver.MustParse("1.1.0"))
+		foo = dr.GetLibrary("Foo", "https://github.com/a/Foo", "https://github.com/a")
+		foo.AddVersion(semver.MustParse("1.1.0"))
+		bar := dr.GetLibrary("Bar", "https://github.com/a/Bar", "https://github.com/a")
+		bar.AddVersion(semver.MustParse("0.1.0"))
+
+		dr.summary()
+		out := buf.String()
+		IsTrue(c, strings.Contains(out, "Would record Foo (https://github.com/a/Foo) version 1.1.0"))
+		IsTrue(c, strings.Contains(out, "2 libraries and 3 versions would be recorded"))
+	})
+}
@@ -0,0 +1,145 @@
+package crawl
+
+import (
+	"github.com/blang/semver"
+
+	"github.com/impact/impact/recorder"
+)
+
+// The duplicatesRecorder is used when crawling several users (or
+// organizations) at once.  If a library with the same name is found under
+// more than one owner, a warning is logged and everything is recorded as
+// a single library (owned by whichever owner it was found under first).
+// The library level information (e.g., stars and description) is taken
+// from whichever repository has the most stars.
+//
+// Note that this recorder is not safe for concurrent use on its own (it
+// relies on the crawler to serialize recording).  It deliberately isn't a
+// recorder.Finisher since versions of a library may still be found under
+// another owner after a repository has been processed.
+type duplicatesRecorder struct {
+	r         recorder.Recorder
+	logger    CrawlLogger
+	libraries map[string]*duplicateLibrary
+}
+
+// Information about a library that has already been recorded
+type duplicateLibrary struct {
+	lr    recorder.LibraryRecorder
+	owner string
+	// Largest number of stars seen so far (negative if unknown)
+	stars int
+	// Owners for which a warning has already been logged
+	warned map[string]bool
+}
+
+func newDuplicatesRecorder(r recorder.Recorder, logger CrawlLogger) *duplicatesRecorder {
+	return &duplicatesRecorder{
+		r:         r,
+		logger:    logger,
+		libraries: map[string]*duplicateLibrary{},
+	}
+}
+
+func (d *duplicatesRecorder) GetLibrary(name string, uri string,
+	owner_uri string) recorder.LibraryRecorder {
+	lib, exists := d.libraries[name]
+	if !exists {
+		lib = &duplicateLibrary{
+			lr:     d.r.GetLibrary(name, uri, owner_uri),
+			owner:  owner_uri,
+			stars:  -1,
+			warned: map[string]bool{},
+		}
+		d.libraries[name] = lib
+		return &duplicateLibraryRecorder{lib: lib, winning: true}
+	}
+
+	if lib.owner != owner_uri && !lib.warned[owner_uri] {
+		d.logger.Warnf("Library %s found under both %s and %s (%s)",
+			name, lib.owner, owner_uri, uri)
+		lib.warned[owner_uri] = true
+	}
+
+	// Until we know how many stars this repository has, assume the
+	// information we already have is better
+	return &duplicateLibraryRecorder{lib: lib, winning: false}
+}
+
+// The duplicateLibraryRecorder records information about a library found
+// in a particular repository.  Library level information is only passed
+// on if the repository has more stars than any other repository this
+// library has been found in.
+type duplicateLibraryRecorder struct {
+	lib     *duplicateLibrary
+	winning bool
+}
+
+func (d *duplicateLibraryRecorder) SetStars(stars int) {
+	if stars <= d.lib.stars {
+		d.winning = false
+		return
+	}
+	d.winning = true
+	d.lib.stars = stars
+	d.lib.lr.SetStars(stars)
+}
+
+func (d *duplicateLibraryRecorder) SetForks(forks int) {
+	if d.winning {
+		d.lib.lr.SetForks(forks)
+	}
+}
+
+func (d *duplicateLibraryRecorder) SetOpenIssues(issues int) {
+	if d.winning {
+		d.lib.lr.SetOpenIssues(issues)
+	}
+}
+
+func (d *duplicateLibraryRecorder) SetDescription(desc string) {
+	if d.winning {
+		d.lib.lr.SetDescription(desc)
+	}
+}
+
+func (d *duplicateLibraryRecorder) SetHomepage(url string) {
+	if d.winning {
+		d.lib.lr.SetHomepage(url)
+	}
+}
+
+func (d *duplicateLibraryRecorder) SetRepository(url string, format string) {
+	if d.winning {
+		d.lib.lr.SetRepository(url, format)
+	}
+}
+
+func (d *duplicateLibraryRecorder) SetEmail(email string) {
+	if d.winning {
+		d.lib.lr.SetEmail(email)
+	}
+}
+
+func (d *duplicateLibraryRecorder) SetMaintainers(maintainers []recorder.Maintainer) {
+	if d.winning {
+		d.lib.lr.SetMaintainers(maintainers)
+	}
+}
+
+func (d *duplicateLibraryRecorder) SetLicense(license string) {
+	if d.winning {
+		d.lib.lr.SetLicense(license)
+	}
+}
+
+func (d *duplicateLibraryRecorder) HasVersion(v semver.Version) bool {
+	return d.lib.lr.HasVersion(v)
+}
+
+func (d *duplicateLibraryRecorder) AddVersion(v semver.Version) recorder.VersionRecorder {
+	return d.lib.lr.AddVersion(v)
+}
+
+var _ recorder.Recorder = (*duplicatesRecorder)(nil)
+var _ recorder.LibraryRecorder = (*duplicateLibraryRecorder)(nil)
@@ -0,0 +1,70 @@
+package crawl
+
+import (
+	"io/ioutil"
+	"log"
+	"testing"
+
+	"github.com/blang/semver"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+
+	"github.com/impact/impact/recorder"
+)
+
+// This recorder keeps track of the library level information recorded
+// for each library (and how many times each library was created)
+type detailsRecorder struct {
+	recorder.NullRecorder
+	created     map[string]int
+	description string
+	stars       int
+}
+
+func (dr *detailsRecorder) GetLibrary(name string, uri string,
+	owner_uri string) recorder.LibraryRecorder {
+	dr.created[name]++
+	return dr
+}
+
+func (dr *detailsRecorder) SetDescription(desc string) { dr.description = desc }
+func (dr *detailsRecorder) SetStars(stars int)         { dr.stars = stars }
+
+func (dr *detailsRecorder) AddVersion(v semver.Version) recorder.VersionRecorder {
+	return dr
+}
+
+func TestDuplicates(t *testing.T) {
+	Convey("Testing libraries found under several owners", t, func(c C) {
+		dr := &detailsRecorder{created: map[string]int{}}
+		r := newDuplicatesRecorder(dr, StandardLogger(log.New(ioutil.Discard, "", 0), Quiet))
+
+		record := func(owner string, stars int, desc string) {
+			details := repoDetails{
+				URI:         "https://github.com/" + owner + "/Foo",
+				Description: desc,
+				Stars:       stars,
+			}
+			lr := r.GetLibrary("Foo", details.URI, "https://github.com/"+owner)
+			lr.SetStars(details.Stars)
+			lr.SetDescription(details.Description)
+		}
+
+		record("a", 5, "From a")
+		Equals(c, dr.description, "From a")
+		Equals(c, dr.stars, 5)
+
+		// Fewer stars, so this information is ignored
+		record("b", 2, "From b")
+		Equals(c, dr.description, "From a")
+		Equals(c, dr.stars, 5)
+
+		// More stars, so this information wins
+		record("c", 10, "From c")
+		Equals(c, dr.description, "From c")
+		Equals(c, dr.stars, 10)
+
+		Equals(c, dr.created["Foo"], 1)
+	})
+}
@@ -0,0 +1,53 @@
+package crawl
+
+import (
+	"sort"
+	"sync"
+)
+
+// The emptyRepositories type keeps track of repositories that matched
+// the pattern of a crawler but didn't contribute any versions to the
+// index (typically because no release has been tagged).  It can safely
+// be used from multiple goroutines (and methods on a nil value do
+// nothing).
+type emptyRepositories struct {
+	mutex   sync.Mutex
+	reasons map[string]string
+}
+
+// The add method records that the named repository didn't contribute
+// any versions (and why).
+func (e *emptyRepositories) add(name string, reason string) {
+	if e == nil {
+		return
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if e.reasons == nil {
+		e.reasons = map[string]string{}
+	}
+	e.reasons[name] = reason
+}
+
+// The report method logs a summary of all the empty repositories.
+func (e *emptyRepositories) report(logger CrawlLogger) {
+	if e == nil {
+		return
+	}
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+	if len(e.reasons) == 0 {
+		return
+	}
+
+	names := []string{}
+	for name := range e.reasons {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	logger.Infof("=== %d repositories contributed no versions ===", len(names))
+	for _, name := range names {
+		logger.Infof("  %s: %s", name, e.reasons[name])
+	}
+}
@@ -0,0 +1,168 @@
+package crawl
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/impact/impact/parsing"
+)
+
+var exclusionList []string
+
+func init() {
+	exclusionList = []string{
+		"modelica-3rdparty:BrineProp:0.1.9",  // Directory structure is a mess
+		"modelica-3rdparty:ModelicaDEVS:1.0", // Self reference (and invalid at that)
+		"modelica-3rdparty:NCLib:0.82",       // Missing package.mo
+	}
+}
+
+// An exclusion matches versions that should not be indexed.  An entry has
+// one of the following forms:
+//
+//	user:repo:version  - Matches exactly this version
+//	user:repo:*        - Glob pattern ('*' matches any sequence of
+//	                     characters, '?' matches any single character)
+//	re:<regexp>        - Regular expression that must match the entire
+//	                     'user:repo:version' string
+type exclusion struct {
+	entry string
+	re    *regexp.Regexp // nil for exact matches
+}
+
+// Prefix used to identify regular expression exclusions
+var regexpPrefix = "re:"
+
+func compileExclusion(entry string) (exclusion, error) {
+	expr := ""
+	switch {
+	case strings.HasPrefix(entry, regexpPrefix):
+		expr = "^(?:" + strings.TrimPrefix(entry, regexpPrefix) + ")$"
+	case strings.ContainsAny(entry, "*?"):
+		expr = "^"
+		for _, ch := range entry {
+			switch ch {
+			case '*':
+				expr = expr + ".*"
+			case '?':
+				expr = expr + "."
+			default:
+				expr = expr + regexp.QuoteMeta(string(ch))
+			}
+		}
+		expr = expr + "$"
+	default:
+		return exclusion{entry: entry}, nil
+	}
+
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return exclusion{}, fmt.Errorf("Invalid exclusion '%s': %v", entry, err)
+	}
+	return exclusion{entry: entry, re: re}, nil
+}
+
+func (e exclusion) matches(str string) bool {
+	if e.re == nil {
+		return e.entry == str
+	}
+	return e.re.MatchString(str)
+}
+
+// An exclusionSet is a collection of versions that should not be indexed.
+type exclusionSet []exclusion
+
+func defaultExclusions() exclusionSet {
+	ret := exclusionSet{}
+	for _, entry := range exclusionList {
+		ex, err := compileExclusion(entry)
+		if err != nil {
+			panic(err)
+		}
+		ret = append(ret, ex)
+	}
+	return ret
+}
+
+func (e exclusionSet) excludes(user string, reponame string, tagname string) bool {
+	str := fmt.Sprintf("%s:%s:%s", user, reponame, tagname)
+	for _, ex := range e {
+		//log.Printf("Comparing '%s' to '%s'", ex.entry, str)
+		if ex.matches(str) {
+			return true
+		}
+	}
+	return false
+}
+
+// This function reads exclusions from a file.  The file should contain one
+// entry (see exclusion) per line.  Anything following a '#' is treated
+// as a comment and blank lines are ignored.  Malformed lines are logged
+// (as warnings) and skipped.
+func readExclusions(filename string, logger CrawlLogger) (exclusionSet, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open exclusions file '%s': %v", filename, err)
+	}
+	defer f.Close()
+
+	ret := exclusionSet{}
+	scanner := bufio.NewScanner(f)
+	lineno := 0
+	for scanner.Scan() {
+		lineno++
+		line := scanner.Text()
+
+		// Strip comments and whitespace
+		if i := strings.Index(line, "#"); i != -1 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		// Regular expressions can contain any number of ':'s, but all
+		// other entries must have exactly three fields
+		if !strings.HasPrefix(line, regexpPrefix) {
+			fields := strings.Split(line, ":")
+			valid := len(fields) == 3
+			for i, field := range fields {
+				fields[i] = strings.TrimSpace(field)
+				if fields[i] == "" {
+					valid = false
+				}
+			}
+			if !valid {
+				logger.Warnf("%s:%d: Ignoring malformed exclusion '%s' (expected user:repo:version)",
+					filename, lineno, line)
+				continue
+			}
+			line = strings.Join(fields, ":")
+		}
+
+		ex, err := compileExclusion(line)
+		if err != nil {
+			logger.Warnf("%s:%d: Ignoring exclusion: %v", filename, lineno, err)
+			continue
+		}
+		ret = append(ret, ex)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Error reading exclusions file '%s': %v", filename, err)
+	}
+
+	return ret, nil
+}
+
+// This function determines whether the given version string represents a
+// pre-release (e.g., 2.1.0-rc1) according to the given scheme (see
+// parsing.NormalizeWith).  Version strings that cannot be parsed are not
+// considered pre-releases.
+func isPrerelease(scheme parsing.VersionScheme, versionString string) bool {
+	v, err := parsing.NormalizeWith(scheme, versionString)
+	return err == nil && len(v.Pre) > 0
+}
@@ -0,0 +1,77 @@
+package crawl
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+)
+
+var sampleExclusions = `
+# Known problems
+modelica-3rdparty:Foo:1.0   # Missing package.mo
+  modelica:Bar:2.1.0
+modelica-3rdparty:BrineProp:*
+re:.*:Messy:0\.[0-9].*
+re:unbalanced(
+not-enough:fields
+too:many:fields:here
+`
+
+func TestExclusions(t *testing.T) {
+	Convey("Testing exclusions", t, func(c C) {
+		f, err := ioutil.TempFile("", "exclusions")
+		NoError(c, err)
+		defer os.Remove(f.Name())
+		_, err = f.WriteString(sampleExclusions)
+		NoError(c, err)
+		f.Close()
+
+		logged := bytes.Buffer{}
+		logger := StandardLogger(log.New(&logged, "", 0), Quiet)
+		ex, err := readExclusions(f.Name(), logger)
+		NoError(c, err)
+		Equals(c, len(ex), 4)
+		// Malformed entries are logged as warnings
+		Equals(c, strings.Count(logged.String(), "Warning: "), 3)
+		IsTrue(c, strings.Contains(logged.String(), ":8: Ignoring malformed exclusion 'not-enough:fields'"))
+		Equals(c, ex[0].entry, "modelica-3rdparty:Foo:1.0")
+		Equals(c, ex[1].entry, "modelica:Bar:2.1.0")
+
+		ex = append(defaultExclusions(), ex...)
+		IsTrue(c, ex.excludes("modelica-3rdparty", "Foo", "1.0"))
+		IsTrue(c, ex.excludes("modelica", "Bar", "2.1.0"))
+		IsTrue(c, ex.excludes("modelica-3rdparty", "NCLib", "0.82"))
+		IsTrue(c, !ex.excludes("modelica", "Bar", "2.1.1"))
+
+		// Glob patterns
+		IsTrue(c, ex.excludes("modelica-3rdparty", "BrineProp", "0.1.9"))
+		IsTrue(c, ex.excludes("modelica-3rdparty", "BrineProp", "2.0.0"))
+		IsTrue(c, !ex.excludes("modelica", "BrineProp", "2.0.0"))
+
+		// Regular expressions
+		IsTrue(c, ex.excludes("anyone", "Messy", "0.5.1"))
+		IsTrue(c, !ex.excludes("anyone", "Messy", "1.0.0"))
+
+		// Entries without wildcards must match exactly
+		IsTrue(c, !ex.excludes("modelica-3rdparty", "Foo", "1.0.1"))
+
+		_, err = readExclusions(f.Name()+".missing", logger)
+		IsError(c, err)
+	})
+}
+
+func TestPrereleases(t *testing.T) {
+	Convey("Testing pre-release detection", t, func(c C) {
+		IsTrue(c, isPrerelease(nil, "2.1.0-rc1"))
+		IsTrue(c, isPrerelease(nil, "2.1-beta"))
+		IsTrue(c, !isPrerelease(nil, "1.4.0+build.17"))
+		IsTrue(c, !isPrerelease(nil, "1.4"))
+		IsTrue(c, !isPrerelease(nil, "not-a-version"))
+	})
+}
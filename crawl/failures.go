@@ -0,0 +1,97 @@
+package crawl
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// These are the phases of processing a repository in which a failure
+// can occur (see RepoError).
+const (
+	// Fetching the complete details of the repository
+	PhaseDetails = "details"
+	// Listing the tags of the repository
+	PhaseTags = "tags"
+	// Fetching the topics of the repository
+	PhaseTopics = "topics"
+	// Fetching the languages of the repository
+	PhaseLanguages = "languages"
+	// Processing the repository took too long (see SetRepoTimeout)
+	PhaseTimeout = "timeout"
+	// Listing the repositories of the user (the repository is unknown
+	// since a whole page of repositories was skipped)
+	PhaseListing = "listing"
+)
+
+// A RepoError describes a repository that was skipped because some
+// information about it couldn't be retrieved.  This allows the failures
+// of a crawl to be reported (e.g., as JSON) without having to look
+// through the log.
+type RepoError struct {
+	User  string // User (or organization) being crawled
+	Repo  string // Name of the repository
+	Phase string // What was being done (e.g., PhaseTags)
+	Err   error  // The underlying error
+}
+
+func (e RepoError) Error() string {
+	return fmt.Sprintf("%s/%s (%s): %v", e.User, e.Repo, e.Phase, e.Err)
+}
+
+// The MarshalJSON method represents the error by its message (since
+// errors don't otherwise have a JSON representation).
+func (e RepoError) MarshalJSON() ([]byte, error) {
+	msg := ""
+	if e.Err != nil {
+		msg = e.Err.Error()
+	}
+	return json.Marshal(struct {
+		User  string `json:"user"`
+		Repo  string `json:"repo"`
+		Phase string `json:"phase"`
+		Err   string `json:"error"`
+	}{e.User, e.Repo, e.Phase, msg})
+}
+
+// The repoErrors type accumulates the RepoErrors found during a crawl.  It
+// can safely be used from multiple goroutines (and methods on a nil
+// value do nothing).
+type repoErrors struct {
+	mutex  sync.Mutex
+	errors []RepoError
+}
+
+func (r *repoErrors) add(err RepoError) {
+	if r == nil {
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.errors = append(r.errors, err)
+}
+
+// The has method returns true if an error was recorded for the given
+// repository.
+func (r *repoErrors) has(user string, repo string) bool {
+	if r == nil {
+		return false
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, err := range r.errors {
+		if err.User == user && err.Repo == repo {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *repoErrors) list() []RepoError {
+	if r == nil {
+		return nil
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]RepoError{}, r.errors...)
+}
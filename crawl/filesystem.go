@@ -0,0 +1,216 @@
+package crawl
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/impact/impact/parsing"
+	"github.com/impact/impact/recorder"
+)
+
+// The name of the (optional) file, within a library directory, that
+// contains the version of that library
+var versionMarker = ".impact"
+
+// The FileSystemCrawler indexes libraries stored in a directory tree on
+// disk.  Every directory containing a package.mo file is treated as a
+// library.  The version of the library is taken either from a version
+// marker file (see versionMarker) or from the name of the directory
+// (following the '<LibraryName> <Version>' convention).
+type FileSystemCrawler struct {
+	root string
+	// How long the directory tree has to stay unchanged before changes
+	// are indexed (see Watch)
+	debounce time.Duration
+}
+
+// This provides access to the contents of a directory on disk
+type fileSystemContents struct {
+	root string
+}
+
+func (f fileSystemContents) ReadFile(p string) ([]byte, error) {
+	return ioutil.ReadFile(filepath.Join(f.root, filepath.FromSlash(p)))
+}
+
+func (f fileSystemContents) ReadPrefix(p string, n int) ([]byte, bool, error) {
+	file, err := os.Open(filepath.Join(f.root, filepath.FromSlash(p)))
+	if err != nil {
+		return nil, false, err
+	}
+	defer file.Close()
+
+	// Read one more byte than needed to tell whether that is the whole file
+	data, err := ioutil.ReadAll(io.LimitReader(file, int64(n)+1))
+	if err != nil {
+		return nil, false, err
+	}
+	if len(data) > n {
+		return data[:n], false, nil
+	}
+	return data, true, nil
+}
+
+func (f fileSystemContents) ReadDir(p string) ([]entry, error) {
+	infos, err := ioutil.ReadDir(filepath.Join(f.root, filepath.FromSlash(p)))
+	if err != nil {
+		return nil, err
+	}
+
+	ret := []entry{}
+	for _, info := range infos {
+		ret = append(ret, entry{
+			Name:  info.Name(),
+			Path:  path.Join(p, info.Name()),
+			IsDir: info.IsDir(),
+		})
+	}
+	return ret, nil
+}
+
+// This function determines the version of the library stored in the given
+// directory.
+func fileSystemVersion(dir string) (string, bool) {
+	// An explicit version marker takes precedence
+	raw, err := ioutil.ReadFile(filepath.Join(dir, versionMarker))
+	if err == nil {
+		v := strings.TrimSpace(string(raw))
+		if v != "" {
+			return v, true
+		}
+	}
+
+	// Otherwise, look for a directory named '<LibraryName> <Version>'
+	base := filepath.Base(dir)
+	i := strings.LastIndex(base, " ")
+	if i == -1 {
+		return "", false
+	}
+	return trimVersionPrefix(base[i+1:]), true
+}
+
+func (c FileSystemCrawler) processLibrary(r recorder.Recorder, dir string,
+	logger CrawlLogger) {
+	rel, err := filepath.Rel(c.root, dir)
+	if err != nil {
+		rel = dir
+	}
+
+	versionString, found := fileSystemVersion(dir)
+	if !found {
+		logger.Warnf("No version found for library in %s, skipping", rel)
+		return
+	}
+
+	v, verr := parsing.NormalizeVersion(versionString)
+	if verr != nil {
+		// If not, ignore it
+		logger.Debugf("  %s: Ignoring", versionString)
+		return
+	}
+
+	logger.Debugf("Processing: %s", rel)
+	logger.Debugf("  %s: Recording", versionString)
+
+	uri := "file://" + filepath.ToSlash(dir)
+	src := fileSystemContents{root: dir}
+
+	// Formulate directory info (impact.json) for this library
+	di := extractInfo(src, c.root, filepath.Base(dir), "file://"+filepath.ToSlash(c.root),
+		"", "", "", nil, logger)
+	reportLibraryErrors(di, rel, versionString, logger)
+
+	if len(di.Libraries) == 0 {
+		logger.Infof("    No Modelica libraries found in %s", rel)
+		return
+	}
+
+	// There is no hash, archive or rating information available for
+	// libraries stored on disk
+	details := repoDetails{
+		URI:   uri,
+		Stars: -1,
+	}
+
+	recordVersion(r, di, details, v, versionRecord{}, recordPolicy{}, logger)
+	recorder.Finish(r, uri)
+}
+
+func (c FileSystemCrawler) Crawl(r recorder.Recorder, verbosity Verbosity, stdlogger *log.Logger) error {
+	logger := StandardLogger(stdlogger, verbosity)
+	info, err := os.Stat(c.root)
+	if err != nil {
+		return fmt.Errorf("Unable to read directory %s: %v", c.root, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", c.root)
+	}
+
+	for _, dir := range c.libraryDirs(logger) {
+		c.processLibrary(r, dir, logger)
+	}
+	return nil
+}
+
+// This function returns every directory under the root directory that
+// contains a library (i.e., a package.mo file).  Hidden directories (e.g.,
+// .git) are skipped and so are the sub-packages within each library.
+func (c FileSystemCrawler) libraryDirs(logger CrawlLogger) []string {
+	ret := []string{}
+	filepath.Walk(c.root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			logger.Errorf("Reading %s: %v", p, err)
+			return nil
+		}
+		if !info.IsDir() {
+			return nil
+		}
+
+		// Ignore hidden directories (e.g., .git)
+		if p != c.root && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+
+		// Does this directory contain a library?
+		_, serr := os.Stat(filepath.Join(p, "package.mo"))
+		if serr != nil {
+			return nil
+		}
+
+		ret = append(ret, p)
+
+		// Don't descend into the library (its sub-packages will also
+		// contain package.mo files)
+		return filepath.SkipDir
+	})
+	return ret
+}
+
+func (c FileSystemCrawler) String() string {
+	return "file://" + filepath.ToSlash(c.root)
+}
+
+// The MakeFileSystemCrawler function creates a crawler that indexes all
+// libraries found under the root directory.
+func MakeFileSystemCrawler(root string) (FileSystemCrawler, error) {
+	abs, err := filepath.Abs(root)
+	if err != nil {
+		return FileSystemCrawler{}, fmt.Errorf("Unable to determine absolute path of %s: %v",
+			root, err)
+	}
+
+	return FileSystemCrawler{
+		root:     abs,
+		debounce: 500 * time.Millisecond,
+	}, nil
+}
+
+var _ Crawler = (*FileSystemCrawler)(nil)
+var _ prefixReader = (*fileSystemContents)(nil)
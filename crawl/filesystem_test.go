@@ -0,0 +1,77 @@
+package crawl
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/blang/semver"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+
+	"github.com/impact/impact/recorder"
+)
+
+// This recorder simply keeps track of which versions of which libraries
+// were recorded
+type versionsRecorder struct {
+	recorder.NullRecorder
+	versions map[string][]string
+	name     string
+}
+
+func (vr *versionsRecorder) GetLibrary(name string, owner string,
+	uri string) recorder.LibraryRecorder {
+	vr.name = name
+	return vr
+}
+
+func (vr *versionsRecorder) AddVersion(v semver.Version) recorder.VersionRecorder {
+	vr.versions[vr.name] = append(vr.versions[vr.name], v.String())
+	return vr
+}
+
+func writeFile(c C, name string, contents string) {
+	err := os.MkdirAll(filepath.Dir(name), 0755)
+	NoError(c, err)
+	err = ioutil.WriteFile(name, []byte(contents), 0644)
+	NoError(c, err)
+}
+
+func TestFileSystem(t *testing.T) {
+	Convey("Testing file system crawler", t, func(c C) {
+		root, err := ioutil.TempDir("", "impact")
+		NoError(c, err)
+		defer os.RemoveAll(root)
+
+		writeFile(c, filepath.Join(root, "Foo 1.2", "package.mo"), `within;
+package Foo
+  annotation(uses(Modelica(version="3.2.1")));
+end Foo;`)
+		writeFile(c, filepath.Join(root, "Foo 1.2", "Sub", "package.mo"), `within Foo;
+package Sub
+end Sub;`)
+		writeFile(c, filepath.Join(root, "libs", "Bar", "package.mo"), `within;
+package Bar
+end Bar;`)
+		writeFile(c, filepath.Join(root, "libs", "Bar", ".impact"), "0.5.0\n")
+		writeFile(c, filepath.Join(root, "Unversioned", "package.mo"), `within;
+package Unversioned
+end Unversioned;`)
+
+		logger := log.New(ioutil.Discard, "", 0)
+		cr, err := MakeFileSystemCrawler(root)
+		NoError(c, err)
+
+		rec := &versionsRecorder{versions: map[string][]string{}}
+		err = cr.Crawl(rec, Quiet, logger)
+		NoError(c, err)
+
+		Equals(c, len(rec.versions), 2)
+		Resembles(c, rec.versions["Foo"], []string{"1.2.0"})
+		Resembles(c, rec.versions["Bar"], []string{"0.5.0"})
+	})
+}
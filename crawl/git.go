@@ -0,0 +1,240 @@
+package crawl
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/impact/impact/parsing"
+	"github.com/impact/impact/recorder"
+)
+
+// The GitCrawler indexes repositories that can be cloned with git but
+// aren't hosted anywhere with an API we support.  The tags of each
+// repository are listed with 'git ls-remote' and each tag is then
+// shallow cloned so that its working tree can be inspected.  Because
+// there is no way of downloading an archive of a tag, the archive URLs
+// recorded are of the form git+<url>@<tag>.
+type GitCrawler struct {
+	tagFilter
+	urls []string
+}
+
+// A tag found in a remote repository
+type gitTag struct {
+	Name string
+	Sha  string
+}
+
+// This function runs git (in the given directory, if any) and returns
+// whatever it writes to standard output.
+func runGit(dir string, args ...string) (string, error) {
+	if dir != "" {
+		args = append([]string{"-C", dir}, args...)
+	}
+	cmd := exec.Command("git", args...)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err := cmd.Run()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			return "", err
+		}
+		return "", fmt.Errorf("%v: %s", err, msg)
+	}
+	return stdout.String(), nil
+}
+
+// This function splits a clone URL into the owner and name of the
+// repository (e.g., https://example.com/owner/Lib.git yields owner and
+// Lib) along with the URI of the owner.
+func gitRepoName(u string) (owner string, name string, owner_uri string) {
+	trimmed := strings.TrimSuffix(strings.TrimSuffix(u, "/"), ".git")
+	i := strings.LastIndexAny(trimmed, "/:")
+	if i == -1 {
+		return "", trimmed, ""
+	}
+	name = trimmed[i+1:]
+	owner_uri = trimmed[:i]
+	j := strings.LastIndexAny(owner_uri, "/:")
+	owner = owner_uri[j+1:]
+	return owner, name, owner_uri
+}
+
+// This function lists the tags of the remote repository along with the
+// commit each tag refers to.  Annotated tags are listed twice by
+// 'git ls-remote', once as the tag object and once (with a ^{} suffix) as
+// the commit it points to.  It is the latter we are interested in.
+func (c GitCrawler) listTags(u string) ([]gitTag, error) {
+	out, err := runGit("", "ls-remote", "--tags", u)
+	if err != nil {
+		return nil, err
+	}
+
+	shas := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 || !strings.HasPrefix(fields[1], "refs/tags/") {
+			continue
+		}
+		name := strings.TrimPrefix(fields[1], "refs/tags/")
+		if strings.HasSuffix(name, "^{}") {
+			shas[strings.TrimSuffix(name, "^{}")] = fields[0]
+			continue
+		}
+		if _, exists := shas[name]; !exists {
+			shas[name] = fields[0]
+		}
+	}
+
+	names := []string{}
+	for name := range shas {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	ret := []gitTag{}
+	for _, name := range names {
+		ret = append(ret, gitTag{Name: name, Sha: shas[name]})
+	}
+	return ret, nil
+}
+
+// This function returns the URL recorded for an archive of the given tag.
+func gitArchiveURL(u string, tag string) string {
+	return fmt.Sprintf("git+%s@%s", u, tag)
+}
+
+func (c GitCrawler) processVersion(r recorder.Recorder, u string, versionString string,
+	tag gitTag, logger CrawlLogger) {
+
+	v, verr := parsing.NormalizeWith(c.scheme, versionString)
+	if verr != nil {
+		// If not, ignore it
+		logger.Debugf("  %s: Ignoring", versionString)
+		return
+	}
+
+	logger.Debugf("  %s: Recording", versionString)
+
+	dir, err := ioutil.TempDir("", "impact-git")
+	if err != nil {
+		logger.Errorf("Unable to create directory to clone %s into: %v", u, err)
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	_, err = runGit("", "-c", "advice.detachedHead=false", "clone", "--quiet",
+		"--depth", "1", "--branch", tag.Name, u, dir)
+	if err != nil {
+		logger.Errorf("Cloning tag %s of %s: %v", tag.Name, u, err)
+		return
+	}
+
+	var date time.Time
+	out, err := runGit(dir, "log", "-1", "--format=%cI")
+	if err == nil {
+		date, err = time.Parse(time.RFC3339, strings.TrimSpace(out))
+	}
+	if err != nil {
+		logger.Warnf("Unable to determine date of tag %s of %s: %v", tag.Name, u, err)
+	}
+
+	owner, name, owner_uri := gitRepoName(u)
+	src := fileSystemContents{root: dir}
+
+	// Formulate directory info (impact.json) for this version of this repository
+	di := extractInfo(src, owner, name, owner_uri, "", "", "", nil, logger)
+	reportLibraryErrors(di, u, tag.Name, logger)
+
+	if len(di.Libraries) == 0 {
+		logger.Infof("    No Modelica libraries found in repository %s:%s",
+			u, versionString)
+		return
+	}
+
+	// There is no rating information available for arbitrary git
+	// repositories
+	details := repoDetails{
+		URI:    u,
+		GitURL: u,
+		Stars:  -1,
+	}
+
+	archive := gitArchiveURL(u, tag.Name)
+	recordVersion(r, di, details, v,
+		versionRecord{sha: tag.Sha, tag: tag.Name, date: date, tarurl: archive, zipurl: archive},
+		c.policy(nil), logger)
+}
+
+func (c GitCrawler) Crawl(r recorder.Recorder, verbosity Verbosity, stdlogger *log.Logger) error {
+	logger := StandardLogger(stdlogger, verbosity)
+	tlogger := withLevel(logger, TagLevel)
+	c.hook = c.hook.start(nil)
+
+	for _, u := range c.urls {
+		logger.Debugf("Processing: %s", u)
+
+		tags, err := c.listTags(u)
+		if err != nil {
+			logger.Errorf("Getting tags for repository %s: %v", u, err)
+			continue
+		}
+
+		owner, name, _ := gitRepoName(u)
+
+		// Loop over the tags
+		for _, tag := range tags {
+			tlogger.Debugf("Processing tag %s", tag.Name)
+			versionString, verdict := c.accept(owner, name, tag.Name)
+			switch verdict {
+			case tagUnmapped:
+				tlogger.Debugf("  %s: Skipping tag", tag.Name)
+				continue
+			case tagExcluded:
+				continue
+			case tagPrerelease:
+				tlogger.Debugf("  %s: Ignoring pre-release", versionString)
+				continue
+			}
+
+			c.processVersion(r, u, versionString, tag, tlogger)
+			if err := c.hook.failure(); err != nil {
+				return err
+			}
+		}
+		recorder.Finish(r, u)
+	}
+	return nil
+}
+
+func (c GitCrawler) String() string {
+	return "git:" + strings.Join(c.urls, ",")
+}
+
+// The MakeGitCrawler function creates a crawler for the repositories that
+// can be cloned from the given URLs.  This requires git to be installed.
+func MakeGitCrawler(urls []string) (GitCrawler, error) {
+	if len(urls) == 0 {
+		return GitCrawler{}, fmt.Errorf("No repositories specified")
+	}
+	_, err := exec.LookPath("git")
+	if err != nil {
+		return GitCrawler{}, fmt.Errorf("Unable to find git: %v", err)
+	}
+
+	return GitCrawler{
+		tagFilter: defaultTagFilter(),
+		urls:      urls,
+	}, nil
+}
+
+var _ Crawler = (*GitCrawler)(nil)
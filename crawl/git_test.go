@@ -0,0 +1,87 @@
+package crawl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+
+	"github.com/impact/impact/recorder"
+)
+
+func TestGitCrawler(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	Convey("Testing git crawler", t, func(c C) {
+		root, err := ioutil.TempDir("", "impact")
+		NoError(c, err)
+		defer os.RemoveAll(root)
+
+		repo := filepath.Join(root, "Foo.git")
+		git := func(args ...string) string {
+			out, err := runGit(repo, append([]string{"-c", "user.name=Test",
+				"-c", "user.email=test@example.com"}, args...)...)
+			NoError(c, err)
+			return out
+		}
+
+		writeFile(c, filepath.Join(repo, "Foo", "package.mo"), `within;
+package Foo
+  annotation(uses(Modelica(version="3.2.1")));
+end Foo;`)
+		git("init", "--quiet")
+		git("add", "-A")
+		git("commit", "--quiet", "-m", "First")
+		git("tag", "v1.0.0")
+		git("tag", "-a", "-m", "Second", "v1.1.0")
+		git("tag", "not-a-version")
+		sha := git("rev-parse", "HEAD")
+
+		u := "file://" + filepath.ToSlash(repo)
+		cr, err := MakeGitCrawler([]string{u})
+		NoError(c, err)
+
+		rec := recorder.NewMemoryRecorder()
+		err = cr.Crawl(rec, Quiet, log.New(ioutil.Discard, "", 0))
+		NoError(c, err)
+
+		foo := rec.Find("Foo")
+		NotNil(c, foo)
+		Equals(c, foo.Repository, u)
+		Equals(c, len(foo.Versions), 2)
+
+		v := foo.Versions["1.1.0"]
+		NotNil(c, v)
+		Equals(c, v.Hash+"\n", sha)
+		Equals(c, v.TarballURL, "git+"+u+"@v1.1.0")
+		Equals(c, v.ZipballURL, "git+"+u+"@v1.1.0")
+		Equals(c, v.Path, "Foo")
+		IsFalse(c, v.ReleaseDate.IsZero())
+		Equals(c, len(v.Dependencies), 1)
+
+		// A failing hook can abort the crawl
+		cr.SetVersionHook(func(library string, version string, vr recorder.VersionRecorder) error {
+			return fmt.Errorf("Unable to load %s", library)
+		}, true)
+		rec = recorder.NewMemoryRecorder()
+		err = cr.Crawl(rec, Quiet, log.New(ioutil.Discard, "", 0))
+		IsError(c, err)
+		Equals(c, len(rec.Find("Foo").Versions), 1)
+
+		owner, name, owner_uri := gitRepoName("https://example.com/owner/Lib.git")
+		Equals(c, owner, "owner")
+		Equals(c, name, "Lib")
+		Equals(c, owner_uri, "https://example.com/owner")
+
+		_, err = MakeGitCrawler(nil)
+		IsError(c, err)
+	})
+}
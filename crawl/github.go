@@ -1,11 +1,19 @@
 package crawl
 
 import (
+	"context"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"net/http"
 	"os"
 	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
+	"github.com/blang/semver"
 	"github.com/google/go-github/github"
 	"golang.org/x/oauth2"
 
@@ -14,262 +22,1772 @@ import (
 )
 
 type GitHubCrawler struct {
-	token   string
-	pattern string
-	re      *regexp.Regexp
-	user    string
+	tagFilter
+	token string
+	// File to read the token from (if no token was given)
+	tokenFile string
+	// GitHub App installation to authenticate as (instead of using token)
+	app *gitHubApp
+	// A repository is crawled if it matches any of patterns and none of
+	// excludes
+	patterns   []string
+	res        []*regexp.Regexp
+	excludes   []string
+	excludeRes []*regexp.Regexp
+	// Whether the patterns are matched against owner/repo (rather than just
+	// the name of the repository)
+	matchFullName bool
+	// Users (or organizations) whose repositories are crawled
+	users []string
+	// Only these repositories are crawled (if any are given)
+	repoNames []string
+	// Search query for repositories to crawl (instead of users)
+	query string
+	// User currently being crawled
+	user string
+	// Number of repositories to process concurrently
+	concurrency int
+	// Number of tags (within a repository) to process concurrently
+	tagConcurrency int
+	// Maximum total time to wait for rate limits to reset
+	maxWait time.Duration
+	// Number of retries (and initial delay) for transient errors
+	retries    int
+	retryDelay time.Duration
+	// Only versions in this range are indexed (if specified)
+	rangeSpec    string
+	versionRange semver.Range
+	// Whether to index the HEAD of the default branch
+	includeHead bool
+	// Whether to index releases whose tags aren't listed
+	releases bool
+	// Whether to skip lightweight tags (i.e., only index annotated tags)
+	annotatedOnly bool
+	// Whether to index forks in addition to their source
+	indexForks bool
+	// Whether renamed repositories are indexed under their new name
+	followRenames bool
+	// Maximum number of versions (the newest ones) to index for each
+	// repository (zero means there is no maximum)
+	maxVersions int
+	// Whether to just report what would be indexed
+	dryRun bool
+	// Whether to read just the header of each package (where possible)
+	headersOnly bool
+	// What to do about versions that have already been recorded
+	duplicates DuplicatePolicy
+	// Whether to use archives attached to releases
+	assets AssetPolicy
+	// Whether to index archived repositories
+	archived ArchivedPolicy
+	// Used to make all requests (if nil, http.DefaultClient is used)
+	httpClient *http.Client
+	// Whether to download the tarball of each version to record its
+	// checksum (and the client used to download it)
+	checksums bool
+	download  *http.Client
+	// Whether to compute the size of each version from its files (rather
+	// than using the size of the repository)
+	exactSizes bool
+	// Whether to record the release notes of each version (and how long
+	// they may be)
+	releaseNotes bool
+	maxNotes     int
+	// Used to rewrite archive URLs (if any)
+	rewrite URLRewriter
+	// Used to compute other URLs each tarball can be downloaded from
+	mirrors []URLRewriter
+	// Decides which libraries are indexed (if not nil)
+	filter VersionFilter
+	// Maximum time to spend processing a single repository (zero means
+	// there is no maximum) and what was found in the repository currently
+	// being processed (if there is a maximum)
+	repoTimeout time.Duration
+	pending     *pendingRecords
+	// Owner URIs to record for particular repositories (keyed by
+	// owner/repo in lower case, e.g., for mirrors)
+	owners map[string]string
+	// Minimum number of stars a repository needs to be indexed
+	minStars int
+	// Repositories with any of these topics aren't indexed and, if there
+	// are any required topics, only repositories with one of them are
+	excludedTopics []string
+	requiredTopics []string
+	// Minimum number of bytes of Modelica code (as detected by GitHub) a
+	// repository needs to be indexed and whether Modelica must be its
+	// primary language
+	minModelicaBytes int
+	primaryLanguage  bool
+	// Repositories not pushed to since this time are taken from baseline
+	since    time.Time
+	baseline Baseline
+	// Where to keep track of the repositories processed so far (and where
+	// to take the repositories already processed from when resuming)
+	checkpointFile string
+	resumeFrom     Baseline
+	checkpoint     *checkpoint
+	// How often to log the progress of the crawl (if at all)
+	heartbeat time.Duration
+	// Number of repositories to request per page (and the first page)
+	perPage   int
+	startPage int
+	// Whether repositories are listed using the GraphQL API (and what was
+	// fetched along with them, see SetGraphQL)
+	graphQL    bool
+	prefetched prefetchedRepos
+	// Repositories that didn't contribute any versions
+	empty *emptyRepositories
+	// Repositories that were skipped because of errors
+	failures *repoErrors
+	// Statistics about the current crawl
+	stats *CrawlStats
+	// Where metrics about crawls are accumulated (if anywhere)
+	metrics *CrawlMetrics
+	// File used to cache information between crawls (if any)
+	cacheFile string
+	cache     *crawlCache
+	// Version of impact recorded as having generated the output (if any)
+	generatorVersion string
+	// Publishers whose signatures of impact.json are trusted
+	publisherKeys PublisherKeys
 }
 
-var exclusionList []string
+// This function reads a token from the named file (ignoring any
+// surrounding whitespace).  If there is no such file (or it is empty), the
+// empty string is returned so that other sources of a token can be used.
+func readTokenFile(name string, logger CrawlLogger) string {
+	if name == "" {
+		return ""
+	}
+	raw, err := ioutil.ReadFile(name)
+	if err != nil {
+		logger.Warnf("Unable to read token file %s: %v", name, err)
+		return ""
+	}
+	token := strings.TrimSpace(string(raw))
+	if token == "" {
+		logger.Warnf("Token file %s is empty", name)
+	}
+	return token
+}
 
-func init() {
-	exclusionList = []string{
-		"modelica-3rdparty:BrineProp:0.1.9",  // Directory structure is a mess
-		"modelica-3rdparty:ModelicaDEVS:1.0", // Self reference (and invalid at that)
-		"modelica-3rdparty:NCLib:0.82",       // Missing package.mo
+// This function returns the string pointed to by s (or the empty string
+// if s is nil).
+func stringOf(s *string) string {
+	if s == nil {
+		return ""
 	}
+	return *s
 }
 
-func exclude(user string, reponame string, tagname string) bool {
-	str := fmt.Sprintf("%s:%s:%s", user, reponame, tagname)
-	for _, ex := range exclusionList {
-		//log.Printf("Comparing '%s' to '%s'", ex, str)
-		if ex == str {
-			return true
-		}
+// This function returns the int pointed to by i (or zero if i is nil).
+func intOf(i *int) int {
+	if i == nil {
+		return 0
 	}
-	return false
+	return *i
+}
+
+// The candidate type describes a version found in a repository (e.g., as
+// a tag) that may be recorded.
+type candidate struct {
+	version string // Version string (before normalization)
+	sha     string // Commit the version refers to
+	tarurl  string // Archives of the version (before rewriting)
+	zipurl  string
+	tag     string // Tag of the version (if any)
 }
 
-func (c GitHubCrawler) processVersion(client *github.Client, r recorder.Recorder,
-	altname string, repo github.Repository, versionString string, sha string, tarurl string,
-	zipurl string, verbose bool, logger *log.Logger) {
+// This function records the given version of a repository (if it is a
+// valid version that contains at least one library).  It returns true if
+// the version was recorded.
+func (c GitHubCrawler) processVersion(client *GitHubClient, r recorder.Recorder,
+	altname string, repo github.Repository, found candidate, logger CrawlLogger) bool {
+	versionString := found.version
+	sha := found.sha
 
+	// Make sure we have all the information we need about this repository
+	if repo.Name == nil {
+		logger.Warnf("Skipping version %s of repository with no name", versionString)
+		return false
+	}
 	rname := *repo.Name
 
-	v, verr := parsing.NormalizeVersion(versionString)
+	if repo.Owner == nil || repo.Owner.Login == nil {
+		logger.Warnf("Skipping %s:%s because owner is not specified",
+			rname, versionString)
+		return false
+	}
+
+	v, verr := parsing.NormalizeWith(c.scheme, versionString)
 	if verr != nil {
 		// If not, ignore it
-		if verbose {
-			logger.Printf("  %s: Ignoring", versionString)
+		logger.Debugf("  %s: Ignoring", versionString)
+		count(&c.stats.VersionsIgnored)
+		if c.dryRun {
+			logger.Infof("    Would skip %s:%s (not a semantic version)", rname, versionString)
 		}
-		return
+		return false
 	}
 
-	if verbose {
-		logger.Printf("  %s: Recording", versionString)
+	if c.versionRange != nil && !c.versionRange(v) {
+		logger.Debugf("  %s: Ignoring, not in range '%s'", versionString, c.rangeSpec)
+		if c.dryRun {
+			logger.Infof("    Would skip %s:%s (not in range '%s')", rname, versionString, c.rangeSpec)
+		}
+		return false
 	}
 
+	logger.Debugf("  %s: Recording", versionString)
+
 	ownerid := *repo.Owner.Login
-	// Formulate directory info (impact.json) for this version of this repository
-	di := ExtractInfo(client, ownerid, altname, repo, sha, versionString, verbose, logger)
+
+	// If this version was already processed (at the same SHA and with
+	// the same settings) during a previous crawl, the cached information
+	// can be reused
+	key := fmt.Sprintf("%s/%s/%s", ownerid, rname, versionString)
+	settings := extractionSettings(c.headersOnly, c.publisherKeys)
+	di, cached := c.cache.version(key, sha, settings)
+	if cached {
+		logger.Debugf("    Using cached information for %s", key)
+	} else {
+		// Formulate directory info (impact.json) for this version of this repository
+		di = extractGitHubInfo(client, ownerid, altname, repo, sha, versionString,
+			c.headersOnly, c.publisherKeys, logger)
+
+		// If the crawl was cancelled, the information may be incomplete
+		if client.ctx.Err() != nil {
+			return false
+		}
+		if len(di.Libraries) > 0 {
+			c.cache.setVersion(key, sha, settings, di)
+		}
+	}
+	reportLibraryErrors(di, ownerid+"/"+rname, versionString, logger)
+
+	if owner, ok := c.owners[strings.ToLower(ownerid+"/"+rname)]; ok {
+		logger.Debugf("    Recording %s/%s as owned by %s", ownerid, rname, owner)
+		di.OwnerURI = owner
+	}
 
 	if len(di.Libraries) == 0 {
-		logger.Printf("    No Modelica libraries found in repository %s:%s",
+		logger.Infof("    No Modelica libraries found in repository %s:%s",
 			rname, versionString)
+		return false
+	}
+
+	di.Libraries = c.acceptedLibraries(di.Libraries, v, repo, logger)
+	if len(di.Libraries) == 0 {
+		return false
+	}
+
+	if repo.HTMLURL == nil {
+		logger.Errorf("Cannot index because HTMLURL is not specified")
+		return false
+	}
+
+	details := repoDetails{
+		URI:         *repo.HTMLURL,
+		Description: stringOf(repo.Description),
+		GitURL:      stringOf(repo.GitURL),
+		Stars:       -1,
+		Forks:       intOf(repo.ForksCount),
+		OpenIssues:  intOf(repo.OpenIssuesCount),
+	}
+	if repo.StargazersCount != nil {
+		details.Stars = *repo.StargazersCount
+	}
+
+	tarurl := rewriteURL(c.rewrite, found.tarurl)
+	zipurl := rewriteURL(c.rewrite, found.zipurl)
+	mirrors := mirrorURLs(c.mirrors, found.tarurl, tarurl)
+
+	date := commitDate(client, ownerid, rname, sha, logger)
+	checksums := c.archiveChecksums(client, key, sha, found.tarurl, logger)
+	size := c.versionSize(client, key, ownerid, rname, sha, repo, logger)
+	notes := c.versionNotes(client, key, ownerid, rname, found.tag, sha, logger)
+
+	replace := c.replaceDuplicate(client, repo, sha, logger)
+	c.pending.add(func() {
+		recordVersion(r, di, details, v,
+			versionRecord{sha: sha, tag: found.tag, date: date, size: size, notes: notes,
+				tarurl: tarurl, zipurl: zipurl, mirrors: mirrors, checksums: checksums},
+			c.policy(replace), logger)
+		count(&c.stats.VersionsRecorded)
+		c.metrics.versionRecorded()
+	})
+	return true
+}
+
+// This function returns the date of the given commit (the zero time if
+// the date cannot be determined).  The committer date is used since this
+// reflects when the commit was actually made part of the repository.
+func commitDate(client *GitHubClient, owner string, rname string, sha string,
+	logger CrawlLogger) time.Time {
+	var commit *github.Commit
+	err := client.call(func() (err error) {
+		commit, _, err = client.client.Git.GetCommit(owner, rname, sha)
 		return
+	})
+	if err != nil {
+		logger.Warnf("Unable to determine date of commit %s in %s/%s: %v", sha, owner, rname, err)
+		return time.Time{}
+	}
+	if commit == nil {
+		return time.Time{}
+	}
+	if commit.Committer != nil && commit.Committer.Date != nil {
+		return *commit.Committer.Date
 	}
+	if commit.Author != nil && commit.Author.Date != nil {
+		return *commit.Author.Date
+	}
+	return time.Time{}
+}
 
-	// Loop over all libraries present in this repository
-	for _, lib := range di.Libraries {
-		if verbose {
-			logger.Printf("    Processing library %s @ %s", lib.Name, lib.Path)
+// This function returns the function used (see recordVersion) to decide
+// whether an existing version should be replaced by the version at the
+// given commit.
+func (c GitHubCrawler) replaceDuplicate(client *GitHubClient, repo github.Repository, sha string,
+	logger CrawlLogger) func() bool {
+	switch c.duplicates {
+	case SkipDuplicates:
+		return func() bool { return false }
+	case PreferDefaultBranch:
+		// Only check (at most) once, no matter how many libraries there are
+		checked := false
+		reachable := false
+		return func() bool {
+			if !checked {
+				reachable = c.onDefaultBranch(client, repo, sha, logger)
+				checked = true
+			}
+			return reachable
 		}
+	}
+	return nil
+}
 
-		if repo.HTMLURL == nil {
-			logger.Printf("Error: Cannot index because HTMLURL is not specified")
-			continue
-		}
+// This function determines whether the given commit is reachable from
+// the HEAD of the default branch of the repository.
+func (c GitHubCrawler) onDefaultBranch(client *GitHubClient, repo github.Repository, sha string,
+	logger CrawlLogger) bool {
+	branchName := "master"
+	if repo.DefaultBranch != nil && *repo.DefaultBranch != "" {
+		branchName = *repo.DefaultBranch
+	}
+	owner := ""
+	if repo.Owner != nil {
+		owner = stringOf(repo.Owner.Login)
+	}
+	rname := stringOf(repo.Name)
 
-		libr := r.GetLibrary(lib.Name, *repo.HTMLURL, di.OwnerURI)
+	var comp *github.CommitsComparison
+	err := client.call(func() (err error) {
+		comp, _, err = client.client.Repositories.CompareCommits(owner, rname, branchName, sha)
+		return
+	})
+	if err != nil {
+		logger.Errorf("Comparing %s with branch %s of repository %s/%s: %v",
+			sha, branchName, owner, rname, err)
+		return false
+	}
 
-		if repo.Description != nil {
-			libr.SetDescription(*repo.Description)
-		}
+	// If the commit is "behind" the branch (or identical to it), then it
+	// is reachable from the branch
+	status := ""
+	if comp != nil {
+		status = stringOf(comp.Status)
+	}
+	return status == "behind" || status == "identical"
+}
 
-		libr.SetHomepage(*repo.HTMLURL)
-		libr.SetRepository(*repo.GitURL, "git")
-		libr.SetStars(*repo.StargazersCount)
-		libr.SetEmail(di.Email)
+func (c GitHubCrawler) Crawl(r recorder.Recorder, verbosity Verbosity, logger *log.Logger) error {
+	_, err := c.CrawlWithOptions(DefaultCrawlOptions(r, verbosity, logger))
+	return err
+}
+
+// The CrawlContext method is like Crawl except that the crawl is subject
+// to the given context.  If the context is cancelled (or its deadline
+// passes), crawling stops and ctx.Err() is returned.  Anything recorded up
+// to that point remains in the recorder (but no version is ever partially
+// recorded).
+func (c GitHubCrawler) CrawlContext(ctx context.Context, r recorder.Recorder, verbosity Verbosity,
+	logger *log.Logger) error {
+	opts := DefaultCrawlOptions(r, verbosity, logger)
+	opts.Context = ctx
+	_, err := c.CrawlWithOptions(opts)
+	return err
+}
 
-		vr := libr.AddVersion(v)
+// The CrawlWithStats method is like CrawlContext except that it also
+// returns statistics about the crawl (even if the crawl fails).  All
+// messages are passed to the given CrawlLogger.
+func (c GitHubCrawler) CrawlWithStats(ctx context.Context, r recorder.Recorder,
+	logger CrawlLogger) (CrawlStats, error) {
+	return c.CrawlWithOptions(CrawlOptions{
+		Recorder: r,
+		Logger:   logger,
+		Context:  ctx,
+	})
+}
+
+// The CrawlWithOptions method crawls the repositories of this crawler
+// using the given options (which take precedence over the settings of the
+// crawler itself).  It returns statistics about the crawl (even if the
+// crawl fails).  Repositories that couldn't be processed (see RepoError)
+// are skipped and listed in the Failures of the statistics.
+func (c GitHubCrawler) CrawlWithOptions(opts CrawlOptions) (CrawlStats, error) {
+	c.stats = &CrawlStats{}
+	c.metrics = opts.Metrics
+	start := time.Now()
+	r := opts.Recorder
 
-		vr.SetPath(lib.Path, lib.IsFile)
-		vr.SetHash(sha)
-		vr.SetTarballURL(tarurl)
-		vr.SetZipballURL(zipurl)
+	// A version hook may abort the crawl (see SetVersionHook)
+	ctx, cancel := context.WithCancel(opts.context())
+	defer cancel()
+	c.hook = c.hook.start(cancel)
+	logger := opts.logger()
 
-		for _, dep := range lib.Dependencies {
-			vr.AddDependency(dep.Name, dep.Version)
+	if opts.Concurrency > 0 {
+		c.concurrency = opts.Concurrency
+	}
+	if opts.TagConcurrency > 0 {
+		c.tagConcurrency = opts.TagConcurrency
+	}
+	if opts.VersionRange != "" {
+		err := c.SetVersionRange(opts.VersionRange)
+		if err != nil {
+			return *c.stats, fmt.Errorf("Invalid version range '%s': %v", opts.VersionRange, err)
 		}
 	}
-}
+	if !opts.Since.IsZero() {
+		c.SetSince(opts.Since, opts.Baseline)
+	}
+	if opts.Heartbeat > 0 {
+		c.heartbeat = opts.Heartbeat
+	}
 
-func (c GitHubCrawler) Crawl(r recorder.Recorder, verbose bool, logger *log.Logger) error {
 	// Start with whatever token we were given when this crawler was created
 	token := c.token
 
-	// If a token wasn't provided with the crawler, look for a token
-	// as an environment variable
-	if c.token == "" {
+	// If a token wasn't provided with the crawler, look for a token file
+	// and then for a token as an environment variable
+	if token == "" {
+		token = readTokenFile(c.tokenFile, logger)
+	}
+	if token == "" {
 		token = os.Getenv("GITHUB_TOKEN")
 	}
 
-	// Create a client assuming no authentication
-	client := github.NewClient(nil)
+	// Load information cached by previous crawls
+	if c.cacheFile != "" {
+		cache, err := loadCache(c.cacheFile, logger)
+		if err != nil {
+			return *c.stats, err
+		}
+		c.cache = cache
+	}
+
+	// Load the checkpoint left by an interrupted crawl (if any)
+	if c.checkpointFile != "" {
+		cp, err := loadCheckpoint(c.checkpointFile)
+		if err != nil {
+			return *c.stats, err
+		}
+		c.checkpoint = cp
+	}
+
+	// Start with the HTTP client we were given (or the default HTTP
+	// client), i.e., no authentication
+	hc := c.httpClient
 
-	// If we have a token, re-initialize the client with
-	// authentication
-	if token != "" {
+	// If we have a token (or a GitHub App installation), use an HTTP
+	// client with authentication.  The oauth2 package makes its requests
+	// with whatever client is stored in the context.
+	octx := ctx
+	if hc != nil {
+		octx = context.WithValue(ctx, oauth2.HTTPClient, hc)
+	}
+	if c.app != nil {
+		hc = oauth2.NewClient(octx, c.app.tokenSource(octx))
+	} else if token != "" {
 		ts := oauth2.StaticTokenSource(
 			&oauth2.Token{AccessToken: token},
 		)
-		tc := oauth2.NewClient(oauth2.NoContext, ts)
+		hc = oauth2.NewClient(octx, ts)
+	}
 
-		client = github.NewClient(tc)
+	// The authenticated client only keeps the transport of the client we
+	// were given, so the rest of it (e.g., its timeout) is restored
+	if c.httpClient != nil && hc != c.httpClient {
+		cp := *c.httpClient
+		cp.Transport = hc.Transport
+		hc = &cp
 	}
 
-	lopts := github.RepositoryListOptions{}
-	lopts.Page = 1
-	lopts.PerPage = 10
+	// Archives are downloaded without the cache (they would bloat it)
+	c.download = hc
+	base := c.cache.client(hc)
+	client := github.NewClient(contextClient(ctx, base))
+
+	// Make all requests subject to rate limit handling
+	gc := NewGitHubClient(client, c.maxWait, logger)
+	gc.SetRetries(c.retries, c.retryDelay)
+	gc.SetContext(ctx)
+	gc.base = base
+	gc.metrics = c.metrics
+
+	c.empty = &emptyRepositories{}
+	c.failures = &repoErrors{}
+
+	// During a dry run, nothing is actually recorded
+	var dry *dryRunRecorder
+	if c.dryRun {
+		dry = newDryRunRecorder(logger)
+		r = dry
+	}
+
+	// Record when (and by what) the crawl was done
+	recorder.Stamp(r, start, c.generatorVersion)
+
+	// When crawling several users (or searching), the same library may be
+	// found under more than one of them
+	if len(c.users) > 1 || c.query != "" {
+		r = newDuplicatesRecorder(r, logger)
+	}
+
+	// The progress is reported from another goroutine
+	if c.heartbeat > 0 {
+		logger = synchronizedLogger(logger)
+	}
+	stop := startHeartbeat(ctx, c.heartbeat, c.stats, logger)
+
+	var err error
+	if c.query != "" {
+		err = c.crawlSearch(gc, r, logger)
+	}
+	for _, user := range c.users {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+			break
+		}
+		uc := c
+		uc.user = user
+		err = uc.crawlUser(gc, r, logger)
+		if err != nil {
+			break
+		}
+	}
+
+	stop()
+
+	// If the crawl was aborted by the version hook, that is the reason
+	// it failed
+	if herr := c.hook.failure(); herr != nil {
+		err = herr
+	}
 
-	if verbose {
-		logger.Printf("Fetching repositories for %s", c.user)
+	c.empty.report(logger)
+	if dry != nil {
+		dry.summary()
 	}
+
+	// Save the cache, even if the crawl failed, so that whatever was
+	// learned is available next time
+	serr := c.cache.save()
+	if serr != nil {
+		logger.Errorf("Saving cache: %v", serr)
+		if err == nil {
+			err = serr
+		}
+	}
+
+	// The checkpoint is only needed if the crawl has to be resumed
+	if err == nil {
+		serr = c.checkpoint.remove()
+	} else {
+		serr = c.checkpoint.save()
+	}
+	if serr != nil {
+		logger.Errorf("Updating checkpoint: %v", serr)
+		if err == nil {
+			err = serr
+		}
+	}
+
+	c.stats.APICalls = gc.Calls()
+	c.stats.Failures = c.failures.list()
+	c.metrics.crawled(time.Since(start))
+	return *c.stats, err
+}
+
+// This function indexes all the repositories of the current user (or
+// just the ones named, see SetRepositories).
+func (c GitHubCrawler) crawlUser(gc *GitHubClient, r recorder.Recorder,
+	logger CrawlLogger) error {
+	list := c.listRepos
+	if len(c.repoNames) > 0 {
+		list = c.namedRepos
+	} else if c.graphQL {
+		repos, prefetched, err := c.graphQLRepos(gc, logger)
+		if err != nil {
+			return err
+		}
+		c.prefetched = prefetched
+		return c.crawlRepos(gc, r, repos, logger)
+	}
+	repos, err := list(gc, logger)
+	if err != nil {
+		return err
+	}
+	return c.crawlRepos(gc, r, repos, logger)
+}
+
+// This function returns all the repositories of the current user.  Each
+// page of the listing is retried like any other request (see
+// SetRetries).  If a page still can't be listed, the repositories on it
+// are skipped (which is logged and recorded as a RepoError) and the
+// listing continues with the next page, so that the work done for the
+// other pages isn't lost.  Only if the first page can't be listed (or
+// nothing is known about the pages that follow) does the listing stop.
+func (c GitHubCrawler) listRepos(gc *GitHubClient, logger CrawlLogger) ([]github.Repository, error) {
+	lopts := github.RepositoryListOptions{}
+	lopts.Page = c.startPage
+	lopts.PerPage = c.perPage
+
+	logger.Debugf("Fetching repositories for %s", c.user)
 	repos := []github.Repository{}
+	// The last page (as of the most recent page that was listed)
+	last := 0
 	for {
 		// Get a list of all repositories associated with the specified
 		// organization
-		page, _, err := client.Repositories.List(c.user, &lopts)
+		var page []github.Repository
+		var resp *github.Response
+		err := gc.call(func() (err error) {
+			page, resp, err = gc.client.Repositories.List(c.user, &lopts)
+			return
+		})
+		if gc.ctx.Err() != nil {
+			return nil, gc.ctx.Err()
+		}
 		if err != nil {
-			logger.Printf("Error listing repositories for %s: %v", c.user, err)
-			return fmt.Errorf("Error listing repositories for %s: %v", c.user, err)
+			if last == 0 {
+				logger.Errorf("Listing repositories for %s: %v", c.user, err)
+				return nil, fmt.Errorf("Error listing repositories for %s: %v", c.user, err)
+			}
+			current := lopts.Page
+			if current < 1 {
+				current = 1
+			}
+			logger.Warnf("Skipping page %d (of %d) of the repositories of %s: %v",
+				current, last, c.user, err)
+			c.failures.add(RepoError{
+				User:  c.user,
+				Phase: PhaseListing,
+				Err:   fmt.Errorf("Page %d: %v", current, err),
+			})
+			if current >= last {
+				break
+			}
+			lopts.Page = current + 1
+			continue
 		}
 		repos = append(repos, page...)
-		if verbose {
-			logger.Printf("  Fetching page %d, %d entries", lopts.Page, len(page))
-		}
+		logger.Debugf("  Fetching page %d, %d entries", lopts.Page, len(page))
 
-		if len(page) == 0 {
+		// The response indicates whether there are more pages
+		if resp == nil || resp.NextPage == 0 {
 			break
 		}
-		lopts.Page = lopts.Page + 1
+		if resp.LastPage > 0 {
+			last = resp.LastPage
+		}
+		lopts.Page = resp.NextPage
 	}
+	return repos, nil
+}
+
+// This function indexes the given repositories (using as many workers as
+// the concurrency allows).
+func (c GitHubCrawler) crawlRepos(gc *GitHubClient, r recorder.Recorder,
+	repos []github.Repository, logger CrawlLogger) error {
+	atomic.AddInt64(&c.stats.ReposListed, int64(len(repos)))
+
+	// If we are processing repositories (or tags) concurrently, make sure
+	// all recording and logging is serialized
+	workers := c.concurrency
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > 1 || c.tagConcurrency > 1 {
+		r = recorder.Synchronized(r)
+		logger = synchronizedLogger(logger)
+	}
+
+	// Hand the repositories out to a bounded pool of workers.  The first
+	// error reported by any worker is returned to the caller while the
+	// others are cancelled (along with any repositories not handed out
+	// yet).
+	ctx, cancel := context.WithCancel(gc.ctx)
+	defer cancel()
+	pool := gc.withContext(ctx)
+	work := make(chan github.Repository)
+	var once sync.Once
+	var first error
+	var wg sync.WaitGroup
 
-	// Loop over all repos associated with the given owner
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for minrepo := range work {
+				// Don't start on any more repositories once the crawl
+				// has been cancelled
+				if pool.ctx.Err() != nil {
+					return
+				}
+				rlogger := logger
+				if workers > 1 {
+					rlogger = repoLogger(logger, stringOf(minrepo.Name))
+				}
+				rc := c.forRepo(minrepo)
+				err := rc.processRepoWithin(pool, r, minrepo, rlogger)
+				if err != nil {
+					once.Do(func() {
+						first = err
+						cancel()
+					})
+					return
+				}
+				rc.completed(pool, minrepo, rlogger)
+			}
+		}()
+	}
+
+feed:
 	for _, minrepo := range repos {
-		rname := *minrepo.Name
-		single, _, err := client.Repositories.Get(c.user, rname)
+		select {
+		case work <- minrepo:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	if first == nil {
+		first = gc.ctx.Err()
+	}
+	return first
+}
+
+// This function returns the name the patterns are matched against for the
+// given repository (of the user currently being crawled).
+func (c GitHubCrawler) matchedName(rname string) string {
+	if c.matchFullName {
+		return c.user + "/" + rname
+	}
+	return rname
+}
+
+// This function returns true if the given repository (of the user
+// currently being crawled) matches any of the patterns and none of the
+// exclusion patterns.
+func (c GitHubCrawler) matches(rname string) bool {
+	name := c.matchedName(rname)
+	for _, re := range c.excludeRes {
+		if re.MatchString(name) {
+			return false
+		}
+	}
+	for _, re := range c.res {
+		if re.MatchString(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// This function compiles each of the given patterns.
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	res := []*regexp.Regexp{}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
 		if err != nil {
-			logger.Printf("Unable to fetch complete details for repo %s/%s: %v",
-				c.user, rname, err)
-			continue
+			return nil, fmt.Errorf("Invalid pattern '%s': %v", pattern, err)
 		}
+		res = append(res, re)
+	}
+	return res, nil
+}
 
-		if !c.re.MatchString(rname) {
-			if verbose {
-				logger.Printf("Skipping: %s (%s), doesn't match pattern '%s'",
-					rname, *minrepo.HTMLURL, c.pattern)
-			}
-			continue
+// This function returns a summary of a set of patterns (e.g., for
+// messages).
+func patternSet(patterns []string) string {
+	if len(patterns) == 1 {
+		return patterns[0]
+	}
+	return "{" + strings.Join(patterns, ",") + "}"
+}
+
+// This function returns a summary of which repositories are crawled.
+func (c GitHubCrawler) patternSummary() string {
+	if len(c.excludes) == 0 {
+		return patternSet(c.patterns)
+	}
+	return patternSet(c.patterns) + " excluding " + patternSet(c.excludes)
+}
+
+// This function processes a single repository (as returned by the
+// repository listing) and records all versions found in its tags.
+func (c GitHubCrawler) processRepo(client *GitHubClient, r recorder.Recorder,
+	minrepo github.Repository, logger CrawlLogger) error {
+	if minrepo.Name == nil {
+		logger.Warnf("Skipping repository with no name")
+		return nil
+	}
+	rname := *minrepo.Name
+	count(&c.stats.ReposExamined)
+	c.metrics.repoProcessed()
+
+	fork := minrepo.Fork != nil && *minrepo.Fork
+
+	// Repositories completely processed before the crawl was interrupted
+	// are taken from the baseline (see SetCheckpoint)
+	if !fork && c.resume(r, minrepo, logger) {
+		return nil
+	}
+
+	// Repositories that haven't changed since the previous crawl don't need
+	// to be examined again.  This doesn't apply to forks since it is
+	// (normally) their source repository that is indexed.
+	if !fork && c.unchanged(minrepo) && c.matches(rname) {
+		c.reuse(r, minrepo, logger)
+		return nil
+	}
+
+	single, status, err := c.repository(client, rname)
+	if err != nil {
+		logger.Warnf("Unable to fetch complete details for repo %s/%s: %v",
+			c.user, rname, err)
+		c.fail(client, rname, PhaseDetails, err)
+		return nil
+	}
+	if single == nil {
+		logger.Warnf("No details returned for repo %s/%s", c.user, rname)
+		return nil
+	}
+
+	// Renamed repositories are only found because GitHub redirects
+	// requests for the old name (see SetFollowRenames)
+	if owner, name, renamed := renamedTo(c.user, rname, *single); renamed {
+		if c.followRenames {
+			logger.Infof("Repository %s/%s was renamed to %s/%s, indexing it under the new name",
+				c.user, rname, owner, name)
+			c.user = owner
+			rname = name
+		} else {
+			logger.Infof("Repository %s/%s was renamed to %s/%s", c.user, rname, owner, name)
 		}
+	}
+
+	if !c.matches(rname) {
+		count(&c.stats.ReposSkippedPattern)
+		logger.Debugf("Skipping: %s (%s), doesn't match pattern '%s'",
+			c.matchedName(rname), stringOf(minrepo.HTMLURL), c.patternSummary())
+		return nil
+	}
+
+	if !c.topicsAllowed(client, *single, logger) {
+		count(&c.stats.ReposSkippedTopics)
+		return nil
+	}
+
+	if !c.languageAllowed(client, *single, logger) {
+		count(&c.stats.ReposSkippedLanguage)
+		return nil
+	}
+
+	logger.Debugf("Processing: %s (%s, fork=%v)",
+		rname, stringOf(minrepo.HTMLURL), fork)
+
+	repo := *single
+
+	archived := status.Archived
+
+	// If this is a fork, index the "real" repository
+	if fork && single.Source != nil {
+		repo = *single.Source
+		archived = status.Source != nil && status.Source.Archived
+		logger.Debugf("Source for %s exists", stringOf(repo.Name))
+	} else {
+		logger.Debugf("No source for %s", stringOf(repo.Name))
+	}
 
-		if verbose {
-			logger.Printf("Processing: %s (%s, fork=%v)",
-				rname, *minrepo.HTMLURL, *minrepo.Fork)
+	/*
+		if orepo.Parent != nil {
+			repo = *orepo.Parent
+			log.Printf("Parent for %s exists", *repo.Name)
+		} else {
+			log.Printf("No parent for %s", *repo.Name)
+		}
+	*/
+
+	// Only index repositories (the source and, if requested, the fork) that
+	// have enough stars (and are, or aren't, archived)
+	indexRepo := c.enoughStars(repo, logger) && c.archivedAllowed(repo, archived, logger)
+	indexFork := c.indexForks && fork && single.Source != nil && c.enoughStars(*single, logger) &&
+		c.archivedAllowed(*single, status.Archived, logger)
+	if !indexRepo && !indexFork {
+		return nil
+	}
+
+	// Get all the tags associated with this repository
+	tags, err := c.repositoryTags(client, rname)
+	if err != nil {
+		logger.Errorf("Getting tags for repository %s/%s: %v",
+			c.user, rname, err)
+		c.fail(client, rname, PhaseTags, err)
+		return nil
+	}
+
+	// Releases may have been published without their tags being listed
+	if c.releases {
+		tags = append(tags, c.releaseTags(client, rname, tags, logger)...)
+		if client.ctx.Err() != nil {
+			return client.ctx.Err()
 		}
+	}
 
-		repo := *single
+	if indexRepo {
+		c.processTags(client, r, rname, repo, tags, logger)
+	}
 
-		// If this is a fork, index the "real" repository
-		if *minrepo.Fork && single.Source != nil {
-			repo = *single.Source
-			if verbose {
-				log.Printf("Source for %s exists", *repo.Name)
+	// If requested, also index the fork itself (under its own owner and
+	// name) so that changes made in the fork are available as well
+	if indexFork {
+		logger.Debugf("Also indexing fork %s", stringOf(single.HTMLURL))
+		c.processTags(client, r, rname, *single, tags, logger)
+	}
+	return client.ctx.Err()
+}
+
+// This function records that the named repository (of the current user)
+// was skipped because of an error.  Errors caused by the crawl being
+// cancelled aren't recorded since they aren't specific to the repository.
+func (c GitHubCrawler) fail(client *GitHubClient, rname string, phase string, err error) {
+	if client.ctx.Err() != nil {
+		return
+	}
+	c.failures.add(RepoError{User: c.user, Repo: rname, Phase: phase, Err: err})
+}
+
+// This function records whatever was recorded for a repository that was
+// completely processed (according to the checkpoint) before the crawl
+// was interrupted.  It returns false if the repository has to be
+// processed again (e.g., because it has changed since or nothing was
+// recorded for it).
+func (c GitHubCrawler) resume(r recorder.Recorder, repo github.Repository,
+	logger CrawlLogger) bool {
+	if c.resumeFrom == nil || !c.checkpoint.done(c.user, repo) {
+		return false
+	}
+	uri := stringOf(repo.HTMLURL)
+	if !c.resumeFrom.Replay(uri, r) {
+		return false
+	}
+	logger.Debugf("Skipping: %s (%s), already processed", stringOf(repo.Name), uri)
+	recorder.Finish(r, uri)
+	return true
+}
+
+// This function records (in the checkpoint) that the given repository has
+// been completely processed.  Repositories for which errors were recorded
+// (or that were interrupted) still need to be processed.
+func (c GitHubCrawler) completed(client *GitHubClient, repo github.Repository,
+	logger CrawlLogger) {
+	if repo.Name == nil || client.ctx.Err() != nil || c.failures.has(c.user, *repo.Name) {
+		return
+	}
+	err := c.checkpoint.mark(c.user, repo)
+	if err != nil {
+		logger.Warnf("Updating checkpoint: %v", err)
+	}
+}
+
+// This function determines whether the given repository was last pushed
+// to before the time given to SetSince.
+func (c GitHubCrawler) unchanged(repo github.Repository) bool {
+	if c.since.IsZero() || repo.PushedAt == nil {
+		return false
+	}
+	return repo.PushedAt.Time.Before(c.since)
+}
+
+// This function records whatever was recorded for an unchanged repository
+// by the previous crawl (see SetSince).
+func (c GitHubCrawler) reuse(r recorder.Recorder, repo github.Repository, logger CrawlLogger) {
+	count(&c.stats.ReposUnchanged)
+	uri := stringOf(repo.HTMLURL)
+	logger.Debugf("Skipping: %s (%s), not pushed since %s", stringOf(repo.Name), uri,
+		c.since.Format(time.RFC3339))
+	if c.baseline == nil || !c.baseline.Replay(uri, r) {
+		logger.Warnf("No previous entries found for unchanged repository %s", uri)
+	}
+	recorder.Finish(r, uri)
+}
+
+// This function determines whether the given repository (i.e., the one
+// actually being indexed) has at least the minimum number of stars.
+func (c GitHubCrawler) enoughStars(repo github.Repository, logger CrawlLogger) bool {
+	if c.minStars <= 0 {
+		return true
+	}
+	stars := 0
+	if repo.StargazersCount != nil {
+		stars = *repo.StargazersCount
+	}
+	if stars < c.minStars {
+		logger.Infof("Skipping: %s, only %d stars (minimum is %d)",
+			stringOf(repo.HTMLURL), stars, c.minStars)
+		return false
+	}
+	return true
+}
+
+// The tagResult type indicates what became of a single tag.
+type tagResult struct {
+	normalized bool // The tag represents a semantic version
+	excluded   bool // The version was excluded
+	recorded   bool // The version was recorded
+}
+
+// This function records a version for each of the given tags (and, if
+// requested, for the HEAD of the default branch).  The information
+// about the repository (homepage, owner, etc.) is taken from repo.  If a
+// tag concurrency has been specified, several tags are processed at
+// once.  In that case, the recorder and logger must be safe to use from
+// multiple goroutines.
+func (c GitHubCrawler) processTags(client *GitHubClient, r recorder.Recorder, rname string,
+	repo github.Repository, tags []github.RepositoryTag, logger CrawlLogger) {
+	// Keep track of whether this repository contributes anything
+	normalized := 0
+	recorded := 0
+	excluded := 0
+	lightweight := 0
+	defer func() {
+		if len(tags) > 0 && excluded == len(tags) {
+			count(&c.stats.ReposSkippedExclusion)
+		}
+		if recorded > 0 || client.ctx.Err() != nil {
+			return
+		}
+		name := fmt.Sprintf("%s/%s", c.user, rname)
+		if repo.HTMLURL != nil {
+			name = *repo.HTMLURL
+		}
+		switch {
+		case len(tags) == 0:
+			c.empty.add(name, "no tags")
+		case normalized == 0 && lightweight > 0:
+			c.empty.add(name, fmt.Sprintf("%d tags, %d of them lightweight", len(tags), lightweight))
+		case normalized == 0:
+			c.empty.add(name, fmt.Sprintf("%d tags, none with a semantic version", len(tags)))
+		default:
+			c.empty.add(name, fmt.Sprintf("%d tags with semantic versions, none recorded", normalized))
+		}
+	}()
+
+	// Whatever happens to individual tags is logged at the tag level
+	tlogger := withLevel(logger, TagLevel)
+	selected, lightweight := c.annotatedTags(client, rname, tags, tlogger)
+	selected = c.newestTags(rname, selected, tlogger)
+
+	workers := c.tagConcurrency
+	if workers < 1 {
+		workers = 1
+	}
+	if workers > len(selected) {
+		workers = len(selected)
+	}
+
+	// Hand the tags out to a bounded pool of workers and collect the
+	// results.  With a single worker, the tags are processed in order.
+	work := make(chan github.RepositoryTag)
+	results := make(chan tagResult)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for tag := range work {
+				results <- c.processTag(client, r, rname, repo, tag, tlogger)
 			}
-		} else {
-			if verbose {
-				log.Printf("No source for %s", *repo.Name)
+		}()
+	}
+	go func() {
+		defer close(work)
+		for _, tag := range selected {
+			if client.ctx.Err() != nil {
+				return
 			}
+			work <- tag
 		}
+	}()
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
 
-		// TODO: Record both Source and fork?!?
+	for result := range results {
+		if result.normalized {
+			normalized++
+		}
+		if result.excluded {
+			excluded++
+		}
+		if result.recorded {
+			recorded++
+		}
+	}
 
-		/*
-			if orepo.Parent != nil {
-				repo = *orepo.Parent
-				log.Printf("Parent for %s exists", *repo.Name)
-			} else {
-				log.Printf("No parent for %s", *repo.Name)
-			}
-		*/
+	// Optionally, include the HEAD of the default branch as well
+	if c.includeHead && client.ctx.Err() == nil {
+		if c.processHead(client, r, rname, repo, tlogger) {
+			recorded++
+		}
+	}
 
-		// Get all the tags associated with this repository
-		tags, _, err := client.Repositories.ListTags(c.user, rname, nil)
-		if err != nil {
-			logger.Printf("Error getting tags for repository %s/%s: %v",
-				c.user, rname, err)
+	// Everything found in this repository has now been recorded
+	c.pending.add(func() {
+		recorder.Finish(r, stringOf(repo.HTMLURL))
+	})
+}
+
+// This function returns the given tags without those for versions older
+// than the newest versions that would be indexed (see
+// SetMaxVersionsPerLibrary).  Other tags (e.g., those that aren't
+// semantic versions) are kept so that they are accounted for as usual.
+// Several tags for the same version only count as one version.
+func (c GitHubCrawler) newestTags(rname string, tags []github.RepositoryTag,
+	logger CrawlLogger) []github.RepositoryTag {
+	if c.maxVersions <= 0 {
+		return tags
+	}
+
+	// Determine the version of each tag that would be indexed
+	versions := map[int]semver.Version{}
+	distinct := map[string]semver.Version{}
+	for i, tag := range tags {
+		if tag.Name == nil {
+			continue
+		}
+		versionString, verdict := c.accept(c.user, rname, *tag.Name)
+		if verdict != tagAccepted {
 			continue
 		}
+		v, err := parsing.NormalizeWith(c.scheme, versionString)
+		if err != nil || (c.versionRange != nil && !c.versionRange(v)) {
+			continue
+		}
+		versions[i] = v
+		distinct[v.String()] = v
+	}
+	if len(distinct) <= c.maxVersions {
+		return tags
+	}
 
-		// Loop over the tags
-		for _, tag := range tags {
-			if verbose {
-				log.Printf("Processing tag %s", *tag.Name)
-			}
-			// Check if this has a semantic version
-			versionString := *tag.Name
-			sha := *tag.Commit.SHA
+	sorted := []semver.Version{}
+	for _, v := range distinct {
+		sorted = append(sorted, v)
+	}
+	semver.Sort(sorted)
+	oldest := sorted[len(sorted)-c.maxVersions]
 
-			if versionString[0] == 'v' {
-				versionString = versionString[1:]
-			}
+	ret := []github.RepositoryTag{}
+	for i, tag := range tags {
+		v, ok := versions[i]
+		if ok && v.LT(oldest) {
+			continue
+		}
+		ret = append(ret, tag)
+	}
+	logger.Infof("  Dropping %d older versions of %s/%s (keeping the newest %d)",
+		len(distinct)-c.maxVersions, c.user, rname, c.maxVersions)
+	return ret
+}
 
-			tarurl := ""
-			if tag.TarballURL != nil {
-				tarurl = *tag.TarballURL
-			}
+// This function records the version (if any) represented by a single tag.
+func (c GitHubCrawler) processTag(client *GitHubClient, r recorder.Recorder, rname string,
+	repo github.Repository, tag github.RepositoryTag, logger CrawlLogger) tagResult {
+	result := tagResult{}
+	if tag.Name == nil || tag.Commit == nil || tag.Commit.SHA == nil {
+		logger.Warnf("Skipping incomplete tag in repository %s", rname)
+		return result
+	}
+	logger.Debugf("Processing tag %s", *tag.Name)
+	count(&c.stats.TagsProcessed)
+	// Check if this has a semantic version
+	versionString, verdict := c.accept(c.user, rname, *tag.Name)
+	if verdict == tagUnmapped {
+		logger.Debugf("  %s: Skipping tag", *tag.Name)
+		return result
+	}
+	sha := *tag.Commit.SHA
 
-			zipurl := ""
-			if tag.ZipballURL != nil {
-				zipurl = *tag.ZipballURL
-			}
+	_, verr := parsing.NormalizeWith(c.scheme, versionString)
+	result.normalized = verr == nil
 
-			// Check for version we know are not supported
-			if exclude(c.user, rname, versionString) {
-				continue
-			}
+	tarurl := ""
+	if tag.TarballURL != nil {
+		tarurl = *tag.TarballURL
+	}
+
+	zipurl := ""
+	if tag.ZipballURL != nil {
+		zipurl = *tag.ZipballURL
+	}
 
-			c.processVersion(client, r, rname, repo, versionString, sha, tarurl, zipurl,
-				verbose, logger)
+	switch verdict {
+	case tagExcluded:
+		result.excluded = true
+		if c.dryRun {
+			logger.Infof("    Would skip %s:%s (excluded)", rname, versionString)
 		}
+		return result
+	case tagPrerelease:
+		logger.Debugf("  %s: Ignoring pre-release", versionString)
+		return result
+	}
+
+	// If the tag has a release with archives attached, use those instead
+	// of the source archives
+	if c.assets != IgnoreAssets && verr == nil {
+		atar, azip := releaseAssets(client, c.user, rname, *tag.Name, logger)
+		if atar == "" && azip == "" && c.assets == RequireAssets {
+			logger.Debugf("  %s: Ignoring, no release assets", versionString)
+			if c.dryRun {
+				logger.Infof("    Would skip %s:%s (no release assets)", rname, versionString)
+			}
+			return result
+		}
+		if atar != "" || azip != "" {
+			tarurl = atar
+			zipurl = azip
+		}
+	}
+
+	result.recorded = c.processVersion(client, r, rname, repo,
+		candidate{version: versionString, sha: sha, tarurl: tarurl, zipurl: zipurl, tag: *tag.Name}, logger)
+	return result
+}
+
+// This function records the HEAD of the default branch of a repository
+// as a synthetic pre-release version of the form 0.0.0-dev+<shortsha>.
+// This makes it possible to install the latest (untagged) version of a
+// library.
+func (c GitHubCrawler) processHead(client *GitHubClient, r recorder.Recorder, rname string,
+	repo github.Repository, logger CrawlLogger) bool {
+	branchName := "master"
+	if repo.DefaultBranch != nil && *repo.DefaultBranch != "" {
+		branchName = *repo.DefaultBranch
+	}
+
+	var branch *github.Branch
+	err := client.call(func() (err error) {
+		branch, _, err = client.client.Repositories.GetBranch(c.user, rname, branchName)
+		return
+	})
+	if err != nil {
+		logger.Errorf("Getting branch %s of repository %s/%s: %v",
+			branchName, c.user, rname, err)
+		return false
+	}
+	if branch == nil || branch.Commit == nil || branch.Commit.SHA == nil {
+		logger.Warnf("No HEAD commit found for branch %s of repository %s/%s",
+			branchName, c.user, rname)
+		return false
+	}
+
+	sha := *branch.Commit.SHA
+	short := sha
+	if len(short) > 7 {
+		short = short[:7]
+	}
+	versionString := fmt.Sprintf("0.0.0-dev+%s", short)
+
+	logger.Debugf("Processing HEAD of %s (%s)", branchName, short)
+
+	archive := fmt.Sprintf("https://codeload.github.com/%s/%s", c.user, rname)
+	tarurl := fmt.Sprintf("%s/tar.gz/%s", archive, branchName)
+	zipurl := fmt.Sprintf("%s/zip/%s", archive, branchName)
+
+	return c.processVersion(client, r, rname, repo,
+		candidate{version: versionString, sha: sha, tarurl: tarurl, zipurl: zipurl}, logger)
+}
+
+// The SetConcurrency method specifies how many repositories should be
+// processed concurrently.  The default is 1 (i.e., serial processing).
+func (c *GitHubCrawler) SetConcurrency(n int) {
+	c.concurrency = n
+}
+
+// The SetTagConcurrency method specifies how many tags of a single
+// repository should be processed concurrently.  This is independent of
+// the number of repositories processed concurrently (see SetConcurrency).
+// The default is 1 (i.e., tags are processed in order).  Note that when
+// tags are processed concurrently, it isn't defined which of several tags
+// representing the same version is found last (see SetDuplicatePolicy).
+func (c *GitHubCrawler) SetTagConcurrency(n int) {
+	c.tagConcurrency = n
+}
+
+// The SetMaxRateLimitWait method caps the total amount of time the
+// crawler will spend waiting for GitHub rate limits to reset.  Once
+// this time has been used up, requests that exceed the rate limit
+// fail.  A value of zero (the default) means there is no cap.
+func (c *GitHubCrawler) SetMaxRateLimitWait(d time.Duration) {
+	c.maxWait = d
+}
 
-		// TODO: Add HEAD of master to list?  But how?  What kind of semantic
-		// version number should I associate with it?
+// The SetRetries method specifies how many times a request that fails
+// with a transient (server or network) error is retried and how long to
+// wait before the first retry.  The delay doubles with each retry.
+func (c *GitHubCrawler) SetRetries(retries int, baseDelay time.Duration) {
+	c.retries = retries
+	c.retryDelay = baseDelay
+}
+
+// The SetIncludeHead method specifies whether the HEAD of the default
+// branch of each repository should be indexed (as a pre-release version)
+// in addition to its tags.
+func (c *GitHubCrawler) SetIncludeHead(include bool) {
+	c.includeHead = include
+}
+
+// The SetIncludeReleases method specifies whether releases whose tags
+// aren't listed with the other tags of a repository should be indexed as
+// well.  This is the default.
+func (c *GitHubCrawler) SetIncludeReleases(include bool) {
+	c.releases = include
+}
+
+// The SetAnnotatedOnly method specifies whether only annotated tags
+// should be indexed (i.e., lightweight tags are skipped).  Annotated tags
+// are created intentionally (and may be signed), so this only indexes
+// deliberate releases.  Telling the two apart requires an additional
+// request for each tag.
+func (c *GitHubCrawler) SetAnnotatedOnly(annotatedOnly bool) {
+	c.annotatedOnly = annotatedOnly
+}
+
+// The SetArchiveChecksums method specifies whether the tarball of each
+// version should be downloaded so that its SHA-256 can be recorded (and
+// verified by the client).  This uses a lot of bandwidth so it is off by
+// default.  Tarballs are downloaded as versions are processed, so
+// several are downloaded at once when crawling concurrently (see
+// SetConcurrency and SetTagConcurrency).
+func (c *GitHubCrawler) SetArchiveChecksums(enable bool) {
+	c.checksums = enable
+}
+
+// The SetExactSizes method specifies whether the size recorded for each
+// version is computed from the files in that version.  This takes another
+// API call for each version so it is off by default, in which case the
+// size GitHub reports for the repository is recorded instead.
+func (c *GitHubCrawler) SetExactSizes(exact bool) {
+	c.exactSizes = exact
+}
+
+// The SetReleaseNotes method specifies whether the release notes of each
+// version are recorded.  These are taken from the GitHub release of the
+// tag or, if there is none, from the message of the (annotated) tag.
+// This takes another API call (or two) for each version so it is off by
+// default.  Notes longer than maxLength bytes are truncated (if maxLength
+// isn't positive, defaultMaxNotes is used).
+func (c *GitHubCrawler) SetReleaseNotes(enable bool, maxLength int) {
+	c.releaseNotes = enable
+	c.maxNotes = maxLength
+}
+
+// The SetGeneratorVersion method specifies which version of impact is
+// doing the crawl.  Along with when the crawl started, this is recorded
+// by recorders that support it (see recorder.Stamper).
+func (c *GitHubCrawler) SetGeneratorVersion(version string) {
+	c.generatorVersion = version
+}
+
+// The SetPublisherKeys method specifies the publishers (i.e., their
+// public keys) whose signatures of impact.json are trusted.  Versions
+// whose impact.json comes with a signature (see signatureFile) made with
+// any of these keys are recorded as verified.  Other versions are still
+// indexed, just not as verified.  By default, no publishers are trusted
+// (and signatures aren't even looked for).
+func (c *GitHubCrawler) SetPublisherKeys(keys PublisherKeys) {
+	c.publisherKeys = keys
+}
+
+// The SetFollowRenames method specifies whether repositories that have
+// been renamed (or transferred to another owner) are indexed under their
+// new name.  GitHub redirects requests made with the old name, so such
+// repositories can still be found under the old name (e.g., when named
+// explicitly, see SetRepositories).  If renames are followed, everything
+// about the repository (including the URLs of its archives) refers to
+// the new name so that the URLs in the index remain valid.  Otherwise,
+// renames are only logged.
+func (c *GitHubCrawler) SetFollowRenames(follow bool) {
+	c.followRenames = follow
+}
+
+// The SetIndexForks method specifies whether forks should be indexed
+// in addition to their source repository.  By default, only the source
+// repository is indexed.
+func (c *GitHubCrawler) SetIndexForks(index bool) {
+	c.indexForks = index
+}
+
+// The SetVersionRange method restricts the versions that are indexed to
+// those in the given range (e.g., ">=2.0.0 <4.0.0").  Ranges may contain
+// any of the usual comparison operators and alternatives separated by
+// "||" (see parsing.NormalizeConstraint).  This is applied in addition to
+// any exclusions.  An empty range removes the restriction.
+func (c *GitHubCrawler) SetVersionRange(spec string) error {
+	if strings.TrimSpace(spec) == "" {
+		c.rangeSpec = ""
+		c.versionRange = nil
+		return nil
+	}
+	norm, r, err := parsing.NormalizeConstraint(spec)
+	if err != nil {
+		return err
+	}
+	c.rangeSpec = norm
+	c.versionRange = r
+	return nil
+}
+
+// The SetMaxVersionsPerLibrary method specifies the maximum number of
+// versions to index for each library.  Only the newest versions (i.e.,
+// those with the highest semantic versions, regardless of the order of
+// the tags) are indexed.  Since versions are determined by the tags of a
+// repository, this applies to all the libraries in a repository at once.
+// Zero means there is no maximum.
+func (c *GitHubCrawler) SetMaxVersionsPerLibrary(n int) {
+	c.maxVersions = n
+}
+
+// The SetDryRun method specifies whether this crawler should only report
+// what would be indexed.  During a dry run, repositories and tags are
+// processed as usual but nothing is passed on to the recorder.  Instead,
+// the versions that would be recorded (or skipped) are logged along with
+// a final count of libraries and versions.
+func (c *GitHubCrawler) SetDryRun(dryRun bool) {
+	c.dryRun = dryRun
+}
+
+// The SetDuplicatePolicy method specifies what to do when a version of a
+// library is found that has already been recorded (see DuplicatePolicy).
+// The default is ReplaceDuplicates.
+func (c *GitHubCrawler) SetDuplicatePolicy(policy DuplicatePolicy) {
+	c.duplicates = policy
+}
+
+// The SetAssetPolicy method specifies whether the archives attached to the
+// GitHub release for a tag should be used in place of the source archives
+// (see AssetPolicy).  The default is IgnoreAssets.
+func (c *GitHubCrawler) SetAssetPolicy(policy AssetPolicy) {
+	c.assets = policy
+}
+
+// The SetArchivedPolicy method specifies whether repositories that have
+// been archived on GitHub are indexed.  For forks, it is whether the
+// source repository is archived that matters (unless the fork itself is
+// indexed, see SetIndexForks).  By default, archived repositories are
+// skipped.
+func (c *GitHubCrawler) SetArchivedPolicy(policy ArchivedPolicy) {
+	c.archived = policy
+}
+
+// The SetHTTPClient method specifies the HTTP client used to make all
+// requests (e.g., one with a transport that uses a proxy or trusts a
+// custom certificate authority).  Authentication is added on top of this
+// client.  A nil client means http.DefaultClient is used.
+func (c *GitHubCrawler) SetHTTPClient(client *http.Client) {
+	c.httpClient = client
+}
+
+// The SetURLRewriter method specifies a function that is applied to the
+// tarball and zipball URLs of every version before it is recorded (e.g.,
+// to point to a mirror).  A nil rewriter leaves URLs unchanged.
+func (c *GitHubCrawler) SetURLRewriter(rewrite URLRewriter) {
+	c.rewrite = rewrite
+}
+
+// The SetMirrors method specifies functions that each return another URL
+// the tarball of a version can be downloaded from (given the URL GitHub
+// provides).  These are recorded, in order, as mirrors of the tarball
+// (see recorder.VersionRecorder).  Empty URLs are ignored.
+func (c *GitHubCrawler) SetMirrors(mirrors ...URLRewriter) {
+	c.mirrors = mirrors
+}
+
+// The SetExcludePatterns method specifies patterns for repositories that
+// should not be crawled (even if they match one of the patterns the
+// crawler was created with).
+func (c *GitHubCrawler) SetExcludePatterns(patterns []string) error {
+	res, err := compilePatterns(patterns)
+	if err != nil {
+		return err
 	}
+	c.excludes = patterns
+	c.excludeRes = res
 	return nil
 }
 
+// The SetMatchFullName method specifies whether the patterns are matched
+// against the full name of each repository (e.g., acme/modelica-lib, so
+// that patterns like ^acme/modelica-.* can be used) rather than just its
+// name.  By default, only the name is matched.
+func (c *GitHubCrawler) SetMatchFullName(full bool) {
+	c.matchFullName = full
+}
+
+// The SetHeadersOnly method specifies whether only the start of each
+// package.mo is downloaded (which is normally enough to determine the
+// name, version and uses annotation of a library).  If the start of the
+// file turns out not to be enough, all of it is downloaded anyway.  By
+// default, all of each file is downloaded.
+func (c *GitHubCrawler) SetHeadersOnly(headersOnly bool) {
+	c.headersOnly = headersOnly
+}
+
+// The SetVersionFilter method specifies a function that decides whether
+// each library found in a version should be indexed (see VersionFilter).
+// It is consulted after the version range and exclusions.  A nil filter
+// (the default) accepts every library.
+func (c *GitHubCrawler) SetVersionFilter(filter VersionFilter) {
+	c.filter = filter
+}
+
+// The SetOwnerURIs method specifies the owner URI to record for
+// particular repositories (given as owner/repo) instead of the one that
+// is detected.  This allows a mirror of a library to be recorded as
+// belonging to the canonical upstream owner.
+func (c *GitHubCrawler) SetOwnerURIs(owners map[string]string) {
+	c.owners = map[string]string{}
+	for repo, uri := range owners {
+		c.owners[strings.ToLower(repo)] = uri
+	}
+}
+
+// The SetRepoTimeout method specifies the maximum time to spend processing
+// a single repository.  If a repository takes longer than that, it is
+// abandoned (and nothing is recorded for it) so that the rest of the
+// crawl isn't held up.  Zero means there is no maximum.
+func (c *GitHubCrawler) SetRepoTimeout(timeout time.Duration) {
+	c.repoTimeout = timeout
+}
+
+// The SetMinStars method specifies the minimum number of stars a
+// repository must have to be indexed.  For forks, the stars of whichever
+// repository is actually indexed are used.  Zero means there is no
+// minimum.
+func (c *GitHubCrawler) SetMinStars(stars int) {
+	c.minStars = stars
+}
+
+// The SetExcludedTopics method specifies GitHub topics (e.g., "archived"
+// or "example") that prevent a repository from being indexed.  Topics
+// are compared without regard to case.
+func (c *GitHubCrawler) SetExcludedTopics(topics ...string) {
+	c.excludedTopics = topics
+}
+
+// The SetRequiredTopics method restricts indexing to repositories that
+// have at least one of the given GitHub topics.  With no topics, any
+// repository may be indexed (which is the default).
+func (c *GitHubCrawler) SetRequiredTopics(topics ...string) {
+	c.requiredTopics = topics
+}
+
+// The SetMinModelicaBytes method restricts indexing to repositories in
+// which GitHub detected at least the given number of bytes of Modelica
+// code.  Zero means there is no minimum (and the languages of
+// repositories aren't fetched at all).
+func (c *GitHubCrawler) SetMinModelicaBytes(bytes int) {
+	c.minModelicaBytes = bytes
+}
+
+// The SetPrimaryLanguageOnly method restricts indexing to repositories
+// whose primary language (as detected by GitHub) is Modelica.
+func (c *GitHubCrawler) SetPrimaryLanguageOnly(primary bool) {
+	c.primaryLanguage = primary
+}
+
+// The SetSince method limits the crawl to repositories that have been
+// pushed to since the given time.  Whatever was recorded for any other
+// repository (by a previous crawl) is taken from the baseline instead
+// (so nothing is dropped from the index).  Forks are always examined.  A
+// zero time (the default) means every repository is examined.
+func (c *GitHubCrawler) SetSince(since time.Time, baseline Baseline) {
+	c.since = since
+	c.baseline = baseline
+}
+
+// Default number of repositories to request per page (the maximum
+// allowed by GitHub)
+var defaultPerPage = 100
+
+// The SetHeartbeat method specifies how often the progress of a crawl
+// (i.e., how many repositories have been processed and how many versions
+// recorded) is logged.  The progress is logged even when not in verbose
+// mode.  By default (or if interval isn't positive), it is never logged.
+func (c *GitHubCrawler) SetHeartbeat(interval time.Duration) {
+	c.heartbeat = interval
+}
+
+// The SetCheckpoint method specifies a file in which to keep track of
+// the repositories that have been completely processed.  If a crawl is
+// interrupted, the next crawl takes whatever was recorded for those
+// repositories (provided they haven't been pushed to since) from the
+// baseline rather than processing them again.  The baseline would
+// normally hold what was recorded by the interrupted crawl (e.g., the
+// output of a persistent recorder).  The checkpoint file is removed once
+// a crawl completes successfully.
+func (c *GitHubCrawler) SetCheckpoint(filename string, baseline Baseline) {
+	c.checkpointFile = filename
+	c.resumeFrom = baseline
+}
+
+// The SetPagination method specifies how many repositories are requested
+// per page when listing the repositories of a user and which page to start
+// with.  By default, 100 repositories are requested per page starting
+// with the first page.
+func (c *GitHubCrawler) SetPagination(perPage int, startPage int) {
+	c.perPage = perPage
+	c.startPage = startPage
+}
+
+// The SetRepositories method restricts the crawl to the named
+// repositories (of the users being crawled).  Instead of listing all the
+// repositories of each user, the named repositories are fetched directly.
+// This makes it much quicker to re-index just a few repositories.  A name
+// of the form owner/name only applies to that owner.  The patterns (and
+// everything else that determines whether a repository is indexed) still
+// apply.  This has no effect on search crawlers (see
+// MakeGitHubSearchCrawler).  Calling this with no names crawls all
+// repositories again.
+func (c *GitHubCrawler) SetRepositories(names ...string) {
+	c.repoNames = names
+}
+
+// The SetGraphQL method specifies whether the repositories of each user
+// are listed using the GraphQL API rather than the REST API.  The
+// details, topics, languages and (up to 100 of the newest) tags of every
+// repository are then fetched along with the listing, so most of the
+// requests otherwise made for each repository aren't needed.  This
+// doesn't apply to forks (or to repositories named with SetRepositories).
+// The start page given to SetPagination is ignored since GraphQL pages
+// can't be addressed by number.
+func (c *GitHubCrawler) SetGraphQL(enable bool) {
+	c.graphQL = enable
+}
+
+// The SetCacheFile method specifies a file used to cache information
+// between crawls (see crawlCache).  The file is created if it doesn't
+// exist.  An empty filename disables caching.
+func (c *GitHubCrawler) SetCacheFile(filename string) {
+	c.cacheFile = filename
+}
+
+// The SetTokenFile method specifies a file to read the GitHub token from.
+// This is only used if no token was given when the crawler was created.
+// If the file doesn't exist (or is empty), the GITHUB_TOKEN environment
+// variable is used instead.
+func (c *GitHubCrawler) SetTokenFile(name string) {
+	c.tokenFile = name
+}
+
 func (c GitHubCrawler) String() string {
-	return fmt.Sprintf("github://%s/%s", c.user, c.pattern)
+	if c.query != "" {
+		return fmt.Sprintf("github search '%s'/%s", c.query, c.patternSummary())
+	}
+	if c.matchFullName {
+		return fmt.Sprintf("github://%s/%s (matching owner/repo)",
+			strings.Join(c.users, ","), c.patternSummary())
+	}
+	return fmt.Sprintf("github://%s/%s", strings.Join(c.users, ","), c.patternSummary())
+}
+
+// The MakeGitHubCrawler function creates a crawler that indexes the
+// repositories of the given user (or organization) that match any of the
+// given patterns.  If no patterns are given, all repositories are
+// crawled.
+func MakeGitHubCrawler(user string, patterns []string, token string) (GitHubCrawler, error) {
+	return MakeMultiGitHubCrawler([]string{user}, patterns, token)
 }
 
-func MakeGitHubCrawler(user string, pattern string, token string) (GitHubCrawler, error) {
-	if pattern == "" {
-		pattern = ".+"
+// The MakeMultiGitHubCrawler function creates a crawler that indexes the
+// repositories of several users (or organizations) in a single crawl.  If
+// libraries with the same name are found under more than one user, they
+// are recorded as a single library (see duplicatesRecorder).
+func MakeMultiGitHubCrawler(users []string, patterns []string, token string) (GitHubCrawler, error) {
+	if len(users) == 0 {
+		return GitHubCrawler{}, fmt.Errorf("No GitHub users specified")
 	}
 
-	re, err := regexp.Compile(pattern)
+	if len(patterns) == 0 {
+		patterns = []string{".+"}
+	}
+
+	res, err := compilePatterns(patterns)
 	if err != nil {
 		return GitHubCrawler{}, err
 	}
 
 	return GitHubCrawler{
-		token:   token,
-		pattern: pattern,
-		re:      re,
-		user:    user,
+		tagFilter:   defaultTagFilter(),
+		token:       token,
+		patterns:    patterns,
+		res:         res,
+		users:       users,
+		user:        users[0],
+		concurrency: 1,
+		perPage:     defaultPerPage,
+		startPage:   1,
+		releases:    true,
+		retries:     defaultRetries,
+		retryDelay:  defaultRetryDelay,
+		repoTimeout: defaultRepoTimeout,
+		stats:       &CrawlStats{},
 	}, nil
 }
 
+// The MakeGitHubAppCrawler function creates a crawler that authenticates
+// as an installation of a GitHub App (rather than with a personal access
+// token).  The private key of the app must be PEM encoded.  Short-lived
+// installation tokens are requested as needed (and refreshed before they
+// expire) so even long crawls remain authenticated.
+func MakeGitHubAppCrawler(user string, patterns []string, appID int64, installationID int64,
+	privateKey []byte) (GitHubCrawler, error) {
+	key, err := parsePrivateKey(privateKey)
+	if err != nil {
+		return GitHubCrawler{}, err
+	}
+
+	c, err := MakeGitHubCrawler(user, patterns, "")
+	if err != nil {
+		return GitHubCrawler{}, err
+	}
+	c.app = &gitHubApp{
+		appID:          appID,
+		installationID: installationID,
+		key:            key,
+		baseURL:        "https://api.github.com",
+	}
+	return c, nil
+}
+
 var _ Crawler = (*GitHubCrawler)(nil)
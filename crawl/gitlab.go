@@ -0,0 +1,376 @@
+package crawl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/impact/impact/parsing"
+	"github.com/impact/impact/recorder"
+)
+
+// The GitLabCrawler indexes all projects in a given GitLab group (on
+// any GitLab instance, including self-hosted ones).
+type GitLabCrawler struct {
+	tagFilter
+	baseURL string
+	group   string
+	token   string
+	pattern string
+	re      *regexp.Regexp
+	// Used to make all requests (if nil, http.DefaultClient is used)
+	httpClient *http.Client
+	// Used to rewrite archive URLs (if any)
+	rewrite URLRewriter
+}
+
+// Information about a GitLab project (as returned by the GitLab API)
+type gitLabProject struct {
+	ID              int    `json:"id"`
+	Name            string `json:"name"`
+	Path            string `json:"path"`
+	Description     string `json:"description"`
+	WebURL          string `json:"web_url"`
+	HTTPURLToRepo   string `json:"http_url_to_repo"`
+	StarCount       int    `json:"star_count"`
+	ForksCount      int    `json:"forks_count"`
+	OpenIssuesCount int    `json:"open_issues_count"`
+	Namespace       struct {
+		FullPath string `json:"full_path"`
+		WebURL   string `json:"web_url"`
+	} `json:"namespace"`
+}
+
+// Information about a tag in a GitLab project
+type gitLabTag struct {
+	Name   string `json:"name"`
+	Commit struct {
+		ID            string    `json:"id"`
+		CommittedDate time.Time `json:"committed_date"`
+	} `json:"commit"`
+}
+
+// An entry in the repository tree of a GitLab project
+type gitLabTreeEntry struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+	Type string `json:"type"`
+}
+
+// This function performs a GET request against the GitLab API and returns
+// the body of the response along with the number of the next page of
+// results (or "" if this was the last page).
+func (c GitLabCrawler) get(path string, query url.Values) ([]byte, string, error) {
+	u := fmt.Sprintf("%s/api/v4/%s", c.baseURL, path)
+	if len(query) > 0 {
+		u = u + "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("Error creating request for %s: %v", u, err)
+	}
+	// If a token wasn't provided with the crawler, look for a token
+	// as an environment variable
+	token := c.token
+	if token == "" {
+		token = os.Getenv("GITLAB_TOKEN")
+	}
+	if token != "" {
+		req.Header.Set("PRIVATE-TOKEN", token)
+	}
+
+	client := c.httpClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("Error for GET %s: %v", u, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("Unable to read body of response from GET %s: %v", u, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("GET %s returned status %d: %s", u, resp.StatusCode,
+			strings.TrimSpace(string(body)))
+	}
+
+	return body, resp.Header.Get("X-Next-Page"), nil
+}
+
+// This function requests every page of a paginated GitLab API endpoint.
+// The handle function is called with the body of each page.
+func (c GitLabCrawler) getAll(path string, query url.Values, handle func([]byte) error) error {
+	q := url.Values{}
+	for k, v := range query {
+		q[k] = v
+	}
+	q.Set("per_page", "100")
+	q.Set("page", "1")
+
+	for {
+		body, next, err := c.get(path, q)
+		if err != nil {
+			return err
+		}
+		err = handle(body)
+		if err != nil {
+			return err
+		}
+		if next == "" {
+			return nil
+		}
+		q.Set("page", next)
+	}
+}
+
+func (c GitLabCrawler) listProjects() ([]gitLabProject, error) {
+	ret := []gitLabProject{}
+	err := c.getAll(fmt.Sprintf("groups/%s/projects", url.PathEscape(c.group)), nil,
+		func(body []byte) error {
+			page := []gitLabProject{}
+			err := json.Unmarshal(body, &page)
+			if err != nil {
+				return fmt.Errorf("Unable to parse list of projects: %v", err)
+			}
+			ret = append(ret, page...)
+			return nil
+		})
+	return ret, err
+}
+
+func (c GitLabCrawler) listTags(project gitLabProject) ([]gitLabTag, error) {
+	ret := []gitLabTag{}
+	err := c.getAll(fmt.Sprintf("projects/%d/repository/tags", project.ID), nil,
+		func(body []byte) error {
+			page := []gitLabTag{}
+			err := json.Unmarshal(body, &page)
+			if err != nil {
+				return fmt.Errorf("Unable to parse list of tags: %v", err)
+			}
+			ret = append(ret, page...)
+			return nil
+		})
+	return ret, err
+}
+
+// This provides access to the contents of a GitLab project at a specific
+// version.
+type gitLabContents struct {
+	crawler GitLabCrawler
+	project gitLabProject
+	ref     string
+}
+
+func (g gitLabContents) ReadFile(path string) ([]byte, error) {
+	q := url.Values{}
+	q.Set("ref", g.ref)
+	body, _, err := g.crawler.get(fmt.Sprintf("projects/%d/repository/files/%s/raw",
+		g.project.ID, url.PathEscape(path)), q)
+	return body, err
+}
+
+func (g gitLabContents) ReadDir(path string) ([]entry, error) {
+	q := url.Values{}
+	q.Set("ref", g.ref)
+	if path != "." {
+		q.Set("path", path)
+	}
+
+	ret := []entry{}
+	err := g.crawler.getAll(fmt.Sprintf("projects/%d/repository/tree", g.project.ID), q,
+		func(body []byte) error {
+			page := []gitLabTreeEntry{}
+			err := json.Unmarshal(body, &page)
+			if err != nil {
+				return fmt.Errorf("Unable to parse repository tree: %v", err)
+			}
+			for _, e := range page {
+				ret = append(ret, entry{
+					Name:  e.Name,
+					Path:  e.Path,
+					IsDir: e.Type == "tree",
+				})
+			}
+			return nil
+		})
+	return ret, err
+}
+
+// This function returns the URL of an archive (with the given extension)
+// containing the specified tag of the project.
+func (c GitLabCrawler) archiveURL(project gitLabProject, tag string, ext string) string {
+	// GitLab names archives after the project and the tag (where any slashes
+	// in the tag are replaced by dashes)
+	name := fmt.Sprintf("%s-%s.%s", project.Path, strings.Replace(tag, "/", "-", -1), ext)
+	return fmt.Sprintf("%s/-/archive/%s/%s", project.WebURL, url.PathEscape(tag), name)
+}
+
+func (c GitLabCrawler) processVersion(r recorder.Recorder, project gitLabProject,
+	versionString string, tag gitLabTag, logger CrawlLogger) {
+
+	v, verr := parsing.NormalizeWith(c.scheme, versionString)
+	if verr != nil {
+		// If not, ignore it
+		logger.Debugf("  %s: Ignoring", versionString)
+		return
+	}
+
+	logger.Debugf("  %s: Recording", versionString)
+
+	src := gitLabContents{
+		crawler: c,
+		project: project,
+		ref:     tag.Commit.ID,
+	}
+
+	// Formulate directory info (impact.json) for this version of this repository
+	di := extractInfo(src, project.Namespace.FullPath, project.Path, project.Namespace.WebURL,
+		"", "", project.WebURL+"/issues", nil, logger)
+	reportLibraryErrors(di, project.WebURL, versionString, logger)
+
+	if len(di.Libraries) == 0 {
+		logger.Infof("    No Modelica libraries found in repository %s:%s",
+			project.Path, versionString)
+		return
+	}
+
+	details := repoDetails{
+		URI:         project.WebURL,
+		Description: project.Description,
+		GitURL:      project.HTTPURLToRepo,
+		Stars:       project.StarCount,
+		Forks:       project.ForksCount,
+		OpenIssues:  project.OpenIssuesCount,
+	}
+
+	tarurl := rewriteURL(c.rewrite, c.archiveURL(project, tag.Name, "tar.gz"))
+	zipurl := rewriteURL(c.rewrite, c.archiveURL(project, tag.Name, "zip"))
+
+	recordVersion(r, di, details, v,
+		versionRecord{sha: tag.Commit.ID, tag: tag.Name, date: tag.Commit.CommittedDate, tarurl: tarurl, zipurl: zipurl},
+		c.policy(nil), logger)
+}
+
+func (c GitLabCrawler) Crawl(r recorder.Recorder, verbosity Verbosity, stdlogger *log.Logger) error {
+	logger := StandardLogger(stdlogger, verbosity)
+	tlogger := withLevel(logger, TagLevel)
+	logger.Debugf("Fetching projects for %s", c.group)
+	c.hook = c.hook.start(nil)
+
+	projects, err := c.listProjects()
+	if err != nil {
+		logger.Errorf("Listing projects for %s: %v", c.group, err)
+		return fmt.Errorf("Error listing projects for %s: %v", c.group, err)
+	}
+
+	// Loop over all projects in the given group
+	for _, project := range projects {
+		if !c.re.MatchString(project.Path) {
+			logger.Debugf("Skipping: %s (%s), doesn't match pattern '%s'",
+				project.Path, project.WebURL, c.pattern)
+			continue
+		}
+
+		logger.Debugf("Processing: %s (%s)", project.Path, project.WebURL)
+
+		// Get all the tags associated with this project
+		tags, err := c.listTags(project)
+		if err != nil {
+			logger.Errorf("Getting tags for project %s/%s: %v",
+				c.group, project.Path, err)
+			continue
+		}
+
+		// Loop over the tags
+		for _, tag := range tags {
+			tlogger.Debugf("Processing tag %s", tag.Name)
+			versionString, verdict := c.accept(c.group, project.Path, tag.Name)
+			switch verdict {
+			case tagUnmapped:
+				tlogger.Debugf("  %s: Skipping tag", tag.Name)
+				continue
+			case tagExcluded:
+				continue
+			case tagPrerelease:
+				tlogger.Debugf("  %s: Ignoring pre-release", versionString)
+				continue
+			}
+
+			c.processVersion(r, project, versionString, tag, tlogger)
+			if err := c.hook.failure(); err != nil {
+				return err
+			}
+		}
+		recorder.Finish(r, project.WebURL)
+	}
+	return nil
+}
+
+// The SetHTTPClient method specifies the HTTP client used to make all
+// requests (e.g., one that uses a proxy).  A nil client means
+// http.DefaultClient is used.
+func (c *GitLabCrawler) SetHTTPClient(client *http.Client) {
+	c.httpClient = client
+}
+
+// The SetURLRewriter method specifies a function that is applied to the
+// archive URLs of every version before it is recorded (e.g., to point to
+// a mirror).  A nil rewriter leaves URLs unchanged.
+func (c *GitLabCrawler) SetURLRewriter(rewrite URLRewriter) {
+	c.rewrite = rewrite
+}
+
+func (c GitLabCrawler) String() string {
+	u, err := url.Parse(c.baseURL)
+	if err != nil {
+		return fmt.Sprintf("gitlab://%s/%s", c.group, c.pattern)
+	}
+	return fmt.Sprintf("gitlab://%s/%s/%s", u.Host, c.group, c.pattern)
+}
+
+// The MakeGitLabCrawler function creates a crawler for the projects within
+// the given group on the GitLab instance at baseURL (e.g.,
+// https://gitlab.com).  Only projects whose path matches pattern are
+// indexed.  If no token is given, the GITLAB_TOKEN environment variable
+// is used (if set).
+func MakeGitLabCrawler(baseURL string, group string, pattern string,
+	token string) (GitLabCrawler, error) {
+	if pattern == "" {
+		pattern = ".+"
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return GitLabCrawler{}, err
+	}
+
+	_, err = url.Parse(baseURL)
+	if err != nil {
+		return GitLabCrawler{}, fmt.Errorf("Invalid GitLab URL %s: %v", baseURL, err)
+	}
+
+	return GitLabCrawler{
+		tagFilter: defaultTagFilter(),
+		baseURL:   strings.TrimSuffix(baseURL, "/"),
+		group:     group,
+		token:     token,
+		pattern:   pattern,
+		re:        re,
+	}, nil
+}
+
+var _ Crawler = (*GitLabCrawler)(nil)
@@ -0,0 +1,170 @@
+package crawl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+
+	"github.com/impact/impact/recorder"
+)
+
+func TestGitLabCrawler(t *testing.T) {
+	Convey("Testing GitLab crawler", t, func(c C) {
+		var base string
+		tokens := map[string]bool{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			tokens[r.Header.Get("PRIVATE-TOKEN")] = true
+			q := r.URL.Query()
+			page := func(next string) {
+				if next != "" {
+					w.Header().Set("X-Next-Page", next)
+				}
+			}
+			switch r.URL.Path {
+			case "/api/v4/groups/grp/projects":
+				Equals(c, q.Get("per_page"), "100")
+				if q.Get("page") == "1" {
+					page("2")
+					fmt.Fprintf(w, `[{"id": 1, "name": "Foo", "path": "foo", "description": "A library",
+					                  "web_url": "%s/grp/foo", "http_url_to_repo": "%s/grp/foo.git",
+					                  "star_count": 3, "forks_count": 1, "open_issues_count": 2,
+					                  "namespace": {"full_path": "grp", "web_url": "%s/grp"}}]`,
+						base, base, base)
+					return
+				}
+				fmt.Fprintf(w, `[{"id": 2, "name": "Empty", "path": "empty", "web_url": "%s/grp/empty",
+				                  "namespace": {"full_path": "grp", "web_url": "%s/grp"}}]`, base, base)
+			case "/api/v4/projects/1/repository/tags":
+				if q.Get("page") == "1" {
+					page("2")
+					fmt.Fprint(w, `[{"name": "v1.0.0", "commit": {"id": "abc", "committed_date": "2020-01-02T03:04:05Z"}}]`)
+					return
+				}
+				fmt.Fprint(w, `[{"name": "release/1.1.0", "commit": {"id": "def", "committed_date": "2020-02-02T03:04:05Z"}},
+				                {"name": "nightly", "commit": {"id": "ghi", "committed_date": "2020-03-02T03:04:05Z"}}]`)
+			case "/api/v4/projects/2/repository/tags":
+				fmt.Fprint(w, `[]`)
+			case "/api/v4/projects/1/repository/tree":
+				switch q.Get("path") {
+				case "":
+					// The tree is listed a page at a time as well
+					if q.Get("page") == "1" {
+						page("2")
+						fmt.Fprint(w, `[{"name": "Foo", "path": "Foo", "type": "tree"}]`)
+						return
+					}
+					fmt.Fprint(w, `[{"name": "README.md", "path": "README.md", "type": "blob"}]`)
+				case "Foo":
+					fmt.Fprint(w, `[{"name": "package.mo", "path": "Foo/package.mo", "type": "blob"}]`)
+				default:
+					fmt.Fprint(w, `[]`)
+				}
+			case "/api/v4/projects/1/repository/files/Foo/package.mo/raw":
+				fmt.Fprintf(w, `within;
+package Foo
+  annotation(version="%s", uses(Modelica(version="3.2.1")));
+end Foo;`, map[string]string{"abc": "1.0.0", "def": "1.1.0"}[q.Get("ref")])
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		defer server.Close()
+		base = server.URL
+
+		cr, err := MakeGitLabCrawler(base+"/", "grp", "", "secret")
+		NoError(c, err)
+		Equals(c, cr.String(), "gitlab://"+strings.TrimPrefix(base, "http://")+"/grp/.+")
+
+		// Every page is listed
+		projects, err := cr.listProjects()
+		NoError(c, err)
+		Equals(c, len(projects), 2)
+		Equals(c, projects[0].Path, "foo")
+		Equals(c, projects[0].Namespace.FullPath, "grp")
+		Equals(c, projects[1].Path, "empty")
+		tags, err := cr.listTags(projects[0])
+		NoError(c, err)
+		Equals(c, len(tags), 3)
+		Equals(c, tags[1].Name, "release/1.1.0")
+		Equals(c, tags[1].Commit.ID, "def")
+		IsFalse(c, tags[1].Commit.CommittedDate.IsZero())
+
+		_, _, err = cr.get("projects/3/repository/tags", nil)
+		IsError(c, err)
+		IsTrue(c, strings.Contains(err.Error(), "404"))
+		IsTrue(c, tokens["secret"])
+
+		// The contents of a project are read at the given ref
+		src := gitLabContents{crawler: cr, project: projects[0], ref: "abc"}
+		entries, err := src.ReadDir(".")
+		NoError(c, err)
+		Equals(c, len(entries), 2)
+		Resembles(c, entries[0], entry{Name: "Foo", Path: "Foo", IsDir: true})
+		Resembles(c, entries[1], entry{Name: "README.md", Path: "README.md", IsDir: false})
+		entries, err = src.ReadDir("Foo")
+		NoError(c, err)
+		Equals(c, len(entries), 1)
+		Equals(c, entries[0].Path, "Foo/package.mo")
+		contents, err := src.ReadFile("Foo/package.mo")
+		NoError(c, err)
+		IsTrue(c, strings.Contains(string(contents), `version="1.0.0"`))
+		_, err = src.ReadFile("Missing.mo")
+		IsError(c, err)
+
+		// Archives are named after the project and the tag
+		Equals(c, cr.archiveURL(projects[0], "v1.0.0", "tar.gz"),
+			base+"/grp/foo/-/archive/v1.0.0/foo-v1.0.0.tar.gz")
+		Equals(c, cr.archiveURL(projects[0], "release/1.1.0", "zip"),
+			base+"/grp/foo/-/archive/release%2F1.1.0/foo-release-1.1.0.zip")
+
+		// Tags are mapped to versions (and skipped if they are not one)
+		cr.SetTagMapper(func(tag string) (string, bool) {
+			if strings.HasPrefix(tag, "release/") {
+				return strings.TrimPrefix(tag, "release/"), true
+			}
+			return DefaultTagMapper(tag)
+		})
+		rec := recorder.NewMemoryRecorder()
+		err = cr.Crawl(rec, Quiet, log.New(ioutil.Discard, "", 0))
+		NoError(c, err)
+
+		foo := rec.Find("Foo")
+		NotNil(c, foo)
+		Equals(c, foo.Description, "A library")
+		Equals(c, foo.Stars, 3)
+		Equals(c, len(foo.Versions), 2)
+
+		v := foo.Versions["1.0.0"]
+		NotNil(c, v)
+		Equals(c, v.Hash, "abc")
+		Equals(c, v.Path, "Foo")
+		Equals(c, v.TarballURL, base+"/grp/foo/-/archive/v1.0.0/foo-v1.0.0.tar.gz")
+		Equals(c, v.ZipballURL, base+"/grp/foo/-/archive/v1.0.0/foo-v1.0.0.zip")
+		Equals(c, len(v.Dependencies), 1)
+
+		v = foo.Versions["1.1.0"]
+		NotNil(c, v)
+		Equals(c, v.Hash, "def")
+		Equals(c, v.ZipballURL, base+"/grp/foo/-/archive/release%2F1.1.0/foo-release-1.1.0.zip")
+		IsNil(c, rec.Find("Empty"))
+
+		// Only projects that match the pattern are indexed
+		cr, err = MakeGitLabCrawler(base, "grp", "^empty$", "secret")
+		NoError(c, err)
+		rec = recorder.NewMemoryRecorder()
+		NoError(c, cr.Crawl(rec, Quiet, log.New(ioutil.Discard, "", 0)))
+		IsNil(c, rec.Find("Foo"))
+
+		// Projects can't be listed for a group that doesn't exist
+		cr, err = MakeGitLabCrawler(base, "missing", "", "")
+		NoError(c, err)
+		IsError(c, cr.Crawl(recorder.NewMemoryRecorder(), Quiet, log.New(ioutil.Discard, "", 0)))
+	})
+}
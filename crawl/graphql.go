@@ -0,0 +1,340 @@
+package crawl
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+)
+
+// The query used to list the repositories of a user (or organization)
+// along with everything needed to decide whether (and how) each one is
+// indexed.  This takes one request per page of repositories rather than
+// several requests for each repository (see SetGraphQL).
+var repositoriesQuery = `query($owner: String!, $first: Int!, $after: String) {
+  repositoryOwner(login: $owner) {
+    repositories(first: $first, after: $after, orderBy: {field: NAME, direction: ASC}) {
+      pageInfo { hasNextPage endCursor }
+      nodes {
+        name
+        nameWithOwner
+        url
+        description
+        homepageUrl
+        isFork
+        isArchived
+        pushedAt
+        diskUsage
+        stargazerCount
+        forkCount
+        hasIssuesEnabled
+        owner { login url ... on User { email } ... on Organization { email } }
+        defaultBranchRef { name }
+        primaryLanguage { name }
+        licenseInfo { key }
+        issues(states: OPEN) { totalCount }
+        pullRequests(states: OPEN) { totalCount }
+        repositoryTopics(first: 100) { nodes { topic { name } } }
+        languages(first: 100) { edges { size node { name } } }
+        refs(refPrefix: "refs/tags/", first: 100, orderBy: {field: TAG_COMMIT_DATE, direction: DESC}) {
+          nodes { name target { oid ... on Tag { target { oid } } } }
+        }
+      }
+    }
+  }
+}`
+
+type graphQLRequest struct {
+	Query     string                 `json:"query"`
+	Variables map[string]interface{} `json:"variables"`
+}
+
+type graphQLError struct {
+	Message string `json:"message"`
+}
+
+type graphQLName struct {
+	Name string `json:"name"`
+}
+
+type graphQLCount struct {
+	TotalCount int `json:"totalCount"`
+}
+
+type graphQLRepository struct {
+	Name             string       `json:"name"`
+	NameWithOwner    string       `json:"nameWithOwner"`
+	URL              string       `json:"url"`
+	Description      *string      `json:"description"`
+	HomepageURL      *string      `json:"homepageUrl"`
+	IsFork           bool         `json:"isFork"`
+	IsArchived       bool         `json:"isArchived"`
+	PushedAt         *time.Time   `json:"pushedAt"`
+	DiskUsage        int          `json:"diskUsage"`
+	StargazerCount   int          `json:"stargazerCount"`
+	ForkCount        int          `json:"forkCount"`
+	HasIssuesEnabled bool         `json:"hasIssuesEnabled"`
+	Issues           graphQLCount `json:"issues"`
+	PullRequests     graphQLCount `json:"pullRequests"`
+	Owner            struct {
+		Login string  `json:"login"`
+		URL   string  `json:"url"`
+		Email *string `json:"email"`
+	} `json:"owner"`
+	DefaultBranchRef *graphQLName `json:"defaultBranchRef"`
+	PrimaryLanguage  *graphQLName `json:"primaryLanguage"`
+	LicenseInfo      *struct {
+		Key string `json:"key"`
+	} `json:"licenseInfo"`
+	RepositoryTopics struct {
+		Nodes []struct {
+			Topic graphQLName `json:"topic"`
+		} `json:"nodes"`
+	} `json:"repositoryTopics"`
+	Languages struct {
+		Edges []struct {
+			Size int         `json:"size"`
+			Node graphQLName `json:"node"`
+		} `json:"edges"`
+	} `json:"languages"`
+	Refs struct {
+		Nodes []struct {
+			Name   string `json:"name"`
+			Target struct {
+				OID    string `json:"oid"`
+				Target *struct {
+					OID string `json:"oid"`
+				} `json:"target"`
+			} `json:"target"`
+		} `json:"nodes"`
+	} `json:"refs"`
+}
+
+type graphQLRepositories struct {
+	Data *struct {
+		RepositoryOwner *struct {
+			Repositories struct {
+				PageInfo struct {
+					HasNextPage bool   `json:"hasNextPage"`
+					EndCursor   string `json:"endCursor"`
+				} `json:"pageInfo"`
+				Nodes []graphQLRepository `json:"nodes"`
+			} `json:"repositories"`
+		} `json:"repositoryOwner"`
+	} `json:"data"`
+	Errors []graphQLError `json:"errors"`
+}
+
+// A prefetchedRepo holds everything the GraphQL listing returned for a
+// repository (see SetGraphQL).
+type prefetchedRepo struct {
+	repo      github.Repository
+	archived  bool
+	tags      []github.RepositoryTag
+	topics    []string
+	languages map[string]int
+}
+
+// The prefetchedRepos type holds the repositories returned by the
+// GraphQL listing keyed by their name.  It is only written before the
+// repositories are processed, so it can be read concurrently.
+type prefetchedRepos map[string]prefetchedRepo
+
+// This function returns the URL of the GraphQL API that goes along with
+// the given (REST) API URL.  GitHub Enterprise serves the REST API under
+// /api/v3/ and the GraphQL API as /api/graphql.
+func graphQLEndpoint(base *url.URL) string {
+	u := *base
+	if strings.HasSuffix(u.Path, "/api/v3/") {
+		u.Path = strings.TrimSuffix(u.Path, "v3/") + "graphql"
+		return u.String()
+	}
+	return u.ResolveReference(&url.URL{Path: "graphql"}).String()
+}
+
+// This function returns all the repositories of the current user (like
+// listRepos) using the GraphQL API.  The details, topics, languages and
+// tags of each repository are fetched along with it, so they don't need
+// to be requested for each repository (see prefetched).  Forks are the
+// exception since the details of their source can't be fetched this way.
+// Pages are requested with a cursor, so (unlike listRepos) the listing
+// can't continue past a page that can't be listed.  Such a page (and
+// every page after it) is skipped, which is logged and recorded as a
+// RepoError, unless it is the first page.
+func (c GitHubCrawler) graphQLRepos(gc *GitHubClient, logger CrawlLogger) ([]github.Repository,
+	prefetchedRepos, error) {
+	first := c.perPage
+	if first <= 0 || first > 100 {
+		first = 100
+	}
+	endpoint := graphQLEndpoint(gc.client.BaseURL)
+	tarballs := gc.client.BaseURL.ResolveReference(&url.URL{Path: "repos/"}).String()
+
+	logger.Debugf("Fetching repositories for %s (using GraphQL)", c.user)
+	repos := []github.Repository{}
+	prefetched := prefetchedRepos{}
+	var after interface{}
+	for page := 1; ; page++ {
+		body := graphQLRequest{
+			Query: repositoriesQuery,
+			Variables: map[string]interface{}{
+				"owner": c.user,
+				"first": first,
+				"after": after,
+			},
+		}
+		result := graphQLRepositories{}
+		err := gc.call(func() error {
+			result = graphQLRepositories{}
+			req, err := gc.client.NewRequest("POST", endpoint, body)
+			if err != nil {
+				return err
+			}
+			_, err = gc.client.Do(req, &result)
+			return err
+		})
+		if gc.ctx.Err() != nil {
+			return nil, nil, gc.ctx.Err()
+		}
+		if err == nil && len(result.Errors) > 0 {
+			err = fmt.Errorf("GraphQL query failed: %s", result.Errors[0].Message)
+		}
+		if err == nil && (result.Data == nil || result.Data.RepositoryOwner == nil) {
+			err = fmt.Errorf("No user or organization named %s", c.user)
+		}
+		if err != nil {
+			if page == 1 {
+				logger.Errorf("Listing repositories for %s: %v", c.user, err)
+				return nil, nil, fmt.Errorf("Error listing repositories for %s: %v", c.user, err)
+			}
+			logger.Warnf("Skipping page %d (and any after it) of the repositories of %s: %v",
+				page, c.user, err)
+			c.failures.add(RepoError{
+				User:  c.user,
+				Phase: PhaseListing,
+				Err:   fmt.Errorf("Page %d: %v", page, err),
+			})
+			break
+		}
+
+		listing := result.Data.RepositoryOwner.Repositories
+		for _, node := range listing.Nodes {
+			pre := node.prefetched(tarballs)
+			repos = append(repos, pre.repo)
+			if !node.IsFork {
+				prefetched[node.Name] = pre
+			}
+		}
+		logger.Debugf("  Fetching page %d, %d entries", page, len(listing.Nodes))
+
+		if !listing.PageInfo.HasNextPage {
+			break
+		}
+		after = listing.PageInfo.EndCursor
+	}
+	return repos, prefetched, nil
+}
+
+// The prefetched method converts a repository returned by the GraphQL
+// listing into the form the REST API returns it in.  The archive URLs of
+// the tags are relative to the given URL (of the repositories in the
+// REST API).
+func (node graphQLRepository) prefetched(tarballs string) prefetchedRepo {
+	owner := node.Owner.Login
+	if owner == "" {
+		owner = strings.Split(node.NameWithOwner, "/")[0]
+	}
+	repo := github.Repository{
+		Name:            github.String(node.Name),
+		FullName:        github.String(node.NameWithOwner),
+		HTMLURL:         github.String(node.URL),
+		Description:     node.Description,
+		Homepage:        node.HomepageURL,
+		Fork:            github.Bool(node.IsFork),
+		Size:            github.Int(node.DiskUsage),
+		StargazersCount: github.Int(node.StargazerCount),
+		ForksCount:      github.Int(node.ForkCount),
+		OpenIssuesCount: github.Int(node.Issues.TotalCount + node.PullRequests.TotalCount),
+		Owner: &github.User{
+			Login:   github.String(owner),
+			HTMLURL: github.String(node.Owner.URL),
+			Email:   node.Owner.Email,
+		},
+	}
+	if u, err := url.Parse(node.URL); err == nil {
+		repo.GitURL = github.String(fmt.Sprintf("git://%s%s.git", u.Host, u.Path))
+	}
+	if node.HasIssuesEnabled {
+		repo.IssuesURL = github.String(fmt.Sprintf("%s%s/issues{/number}", tarballs, node.NameWithOwner))
+	}
+	if node.PushedAt != nil {
+		repo.PushedAt = &github.Timestamp{Time: *node.PushedAt}
+	}
+	if node.DefaultBranchRef != nil {
+		repo.DefaultBranch = github.String(node.DefaultBranchRef.Name)
+	}
+	if node.PrimaryLanguage != nil {
+		repo.Language = github.String(node.PrimaryLanguage.Name)
+	}
+	if node.LicenseInfo != nil {
+		repo.License = &github.License{Key: github.String(node.LicenseInfo.Key)}
+	}
+
+	ret := prefetchedRepo{
+		repo:      repo,
+		archived:  node.IsArchived,
+		tags:      []github.RepositoryTag{},
+		topics:    []string{},
+		languages: map[string]int{},
+	}
+	for _, t := range node.RepositoryTopics.Nodes {
+		ret.topics = append(ret.topics, t.Topic.Name)
+	}
+	for _, l := range node.Languages.Edges {
+		ret.languages[l.Node.Name] = l.Size
+	}
+	for _, ref := range node.Refs.Nodes {
+		// Annotated tags refer to a tag object rather than the commit
+		sha := ref.Target.OID
+		if ref.Target.Target != nil && ref.Target.Target.OID != "" {
+			sha = ref.Target.Target.OID
+		}
+		archive := fmt.Sprintf("%s%s", tarballs, node.NameWithOwner)
+		ret.tags = append(ret.tags, github.RepositoryTag{
+			Name:       github.String(ref.Name),
+			Commit:     &github.Commit{SHA: github.String(sha)},
+			TarballURL: github.String(fmt.Sprintf("%s/tarball/%s", archive, ref.Name)),
+			ZipballURL: github.String(fmt.Sprintf("%s/zipball/%s", archive, ref.Name)),
+		})
+	}
+	return ret
+}
+
+// This function returns the complete details of the named repository
+// (see getRepository).  These come from the GraphQL listing if the
+// repository was listed that way (see SetGraphQL).
+func (c GitHubCrawler) repository(client *GitHubClient, rname string) (*github.Repository,
+	archiveStatus, error) {
+	if pre, exists := c.prefetched[rname]; exists {
+		repo := pre.repo
+		return &repo, archiveStatus{Archived: pre.archived}, nil
+	}
+	return getRepository(client, c.user, rname)
+}
+
+// This function returns the tags of the named repository.  These come
+// from the GraphQL listing if the repository was listed that way (see
+// SetGraphQL).
+func (c GitHubCrawler) repositoryTags(client *GitHubClient, rname string) ([]github.RepositoryTag, error) {
+	if pre, exists := c.prefetched[rname]; exists {
+		return pre.tags, nil
+	}
+	var tags []github.RepositoryTag
+	err := client.call(func() (err error) {
+		tags, _, err = client.client.Repositories.ListTags(c.user, rname, nil)
+		return
+	})
+	return tags, err
+}
@@ -0,0 +1,154 @@
+package crawl
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/github"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+
+	"github.com/impact/impact/recorder"
+)
+
+func TestGraphQL(t *testing.T) {
+	Convey("Testing the GraphQL endpoint", t, func(c C) {
+		base, err := url.Parse("https://api.github.com/")
+		NoError(c, err)
+		Equals(c, graphQLEndpoint(base), "https://api.github.com/graphql")
+		base, err = url.Parse("https://github.example.com/api/v3/")
+		NoError(c, err)
+		Equals(c, graphQLEndpoint(base), "https://github.example.com/api/graphql")
+	})
+
+	Convey("Testing listing repositories using GraphQL", t, func(c C) {
+		requests := []string{}
+		failPage := "none"
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests = append(requests, r.Method+" "+r.URL.Path)
+			if r.URL.Path != "/graphql" {
+				http.NotFound(w, r)
+				return
+			}
+			query := graphQLRequest{}
+			if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if query.Variables["owner"] != "a" {
+				fmt.Fprint(w, `{"data": {"repositoryOwner": null}}`)
+				return
+			}
+			after, _ := query.Variables["after"].(string)
+			if after == failPage {
+				fmt.Fprint(w, `{"errors": [{"message": "Something went wrong"}]}`)
+				return
+			}
+			switch after {
+			case "":
+				fmt.Fprint(w, `{"data": {"repositoryOwner": {"repositories": {
+				  "pageInfo": {"hasNextPage": true, "endCursor": "c1"},
+				  "nodes": [{
+				    "name": "Foo", "nameWithOwner": "a/Foo", "url": "https://github.com/a/Foo",
+				    "description": "A library", "isFork": false, "isArchived": true,
+				    "pushedAt": "2016-03-01T00:00:00Z", "diskUsage": 5, "stargazerCount": 7,
+				    "forkCount": 2, "hasIssuesEnabled": true,
+				    "issues": {"totalCount": 3}, "pullRequests": {"totalCount": 1},
+				    "owner": {"login": "a", "url": "https://github.com/a", "email": "a@example.com"},
+				    "defaultBranchRef": {"name": "main"},
+				    "primaryLanguage": {"name": "Modelica"},
+				    "licenseInfo": {"key": "mit"},
+				    "repositoryTopics": {"nodes": [{"topic": {"name": "modelica"}}]},
+				    "languages": {"edges": [{"size": 1234, "node": {"name": "Modelica"}}]},
+				    "refs": {"nodes": [
+				      {"name": "v1.1.0", "target": {"oid": "t1", "target": {"oid": "def"}}},
+				      {"name": "v1.0.0", "target": {"oid": "abc"}}
+				    ]}
+				  }]
+				}}}}`)
+			case "c1":
+				fmt.Fprint(w, `{"data": {"repositoryOwner": {"repositories": {
+				  "pageInfo": {"hasNextPage": false, "endCursor": "c2"},
+				  "nodes": [{"name": "Bar", "nameWithOwner": "a/Bar", "url": "https://github.com/a/Bar",
+				    "isFork": true, "owner": {"login": "a", "url": "https://github.com/a"}}]
+				}}}}`)
+			}
+		}))
+		defer server.Close()
+
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		client := github.NewClient(nil)
+		base, err := url.Parse(server.URL + "/")
+		NoError(c, err)
+		client.BaseURL = base
+		gc := NewGitHubClient(client, 0, logger)
+		gc.SetRetries(0, 0)
+
+		cr, err := MakeGitHubCrawler("a", nil, "")
+		NoError(c, err)
+		cr.failures = &repoErrors{}
+
+		// Everything is fetched along with the listing (except for forks)
+		repos, prefetched, err := cr.graphQLRepos(gc, logger)
+		NoError(c, err)
+		Resembles(c, requests, []string{"POST /graphql", "POST /graphql"})
+		Equals(c, len(repos), 2)
+		Equals(c, stringOf(repos[1].Name), "Bar")
+		IsTrue(c, *repos[1].Fork)
+		Equals(c, len(prefetched), 1)
+
+		foo := prefetched["Foo"]
+		Equals(c, stringOf(foo.repo.HTMLURL), "https://github.com/a/Foo")
+		Equals(c, stringOf(foo.repo.GitURL), "git://github.com/a/Foo.git")
+		Equals(c, stringOf(foo.repo.Owner.Email), "a@example.com")
+		Equals(c, intOf(foo.repo.StargazersCount), 7)
+		Equals(c, intOf(foo.repo.OpenIssuesCount), 4)
+		Equals(c, intOf(foo.repo.Size), 5)
+		Equals(c, stringOf(foo.repo.DefaultBranch), "main")
+		Equals(c, gitHubLicense(foo.repo.License), "MIT")
+		Equals(c, foo.repo.PushedAt.Time.Year(), 2016)
+		IsTrue(c, foo.archived)
+		Resembles(c, foo.topics, []string{"modelica"})
+		Equals(c, foo.languages["Modelica"], 1234)
+		Equals(c, len(foo.tags), 2)
+		Equals(c, stringOf(foo.tags[0].Commit.SHA), "def")
+		Equals(c, stringOf(foo.tags[1].Commit.SHA), "abc")
+		Equals(c, stringOf(foo.tags[1].TarballURL), server.URL+"/repos/a/Foo/tarball/v1.0.0")
+
+		// So processing the repository doesn't take any more requests
+		// (other than those needed to index its tags)
+		requests = []string{}
+		cr.prefetched = prefetched
+		cr.SetMinModelicaBytes(1000)
+		cr.SetRequiredTopics("modelica")
+		cr.SetArchivedPolicy(IncludeArchived)
+		NoError(c, cr.processRepo(gc, recorder.NullRecorder{}, repos[0], logger))
+		IsTrue(c, len(requests) > 0)
+		for _, req := range requests {
+			IsFalse(c, req == "GET /repos/a/Foo" || req == "GET /repos/a/Foo/tags" ||
+				req == "GET /repos/a/Foo/topics" || req == "GET /repos/a/Foo/languages")
+		}
+
+		// Pages after the first that can't be listed are skipped
+		failPage = "c1"
+		repos, _, err = cr.graphQLRepos(gc, logger)
+		NoError(c, err)
+		Equals(c, len(repos), 1)
+		failures := cr.failures.list()
+		Equals(c, len(failures), 1)
+		Equals(c, failures[0].Phase, PhaseListing)
+
+		// Unless it is the first page
+		failPage = "none"
+		cr.user = "b"
+		_, _, err = cr.graphQLRepos(gc, logger)
+		IsTrue(c, err != nil)
+	})
+}
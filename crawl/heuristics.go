@@ -2,8 +2,8 @@ package crawl
 
 import (
 	"fmt"
-	"io/ioutil"
-	"log"
+	"path"
+	"sort"
 	"strings"
 
 	"github.com/blang/semver"
@@ -14,65 +14,127 @@ import (
 	"github.com/impact/impact/parsing"
 )
 
-func parsePackage(client *github.Client, user string, reponame string,
-	mopath string, opts *github.RepositoryContentGetOptions) (string,
-	map[string]semver.Version, error) {
-	blank := map[string]semver.Version{}
+// The number of bytes read from the start of each package.mo when only
+// the header of each package is needed (see headerContents)
+var headerBytes = 16 * 1024
+
+// This function reads the Modelica code of a top-level package.  If only
+// the header of the package is needed (see headerContents), just the
+// start of the file is read.  Unless that includes the declaration of the
+// package and a complete uses annotation, the whole file is read anyway
+// (since the annotations may be found later in the file).
+func readPackage(src contents, mopath string, logger CrawlLogger) (string, error) {
+	hc, ok := src.(headerContents)
+	if ok {
+		raw, complete, err := hc.ReadHeader(mopath)
+		if err == nil {
+			code := string(raw)
+			if complete {
+				return code, nil
+			}
+			_, declared := parsing.DeclaredName(code)
+			if declared && parsing.HasUsesAnnotation(code) {
+				return code, nil
+			}
+			logger.Debugf("    Reading all of %s, the header isn't enough", mopath)
+		} else {
+			logger.Debugf("    Reading all of %s, unable to read the header: %v", mopath, err)
+		}
+	}
 
-	reader, err := client.Repositories.DownloadContents(user, reponame, mopath, opts)
+	raw, err := src.ReadFile(mopath)
 	if err != nil {
-		return "", blank, fmt.Errorf("Unable to download Modelica code for %s: %v", mopath, err)
+		return "", err
 	}
-	raw, err := ioutil.ReadAll(reader)
+	return string(raw), nil
+}
+
+// The packageInfo type holds what is found in the top-level package of a
+// library.
+type packageInfo struct {
+	name        string
+	uses        map[string]semver.Version
+	version     string // As declared (empty if not declared)
+	description string // Empty if there is none
+}
+
+// This function parses the top-level package of a library and returns its
+// name, the libraries it uses, the version it declares (if any) and its
+// description (if any).
+func parsePackage(src contents, reponame string, mopath string, logger CrawlLogger) (packageInfo,
+	error) {
+	blank := packageInfo{uses: map[string]semver.Version{}}
+
+	contents, err := readPackage(src, mopath, logger)
 	if err != nil {
-		return "", blank, fmt.Errorf("Error reading response: %v", err)
+		return blank, fmt.Errorf("Unable to download Modelica code for %s: %v", mopath, err)
 	}
 
-	contents := string(raw)
-
-	uses, err := parsing.ParseUses(contents)
+	specs, err := parsing.ParseUsesSpecs(contents)
 	if err != nil {
-		return "", blank,
-			fmt.Errorf("Error while parsing uses annotation of %s in github repository %s: %v",
+		return blank,
+			fmt.Errorf("Error while parsing uses annotation of %s in repository %s: %v",
 				mopath, reponame, err)
 	}
 
+	// Make sure every dependency refers to a valid version.  Any that don't
+	// are skipped (rather than ending up in the index)
+	uses := map[string]semver.Version{}
+	for libname, spec := range specs {
+		v, err := parsing.NormalizeVersion(spec)
+		if err == nil {
+			uses[libname] = v
+			continue
+		}
+
+		_, _, rerr := parsing.NormalizeConstraint(spec)
+		if rerr == nil {
+			logger.Warnf("Skipping dependency of %s on %s: version ranges ('%s') are not supported",
+				mopath, libname, spec)
+		} else {
+			logger.Warnf("Skipping dependency of %s on %s: invalid version '%s': %v",
+				mopath, libname, spec, err)
+		}
+	}
+
 	name, err := parsing.ParseName(contents)
 	if err != nil {
-		return "", blank,
-			fmt.Errorf("Error while parsing name of %s in github repository %s: %v",
+		return blank,
+			fmt.Errorf("Error while parsing name of %s in repository %s: %v",
 				mopath, reponame, err)
 	}
 
-	return name, uses, nil
+	return packageInfo{
+		name:        name,
+		uses:        uses,
+		version:     parsing.ParseVersion(contents),
+		description: parsing.ParseDescription(contents),
+	}, nil
 }
 
-func getLibraries(client *github.Client, user string, repostr string, verbose bool,
-	opts *github.RepositoryContentGetOptions) ([]*dirinfo.LocalLibrary, error) {
+func getLibraries(src contents, root string, user string, repostr string,
+	logger CrawlLogger) ([]*dirinfo.LocalLibrary, error) {
 	blank := []*dirinfo.LocalLibrary{}
 
-	if verbose {
-		log.Printf("  Reviewing contents of %s/%s", user, repostr)
-	}
-	// Grab information about the contents of repository's root directory
-	_, dcon, _, err := client.Repositories.GetContents(user, repostr, ".", opts)
+	logger.Debugf("  Reviewing contents of %s/%s", user, repostr)
+	// Grab information about the contents of the directory the libraries
+	// are kept in (normally the repository's root directory)
+	dcon, err := src.ReadDir(root)
 	if err != nil {
 		return blank, fmt.Errorf("Unable to fetch repository files: %v", err)
 	}
 
 	// First check to see if the root of the repository contains a package.mo
-	// file.  If so, the whole repository is a library...
+	// file.  If so, the whole repository (or the directory) is a library...
 	for _, con := range dcon {
-		if *con.Name == "package.mo" {
-			if verbose {
-				log.Printf("  Repository is a library")
-			}
+		if con.Name == "package.mo" {
+			logger.Debugf("  Repository is a library")
 
 			// Name and depedencies will be adjusted later
 			return []*dirinfo.LocalLibrary{
 				&dirinfo.LocalLibrary{
 					Name:         repostr,
-					Path:         ".",
+					Path:         root,
 					IsFile:       false,
 					Dependencies: []dirinfo.Dependency{},
 				},
@@ -80,44 +142,124 @@ func getLibraries(client *github.Client, user string, repostr string, verbose bo
 		}
 	}
 
+	submodules := readSubmodules(src, repostr, logger)
+	return findLibraries(src, dcon, repostr, 1, submodules, logger), nil
+}
+
+// The name of the (optional) file, within a repository, that contains the
+// path of the directory the libraries are kept in (e.g., src).  This is
+// for repositories (like templates) that don't keep their libraries (or
+// impact.json) at the root.
+var rootMarker = ".impact/root"
+
+// This function returns the directory (relative to the root of the
+// repository) that libraries are kept in.  This is "." unless a root
+// marker (see rootMarker) says otherwise.
+func libraryRoot(src contents, repostr string, logger CrawlLogger) string {
+	raw, err := src.ReadFile(rootMarker)
+	if err != nil {
+		return "."
+	}
+	root := path.Clean(strings.TrimSpace(string(raw)))
+	if path.IsAbs(root) || root == ".." || strings.HasPrefix(root, "../") {
+		logger.Warnf("Ignoring %s in %s, %s is outside the repository", rootMarker,
+			repostr, root)
+		return "."
+	}
+	if root != "." {
+		logger.Debugf("  Libraries in %s are kept in %s", repostr, root)
+	}
+	return root
+}
+
+// Libraries are searched for in directories up to this depth below the
+// root of a repository (e.g., a depth of 2 finds libraries/Foo/package.mo)
+var maxLibraryDepth = 2
+
+// This function looks for libraries among the given directory entries
+// (found at the given depth below the root of the repository).  Any
+// directory containing a package.mo file is a library.  Directories that
+// don't contain a package.mo file are searched recursively (up to
+// maxLibraryDepth).  At the root of the repository, any Modelica file is
+// also considered a library.  Submodules (keyed by their path) are
+// skipped since any libraries they contain are bundled dependencies.
+func findLibraries(src contents, dcon []entry, repostr string, depth int,
+	submodules map[string]submodule, logger CrawlLogger) []*dirinfo.LocalLibrary {
 	ret := []*dirinfo.LocalLibrary{}
 	for _, con := range dcon {
-		switch *con.Type {
-		case "file":
-			if strings.HasSuffix(*con.Name, ".mo") {
+		if !con.IsDir {
+			if depth == 1 && strings.HasSuffix(con.Name, ".mo") {
 				// Name and depedencies will be adjusted later
 				ret = append(ret, &dirinfo.LocalLibrary{
 					Name:         repostr,
-					Path:         *con.Path,
+					Path:         con.Path,
 					IsFile:       true,
 					Dependencies: []dirinfo.Dependency{},
 				})
 			}
-		case "dir":
-			_, subcons, _, err := client.Repositories.GetContents(user, repostr, *con.Name, opts)
-			if err != nil {
-				continue
+			continue
+		}
+
+		// Ignore hidden directories (e.g., .git)
+		if strings.HasPrefix(con.Name, ".") {
+			continue
+		}
+
+		if sub, found := submodules[path.Clean(con.Path)]; found {
+			logger.Debugf("  Ignoring %s, bundled from %s", con.Path, sub.URL)
+			continue
+		}
+
+		subcons, err := src.ReadDir(con.Path)
+		if err != nil {
+			continue
+		}
+
+		pkg := false
+		for _, sub := range subcons {
+			if sub.Name == "package.mo" {
+				pkg = true
 			}
-			for _, sub := range subcons {
-				if *sub.Name == "package.mo" {
-					// Name and depedencies will be adjusted later
-					ret = append(ret, &dirinfo.LocalLibrary{
-						Name:         repostr,
-						Path:         *con.Path,
-						IsFile:       false,
-						Dependencies: []dirinfo.Dependency{},
-					})
-				}
+		}
+
+		if !pkg {
+			if depth < maxLibraryDepth {
+				ret = append(ret, findLibraries(src, subcons, repostr, depth+1, submodules, logger)...)
+			}
+			continue
+		}
+
+		// Below the top level, make sure this really is a top-level
+		// Modelica package (and not, for example, part of some other
+		// library or example)
+		if depth > 1 {
+			raw, err := src.ReadFile(con.Path + "/package.mo")
+			if err != nil || !parsing.IsTopLevel(string(raw)) {
+				logger.Debugf("  Ignoring %s, not a top-level package", con.Path)
+				continue
 			}
 		}
+
+		// Name and depedencies will be adjusted later
+		ret = append(ret, &dirinfo.LocalLibrary{
+			Name:         repostr,
+			Path:         con.Path,
+			IsFile:       false,
+			Dependencies: []dirinfo.Dependency{},
+		})
 	}
 
-	return ret, nil
+	return ret
 }
 
-func Exists(client *github.Client, user string, reponame string,
+func Exists(client *GitHubClient, user string, reponame string,
 	path string, opts *github.RepositoryContentGetOptions) (file bool, dir bool) {
-	f, d, _, err := client.Repositories.GetContents(user, reponame, path, opts)
+	var f *github.RepositoryContent
+	var d []*github.RepositoryContent
+	err := client.call(func() (err error) {
+		f, d, _, err = client.client.Repositories.GetContents(user, reponame, path, opts)
+		return
+	})
 	if err != nil {
 		return false, false
 	}
@@ -130,46 +272,141 @@ func Exists(client *github.Client, user string, reponame string,
 	return false, false
 }
 
+// This function reports whether the given path (relative to the root of
+// the repository) is a file rather than a directory.  The second value
+// returned is false if there is nothing at that path.
+func isFile(src contents, p string) (bool, bool) {
+	p = path.Clean(p)
+	if p == "." {
+		return false, true
+	}
+	dcon, err := src.ReadDir(path.Dir(p))
+	if err != nil {
+		return false, false
+	}
+	for _, con := range dcon {
+		if con.Name == path.Base(p) {
+			return !con.IsDir, true
+		}
+	}
+	return false, false
+}
+
+// This function returns the library name implied by the location of a
+// library (or an empty string if there is none).  Any version number
+// following the name (e.g., "Modelica 3.2.2") is ignored.
+func pathName(lib *dirinfo.LocalLibrary) string {
+	if lib.Path == "." || lib.Path == "" {
+		return ""
+	}
+	base := path.Base(lib.Path)
+	if lib.IsFile {
+		base = strings.TrimSuffix(base, ".mo")
+	}
+	fields := strings.Fields(base)
+	if len(fields) == 0 {
+		return ""
+	}
+	return fields[0]
+}
+
 // The goal of this function is to construct a DirectoryInfo object.  It does this by first
 // reading whatever directory information it can find in impact.json.  Then it tries to
 // "infer" the rest using some heuristics (to lower the burden on library developers)
-func ExtractInfo(client *github.Client, user string, altname string, repo github.Repository,
-	sha string, versionString string, verbose bool, logger *log.Logger) dirinfo.DirectoryInfo {
+// If impact.json is signed by any of the given publishers, the information is marked
+// as verified (see PublisherKeys).
+func ExtractInfo(client *GitHubClient, user string, altname string, repo github.Repository,
+	sha string, versionString string, keys PublisherKeys, logger CrawlLogger) dirinfo.DirectoryInfo {
+	return extractGitHubInfo(client, user, altname, repo, sha, versionString, false, keys, logger)
+}
+
+// This function is like ExtractInfo except that, if headersOnly is true,
+// only the header of each package is read where possible (see
+// headerContents).
+func extractGitHubInfo(client *GitHubClient, user string, altname string, repo github.Repository,
+	sha string, versionString string, headersOnly bool, keys PublisherKeys,
+	logger CrawlLogger) dirinfo.DirectoryInfo {
 
 	// Extract the name of the respository
-	repostr := *repo.Name
+	repostr := stringOf(repo.Name)
 
 	// Extract information about the owner of this repository (note: this is a URI)
 	owner_uri := user
-	if repo.Owner.HTMLURL != nil {
-		owner_uri = *repo.Owner.HTMLURL
-	}
-
-	// Get the owner's email address, if provided
 	email := ""
-	if repo.Owner.Email != nil {
-		email = *repo.Owner.Email
+	if repo.Owner != nil {
+		if repo.Owner.HTMLURL != nil {
+			owner_uri = *repo.Owner.HTMLURL
+		}
+
+		// Get the owner's email address, if provided
+		email = stringOf(repo.Owner.Email)
 	}
 
+	issues := stringOf(repo.IssuesURL)
+
 	// Specify which version of the repository we are interested in
-	opts := &github.RepositoryContentGetOptions{
-		Ref: sha,
+	var src contents = gitHubContents{
+		client: client,
+		user:   user,
+		repo:   repostr,
+		opts: &github.RepositoryContentGetOptions{
+			Ref: sha,
+		},
 	}
+	if headersOnly {
+		src = headerContents{contents: src, limit: headerBytes}
+	}
+
+	return extractInfo(src, user, repostr, owner_uri, email, gitHubLicense(repo.License), issues,
+		keys, logger)
+}
+
+// This function applies the heuristics described for ExtractInfo to the
+// contents of any repository.  The owner URI, email address, license and
+// issues URL are used whenever they are not explicitly provided in
+// impact.json.  If the license still isn't known, it is identified from
+// any license file found in the repository.  The information is only
+// verified if impact.json is signed by one of the given publishers.
+func extractInfo(src contents, user string, repostr string, owner_uri string, email string,
+	license string, issues string, keys PublisherKeys, logger CrawlLogger) dirinfo.DirectoryInfo {
+	// Details of the files read are logged at the file level
+	logger = withLevel(logger, FileLevel)
 
 	// Create a "blank" directory info as default
 	di := dirinfo.MakeDirectoryInfo()
 
+	// Libraries (and impact.json) may be kept in a subdirectory
+	root := libraryRoot(src, repostr, logger)
+
 	// Parse any impact.json file the is present
-	fcon, _, _, err := client.Repositories.GetContents(user, repostr, "impact.json", opts)
+	raw, err := src.ReadFile(path.Join(root, "impact.json"))
 
 	// If impact.json exists, parse it and use that as our baseline
-	if fcon != nil && err == nil {
-		pdi, perr := dirinfo.Parse(*fcon.Content)
+	if err == nil {
+		pdi, perr := dirinfo.Parse(string(raw))
 		if perr == nil {
-			log.Printf("Parsed impact.json file in %s: %v", repostr, pdi)
+			logger.Debugf("Parsed impact.json file in %s: %v", repostr, pdi)
 			di = pdi
+			di.Verified = verifyMetadata(src, root, raw, keys, repostr, logger)
+			// The paths of libraries are recorded relative to the root
+			// of the repository (so that archives can be extracted)
+			if root != "." {
+				for _, lib := range di.Libraries {
+					lib.Path = path.Join(root, lib.Path)
+				}
+			}
+			// Whether a library is a single file is often left out of
+			// impact.json, so it is taken from the repository itself
+			for _, lib := range di.Libraries {
+				file, found := isFile(src, lib.Path)
+				if found && file != lib.IsFile {
+					logger.Debugf("  Library %s in %s is stored as a single file: %v",
+						lib.Path, repostr, file)
+					lib.IsFile = file
+				}
+			}
 		} else {
-			log.Printf("Unable to parse impact.json in %s: %v", repostr, perr)
+			logger.Warnf("Unable to parse impact.json in %s: %v", repostr, perr)
 		}
 	}
 
@@ -182,26 +419,47 @@ func ExtractInfo(client *github.Client, user string, altname string, repo github
 		di.OwnerURI = owner_uri
 	}
 
-	// Ditto with email
+	// Ditto with email (although the first maintainer with an email
+	// address is the next best contact)
+	for _, m := range di.Maintainers {
+		if di.Email == "" {
+			di.Email = m.Email
+		}
+	}
 	if di.Email == "" {
 		di.Email = email
 	}
 
+	// If no maintainers are listed, the contact is the only maintainer
+	if len(di.Maintainers) == 0 && di.Email != "" {
+		di.Maintainers = []dirinfo.Maintainer{{Email: di.Email}}
+	}
+
+	// The license may have been detected by the host, otherwise look
+	// for a license file
+	if di.License == "" {
+		di.License = license
+	}
+	if di.License == "" {
+		di.License = detectLicense(src, repostr, logger)
+	}
+
 	// Are any libraries mentioned?  If not, we need to figure out what the structure
 	// is here.  There are two patterns.  Either a file named <RepoName>.mo or a
 	// directory named <RepoName>.  If neither of these conventions is followed, the
 	// library developers needs to add an explicit impact.json
 	if len(di.Libraries) == 0 {
-		libs, err := getLibraries(client, user, repostr, verbose, opts)
+		libs, err := getLibraries(src, root, user, repostr, logger)
 		if err != nil {
-			if verbose {
-				log.Printf("No libraries found in %s/%s", user, repostr)
-			}
+			logger.Debugf("No libraries found in %s/%s", user, repostr)
 		}
 		di.Libraries = libs
 	}
 
-	// Now, let's loop over all the libraries we are aware of...
+	// Now, let's loop over all the libraries we are aware of.  Each one is
+	// parsed independently so that a library that can't be parsed doesn't
+	// prevent the others from being indexed.
+	parsed := []*dirinfo.LocalLibrary{}
 	for _, lib := range di.Libraries {
 		// Determine path to top-level package in repository
 		path := lib.Path
@@ -210,25 +468,100 @@ func ExtractInfo(client *github.Client, user string, altname string, repo github
 		}
 
 		// Extract information about any libraries this library uses
-		name, uses, err := parsePackage(client, user, repostr, path, opts)
+		pkg, err := parsePackage(src, repostr, path, logger)
 		if err != nil {
-			log.Printf("Error extracting uses annotation: %v", err)
+			di.Errors = append(di.Errors, dirinfo.LibraryError{
+				Name:    lib.Name,
+				Path:    lib.Path,
+				Message: err.Error(),
+			})
 			continue
 		}
-
+		name := pkg.name
+		uses := pkg.uses
+
+		// The name declared in the package is authoritative but it should
+		// normally match where the library is stored
+		expected := pathName(lib)
+		if expected != "" && expected != name {
+			logger.Warnf("Library %s in %s declares package %s (expected %s)",
+				lib.Path, repostr, name, expected)
+		}
 		lib.Name = name
 
-		for libname, ver := range uses {
-			lib.Dependencies = append(lib.Dependencies, dirinfo.Dependency{
-				Name:    libname,
-				Version: ver,
-			})
+		// A version given in impact.json takes precedence over the
+		// version annotation
+		if lib.Version == "" {
+			lib.Version = pkg.version
+		}
+		// Ditto for the description
+		if lib.Description == "" {
+			lib.Description = pkg.description
+		}
+
+		// Process the uses annotation in a predictable order
+		names := []string{}
+		for libname := range uses {
+			names = append(names, libname)
+		}
+		sort.Strings(names)
+
+		if len(lib.Dependencies) == 0 {
+			// If the dependencies aren't explicitly given (in impact.json),
+			// use the uses annotation
+			for _, libname := range names {
+				lib.Dependencies = append(lib.Dependencies, dirinfo.Dependency{
+					Name:    libname,
+					Version: uses[libname],
+				})
+			}
+		} else {
+			// Otherwise, the explicit dependencies take precedence but
+			// warn about any disagreement with the uses annotation
+			explicit := map[string]semver.Version{}
+			for _, dep := range lib.Dependencies {
+				explicit[dep.Name] = dep.Version
+			}
+			for _, libname := range names {
+				ev, found := explicit[libname]
+				if !found {
+					logger.Warnf("%s in %s uses %s %s but impact.json doesn't list it as a dependency",
+						name, repostr, libname, uses[libname].String())
+				} else if !ev.EQ(uses[libname]) {
+					logger.Warnf("%s in %s uses %s %s but impact.json specifies version %s",
+						name, repostr, libname, uses[libname].String(), ev.String())
+				}
+			}
 		}
 
 		if lib.IssuesURL == "" {
-			lib.IssuesURL = *repo.IssuesURL
+			lib.IssuesURL = issues
 		}
+
+		// The version of the Modelica Standard Library used is recorded
+		// separately (so that versions can be filtered by it).  This is
+		// always taken from the dependencies.
+		lib.Modelica = ""
+		for _, dep := range lib.Dependencies {
+			if dep.Name == "Modelica" {
+				lib.Modelica = dep.Version.String()
+				break
+			}
+		}
+
+		parsed = append(parsed, lib)
 	}
+	di.Libraries = parsed
 
 	return di
 }
+
+// This function logs the libraries (if any) that couldn't be parsed in
+// the given version of a repository.
+func reportLibraryErrors(di dirinfo.DirectoryInfo, repostr string, version string,
+	logger CrawlLogger) {
+	for _, e := range di.Errors {
+		logger.Errorf("Library %s in %s at %s failed to parse: %s",
+			e.Library(), repostr, version, e.Message)
+	}
+}
@@ -0,0 +1,442 @@
+package crawl
+
+import (
+	"bytes"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/blang/semver"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+
+	"github.com/impact/impact/recorder"
+)
+
+func TestInvalidDependencies(t *testing.T) {
+	Convey("Testing dependencies with invalid versions", t, func(c C) {
+		root, err := ioutil.TempDir("", "impact")
+		NoError(c, err)
+		defer os.RemoveAll(root)
+
+		writeFile(c, filepath.Join(root, "package.mo"), `within;
+package Foo
+  annotation(uses(Modelica(version="3.2"), Bad(version="1.0.x"), Range(version=">=1.0 <2.0")));
+end Foo;`)
+
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		pkg, err := parsePackage(fileSystemContents{root: root}, "Foo", "package.mo", logger)
+		NoError(c, err)
+		Equals(c, pkg.name, "Foo")
+		Equals(c, len(pkg.uses), 1)
+		Equals(c, pkg.uses["Modelica"].String(), "3.2.0")
+		Equals(c, pkg.description, "")
+
+		writeFile(c, filepath.Join(root, "package.mo"), `within;
+package Foo "A library"
+end Foo;`)
+		pkg, err = parsePackage(fileSystemContents{root: root}, "Foo", "package.mo", logger)
+		NoError(c, err)
+		Equals(c, pkg.description, "A library")
+	})
+}
+
+func TestUsesDependencies(t *testing.T) {
+	Convey("Testing dependencies from uses annotations", t, func(c C) {
+		root, err := ioutil.TempDir("", "impact")
+		NoError(c, err)
+		defer os.RemoveAll(root)
+
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		pkg := `within;
+package Foo
+  annotation(uses(Modelica(version="4.0.0"), Complex(version="4.0.0")));
+end Foo;`
+		writeFile(c, filepath.Join(root, "Foo", "package.mo"), pkg)
+
+		// Without impact.json, dependencies come from the uses annotation
+		di := extractInfo(fileSystemContents{root: root}, "a", "Foo", "", "", "", "", nil, logger)
+		Equals(c, len(di.Libraries), 1)
+		deps := di.Libraries[0].Dependencies
+		Equals(c, len(deps), 2)
+		Equals(c, deps[0].Name, "Complex")
+		Equals(c, deps[1].Name, "Modelica")
+		Equals(c, deps[1].Version.String(), "4.0.0")
+		Equals(c, di.Libraries[0].Modelica, "4.0.0")
+
+		// Explicit dependencies take precedence
+		writeFile(c, filepath.Join(root, "impact.json"), `{
+  "libraries": [{
+    "name": "Foo",
+    "path": "Foo",
+    "dependencies": [{"name": "Modelica", "version": "3.2.2"}]
+  }]
+}`)
+		di = extractInfo(fileSystemContents{root: root}, "a", "Foo", "", "", "", "", nil, logger)
+		Equals(c, len(di.Libraries), 1)
+		deps = di.Libraries[0].Dependencies
+		Equals(c, len(deps), 1)
+		Equals(c, deps[0].Name, "Modelica")
+		Equals(c, deps[0].Version.String(), "3.2.2")
+	})
+}
+
+func TestDeclaredName(t *testing.T) {
+	Convey("Testing library names declared in package.mo", t, func(c C) {
+		root, err := ioutil.TempDir("", "impact")
+		NoError(c, err)
+		defer os.RemoveAll(root)
+
+		writeFile(c, filepath.Join(root, "Right 1.0", "package.mo"), "within;\npackage Right\nend Right;")
+		writeFile(c, filepath.Join(root, "Wrong", "package.mo"), "within;\npackage Other\nend Other;")
+
+		var buf bytes.Buffer
+		logger := StandardLogger(log.New(&buf, "", 0), Quiet)
+		di := extractInfo(fileSystemContents{root: root}, "a", "Repo", "", "", "", "", nil, logger)
+		names := map[string]bool{}
+		for _, lib := range di.Libraries {
+			names[lib.Name] = true
+		}
+		Equals(c, len(names), 2)
+		IsTrue(c, names["Right"])
+		IsTrue(c, names["Other"])
+		Equals(c, strings.Count(buf.String(), "declares package"), 1)
+		IsTrue(c, strings.Contains(buf.String(), "Wrong in Repo declares package Other (expected Wrong)"))
+	})
+}
+
+func TestNestedLibraries(t *testing.T) {
+	Convey("Testing libraries stored in subdirectories", t, func(c C) {
+		root, err := ioutil.TempDir("", "impact")
+		NoError(c, err)
+		defer os.RemoveAll(root)
+
+		writeFile(c, filepath.Join(root, "README.md"), "Several libraries")
+		writeFile(c, filepath.Join(root, "libraries", "Foo", "package.mo"),
+			"within;\npackage Foo\nend Foo;")
+		writeFile(c, filepath.Join(root, "libraries", "Bar", "package.mo"),
+			"within ;\npackage Bar\nend Bar;")
+		// Not a top-level package
+		writeFile(c, filepath.Join(root, "libraries", "Sub", "package.mo"),
+			"within Foo;\npackage Sub\nend Sub;")
+		// Too deep
+		writeFile(c, filepath.Join(root, "a", "b", "Deep", "package.mo"),
+			"within;\npackage Deep\nend Deep;")
+
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		libs, err := getLibraries(fileSystemContents{root: root}, ".", "a", "Repo", logger)
+		NoError(c, err)
+
+		paths := map[string]bool{}
+		for _, lib := range libs {
+			paths[lib.Path] = true
+			IsTrue(c, !lib.IsFile)
+		}
+		Equals(c, len(libs), 2)
+		IsTrue(c, paths["libraries/Foo"])
+		IsTrue(c, paths["libraries/Bar"])
+
+		di := extractInfo(fileSystemContents{root: root}, "a", "Repo", "", "", "", "", nil, logger)
+		names := map[string]bool{}
+		for _, lib := range di.Libraries {
+			names[lib.Name] = true
+		}
+		IsTrue(c, names["Foo"])
+		IsTrue(c, names["Bar"])
+	})
+}
+
+func TestSubmodules(t *testing.T) {
+	Convey("Testing libraries bundled as git submodules", t, func(c C) {
+		root, err := ioutil.TempDir("", "impact")
+		NoError(c, err)
+		defer os.RemoveAll(root)
+
+		writeFile(c, filepath.Join(root, ".gitmodules"),
+			"[submodule \"Dep\"]\n\tpath = libs/Dep\n\turl = https://github.com/b/Dep.git\n")
+		writeFile(c, filepath.Join(root, "Foo", "package.mo"),
+			"within;\npackage Foo\nend Foo;")
+		writeFile(c, filepath.Join(root, "libs", "Dep", "package.mo"),
+			"within;\npackage Dep\nend Dep;")
+		writeFile(c, filepath.Join(root, "libs", "Own", "package.mo"),
+			"within;\npackage Own\nend Own;")
+
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		libs, err := getLibraries(fileSystemContents{root: root}, ".", "a", "Repo", logger)
+		NoError(c, err)
+
+		paths := map[string]bool{}
+		for _, lib := range libs {
+			paths[lib.Path] = true
+		}
+		Equals(c, len(libs), 2)
+		IsTrue(c, paths["Foo"])
+		IsTrue(c, paths["libs/Own"])
+
+		di := extractInfo(fileSystemContents{root: root}, "a", "Repo", "", "", "", "", nil, logger)
+		names := map[string]bool{}
+		for _, lib := range di.Libraries {
+			names[lib.Name] = true
+		}
+		Equals(c, len(names), 2)
+		IsFalse(c, names["Dep"])
+	})
+}
+
+func TestParseGitModules(t *testing.T) {
+	Convey("Testing parsing .gitmodules", t, func(c C) {
+		subs := parseGitModules(`# Bundled dependencies
+[submodule "Dep"]
+	path = libs/Dep/
+	url = https://github.com/b/Dep.git
+[core]
+	path = ignored
+[submodule "NoPath"]
+	url = https://github.com/b/NoPath.git
+[submodule "Other"]
+	url = git@github.com:c/Other.git
+	path = "Other"
+`)
+		Resembles(c, subs, []submodule{
+			{Name: "Dep", Path: "libs/Dep", URL: "https://github.com/b/Dep.git"},
+			{Name: "Other", Path: "Other", URL: "git@github.com:c/Other.git"},
+		})
+	})
+}
+
+func TestLibraryRoot(t *testing.T) {
+	Convey("Testing libraries kept in a subdirectory", t, func(c C) {
+		root, err := ioutil.TempDir("", "impact")
+		NoError(c, err)
+		defer os.RemoveAll(root)
+
+		writeFile(c, filepath.Join(root, ".impact", "root"), "src\n")
+		writeFile(c, filepath.Join(root, "LICENSE"), "Permission is hereby granted, free of charge")
+		writeFile(c, filepath.Join(root, "template", "package.mo"),
+			"within;\npackage Template\nend Template;")
+		writeFile(c, filepath.Join(root, "src", "Foo", "package.mo"),
+			"within;\npackage Foo\nend Foo;")
+
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		di := extractInfo(fileSystemContents{root: root}, "a", "Repo", "", "", "", "", nil, logger)
+		Equals(c, len(di.Libraries), 1)
+		Equals(c, di.Libraries[0].Name, "Foo")
+		// Paths are still relative to the root of the repository
+		Equals(c, di.Libraries[0].Path, "src/Foo")
+		Equals(c, di.License, "MIT")
+
+		// The subdirectory can itself be the library
+		writeFile(c, filepath.Join(root, ".impact", "root"), "src/Foo")
+		di = extractInfo(fileSystemContents{root: root}, "a", "Repo", "", "", "", "", nil, logger)
+		Equals(c, len(di.Libraries), 1)
+		Equals(c, di.Libraries[0].Path, "src/Foo")
+
+		// As can the impact.json file
+		writeFile(c, filepath.Join(root, ".impact", "root"), "src")
+		writeFile(c, filepath.Join(root, "src", "impact.json"), `{
+  "libraries": [{"name": "Foo", "path": "Foo"}]
+}`)
+		di = extractInfo(fileSystemContents{root: root}, "a", "Repo", "", "", "", "", nil, logger)
+		Equals(c, len(di.Libraries), 1)
+		Equals(c, di.Libraries[0].Path, "src/Foo")
+		Equals(c, len(di.Errors), 0)
+
+		// But not outside the repository
+		buf := bytes.Buffer{}
+		logger = StandardLogger(log.New(&buf, "", 0), Quiet)
+		writeFile(c, filepath.Join(root, ".impact", "root"), "../other")
+		Equals(c, libraryRoot(fileSystemContents{root: root}, "a/Repo", logger), ".")
+		IsTrue(c, strings.Contains(buf.String(), "is outside the repository"))
+	})
+}
+
+func TestLibraryLayouts(t *testing.T) {
+	Convey("Testing single file and directory libraries", t, func(c C) {
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		layout := func(files map[string]string, impact string) (string, bool) {
+			root, err := ioutil.TempDir("", "impact")
+			NoError(c, err)
+			defer os.RemoveAll(root)
+			for name, code := range files {
+				writeFile(c, filepath.Join(root, name), code)
+			}
+			if impact != "" {
+				writeFile(c, filepath.Join(root, "impact.json"), impact)
+			}
+			di := extractInfo(fileSystemContents{root: root}, "a", "Foo", "", "", "", "", nil, logger)
+			Equals(c, len(di.Libraries), 1)
+			Equals(c, len(di.Errors), 0)
+			Equals(c, di.Libraries[0].Name, "Foo")
+			return di.Libraries[0].Path, di.Libraries[0].IsFile
+		}
+		code := "within;\npackage Foo\nend Foo;"
+
+		p, file := layout(map[string]string{"Foo.mo": code}, "")
+		Equals(c, p, "Foo.mo")
+		IsTrue(c, file)
+
+		p, file = layout(map[string]string{"Foo/package.mo": code}, "")
+		Equals(c, p, "Foo")
+		IsFalse(c, file)
+
+		p, file = layout(map[string]string{"package.mo": code}, "")
+		Equals(c, p, ".")
+		IsFalse(c, file)
+
+		// Whether a library listed in impact.json is a single file comes
+		// from the repository
+		p, file = layout(map[string]string{"Foo.mo": code},
+			`{"libraries": [{"name": "Foo", "path": "Foo.mo"}]}`)
+		Equals(c, p, "Foo.mo")
+		IsTrue(c, file)
+
+		p, file = layout(map[string]string{"Foo/package.mo": code},
+			`{"libraries": [{"name": "Foo", "path": "Foo", "isFile": true}]}`)
+		Equals(c, p, "Foo")
+		IsFalse(c, file)
+	})
+}
+
+// This counts how many files are read completely
+type countingContents struct {
+	fileSystemContents
+	reads *int
+}
+
+func (cc countingContents) ReadFile(p string) ([]byte, error) {
+	*cc.reads++
+	return cc.fileSystemContents.ReadFile(p)
+}
+
+func TestPackageHeaders(t *testing.T) {
+	Convey("Testing reading just the header of packages", t, func(c C) {
+		root, err := ioutil.TempDir("", "impact")
+		NoError(c, err)
+		defer os.RemoveAll(root)
+
+		body := strings.Repeat("  model M\n  end M;\n", 100)
+		writeFile(c, filepath.Join(root, "Foo", "package.mo"), `within;
+package Foo
+  annotation(uses(Modelica(version="3.2.2")), version="1.2.0");
+`+body+`end Foo;`)
+		writeFile(c, filepath.Join(root, "Bar", "package.mo"), `within;
+package Bar
+`+body+`  annotation(uses(Modelica(version="3.2.1")), version="2.0.0");
+end Bar;`)
+		writeFile(c, filepath.Join(root, "Baz", "package.mo"), `within;
+package Baz
+end Baz;`)
+
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		reads := 0
+		src := headerContents{
+			contents: countingContents{fileSystemContents{root: root}, &reads},
+			limit:    200,
+		}
+		check := func(mopath string, name string, version string, modelica string) {
+			pkg, err := parsePackage(src, "Repo", mopath, logger)
+			NoError(c, err)
+			Equals(c, pkg.name, name)
+			Equals(c, pkg.version, version)
+			Equals(c, pkg.uses["Modelica"].String(), modelica)
+		}
+
+		// The annotation is found in the header
+		check("Foo/package.mo", "Foo", "1.2.0", "3.2.2")
+		Equals(c, reads, 0)
+		// But not here so all of the file is read
+		check("Bar/package.mo", "Bar", "2.0.0", "3.2.1")
+		Equals(c, reads, 1)
+		// A small file can be read completely
+		pkg, err := parsePackage(src, "Repo", "Baz/package.mo", logger)
+		NoError(c, err)
+		Equals(c, pkg.name, "Baz")
+		Equals(c, len(pkg.uses), 0)
+		Equals(c, reads, 1)
+	})
+}
+
+func TestLibraryErrors(t *testing.T) {
+	Convey("Testing libraries that can't be parsed", t, func(c C) {
+		root, err := ioutil.TempDir("", "impact")
+		NoError(c, err)
+		defer os.RemoveAll(root)
+
+		writeFile(c, filepath.Join(root, "impact.json"), `{
+  "libraries": [
+    {"name": "Foo", "path": "Foo"},
+    {"name": "Bar", "path": "Bar"},
+    {"path": "Baz"}
+  ]
+}`)
+		writeFile(c, filepath.Join(root, "Foo", "package.mo"), `within;
+package Foo
+end Foo;`)
+		writeFile(c, filepath.Join(root, "Baz", "package.mo"), `within;
+// Not a package`)
+
+		buf := bytes.Buffer{}
+		logger := StandardLogger(log.New(&buf, "", 0), Quiet)
+		di := extractInfo(fileSystemContents{root: root}, "a", "Repo", "", "", "", "", nil, logger)
+
+		// The library that could be parsed is still found
+		Equals(c, len(di.Libraries), 1)
+		Equals(c, di.Libraries[0].Name, "Foo")
+		Equals(c, len(di.Errors), 2)
+		Equals(c, di.Errors[0].Library(), "Bar")
+		IsTrue(c, strings.Contains(di.Errors[0].Message, "Bar/package.mo"))
+		// Without a name, the library is identified by its path
+		Equals(c, di.Errors[1].Library(), "Baz")
+
+		reportLibraryErrors(di, "a/Repo", "v1.0.0", logger)
+		IsTrue(c, strings.Contains(buf.String(), "Error: Library Bar in a/Repo at v1.0.0 failed to parse: "))
+	})
+}
+
+func TestMaintainers(t *testing.T) {
+	Convey("Testing maintainers of libraries", t, func(c C) {
+		root, err := ioutil.TempDir("", "impact")
+		NoError(c, err)
+		defer os.RemoveAll(root)
+
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		writeFile(c, filepath.Join(root, "Foo", "package.mo"), `within;
+package Foo
+end Foo;`)
+
+		// Without any maintainers, the contact is the only maintainer
+		di := extractInfo(fileSystemContents{root: root}, "a", "Foo", "", "owner@example.com",
+			"", "", nil, logger)
+		Equals(c, di.Email, "owner@example.com")
+		Equals(c, len(di.Maintainers), 1)
+		Equals(c, di.Maintainers[0].Email, "owner@example.com")
+
+		// Otherwise, the first maintainer (with an email address) is the
+		// contact
+		writeFile(c, filepath.Join(root, "impact.json"), `{
+  "maintainers": [
+    {"name": "Jane Doe"},
+    {"name": "John Doe", "email": "john@example.com"}
+  ]
+}`)
+		di = extractInfo(fileSystemContents{root: root}, "a", "Foo", "", "owner@example.com",
+			"", "", nil, logger)
+		Equals(c, di.Email, "john@example.com")
+		Equals(c, len(di.Maintainers), 2)
+		Equals(c, di.Maintainers[0].Name, "Jane Doe")
+
+		m := recorder.NewMemoryRecorder()
+		recordVersion(m, di, repoDetails{URI: "https://github.com/a/Foo", Stars: -1},
+			semver.MustParse("1.0.0"), versionRecord{sha: "abc"}, recordPolicy{}, logger)
+		foo := m.Find("Foo")
+		Equals(c, foo.Email, "john@example.com")
+		Resembles(c, foo.Maintainers, []recorder.Maintainer{
+			{Name: "Jane Doe"},
+			{Name: "John Doe", Email: "john@example.com"},
+		})
+	})
+}
@@ -0,0 +1,122 @@
+package crawl
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/blang/semver"
+	"github.com/google/go-github/github"
+
+	"github.com/impact/impact/dirinfo"
+	"github.com/impact/impact/recorder"
+)
+
+// A VersionFilter decides whether a library found in a version of a
+// repository should be indexed.  This allows policies that can't be
+// expressed with version ranges or exclusions (e.g., skipping versions
+// older than the first stable release of a library).  The version is the
+// one the repository was tagged with.  Returning false skips the library
+// (for just that version).
+type VersionFilter func(lib dirinfo.LocalLibrary, version semver.Version, repo github.Repository) bool
+
+// A VersionHook is called right after each version of a library has been
+// recorded (e.g., to report progress or to download and test the
+// version).  It is called in the same goroutine that recorded the
+// version, so it may be called concurrently when crawling concurrently.
+// Any error returned is logged and may abort the crawl (see
+// SetVersionHook).
+type VersionHook func(library string, version string, vr recorder.VersionRecorder) error
+
+// This function returns the libraries (found in the given version of a
+// repository) accepted by the version filter (see SetVersionFilter).
+func (c GitHubCrawler) acceptedLibraries(libs []*dirinfo.LocalLibrary, v semver.Version,
+	repo github.Repository, logger CrawlLogger) []*dirinfo.LocalLibrary {
+	if c.filter == nil {
+		return libs
+	}
+	ret := []*dirinfo.LocalLibrary{}
+	for _, lib := range libs {
+		if c.filter(*lib, v, repo) {
+			ret = append(ret, lib)
+			continue
+		}
+		logger.Debugf("    Library %s: Rejected by version filter", lib.Name)
+		if c.dryRun {
+			logger.Infof("    Would skip %s:%s (rejected by version filter)", lib.Name, v.String())
+		}
+	}
+	return ret
+}
+
+// The versionHook type holds the hook called after each version is
+// recorded along with whether an error returned by the hook aborts the
+// crawl.
+type versionHook struct {
+	hook  VersionHook
+	abort bool
+	// Called when the crawl is aborted (if not nil)
+	cancel func()
+	mutex  sync.Mutex
+	// The error that aborted the crawl (if any)
+	err error
+}
+
+func newVersionHook(hook VersionHook, abort bool) *versionHook {
+	if hook == nil {
+		return nil
+	}
+	return &versionHook{
+		hook:  hook,
+		abort: abort,
+	}
+}
+
+// This function returns a copy of the hook to use for a single crawl.
+// The given function (if any) is called if the crawl is aborted.
+func (h *versionHook) start(cancel func()) *versionHook {
+	if h == nil {
+		return nil
+	}
+	return &versionHook{
+		hook:   h.hook,
+		abort:  h.abort,
+		cancel: cancel,
+	}
+}
+
+// This function calls the hook (if any) for a version that has just been
+// recorded.
+func (h *versionHook) call(library string, v semver.Version, vr recorder.VersionRecorder,
+	logger CrawlLogger) {
+	if h == nil {
+		return
+	}
+	err := h.hook(library, v.String(), vr)
+	if err == nil {
+		return
+	}
+	logger.Errorf("Version hook for %s %s: %v", library, v.String(), err)
+	if !h.abort {
+		return
+	}
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if h.err == nil {
+		h.err = fmt.Errorf("Version hook failed for %s %s: %v", library, v.String(), err)
+		if h.cancel != nil {
+			h.cancel()
+		}
+	}
+}
+
+// This function returns the error that aborted the crawl (or nil if the
+// crawl hasn't been aborted).
+func (h *versionHook) failure() error {
+	if h == nil {
+		return nil
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	return h.err
+}
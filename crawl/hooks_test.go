@@ -0,0 +1,135 @@
+package crawl
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/blang/semver"
+	"github.com/google/go-github/github"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+
+	"github.com/impact/impact/dirinfo"
+	"github.com/impact/impact/recorder"
+)
+
+func TestVersionHook(t *testing.T) {
+	Convey("Testing the hook called after each version is recorded", t, func(c C) {
+		buf := bytes.Buffer{}
+		logger := StandardLogger(log.New(&buf, "", 0), Quiet)
+
+		di := dirinfo.MakeDirectoryInfo()
+		di.Libraries = []*dirinfo.LocalLibrary{
+			{Name: "Foo", Path: "Foo", Dependencies: []dirinfo.Dependency{
+				{Name: "Modelica", Version: semver.MustParse("3.2.2")},
+			}},
+			{Name: "Bar", Path: "Bar"},
+		}
+		details := repoDetails{URI: "https://github.com/a/Repo", Stars: -1}
+		record := func(hook *versionHook) *recorder.MemoryRecorder {
+			m := recorder.NewMemoryRecorder()
+			recordVersion(m, di, details, semver.MustParse("1.0.0"), versionRecord{sha: "abc"},
+				recordPolicy{hook: hook}, logger)
+			return m
+		}
+
+		// Without a hook, nothing changes
+		Equals(c, len(record(nil).Libraries), 2)
+		IsNil(c, newVersionHook(nil, true))
+
+		called := []string{}
+		deps := 0
+		hook := newVersionHook(func(library string, version string, vr recorder.VersionRecorder) error {
+			called = append(called, library+" "+version)
+			// The version has been completely recorded by now
+			deps += len(vr.(*recorder.MemoryVersion).Dependencies)
+			if library == "Foo" {
+				return fmt.Errorf("Unable to load %s", library)
+			}
+			return nil
+		}, false)
+
+		// Errors are only logged unless they abort the crawl
+		crawl := hook.start(nil)
+		record(crawl)
+		Resembles(c, called, []string{"Foo 1.0.0", "Bar 1.0.0"})
+		Equals(c, deps, 1)
+		NoError(c, crawl.failure())
+		IsTrue(c, strings.Contains(buf.String(), "Version hook for Foo 1.0.0: Unable to load Foo"))
+
+		cancelled := 0
+		hook.abort = true
+		crawl = hook.start(func() { cancelled++ })
+		record(crawl)
+		record(crawl)
+		Equals(c, cancelled, 1)
+		IsError(c, crawl.failure())
+		Equals(c, crawl.failure().Error(), "Version hook failed for Foo 1.0.0: Unable to load Foo")
+
+		// Each crawl starts afresh
+		NoError(c, hook.start(nil).failure())
+	})
+}
+
+func TestVersionFilter(t *testing.T) {
+	Convey("Testing rejecting versions with a custom filter", t, func(c C) {
+		// Nothing but the (cached) libraries is needed
+		server := httptest.NewServer(http.NotFoundHandler())
+		defer server.Close()
+
+		dir, err := ioutil.TempDir("", "impact")
+		NoError(c, err)
+		defer os.RemoveAll(dir)
+
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		client := github.NewClient(nil)
+		base, err := url.Parse(server.URL + "/")
+		NoError(c, err)
+		client.BaseURL = base
+		gc := NewGitHubClient(client, 0, logger)
+		gc.SetRetries(0, 0)
+
+		cr, err := MakeGitHubCrawler("a", nil, "")
+		NoError(c, err)
+		cr.cache, err = loadCache(filepath.Join(dir, "cache.json"), logger)
+		NoError(c, err)
+		for _, v := range []string{"1.0.0", "1.1.0"} {
+			di := dirinfo.MakeDirectoryInfo()
+			di.Libraries = []*dirinfo.LocalLibrary{{Name: "Foo", Path: "Foo"}, {Name: "Bar", Path: "Bar"}}
+			cr.cache.setVersion("a/Repo/"+v, "abc", "", di)
+		}
+
+		repo := github.Repository{
+			Name:    github.String("Repo"),
+			Owner:   &github.User{Login: github.String("a")},
+			HTMLURL: github.String("https://github.com/a/Repo"),
+		}
+		calls := 0
+		cr.SetVersionFilter(func(lib dirinfo.LocalLibrary, v semver.Version, r github.Repository) bool {
+			calls++
+			Equals(c, stringOf(r.Name), "Repo")
+			// Skip odd-numbered minor versions and Bar altogether
+			return v.Minor%2 == 0 && lib.Name != "Bar"
+		})
+
+		m := recorder.NewMemoryRecorder()
+		IsTrue(c, cr.processVersion(gc, m, "Repo", repo, candidate{version: "1.0.0", sha: "abc"}, logger))
+		IsFalse(c, cr.processVersion(gc, m, "Repo", repo, candidate{version: "1.1.0", sha: "abc"}, logger))
+		Equals(c, calls, 4)
+		Equals(c, len(m.Libraries), 1)
+		for _, lib := range m.Libraries {
+			Equals(c, lib.Name, "Foo")
+			Equals(c, len(lib.Versions), 1)
+		}
+	})
+}
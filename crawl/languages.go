@@ -0,0 +1,58 @@
+package crawl
+
+import (
+	"github.com/google/go-github/github"
+)
+
+// The language (as detected by GitHub) that libraries are written in
+const modelicaLanguage = "Modelica"
+
+// This function determines whether the given repository should be
+// indexed based on the languages GitHub detected in it (see
+// SetMinModelicaBytes and SetPrimaryLanguageOnly).  This avoids indexing
+// repositories that merely mention Modelica.  The languages are only
+// fetched if a minimum amount of Modelica code is required.
+// Repositories whose languages can't be fetched are skipped (and
+// recorded as failures).
+func (c GitHubCrawler) languageAllowed(client *GitHubClient, repo github.Repository,
+	logger CrawlLogger) bool {
+	rname := stringOf(repo.Name)
+	if c.primaryLanguage && stringOf(repo.Language) != modelicaLanguage {
+		logger.Infof("Skipping: %s (%s), primary language is '%s' rather than %s",
+			rname, stringOf(repo.HTMLURL), stringOf(repo.Language), modelicaLanguage)
+		return false
+	}
+	if c.minModelicaBytes <= 0 {
+		return true
+	}
+
+	languages, err := c.repositoryLanguages(client, rname)
+	if err != nil {
+		logger.Warnf("Unable to fetch languages for repo %s/%s: %v", c.user, rname, err)
+		c.fail(client, rname, PhaseLanguages, err)
+		return false
+	}
+
+	bytes := languages[modelicaLanguage]
+	if bytes < c.minModelicaBytes {
+		logger.Infof("Skipping: %s (%s), only %d bytes of %s (minimum is %d)",
+			rname, stringOf(repo.HTMLURL), bytes, modelicaLanguage, c.minModelicaBytes)
+		return false
+	}
+	return true
+}
+
+// This function returns the number of bytes of code in each language in
+// the named repository.  These come from the GraphQL listing if the
+// repository was listed that way (see SetGraphQL).
+func (c GitHubCrawler) repositoryLanguages(client *GitHubClient, rname string) (map[string]int, error) {
+	if pre, exists := c.prefetched[rname]; exists {
+		return pre.languages, nil
+	}
+	var languages map[string]int
+	err := client.call(func() (err error) {
+		languages, _, err = client.client.Repositories.ListLanguages(c.user, rname)
+		return
+	})
+	return languages, err
+}
@@ -0,0 +1,74 @@
+package crawl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/github"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+)
+
+func TestLanguages(t *testing.T) {
+	Convey("Testing skipping repositories based on their languages", t, func(c C) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/repos/a/Foo/languages":
+				fmt.Fprint(w, `{"Modelica": 50000, "Python": 2000}`)
+			case "/repos/a/Docs/languages":
+				fmt.Fprint(w, `{"HTML": 80000, "Modelica": 300}`)
+			case "/repos/a/Empty/languages":
+				fmt.Fprint(w, `{}`)
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		defer server.Close()
+
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		client := github.NewClient(nil)
+		base, err := url.Parse(server.URL + "/")
+		NoError(c, err)
+		client.BaseURL = base
+		gc := NewGitHubClient(client, 0, logger)
+		gc.SetRetries(0, 0)
+
+		repo := func(name string, language string) github.Repository {
+			return github.Repository{Name: github.String(name), Language: github.String(language)}
+		}
+
+		cr, err := MakeGitHubCrawler("a", nil, "")
+		NoError(c, err)
+		cr.failures = &repoErrors{}
+
+		// By default, nothing needs to be fetched
+		IsTrue(c, cr.languageAllowed(gc, repo("Missing", "HTML"), logger))
+		Equals(c, gc.Calls(), int64(0))
+
+		cr.SetMinModelicaBytes(1000)
+		IsTrue(c, cr.languageAllowed(gc, repo("Foo", "Modelica"), logger))
+		IsFalse(c, cr.languageAllowed(gc, repo("Docs", "HTML"), logger))
+		IsFalse(c, cr.languageAllowed(gc, repo("Empty", ""), logger))
+
+		// The primary language doesn't need to be fetched
+		cr.SetMinModelicaBytes(0)
+		cr.SetPrimaryLanguageOnly(true)
+		calls := gc.Calls()
+		IsTrue(c, cr.languageAllowed(gc, repo("Foo", "Modelica"), logger))
+		IsFalse(c, cr.languageAllowed(gc, repo("Docs", "HTML"), logger))
+		Equals(c, gc.Calls(), calls)
+
+		// Repositories whose languages can't be fetched are skipped
+		cr.SetMinModelicaBytes(1)
+		IsFalse(c, cr.languageAllowed(gc, repo("Missing", "Modelica"), logger))
+		failures := cr.failures.list()
+		Equals(c, len(failures), 1)
+		Equals(c, failures[0].Phase, PhaseLanguages)
+	})
+}
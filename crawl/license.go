@@ -0,0 +1,126 @@
+package crawl
+
+import (
+	"path"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// GitHub identifies licenses by a (lower case) key.  For most licenses,
+// this is simply the SPDX identifier in lower case but the case matters
+// for SPDX identifiers.
+var gitHubLicenses = map[string]string{
+	"agpl-3.0":     "AGPL-3.0",
+	"apache-2.0":   "Apache-2.0",
+	"bsd-2-clause": "BSD-2-Clause",
+	"bsd-3-clause": "BSD-3-Clause",
+	"cc0-1.0":      "CC0-1.0",
+	"epl-1.0":      "EPL-1.0",
+	"epl-2.0":      "EPL-2.0",
+	"gpl-2.0":      "GPL-2.0",
+	"gpl-3.0":      "GPL-3.0",
+	"lgpl-2.1":     "LGPL-2.1",
+	"lgpl-3.0":     "LGPL-3.0",
+	"mit":          "MIT",
+	"mpl-2.0":      "MPL-2.0",
+	"unlicense":    "Unlicense",
+}
+
+// This function returns the SPDX identifier of the license GitHub detected
+// for a repository (or the empty string if no license was detected).
+func gitHubLicense(license *github.License) string {
+	if license == nil || license.Key == nil {
+		return ""
+	}
+	key := strings.ToLower(*license.Key)
+	// GitHub uses "other" for licenses it found but couldn't identify
+	if key == "" || key == "other" {
+		return ""
+	}
+	id, known := gitHubLicenses[key]
+	if !known {
+		return *license.Key
+	}
+	return id
+}
+
+// The licenseMarker type associates text that identifies a license with
+// the SPDX identifier of that license.  All the given phrases must appear
+// in the text of the license.
+type licenseMarker struct {
+	id      string
+	phrases []string
+}
+
+// These are checked in order, so more specific markers must come first
+var licenseMarkers = []licenseMarker{
+	// There is no SPDX identifier for the Modelica License
+	{"LicenseRef-ModelicaLicense2", []string{"modelica license 2"}},
+	{"LicenseRef-ModelicaLicense2", []string{"modelica license version 2"}},
+	{"Apache-2.0", []string{"apache license", "version 2.0"}},
+	{"MPL-2.0", []string{"mozilla public license", "2.0"}},
+	{"AGPL-3.0", []string{"gnu affero general public license", "version 3"}},
+	{"LGPL-3.0", []string{"gnu lesser general public license", "version 3"}},
+	{"LGPL-2.1", []string{"gnu lesser general public license", "version 2.1"}},
+	{"GPL-3.0", []string{"gnu general public license", "version 3"}},
+	{"GPL-2.0", []string{"gnu general public license", "version 2"}},
+	{"MIT", []string{"permission is hereby granted, free of charge"}},
+	{"BSD-3-Clause", []string{"redistribution and use in source and binary forms", "neither the name"}},
+	{"BSD-2-Clause", []string{"redistribution and use in source and binary forms"}},
+	{"Unlicense", []string{"this is free and unencumbered software released into the public domain"}},
+}
+
+// This function identifies the license whose text is given (returning
+// the empty string if the license isn't recognized).
+func identifyLicense(text string) string {
+	// Ignore differences in case and line breaks
+	text = strings.ToLower(strings.Join(strings.Fields(text), " "))
+	for _, marker := range licenseMarkers {
+		matches := true
+		for _, phrase := range marker.phrases {
+			if !strings.Contains(text, phrase) {
+				matches = false
+				break
+			}
+		}
+		if matches {
+			return marker.id
+		}
+	}
+	return ""
+}
+
+// This function returns true if the named file (normally) contains the
+// license of a repository (e.g., LICENSE, LICENSE.txt or COPYING).
+func isLicenseFile(name string) bool {
+	base := strings.ToUpper(strings.TrimSuffix(name, path.Ext(name)))
+	return base == "LICENSE" || base == "LICENCE" || base == "COPYING"
+}
+
+// This function looks for a license file at the root of the repository
+// and tries to identify the license it contains.  It returns the empty
+// string if the license cannot be determined.
+func detectLicense(src contents, repostr string, logger CrawlLogger) string {
+	entries, err := src.ReadDir(".")
+	if err != nil {
+		logger.Debugf("Unable to list files in %s: %v", repostr, err)
+		return ""
+	}
+	for _, e := range entries {
+		if e.IsDir || !isLicenseFile(e.Name) {
+			continue
+		}
+		raw, err := src.ReadFile(e.Path)
+		if err != nil {
+			logger.Debugf("Unable to read %s in %s: %v", e.Path, repostr, err)
+			continue
+		}
+		id := identifyLicense(string(raw))
+		if id != "" {
+			return id
+		}
+		logger.Debugf("Unrecognized license in %s of %s", e.Path, repostr)
+	}
+	return ""
+}
@@ -0,0 +1,59 @@
+package crawl
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-github/github"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+)
+
+func TestLicense(t *testing.T) {
+	Convey("Testing license identification", t, func(c C) {
+		Equals(c, gitHubLicense(nil), "")
+		Equals(c, gitHubLicense(&github.License{Key: github.String("bsd-3-clause")}), "BSD-3-Clause")
+		Equals(c, gitHubLicense(&github.License{Key: github.String("other")}), "")
+		Equals(c, gitHubLicense(&github.License{Key: github.String("wtfpl")}), "wtfpl")
+
+		Equals(c, identifyLicense("The Modelica License 2\n\nPreamble."), "LicenseRef-ModelicaLicense2")
+		Equals(c, identifyLicense(`Permission is hereby granted,
+free of charge, to any person obtaining a copy`), "MIT")
+		Equals(c, identifyLicense("Redistribution and use in source and binary forms..."), "BSD-2-Clause")
+		Equals(c, identifyLicense("All rights reserved."), "")
+
+		IsTrue(c, isLicenseFile("LICENSE"))
+		IsTrue(c, isLicenseFile("License.txt"))
+		IsTrue(c, isLicenseFile("COPYING"))
+		IsTrue(c, !isLicenseFile("README.md"))
+
+		root, err := ioutil.TempDir("", "impact")
+		NoError(c, err)
+		defer os.RemoveAll(root)
+
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		writeFile(c, filepath.Join(root, "Foo", "package.mo"), "within;\npackage Foo\nend Foo;")
+
+		// Truly unknown
+		di := extractInfo(fileSystemContents{root: root}, "a", "Foo", "", "", "", "", nil, logger)
+		Equals(c, di.License, "")
+
+		// From a license file
+		writeFile(c, filepath.Join(root, "LICENSE.md"), "Apache License\nVersion 2.0, January 2004")
+		di = extractInfo(fileSystemContents{root: root}, "a", "Foo", "", "", "", "", nil, logger)
+		Equals(c, di.License, "Apache-2.0")
+
+		// The license detected by the host takes precedence
+		di = extractInfo(fileSystemContents{root: root}, "a", "Foo", "", "", "MIT", "", nil, logger)
+		Equals(c, di.License, "MIT")
+
+		// But impact.json is authoritative
+		writeFile(c, filepath.Join(root, "impact.json"), `{"license": "BSD-3-Clause"}`)
+		di = extractInfo(fileSystemContents{root: root}, "a", "Foo", "", "", "MIT", "", nil, logger)
+		Equals(c, di.License, "BSD-3-Clause")
+	})
+}
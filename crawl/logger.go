@@ -0,0 +1,173 @@
+package crawl
+
+import (
+	"fmt"
+	"log"
+	"sync"
+)
+
+// A CrawlLogger receives the messages generated while crawling.  Each
+// message has a level so that callers can decide what to do with it
+// (e.g., filter it, send it somewhere else or format it differently).
+type CrawlLogger interface {
+	// Details that are only interesting when troubleshooting
+	Debugf(format string, args ...interface{})
+	// Normal progress information
+	Infof(format string, args ...interface{})
+	// Problems that cause something to be skipped
+	Warnf(format string, args ...interface{})
+	// Problems that prevent something from being indexed at all
+	Errorf(format string, args ...interface{})
+}
+
+// A Verbosity determines which debug messages are written while crawling.
+// Each level includes the messages of the levels below it.  Normal
+// progress information, warnings and errors are always written.
+type Verbosity int
+
+const (
+	// No debug messages at all
+	Quiet Verbosity = iota
+	// Decisions made about each repository (e.g., why it was skipped)
+	RepoLevel
+	// Decisions made about each tag (i.e., version) of a repository
+	TagLevel
+	// Details of the files and libraries found in each version
+	FileLevel
+)
+
+// The standardLogger writes all messages to a standard logger.  Debug
+// messages are only written if they are at a level (see withLevel) that
+// is included in the verbosity.
+type standardLogger struct {
+	logger    *log.Logger
+	verbosity Verbosity
+	level     Verbosity
+}
+
+// The StandardLogger function returns a CrawlLogger that writes to the
+// given logger.  Debug messages are discarded unless the verbosity
+// includes the level they are logged at and warnings and errors are
+// prefixed with "Warning: " and "Error: ", respectively.  This is what
+// Crawl uses with the logger it is given.
+func StandardLogger(logger *log.Logger, verbosity Verbosity) CrawlLogger {
+	return standardLogger{logger: logger, verbosity: verbosity, level: RepoLevel}
+}
+
+func (s standardLogger) Debugf(format string, args ...interface{}) {
+	if s.level <= s.verbosity {
+		s.logger.Printf(format, args...)
+	}
+}
+
+func (s standardLogger) atLevel(level Verbosity) CrawlLogger {
+	s.level = level
+	return s
+}
+
+// A leveledLogger can be told the level of the debug messages passed to
+// it (so that it can filter them).
+type leveledLogger interface {
+	atLevel(level Verbosity) CrawlLogger
+}
+
+// This function returns a logger that passes debug messages on to the
+// given logger as messages at the given level.  Messages are logged at
+// the repository level unless this is used to say otherwise.  Loggers
+// provided by the caller (see CrawlOptions) get all debug messages,
+// regardless of their level.
+func withLevel(logger CrawlLogger, level Verbosity) CrawlLogger {
+	if l, ok := logger.(leveledLogger); ok {
+		return l.atLevel(level)
+	}
+	return logger
+}
+
+func (s standardLogger) Infof(format string, args ...interface{}) {
+	s.logger.Printf(format, args...)
+}
+
+func (s standardLogger) Warnf(format string, args ...interface{}) {
+	s.logger.Printf("Warning: "+format, args...)
+}
+
+func (s standardLogger) Errorf(format string, args ...interface{}) {
+	s.logger.Printf("Error: "+format, args...)
+}
+
+// The prefixLogger adds a fixed prefix to every message before passing
+// it on to another CrawlLogger.
+type prefixLogger struct {
+	logger CrawlLogger
+	prefix string
+}
+
+// This function creates a logger that prefixes each message with the
+// name of the repository being processed.  This keeps the output readable
+// when several repositories are being processed at once.
+func repoLogger(logger CrawlLogger, rname string) CrawlLogger {
+	return prefixLogger{logger: logger, prefix: fmt.Sprintf("[%s] ", rname)}
+}
+
+func (p prefixLogger) Debugf(format string, args ...interface{}) {
+	p.logger.Debugf(p.prefix+format, args...)
+}
+
+func (p prefixLogger) atLevel(level Verbosity) CrawlLogger {
+	return prefixLogger{logger: withLevel(p.logger, level), prefix: p.prefix}
+}
+
+func (p prefixLogger) Infof(format string, args ...interface{}) {
+	p.logger.Infof(p.prefix+format, args...)
+}
+
+func (p prefixLogger) Warnf(format string, args ...interface{}) {
+	p.logger.Warnf(p.prefix+format, args...)
+}
+
+func (p prefixLogger) Errorf(format string, args ...interface{}) {
+	p.logger.Errorf(p.prefix+format, args...)
+}
+
+// The syncLogger serializes all messages passed on to another CrawlLogger
+// so that loggers that aren't safe to use from multiple goroutines can
+// be used when processing repositories (or tags) concurrently.
+type syncLogger struct {
+	mutex  *sync.Mutex
+	logger CrawlLogger
+}
+
+func synchronizedLogger(logger CrawlLogger) CrawlLogger {
+	if _, ok := logger.(syncLogger); ok {
+		return logger
+	}
+	return syncLogger{mutex: &sync.Mutex{}, logger: logger}
+}
+
+func (s syncLogger) Debugf(format string, args ...interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.logger.Debugf(format, args...)
+}
+
+func (s syncLogger) atLevel(level Verbosity) CrawlLogger {
+	return syncLogger{mutex: s.mutex, logger: withLevel(s.logger, level)}
+}
+
+func (s syncLogger) Infof(format string, args ...interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.logger.Infof(format, args...)
+}
+
+func (s syncLogger) Warnf(format string, args ...interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.logger.Warnf(format, args...)
+}
+
+func (s syncLogger) Errorf(format string, args ...interface{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.logger.Errorf(format, args...)
+}
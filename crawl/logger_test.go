@@ -0,0 +1,47 @@
+package crawl
+
+import (
+	"bytes"
+	"log"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+)
+
+func TestStandardLogger(t *testing.T) {
+	Convey("Testing the standard logger adapter", t, func(c C) {
+		var buf bytes.Buffer
+		logger := StandardLogger(log.New(&buf, "", 0), Quiet)
+		logger.Debugf("debug %d", 1)
+		logger.Infof("info %d", 2)
+		logger.Warnf("warn %d", 3)
+		logger.Errorf("error %d", 4)
+		Equals(c, buf.String(), "info 2\nWarning: warn 3\nError: error 4\n")
+
+		buf.Reset()
+		logger = repoLogger(StandardLogger(log.New(&buf, "", 0), FileLevel), "Foo")
+		logger.Debugf("debug %d", 1)
+		logger.Warnf("warn %d", 2)
+		Equals(c, buf.String(), "[Foo] debug 1\nWarning: [Foo] warn 2\n")
+	})
+}
+
+func TestVerbosity(t *testing.T) {
+	Convey("Testing the levels of debug messages", t, func(c C) {
+		var buf bytes.Buffer
+		messages := func(verbosity Verbosity) string {
+			buf.Reset()
+			logger := synchronizedLogger(repoLogger(StandardLogger(log.New(&buf, "", 0), verbosity), "Foo"))
+			logger.Debugf("repo")
+			withLevel(logger, TagLevel).Debugf("tag")
+			withLevel(withLevel(logger, TagLevel), FileLevel).Debugf("file")
+			withLevel(logger, FileLevel).Infof("info")
+			return buf.String()
+		}
+		Equals(c, messages(Quiet), "[Foo] info\n")
+		Equals(c, messages(RepoLevel), "[Foo] repo\n[Foo] info\n")
+		Equals(c, messages(TagLevel), "[Foo] repo\n[Foo] tag\n[Foo] info\n")
+		Equals(c, messages(FileLevel), "[Foo] repo\n[Foo] tag\n[Foo] file\n[Foo] info\n")
+	})
+}
@@ -0,0 +1,116 @@
+package crawl
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// The CrawlMetrics type accumulates metrics about crawls (e.g., for a
+// long-running indexing service) as Prometheus collectors so that they
+// can be scraped along with any other metrics of the service.  Unlike
+// CrawlStats, the counters aren't reset by each crawl.  A CrawlMetrics can
+// safely be used from multiple goroutines (and crawls) at once.  All
+// methods can be called on a nil value, in which case nothing is
+// recorded.
+type CrawlMetrics struct {
+	crawlDuration    prometheus.Histogram
+	lastCrawl        prometheus.Gauge
+	reposProcessed   prometheus.Counter
+	versionsRecorded prometheus.Counter
+	apiErrors        prometheus.Counter
+	rateLimitSleeps  prometheus.Counter
+	rateLimitSeconds prometheus.Counter
+}
+
+// The NewCrawlMetrics function creates the metrics and registers them
+// with the given registerer (e.g., prometheus.DefaultRegisterer).  If
+// the registerer is nil, no metrics are created and nil is returned (so
+// nothing is recorded).  An error is returned if the metrics can't be
+// registered (e.g., because they already are).
+func NewCrawlMetrics(registerer prometheus.Registerer) (*CrawlMetrics, error) {
+	if registerer == nil {
+		return nil, nil
+	}
+
+	m := &CrawlMetrics{
+		crawlDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name: "impact_crawl_duration_seconds",
+			Help: "Duration of crawls (successful or not)",
+			// From a few seconds up to several hours
+			Buckets: prometheus.ExponentialBuckets(1, 4, 8),
+		}),
+		lastCrawl: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "impact_crawl_last_completed_timestamp_seconds",
+			Help: "When the most recent crawl was completed",
+		}),
+		reposProcessed: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "impact_crawl_repos_processed_total",
+			Help: "Number of repositories processed",
+		}),
+		versionsRecorded: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "impact_crawl_versions_recorded_total",
+			Help: "Number of versions recorded",
+		}),
+		apiErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "impact_crawl_api_errors_total",
+			Help: "Number of requests to the GitHub API that failed",
+		}),
+		rateLimitSleeps: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "impact_crawl_rate_limit_sleeps_total",
+			Help: "Number of times a rate limit had to be waited for",
+		}),
+		rateLimitSeconds: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "impact_crawl_rate_limit_sleep_seconds_total",
+			Help: "Time spent waiting for rate limits to reset",
+		}),
+	}
+	for _, c := range []prometheus.Collector{m.crawlDuration, m.lastCrawl, m.reposProcessed,
+		m.versionsRecorded, m.apiErrors, m.rateLimitSleeps, m.rateLimitSeconds} {
+		err := registerer.Register(c)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to register crawl metrics: %v", err)
+		}
+	}
+	return m, nil
+}
+
+func (m *CrawlMetrics) crawled(duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.crawlDuration.Observe(duration.Seconds())
+	m.lastCrawl.SetToCurrentTime()
+}
+
+func (m *CrawlMetrics) repoProcessed() {
+	if m == nil {
+		return
+	}
+	m.reposProcessed.Inc()
+}
+
+func (m *CrawlMetrics) versionRecorded() {
+	if m == nil {
+		return
+	}
+	m.versionsRecorded.Inc()
+}
+
+func (m *CrawlMetrics) apiError() {
+	if m == nil {
+		return
+	}
+	m.apiErrors.Inc()
+}
+
+func (m *CrawlMetrics) rateLimitSleep(d time.Duration) {
+	if m == nil {
+		return
+	}
+	m.rateLimitSleeps.Inc()
+	if d > 0 {
+		m.rateLimitSeconds.Add(d.Seconds())
+	}
+}
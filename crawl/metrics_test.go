@@ -0,0 +1,102 @@
+package crawl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+
+	"github.com/impact/impact/recorder"
+)
+
+func TestMetrics(t *testing.T) {
+	Convey("Testing metrics about crawls", t, func(c C) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/users/a/repos":
+				fmt.Fprint(w, `[{"name": "Foo"}, {"name": "Bar"}]`)
+			case "/repos/a/Foo":
+				fmt.Fprint(w, `{"name": "Foo"}`)
+			case "/repos/a/Foo/tags":
+				http.Error(w, "Broken", http.StatusInternalServerError)
+			default:
+				// Not finding something isn't an error
+				http.NotFound(w, r)
+			}
+		}))
+		defer server.Close()
+
+		target, err := url.Parse(server.URL)
+		NoError(c, err)
+
+		cr, err := MakeGitHubCrawler("a", nil, "")
+		NoError(c, err)
+		cr.SetHTTPClient(&http.Client{Transport: &redirectTransport{target: target}})
+		cr.SetRetries(0, 0)
+
+		registry := prometheus.NewRegistry()
+		metrics, err := NewCrawlMetrics(registry)
+		NoError(c, err)
+		opts := DefaultCrawlOptions(recorder.NullRecorder{}, Quiet, log.New(ioutil.Discard, "", 0))
+		opts.Metrics = metrics
+		for i := 0; i < 2; i++ {
+			_, err = cr.CrawlWithOptions(opts)
+			NoError(c, err)
+		}
+
+		// The counters accumulate over crawls
+		Equals(c, testutil.ToFloat64(metrics.reposProcessed), 4.0)
+		Equals(c, testutil.ToFloat64(metrics.apiErrors), 2.0)
+		Equals(c, testutil.ToFloat64(metrics.versionsRecorded), 0.0)
+		Equals(c, testutil.ToFloat64(metrics.rateLimitSleeps), 0.0)
+		IsTrue(c, testutil.ToFloat64(metrics.lastCrawl) > 0)
+
+		families, err := registry.Gather()
+		NoError(c, err)
+		Equals(c, len(families), 7)
+		for _, family := range families {
+			if family.GetName() == "impact_crawl_duration_seconds" {
+				Equals(c, family.GetMetric()[0].GetHistogram().GetSampleCount(), uint64(2))
+			}
+		}
+
+		// The metrics can be scraped
+		scraper := httptest.NewServer(promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+		defer scraper.Close()
+		resp, err := http.Get(scraper.URL)
+		NoError(c, err)
+		defer resp.Body.Close()
+		body, err := ioutil.ReadAll(resp.Body)
+		NoError(c, err)
+		IsTrue(c, strings.Contains(string(body), "# TYPE impact_crawl_api_errors_total counter\n"+
+			"impact_crawl_api_errors_total 2\n"))
+		IsTrue(c, strings.Contains(string(body), "impact_crawl_duration_seconds_count 2\n"))
+
+		// The same metrics can't be registered twice
+		_, err = NewCrawlMetrics(registry)
+		IsError(c, err)
+
+		// Without a registerer, nothing is recorded
+		none, err := NewCrawlMetrics(nil)
+		NoError(c, err)
+		IsNil(c, none)
+		none.repoProcessed()
+		none.crawled(time.Second)
+		none.rateLimitSleep(time.Second)
+		opts.Metrics = none
+		_, err = cr.CrawlWithOptions(opts)
+		NoError(c, err)
+	})
+}
@@ -0,0 +1,37 @@
+package crawl
+
+import (
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// This function returns the repositories of the current user that were
+// named explicitly (see SetRepositories).  Each repository is fetched on
+// its own, so the repositories of the user are never listed.  Names of the
+// form owner/name only apply to that owner.  Repositories that can't be
+// fetched are skipped (with a warning).
+func (c GitHubCrawler) namedRepos(gc *GitHubClient, logger CrawlLogger) ([]github.Repository, error) {
+	repos := []github.Repository{}
+	for _, name := range c.repoNames {
+		if i := strings.Index(name, "/"); i >= 0 {
+			if !strings.EqualFold(name[:i], c.user) {
+				continue
+			}
+			name = name[i+1:]
+		}
+
+		logger.Debugf("Fetching repository %s/%s", c.user, name)
+		repo, _, err := getRepository(gc, c.user, name)
+		if gc.ctx.Err() != nil {
+			return nil, gc.ctx.Err()
+		}
+		if err != nil {
+			logger.Warnf("Unable to fetch repository %s/%s: %v", c.user, name, err)
+			c.fail(gc, name, PhaseDetails, err)
+			continue
+		}
+		repos = append(repos, *repo)
+	}
+	return repos, nil
+}
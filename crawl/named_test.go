@@ -0,0 +1,62 @@
+package crawl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/github"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+)
+
+func TestNamedRepos(t *testing.T) {
+	Convey("Testing crawling only named repositories", t, func(c C) {
+		paths := []string{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			paths = append(paths, r.URL.Path)
+			switch r.URL.Path {
+			case "/repos/a/Foo":
+				fmt.Fprint(w, `{"name": "Foo", "html_url": "https://github.com/a/Foo"}`)
+			case "/repos/a/Bar":
+				fmt.Fprint(w, `{"name": "Bar", "html_url": "https://github.com/a/Bar"}`)
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		defer server.Close()
+
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		client := github.NewClient(nil)
+		base, err := url.Parse(server.URL + "/")
+		NoError(c, err)
+		client.BaseURL = base
+		gc := NewGitHubClient(client, 0, logger)
+		gc.SetRetries(0, 0)
+
+		cr, err := MakeGitHubCrawler("a", nil, "")
+		NoError(c, err)
+		cr.user = "a"
+		cr.failures = &repoErrors{}
+		cr.SetRepositories("Foo", "b/Bar", "A/Bar", "Missing")
+
+		// Only the repositories named (for this user) are fetched
+		repos, err := cr.namedRepos(gc, logger)
+		NoError(c, err)
+		Equals(c, len(repos), 2)
+		Equals(c, stringOf(repos[0].Name), "Foo")
+		Equals(c, stringOf(repos[1].HTMLURL), "https://github.com/a/Bar")
+		Resembles(c, paths, []string{"/repos/a/Foo", "/repos/a/Bar", "/repos/a/Missing"})
+
+		// Repositories that can't be fetched are failures
+		failures := cr.failures.list()
+		Equals(c, len(failures), 1)
+		Equals(c, failures[0].Repo, "Missing")
+		Equals(c, failures[0].Phase, PhaseDetails)
+	})
+}
@@ -0,0 +1,110 @@
+package crawl
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/go-github/github"
+)
+
+// The maximum length (in bytes) of the release notes recorded for a
+// version unless another one is given (see SetReleaseNotes)
+var defaultMaxNotes = 4096
+
+// This function returns the release notes of the given version of a
+// repository if they are recorded (see SetReleaseNotes).  The body of the
+// GitHub release of the tag is used if there is one.  Otherwise, the
+// message of the tag is used (provided it is an annotated tag).  Versions
+// that aren't tagged (e.g., HEAD) have no release notes.  Notes fetched
+// by a previous crawl for the same SHA are reused.
+func (c GitHubCrawler) versionNotes(client *GitHubClient, key string, owner string, rname string,
+	tag string, sha string, logger CrawlLogger) string {
+	if !c.releaseNotes || tag == "" {
+		return ""
+	}
+
+	notes, cached := c.cache.notes(key, sha)
+	if cached {
+		logger.Debugf("    Using cached release notes of %s", key)
+		return notes
+	}
+
+	notes, err := releaseBody(client, owner, rname, tag)
+	if err == nil && notes == "" {
+		notes, err = tagMessage(client, owner, rname, tag)
+	}
+	if err != nil {
+		if client.ctx.Err() == nil {
+			logger.Warnf("Unable to fetch release notes of %s: %v", key, err)
+		}
+		return ""
+	}
+
+	notes = truncateNotes(strings.TrimSpace(notes), c.maxNotes)
+	c.cache.setNotes(key, sha, notes)
+	return notes
+}
+
+// This function returns the body of the GitHub release of the named tag.
+// If the tag has no release, the body is empty.
+func releaseBody(client *GitHubClient, owner string, rname string, tag string) (string, error) {
+	var release *github.RepositoryRelease
+	err := client.call(func() (err error) {
+		release, _, err = client.client.Repositories.GetReleaseByTag(owner, rname, tag)
+		return
+	})
+	if err != nil {
+		if notFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	if release == nil {
+		return "", nil
+	}
+	return stringOf(release.Body), nil
+}
+
+// This function returns the message of the named tag.  Lightweight tags
+// have no message.
+func tagMessage(client *GitHubClient, owner string, rname string, tag string) (string, error) {
+	obj, err := tagObject(client, owner, rname, tag)
+	if err != nil {
+		if notFound(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	if stringOf(obj.Type) != "tag" {
+		return "", nil
+	}
+
+	var annotated *github.Tag
+	err = client.call(func() (err error) {
+		annotated, _, err = client.client.Git.GetTag(owner, rname, *obj.SHA)
+		return
+	})
+	if err != nil {
+		return "", err
+	}
+	if annotated == nil {
+		return "", nil
+	}
+	return stringOf(annotated.Message), nil
+}
+
+// This function truncates the given release notes to at most max bytes
+// (or defaultMaxNotes, if max isn't positive) without splitting a UTF-8
+// encoded character.
+func truncateNotes(notes string, max int) string {
+	if max <= 0 {
+		max = defaultMaxNotes
+	}
+	if len(notes) <= max {
+		return notes
+	}
+	for max > 0 && !utf8.RuneStart(notes[max]) {
+		max--
+	}
+	return notes[:max]
+}
@@ -0,0 +1,85 @@
+package crawl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-github/github"
+
+	"github.com/impact/impact/dirinfo"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+)
+
+func TestReleaseNotes(t *testing.T) {
+	Convey("Testing recording the release notes of versions", t, func(c C) {
+		releases := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/repos/a/Foo/releases/tags/v1.0.0":
+				releases++
+				fmt.Fprint(w, `{"tag_name": "v1.0.0", "body": "  Fixed everything\n"}`)
+			case "/repos/a/Foo/git/refs/tags/v2.0.0":
+				fmt.Fprint(w, `{"ref": "refs/tags/v2.0.0", "object": {"type": "tag", "sha": "t2"}}`)
+			case "/repos/a/Foo/git/tags/t2":
+				fmt.Fprint(w, `{"sha": "t2", "message": "Ünïcödé release"}`)
+			case "/repos/a/Foo/git/refs/tags/v3.0.0":
+				fmt.Fprint(w, `{"ref": "refs/tags/v3.0.0", "object": {"type": "commit", "sha": "ghi"}}`)
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		defer server.Close()
+
+		dir, err := ioutil.TempDir("", "impact")
+		NoError(c, err)
+		defer os.RemoveAll(dir)
+
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		client := github.NewClient(nil)
+		base, err := url.Parse(server.URL + "/")
+		NoError(c, err)
+		client.BaseURL = base
+		gc := NewGitHubClient(client, 0, logger)
+		gc.SetRetries(0, 0)
+
+		cr, err := MakeGitHubCrawler("a", nil, "")
+		NoError(c, err)
+		cr.cache, err = loadCache(filepath.Join(dir, "cache.json"), logger)
+		NoError(c, err)
+		cr.cache.setVersion("a/Foo/1.0.0", "abc", "", dirinfo.MakeDirectoryInfo())
+
+		// By default, nothing is fetched
+		Equals(c, cr.versionNotes(gc, "a/Foo/1.0.0", "a", "Foo", "v1.0.0", "abc", logger), "")
+		Equals(c, releases, 0)
+
+		// Otherwise, the body of the release is used (once)
+		cr.SetReleaseNotes(true, 0)
+		Equals(c, cr.versionNotes(gc, "a/Foo/1.0.0", "a", "Foo", "v1.0.0", "abc", logger), "Fixed everything")
+		Equals(c, releases, 1)
+		cr.cache.setVersion("a/Foo/1.0.0", "abc", "", dirinfo.MakeDirectoryInfo())
+		Equals(c, cr.versionNotes(gc, "a/Foo/1.0.0", "a", "Foo", "v1.0.0", "abc", logger), "Fixed everything")
+		Equals(c, releases, 1)
+
+		// Without a release, the message of an annotated tag is used
+		Equals(c, cr.versionNotes(gc, "a/Foo/2.0.0", "a", "Foo", "v2.0.0", "def", logger), "Ünïcödé release")
+
+		// Lightweight tags and untagged versions have no notes
+		Equals(c, cr.versionNotes(gc, "a/Foo/3.0.0", "a", "Foo", "v3.0.0", "ghi", logger), "")
+		Equals(c, cr.versionNotes(gc, "a/Foo/0.0.0-dev", "a", "Foo", "", "jkl", logger), "")
+
+		// Long notes are truncated (without splitting characters)
+		cr.SetReleaseNotes(true, 2)
+		Equals(c, cr.versionNotes(gc, "a/Foo/2.0.0", "a", "Foo", "v2.0.0", "def", logger), "Ü")
+		cr.SetReleaseNotes(true, 3)
+		Equals(c, cr.versionNotes(gc, "a/Foo/2.0.0", "a", "Foo", "v2.0.0", "def", logger), "Ün")
+	})
+}
@@ -0,0 +1,74 @@
+package crawl
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"time"
+
+	"github.com/impact/impact/recorder"
+)
+
+// The CrawlOptions type holds everything that applies to a single crawl
+// (as opposed to the crawler itself, e.g., which repositories to crawl).
+// Any option left at its zero value uses whatever was configured on the
+// crawler (or the default).
+type CrawlOptions struct {
+	// Where all libraries found are recorded
+	Recorder recorder.Recorder
+	// Receives all messages.  If this is nil, messages are written to
+	// StdLogger instead (see StandardLogger).
+	Logger    CrawlLogger
+	StdLogger *log.Logger
+	Verbosity Verbosity
+	// The crawl stops once this is done (if given)
+	Context context.Context
+
+	// Number of repositories (and tags within each repository) to process
+	// concurrently (see SetConcurrency and SetTagConcurrency)
+	Concurrency    int
+	TagConcurrency int
+
+	// Only versions in this range are indexed (see SetVersionRange)
+	VersionRange string
+	// Repositories not pushed to since this time are taken from the
+	// baseline (see SetSince)
+	Since    time.Time
+	Baseline Baseline
+
+	// How often to log the progress of the crawl (see SetHeartbeat)
+	Heartbeat time.Duration
+
+	// Where metrics about the crawl are accumulated (if given)
+	Metrics *CrawlMetrics
+}
+
+// The DefaultCrawlOptions function returns the options that correspond to
+// the arguments of the Crawl method of a Crawler.
+func DefaultCrawlOptions(r recorder.Recorder, verbosity Verbosity, logger *log.Logger) CrawlOptions {
+	return CrawlOptions{
+		Recorder:  r,
+		StdLogger: logger,
+		Verbosity: verbosity,
+	}
+}
+
+// The logger method returns the CrawlLogger that all messages should be
+// passed to.
+func (o CrawlOptions) logger() CrawlLogger {
+	if o.Logger != nil {
+		return o.Logger
+	}
+	if o.StdLogger != nil {
+		return StandardLogger(o.StdLogger, o.Verbosity)
+	}
+	return StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+}
+
+// The context method returns the context the crawl is subject to.
+func (o CrawlOptions) context() context.Context {
+	if o.Context != nil {
+		return o.Context
+	}
+	return context.Background()
+}
@@ -0,0 +1,39 @@
+package crawl
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+
+	"github.com/impact/impact/recorder"
+)
+
+func TestCrawlOptions(t *testing.T) {
+	Convey("Testing crawl options", t, func(c C) {
+		buf := bytes.Buffer{}
+		opts := DefaultCrawlOptions(recorder.NullRecorder{}, Quiet, log.New(&buf, "", 0))
+		IsTrue(c, opts.context() == context.Background())
+
+		// Debug messages are only written in verbose mode
+		opts.logger().Debugf("Hidden")
+		opts.logger().Infof("Shown")
+		Equals(c, buf.String(), "Shown\n")
+		opts.Verbosity = FileLevel
+		opts.logger().Debugf("Details")
+		Equals(c, buf.String(), "Shown\nDetails\n")
+
+		// Without any logger, messages are discarded
+		IsTrue(c, CrawlOptions{}.logger() != nil)
+
+		// Options are checked before anything is crawled
+		cr, err := MakeGitHubCrawler("a", nil, "")
+		NoError(c, err)
+		opts.VersionRange = "not a range"
+		_, err = cr.CrawlWithOptions(opts)
+		IsError(c, err)
+	})
+}
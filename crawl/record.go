@@ -0,0 +1,327 @@
+package crawl
+
+import (
+	"strings"
+	"time"
+
+	"github.com/blang/semver"
+
+	"github.com/impact/impact/dirinfo"
+	"github.com/impact/impact/parsing"
+	"github.com/impact/impact/recorder"
+)
+
+// The repoDetails type holds the information about a repository that
+// is recorded along with each library found in it.  This allows crawlers
+// for different hosts to share the same recording logic.
+type repoDetails struct {
+	URI         string // Canonical URI of the repository (also used as homepage)
+	Description string // Textual description (if any)
+	GitURL      string // URL to clone repository from (if any)
+	Stars       int    // Number of stars (negative if unknown)
+	Forks       int    // Number of forks (zero if unknown)
+	OpenIssues  int    // Number of open issues (zero if unknown)
+}
+
+// The DuplicatePolicy type indicates what a crawler should do when it
+// finds a version of a library that has already been recorded (e.g.,
+// because a version was tagged more than once).
+type DuplicatePolicy int
+
+const (
+	// Replace the existing version (i.e., the last one found wins)
+	ReplaceDuplicates DuplicatePolicy = iota
+	// Keep the existing version (i.e., the first one found wins)
+	SkipDuplicates
+	// Replace the existing version only if the commit of the new one is
+	// reachable from the default branch
+	PreferDefaultBranch
+)
+
+// The MismatchPolicy type indicates what a crawler should do when the
+// version a library declares (in impact.json or its version annotation)
+// differs from the version of the tag being processed.
+type MismatchPolicy int
+
+const (
+	// Record the library under the version of the tag
+	TrustTag MismatchPolicy = iota
+	// Record the library under the version it declares
+	TrustMetadata
+	// Don't record the library at all
+	SkipMismatches
+)
+
+// A URLRewriter rewrites the URL of an archive (e.g., so that it points
+// to a mirror).  If it returns an empty string, the original URL is used.
+type URLRewriter func(original string) string
+
+// This function returns the URLs the given mirrors (see URLRewriter) give
+// for the original URL of a tarball.  URLs that are empty or the same as
+// the URL recorded for the tarball are left out.
+func mirrorURLs(mirrors []URLRewriter, original string, recorded string) []string {
+	ret := []string{}
+	if original == "" {
+		return ret
+	}
+	for _, mirror := range mirrors {
+		url := mirror(original)
+		if url != "" && url != recorded {
+			ret = append(ret, url)
+		}
+	}
+	return ret
+}
+
+// This function applies the (optional) rewriter to the given URL.
+func rewriteURL(rewrite URLRewriter, url string) string {
+	if rewrite == nil || url == "" {
+		return url
+	}
+	rewritten := rewrite(url)
+	if rewritten == "" {
+		return url
+	}
+	return rewritten
+}
+
+// A TagMapper maps the name of a tag to the version string it represents
+// (which is then normalized, see parsing.NormalizeVersion).  It returns
+// false if the tag should be skipped.
+type TagMapper func(tagName string) (string, bool)
+
+// Words that commonly precede the version in the name of a tag (checked
+// in order, ignoring case)
+var versionPrefixes = []string{
+	"release-", "release_", "release", "version-", "version_", "version", "ver-", "ver", "v",
+}
+
+// This function strips a leading word like "v", "V" or "release-" from
+// the given string.  The word is only stripped if a digit follows it
+// (e.g., "v1.2.3" and "release-1.2.3" both become "1.2.3", but "vision"
+// is left alone).
+func trimVersionPrefix(s string) string {
+	lower := strings.ToLower(s)
+	for _, prefix := range versionPrefixes {
+		if !strings.HasPrefix(lower, prefix) || len(s) == len(prefix) {
+			continue
+		}
+		rest := s[len(prefix):]
+		if rest[0] >= '0' && rest[0] <= '9' {
+			return rest
+		}
+	}
+	return s
+}
+
+// The DefaultTagMapper function strips a leading "v" (or "V", "release-",
+// etc., see trimVersionPrefix) from the tag name (e.g., "v1.2.3"
+// represents version "1.2.3").  Tags without a name are skipped.
+func DefaultTagMapper(tagName string) (string, bool) {
+	tagName = strings.TrimSpace(tagName)
+	if tagName == "" {
+		return "", false
+	}
+	return trimVersionPrefix(tagName), true
+}
+
+// This function applies the given mapper (or DefaultTagMapper, if it is
+// nil) to the name of a tag.
+func mapTag(mapper TagMapper, tagName string) (string, bool) {
+	if mapper == nil {
+		mapper = DefaultTagMapper
+	}
+	return mapper(tagName)
+}
+
+// This function determines the version a library should be recorded
+// under when the tag being processed represents version v.  The declared
+// version (if any) is parsed according to the same scheme as the tag (or
+// parsing.SemanticVersions, if it is nil).  If the library declares a
+// different version, a warning is logged and the policy determines which
+// version is used (or whether the library is skipped, in which case false
+// is returned).
+func libraryVersion(lib *dirinfo.LocalLibrary, v semver.Version, scheme parsing.VersionScheme,
+	policy MismatchPolicy, logger CrawlLogger) (semver.Version, bool) {
+	if lib.Version == "" {
+		return v, true
+	}
+
+	declared, err := parsing.NormalizeWith(scheme, lib.Version)
+	if err != nil {
+		logger.Warnf("Library %s declares invalid version '%s' (using %s): %v",
+			lib.Name, lib.Version, v.String(), err)
+		return v, true
+	}
+	if declared.EQ(v) {
+		return v, true
+	}
+
+	switch policy {
+	case TrustMetadata:
+		logger.Warnf("VERSION MISMATCH: Library %s declares version %s but is tagged as %s (using %s)",
+			lib.Name, declared.String(), v.String(), declared.String())
+		return declared, true
+	case SkipMismatches:
+		logger.Warnf("VERSION MISMATCH: Library %s declares version %s but is tagged as %s (skipping)",
+			lib.Name, declared.String(), v.String())
+		return v, false
+	default:
+		logger.Warnf("VERSION MISMATCH: Library %s declares version %s but is tagged as %s (using %s)",
+			lib.Name, declared.String(), v.String(), v.String())
+		return v, true
+	}
+}
+
+// The versionRecord type holds what is known about a given version of a
+// repository (beyond the libraries it contains).  The tag (if any) is the
+// name the version was found as, before it was normalized.  The size (in
+// bytes) is zero if it isn't known.  The checksums (if any) are those of
+// the tarball (keyed by algorithm) and the mirrors (if any) are other
+// URLs the tarball can be downloaded from.
+type versionRecord struct {
+	sha       string
+	tag       string
+	date      time.Time
+	size      int64
+	notes     string
+	tarurl    string
+	zipurl    string
+	mirrors   []string
+	checksums map[string]string
+}
+
+// The recordPolicy type determines how the libraries found in a version
+// are recorded.  The scheme and mismatches determine the version each
+// library is recorded as (see libraryVersion).  The replace function (if
+// any) is called to determine whether a library version that was already
+// recorded should be replaced (if it is nil, it always is).  Once a
+// version has been recorded, the hook (if any) is called.
+type recordPolicy struct {
+	scheme     parsing.VersionScheme
+	mismatches MismatchPolicy
+	replace    func() bool
+	hook       *versionHook
+}
+
+// This function records all the libraries found in a given version of a
+// repository.  Each library is recorded as version v unless it declares
+// a different version (see recordPolicy).  If a library version has
+// already been recorded, a warning is logged and it is only replaced if
+// the policy says so.  The size is only recorded if it is known and the
+// release notes only if there are any.
+func recordVersion(r recorder.Recorder, di dirinfo.DirectoryInfo, repo repoDetails,
+	tagged semver.Version, rec versionRecord, policy recordPolicy, logger CrawlLogger) {
+
+	// Loop over all libraries present in this repository
+	for _, lib := range di.Libraries {
+		logger.Debugf("    Processing library %s @ %s", lib.Name, lib.Path)
+
+		v, ok := libraryVersion(lib, tagged, policy.scheme, policy.mismatches, logger)
+		if !ok {
+			continue
+		}
+
+		libr := r.GetLibrary(lib.Name, repo.URI, di.OwnerURI)
+
+		if libr.HasVersion(v) {
+			logger.Warnf("Duplicate version %s for library %s", v.String(), lib.Name)
+			if policy.replace != nil && !policy.replace() {
+				continue
+			}
+		}
+
+		// The rating is recorded first since it may determine which
+		// repository description is used (see duplicatesRecorder)
+		if repo.Stars >= 0 {
+			libr.SetStars(repo.Stars)
+		}
+		libr.SetForks(repo.Forks)
+		libr.SetOpenIssues(repo.OpenIssues)
+		// Many repositories don't have a description, but the library
+		// itself may
+		if repo.Description != "" {
+			libr.SetDescription(repo.Description)
+		} else if lib.Description != "" {
+			libr.SetDescription(lib.Description)
+		}
+
+		libr.SetHomepage(repo.URI)
+		if repo.GitURL != "" {
+			libr.SetRepository(repo.GitURL, "git")
+		}
+		libr.SetEmail(di.Email)
+		libr.SetMaintainers(maintainers(di))
+		libr.SetLicense(di.License)
+
+		vr := libr.AddVersion(v)
+
+		vr.SetPath(lib.Path, lib.IsFile)
+		vr.SetHash(rec.sha)
+		if rec.tag != "" {
+			vr.SetTagName(rec.tag)
+		}
+		vr.SetReleaseDate(rec.date)
+		vr.SetVerified(di.Verified)
+		vr.SetTarballURL(rec.tarurl)
+		for _, url := range rec.mirrors {
+			vr.AddMirror(url)
+		}
+		vr.SetZipballURL(rec.zipurl)
+		for algo, sum := range rec.checksums {
+			vr.SetArchiveChecksum(algo, sum)
+		}
+		if rec.size > 0 {
+			vr.SetSize(rec.size)
+		}
+		if rec.notes != "" {
+			vr.SetReleaseNotes(rec.notes)
+		}
+		vr.SetModelicaCompat(lib.Modelica)
+
+		for _, dep := range mergeDependencies(lib, logger) {
+			vr.AddDependency(dep.Name, dep.Version)
+		}
+
+		policy.hook.call(lib.Name, v, vr, logger)
+	}
+}
+
+// This function returns the maintainers listed in the directory info in
+// the form they are recorded in.
+func maintainers(di dirinfo.DirectoryInfo) []recorder.Maintainer {
+	ret := []recorder.Maintainer{}
+	for _, m := range di.Maintainers {
+		ret = append(ret, recorder.Maintainer{Name: m.Name, Email: m.Email})
+	}
+	return ret
+}
+
+// This function returns the dependencies of the given library with each
+// library listed only once.  If a library is listed more than once, the
+// stricter version (i.e., the newer version with the same major version)
+// is kept.  Dependencies on different major versions of the same library
+// conflict, in which case the first one is kept (and the conflict is
+// logged).
+func mergeDependencies(lib *dirinfo.LocalLibrary, logger CrawlLogger) []dirinfo.Dependency {
+	ret := []dirinfo.Dependency{}
+	index := map[string]int{}
+	for _, dep := range lib.Dependencies {
+		i, exists := index[dep.Name]
+		if !exists {
+			index[dep.Name] = len(ret)
+			ret = append(ret, dep)
+			continue
+		}
+		existing := ret[i].Version
+		if existing.Major != dep.Version.Major {
+			logger.Warnf("Library %s depends on both version %s and %s of %s (using %s)",
+				lib.Name, existing.String(), dep.Version.String(), dep.Name, existing.String())
+			continue
+		}
+		if dep.Version.GT(existing) {
+			ret[i].Version = dep.Version
+		}
+	}
+	return ret
+}
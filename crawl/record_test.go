@@ -0,0 +1,271 @@
+package crawl
+
+import (
+	"io/ioutil"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/blang/semver"
+	"github.com/google/go-github/github"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+
+	"github.com/impact/impact/dirinfo"
+	"github.com/impact/impact/parsing"
+	"github.com/impact/impact/recorder"
+)
+
+// This recorder keeps track of the hash recorded for each version
+type hashRecorder struct {
+	recorder.NullRecorder
+	hashes  map[string]string
+	version string
+}
+
+func (hr *hashRecorder) GetLibrary(name string, uri string,
+	owner_uri string) recorder.LibraryRecorder {
+	return hr
+}
+
+func (hr *hashRecorder) HasVersion(v semver.Version) bool {
+	_, exists := hr.hashes[v.String()]
+	return exists
+}
+
+func (hr *hashRecorder) AddVersion(v semver.Version) recorder.VersionRecorder {
+	hr.version = v.String()
+	hr.hashes[hr.version] = ""
+	return hr
+}
+
+func (hr *hashRecorder) SetHash(hash string) {
+	hr.hashes[hr.version] = hash
+}
+
+func TestDuplicateVersions(t *testing.T) {
+	Convey("Testing handling of duplicate versions", t, func(c C) {
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		di := dirinfo.MakeDirectoryInfo()
+		di.Libraries = []*dirinfo.LocalLibrary{&dirinfo.LocalLibrary{Name: "Foo", Path: "."}}
+		v := semver.MustParse("1.0.0")
+		details := repoDetails{URI: "https://github.com/a/Foo", Stars: -1}
+
+		hr := &hashRecorder{hashes: map[string]string{}}
+		recordVersion(hr, di, details, v, versionRecord{sha: "abc"}, recordPolicy{}, logger)
+		recordVersion(hr, di, details, v, versionRecord{sha: "def"}, recordPolicy{}, logger)
+		Equals(c, hr.hashes["1.0.0"], "def")
+
+		skip := func() bool { return false }
+		recordVersion(hr, di, details, v, versionRecord{sha: "ghi"}, recordPolicy{replace: skip}, logger)
+		Equals(c, hr.hashes["1.0.0"], "def")
+	})
+}
+
+func TestRewriteURL(t *testing.T) {
+	Convey("Testing rewriting of archive URLs", t, func(c C) {
+		mirror := func(original string) string {
+			if strings.HasPrefix(original, "https://codeload.github.com/") {
+				return "https://mirror.example.com/" + strings.TrimPrefix(original, "https://codeload.github.com/")
+			}
+			return ""
+		}
+
+		Equals(c, rewriteURL(mirror, "https://codeload.github.com/a/Foo/zip/master"),
+			"https://mirror.example.com/a/Foo/zip/master")
+		Equals(c, rewriteURL(mirror, "https://github.com/a/Foo/archive/v1.0.0.zip"),
+			"https://github.com/a/Foo/archive/v1.0.0.zip")
+		Equals(c, rewriteURL(mirror, ""), "")
+		Equals(c, rewriteURL(nil, "https://codeload.github.com/a/Foo/zip/master"),
+			"https://codeload.github.com/a/Foo/zip/master")
+
+		// Mirrors that give nothing (or the URL already recorded) are
+		// left out
+		original := "https://codeload.github.com/a/Foo/tar.gz/v1.0.0"
+		other := func(original string) string { return "https://other.example.com/Foo.tar.gz" }
+		Resembles(c, mirrorURLs([]URLRewriter{mirror, other}, original, original),
+			[]string{"https://mirror.example.com/a/Foo/tar.gz/v1.0.0", "https://other.example.com/Foo.tar.gz"})
+		Resembles(c, mirrorURLs([]URLRewriter{mirror, other}, original, rewriteURL(mirror, original)),
+			[]string{"https://other.example.com/Foo.tar.gz"})
+		Resembles(c, mirrorURLs([]URLRewriter{mirror}, "https://github.com/a/Foo.tar.gz", ""), []string{})
+		Resembles(c, mirrorURLs([]URLRewriter{other}, "", ""), []string{})
+	})
+}
+
+func TestMergeDependencies(t *testing.T) {
+	Convey("Testing merging of duplicate dependencies", t, func(c C) {
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		dep := func(name string, version string) dirinfo.Dependency {
+			return dirinfo.Dependency{Name: name, Version: semver.MustParse(version)}
+		}
+		lib := &dirinfo.LocalLibrary{
+			Name: "Foo",
+			Path: ".",
+			Dependencies: []dirinfo.Dependency{
+				dep("Modelica", "3.2.0"),
+				dep("Complex", "1.0.0"),
+				dep("Modelica", "3.2.2"),
+				dep("Complex", "2.0.0"),
+			},
+		}
+
+		deps := mergeDependencies(lib, logger)
+		Equals(c, len(deps), 2)
+		Equals(c, deps[0].Name, "Modelica")
+		Equals(c, deps[0].Version.String(), "3.2.2")
+		// Conflicting versions keep the first one
+		Equals(c, deps[1].Name, "Complex")
+		Equals(c, deps[1].Version.String(), "1.0.0")
+
+		di := dirinfo.MakeDirectoryInfo()
+		di.Libraries = []*dirinfo.LocalLibrary{lib}
+		m := recorder.NewMemoryRecorder()
+		recordVersion(m, di, repoDetails{URI: "https://github.com/a/Foo", Stars: -1},
+			semver.MustParse("1.0.0"), versionRecord{sha: "abc"}, recordPolicy{}, logger)
+		Equals(c, len(m.Find("Foo").Versions["1.0.0"].Dependencies), 2)
+	})
+}
+
+func TestTagMapper(t *testing.T) {
+	Convey("Testing mapping tags to versions", t, func(c C) {
+		v, ok := mapTag(nil, "v1.2.3")
+		IsTrue(c, ok)
+		Equals(c, v, "1.2.3")
+		v, ok = mapTag(nil, "1.2.3")
+		IsTrue(c, ok)
+		Equals(c, v, "1.2.3")
+
+		// Empty tag names are skipped rather than causing a panic
+		_, ok = mapTag(nil, "")
+		IsTrue(c, !ok)
+		_, ok = DefaultTagMapper(" ")
+		IsTrue(c, !ok)
+
+		for tag, expected := range map[string]string{
+			"V1.0.0":        "1.0.0",
+			"1.0.0":         "1.0.0",
+			"v":             "v",
+			"release-2.1":   "2.1",
+			"Release_2.1":   "2.1",
+			"version3.0.0":  "3.0.0",
+			"ver-1.0":       "1.0",
+			"vision":        "vision",
+			"releases-2016": "releases-2016",
+		} {
+			v, ok = DefaultTagMapper(tag)
+			IsTrue(c, ok)
+			Equals(c, v, expected)
+		}
+
+		release := func(tag string) (string, bool) {
+			if !strings.HasPrefix(tag, "release-") {
+				return "", false
+			}
+			return strings.TrimPrefix(tag, "release-"), true
+		}
+		v, ok = mapTag(release, "release-1.2.3")
+		IsTrue(c, ok)
+		Equals(c, v, "1.2.3")
+		_, ok = mapTag(release, "v1.2.3")
+		IsTrue(c, !ok)
+
+		// Skipped tags are never considered versions
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		cr, err := MakeGitHubCrawler("a", nil, "")
+		NoError(c, err)
+		cr.empty = &emptyRepositories{}
+		cr.SetTagMapper(release)
+		tag := func(name string) github.RepositoryTag {
+			return github.RepositoryTag{
+				Name:   github.String(name),
+				Commit: &github.Commit{SHA: github.String("abcdef")},
+			}
+		}
+		gc := NewGitHubClient(nil, 0, logger)
+		cr.processTags(gc, recorder.NullRecorder{}, "Foo", github.Repository{},
+			[]github.RepositoryTag{tag("release-1.0.0"), tag("v2.0.0")}, logger)
+		Equals(c, cr.empty.reasons["a/Foo"], "1 tags with semantic versions, none recorded")
+	})
+}
+
+func TestMismatchPolicy(t *testing.T) {
+	Convey("Testing libraries that declare a different version", t, func(c C) {
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		tagged := semver.MustParse("1.3.0")
+		var scheme parsing.VersionScheme
+
+		record := func(declared string, policy MismatchPolicy) []string {
+			di := dirinfo.MakeDirectoryInfo()
+			di.Libraries = []*dirinfo.LocalLibrary{{Name: "Foo", Path: "Foo", Version: declared}}
+			m := recorder.NewMemoryRecorder()
+			recordVersion(m, di, repoDetails{URI: "https://github.com/a/Foo", Stars: -1},
+				tagged, versionRecord{sha: "abc"}, recordPolicy{scheme: scheme, mismatches: policy}, logger)
+			versions := []string{}
+			for _, lib := range m.Libraries {
+				for k := range lib.Versions {
+					versions = append(versions, k)
+				}
+			}
+			return versions
+		}
+
+		Resembles(c, record("", SkipMismatches), []string{"1.3.0"})
+		Resembles(c, record("1.3", SkipMismatches), []string{"1.3.0"})
+		Resembles(c, record("1.2.0", TrustTag), []string{"1.3.0"})
+		Resembles(c, record("1.2.0", TrustMetadata), []string{"1.2.0"})
+		Resembles(c, record("1.2.0", SkipMismatches), []string{})
+		// Versions that can't be normalized are ignored
+		Resembles(c, record("latest", SkipMismatches), []string{"1.3.0"})
+
+		// The declared version is parsed the same way as the tag
+		scheme = parsing.CalendarVersions
+		var err error
+		tagged, err = parsing.NormalizeWith(scheme, "2023-01-15")
+		NoError(c, err)
+		Resembles(c, record("2023-01-15", SkipMismatches), []string{"2023.1.15"})
+		Resembles(c, record("20230115", SkipMismatches), []string{"2023.1.15"})
+		Resembles(c, record("2023-02-01", SkipMismatches), []string{})
+		Resembles(c, record("2023-02-01", TrustMetadata), []string{"2023.2.1"})
+		Resembles(c, record("1.3.0", TrustMetadata), []string{"2023.1.15"})
+	})
+}
+
+func TestPopularity(t *testing.T) {
+	Convey("Testing recording of forks and open issues", t, func(c C) {
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		di := dirinfo.MakeDirectoryInfo()
+		di.Libraries = []*dirinfo.LocalLibrary{{Name: "Foo", Path: "Foo"}}
+
+		repo := github.Repository{ForksCount: github.Int(4)}
+		details := repoDetails{
+			URI:        "https://github.com/a/Foo",
+			Stars:      -1,
+			Forks:      intOf(repo.ForksCount),
+			OpenIssues: intOf(repo.OpenIssuesCount),
+		}
+		m := recorder.NewMemoryRecorder()
+		recordVersion(m, di, details, semver.MustParse("1.0.0"), versionRecord{sha: "abc"}, recordPolicy{}, logger)
+		foo := m.Find("Foo")
+		Equals(c, foo.Forks, 4)
+		// Missing counts are zero
+		Equals(c, foo.OpenIssues, 0)
+		// The stars are unaffected
+		Equals(c, foo.Stars, -1)
+
+		// Without a description of the repository, the description of the
+		// library is used
+		Equals(c, foo.Description, "")
+		di.Libraries[0].Description = "A library"
+		recordVersion(m, di, details, semver.MustParse("1.1.0"), versionRecord{sha: "def"}, recordPolicy{}, logger)
+		Equals(c, foo.Description, "A library")
+		details.Description = "A repository"
+		recordVersion(m, di, details, semver.MustParse("1.2.0"), versionRecord{sha: "ghi", tag: "v01.2"},
+			recordPolicy{}, logger)
+		Equals(c, foo.Description, "A repository")
+
+		// The tag is recorded as it was written (if there is one)
+		Equals(c, foo.Versions["1.2.0"].TagName, "v01.2")
+		Equals(c, foo.Versions["1.1.0"].TagName, "")
+	})
+}
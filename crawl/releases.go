@@ -0,0 +1,116 @@
+package crawl
+
+import (
+	"fmt"
+
+	"github.com/google/go-github/github"
+)
+
+// This function returns a tag for each (published) release of the named
+// repository whose tag isn't among the given tags.  Some repositories
+// publish releases whose tags aren't returned when listing the tags, so
+// without this the versions they release would be missed.  The releases
+// are processed just like tags, so the commit each release refers to is
+// looked up from its tag.  Releases that can't be resolved to a commit
+// are skipped (with a warning) rather than failing the repository.
+func (c GitHubCrawler) releaseTags(client *GitHubClient, rname string, tags []github.RepositoryTag,
+	logger CrawlLogger) []github.RepositoryTag {
+	known := map[string]bool{}
+	for _, tag := range tags {
+		known[stringOf(tag.Name)] = true
+	}
+
+	releases := []github.RepositoryRelease{}
+	opts := github.ListOptions{PerPage: 100}
+	for {
+		var page []github.RepositoryRelease
+		var resp *github.Response
+		err := client.call(func() (err error) {
+			page, resp, err = client.client.Repositories.ListReleases(c.user, rname, &opts)
+			return
+		})
+		if err != nil {
+			if !notFound(err) {
+				logger.Warnf("Unable to list releases of %s/%s: %v", c.user, rname, err)
+			}
+			return nil
+		}
+		releases = append(releases, page...)
+		if resp == nil || resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	ret := []github.RepositoryTag{}
+	for _, release := range releases {
+		name := stringOf(release.TagName)
+		if name == "" || known[name] || (release.Draft != nil && *release.Draft) {
+			continue
+		}
+		known[name] = true
+
+		sha, err := tagCommit(client, c.user, rname, name)
+		if err != nil {
+			if client.ctx.Err() == nil {
+				logger.Warnf("Skipping release %s of %s/%s, unable to find its commit: %v",
+					name, c.user, rname, err)
+			}
+			continue
+		}
+		logger.Debugf("  Release %s isn't among the tags of %s/%s, processing it as well",
+			name, c.user, rname)
+		ret = append(ret, github.RepositoryTag{
+			Name:       github.String(name),
+			Commit:     &github.Commit{SHA: github.String(sha)},
+			TarballURL: release.TarballURL,
+			ZipballURL: release.ZipballURL,
+		})
+	}
+	return ret
+}
+
+// This function returns the SHA of the commit the named tag refers to.
+// Annotated tags refer to a tag object, which in turn refers to the
+// commit.
+func tagCommit(client *GitHubClient, owner string, rname string, tag string) (string, error) {
+	obj, err := tagObject(client, owner, rname, tag)
+	if err != nil {
+		return "", err
+	}
+
+	sha := *obj.SHA
+	if stringOf(obj.Type) != "tag" {
+		return sha, nil
+	}
+
+	var annotated *github.Tag
+	err = client.call(func() (err error) {
+		annotated, _, err = client.client.Git.GetTag(owner, rname, sha)
+		return
+	})
+	if err != nil {
+		return "", err
+	}
+	if annotated == nil || annotated.Object == nil || annotated.Object.SHA == nil {
+		return "", fmt.Errorf("No commit found for annotated tag %s", tag)
+	}
+	return *annotated.Object.SHA, nil
+}
+
+// This function returns the object the named tag refers to.  This is a
+// commit for a lightweight tag and a tag object for an annotated tag.
+func tagObject(client *GitHubClient, owner string, rname string, tag string) (*github.GitObject, error) {
+	var ref *github.Reference
+	err := client.call(func() (err error) {
+		ref, _, err = client.client.Git.GetRef(owner, rname, "tags/"+tag)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+	if ref == nil || ref.Object == nil || ref.Object.SHA == nil {
+		return nil, fmt.Errorf("No object found for tag %s", tag)
+	}
+	return ref.Object, nil
+}
@@ -0,0 +1,71 @@
+package crawl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/github"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+)
+
+func TestReleaseTags(t *testing.T) {
+	Convey("Testing indexing releases whose tags aren't listed", t, func(c C) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/repos/a/R/releases":
+				fmt.Fprint(w, `[
+{"tag_name": "v1.0.0"},
+{"tag_name": "v2.0.0", "tarball_url": "https://example.com/v2.tar.gz"},
+{"tag_name": "v3.0.0"},
+{"tag_name": "v4.0.0", "draft": true},
+{"tag_name": "v5.0.0"},
+{"tag_name": "v2.0.0"}]`)
+			case "/repos/a/R/git/refs/tags/v2.0.0":
+				fmt.Fprint(w, `{"ref": "refs/tags/v2.0.0", "object": {"type": "commit", "sha": "abc"}}`)
+			case "/repos/a/R/git/refs/tags/v3.0.0":
+				fmt.Fprint(w, `{"ref": "refs/tags/v3.0.0", "object": {"type": "tag", "sha": "t3"}}`)
+			case "/repos/a/R/git/tags/t3":
+				fmt.Fprint(w, `{"sha": "t3", "object": {"type": "commit", "sha": "def"}}`)
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		defer server.Close()
+
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		client := github.NewClient(nil)
+		base, err := url.Parse(server.URL + "/")
+		NoError(c, err)
+		client.BaseURL = base
+		gc := NewGitHubClient(client, 0, logger)
+		gc.SetRetries(0, 0)
+
+		cr, err := MakeGitHubCrawler("a", nil, "")
+		NoError(c, err)
+		tags := []github.RepositoryTag{{
+			Name:   github.String("v1.0.0"),
+			Commit: &github.Commit{SHA: github.String("123")},
+		}}
+
+		// Only releases with (resolvable) tags that weren't listed are
+		// returned, and only once
+		extra := cr.releaseTags(gc, "R", tags, logger)
+		Equals(c, len(extra), 2)
+		Equals(c, *extra[0].Name, "v2.0.0")
+		Equals(c, *extra[0].Commit.SHA, "abc")
+		Equals(c, *extra[0].TarballURL, "https://example.com/v2.tar.gz")
+		IsTrue(c, extra[0].ZipballURL == nil)
+		Equals(c, *extra[1].Name, "v3.0.0")
+		Equals(c, *extra[1].Commit.SHA, "def")
+
+		// A repository without any releases
+		Equals(c, len(cr.releaseTags(gc, "S", tags, logger)), 0)
+	})
+}
@@ -0,0 +1,26 @@
+package crawl
+
+import (
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// This function determines whether the given repository (as returned by
+// GitHub when asked for the named repository of the given owner) has been
+// renamed (or transferred to another owner).  GitHub redirects requests
+// for the old name, so the details are those of the repository under its
+// new name.  If it has been renamed, the new owner and name are returned.
+// Since names aren't case sensitive, names that only differ in case
+// aren't considered renamed.
+func renamedTo(owner string, rname string, repo github.Repository) (string, string, bool) {
+	full := stringOf(repo.FullName)
+	i := strings.Index(full, "/")
+	if i < 0 {
+		return owner, rname, false
+	}
+	if strings.EqualFold(full, owner+"/"+rname) {
+		return owner, rname, false
+	}
+	return full[:i], full[i+1:], true
+}
@@ -0,0 +1,78 @@
+package crawl
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/github"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+
+	"github.com/impact/impact/recorder"
+)
+
+func TestRenames(t *testing.T) {
+	Convey("Testing detecting renamed repositories", t, func(c C) {
+		repo := func(full string) github.Repository {
+			return github.Repository{FullName: github.String(full)}
+		}
+		_, _, renamed := renamedTo("a", "Foo", repo("a/Foo"))
+		IsFalse(c, renamed)
+		_, _, renamed = renamedTo("a", "foo", repo("A/Foo"))
+		IsFalse(c, renamed)
+		_, _, renamed = renamedTo("a", "Foo", github.Repository{})
+		IsFalse(c, renamed)
+		owner, name, renamed := renamedTo("a", "Foo", repo("b/Bar"))
+		IsTrue(c, renamed)
+		Equals(c, owner, "b")
+		Equals(c, name, "Bar")
+	})
+
+	Convey("Testing following renamed repositories", t, func(c C) {
+		listed := []string{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/repos/a/Old":
+				// GitHub follows the redirect to the new name
+				fmt.Fprint(w, `{"name": "New", "full_name": "b/New", "html_url": "https://github.com/b/New"}`)
+			case "/repos/a/Old/tags", "/repos/b/New/tags":
+				listed = append(listed, r.URL.Path)
+				fmt.Fprint(w, `[]`)
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		defer server.Close()
+
+		buf := bytes.Buffer{}
+		logger := StandardLogger(log.New(&buf, "", 0), Quiet)
+		client := github.NewClient(nil)
+		base, err := url.Parse(server.URL + "/")
+		NoError(c, err)
+		client.BaseURL = base
+		gc := NewGitHubClient(client, 0, logger)
+		gc.SetRetries(0, 0)
+
+		cr, err := MakeGitHubCrawler("a", nil, "")
+		NoError(c, err)
+		old := github.Repository{Name: github.String("Old")}
+
+		// By default, the rename is only logged
+		NoError(c, cr.processRepo(gc, recorder.NullRecorder{}, old, logger))
+		Resembles(c, listed, []string{"/repos/a/Old/tags"})
+		IsTrue(c, strings.Contains(buf.String(), "Repository a/Old was renamed to b/New\n"))
+
+		// Otherwise, the new name is used from then on
+		cr.SetFollowRenames(true)
+		NoError(c, cr.processRepo(gc, recorder.NullRecorder{}, old, logger))
+		Resembles(c, listed, []string{"/repos/a/Old/tags", "/repos/b/New/tags"})
+		IsTrue(c, strings.Contains(buf.String(), "indexing it under the new name"))
+	})
+}
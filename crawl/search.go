@@ -0,0 +1,129 @@
+package crawl
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/go-github/github"
+
+	"github.com/impact/impact/recorder"
+)
+
+// GitHub only returns this many results for any search
+const maxSearchResults = 1000
+
+// The MakeGitHubSearchCrawler function creates a crawler that indexes
+// the repositories matching a GitHub repository search (e.g.,
+// "language:Modelica topic:modelica-library") rather than those of
+// particular users.  This finds libraries whose owners aren't known in
+// advance.  Of the repositories found, only those that match any of the
+// given patterns are crawled (all of them if no patterns are given).  As
+// with MakeMultiGitHubCrawler, libraries with the same name found under
+// more than one owner are recorded as a single library.
+func MakeGitHubSearchCrawler(query string, patterns []string, token string) (GitHubCrawler, error) {
+	if strings.TrimSpace(query) == "" {
+		return GitHubCrawler{}, fmt.Errorf("No GitHub search query specified")
+	}
+
+	c, err := MakeGitHubCrawler("", patterns, token)
+	if err != nil {
+		return GitHubCrawler{}, err
+	}
+	c.users = nil
+	c.query = query
+	return c, nil
+}
+
+// This function indexes all the repositories matching the search query.
+func (c GitHubCrawler) crawlSearch(gc *GitHubClient, r recorder.Recorder,
+	logger CrawlLogger) error {
+	repos, err := c.searchRepos(gc, logger)
+	if err != nil {
+		return err
+	}
+	return c.crawlRepos(gc, r, repos, logger)
+}
+
+// This function returns the repositories matching the search query.  The
+// results may change while they are being paged through, so the same
+// repository can turn up on more than one page.  Each repository is only
+// returned once.
+func (c GitHubCrawler) searchRepos(gc *GitHubClient, logger CrawlLogger) ([]github.Repository, error) {
+	sopts := github.SearchOptions{}
+	sopts.Page = c.startPage
+	sopts.PerPage = c.perPage
+
+	logger.Debugf("Searching for repositories matching '%s'", c.query)
+	seen := map[string]bool{}
+	repos := []github.Repository{}
+	total := 0
+	for {
+		var result *github.RepositoriesSearchResult
+		var resp *github.Response
+		err := gc.call(func() (err error) {
+			result, resp, err = gc.client.Search.Repositories(c.query, &sopts)
+			return
+		})
+		if gc.ctx.Err() != nil {
+			return nil, gc.ctx.Err()
+		}
+		if err != nil {
+			logger.Errorf("Searching for repositories matching '%s': %v", c.query, err)
+			return nil, fmt.Errorf("Error searching for repositories matching '%s': %v", c.query, err)
+		}
+		total = intOf(result.Total)
+
+		for _, repo := range result.Repositories {
+			key := strings.ToLower(stringOf(repo.FullName))
+			if key != "" && seen[key] {
+				continue
+			}
+			seen[key] = true
+			repos = append(repos, repo)
+		}
+		logger.Debugf("  Fetching page %d, %d entries", sopts.Page, len(result.Repositories))
+
+		if resp == nil || resp.NextPage == 0 {
+			break
+		}
+		sopts.Page = resp.NextPage
+
+		// Searches have a (much lower) rate limit of their own, so rather
+		// than exceeding it, wait for it to reset
+		if resp.Limit > 0 && resp.Remaining == 0 {
+			err = gc.waitFor(resp.Reset.Time, "Search rate limit")
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if total > maxSearchResults {
+		logger.Warnf("Search for '%s' matched %d repositories but only the first %d can be crawled",
+			c.query, total, maxSearchResults)
+	}
+	return repos, nil
+}
+
+// This function returns the crawler to use for the given repository.  When
+// searching, the repositories found belong to many different owners.
+func (c GitHubCrawler) forRepo(repo github.Repository) GitHubCrawler {
+	if c.query != "" && repo.Owner != nil && repo.Owner.Login != nil {
+		c.user = *repo.Owner.Login
+	}
+	return c
+}
+
+// The waitFor method waits until the given (rate limit) reset time,
+// subject to the maximum wait.
+func (gc *GitHubClient) waitFor(reset time.Time, limit string) error {
+	if !gc.reserve(reset) {
+		return fmt.Errorf("%s reached (resets at %v) and maximum wait of %v exceeded",
+			limit, reset, gc.maxWait)
+	}
+	gc.logger.Infof("%s reached, waiting until %v to continue", limit, reset)
+	wait := reset.Sub(time.Now())
+	gc.metrics.rateLimitSleep(wait)
+	return gc.waitOut(wait)
+}
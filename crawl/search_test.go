@@ -0,0 +1,92 @@
+package crawl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+
+	"github.com/impact/impact/recorder"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+)
+
+func TestSearch(t *testing.T) {
+	Convey("Testing crawling the results of a repository search", t, func(c C) {
+		_, err := MakeGitHubSearchCrawler(" ", nil, "")
+		IsError(c, err)
+
+		reset := time.Now().Unix()
+		var mutex sync.Mutex
+		fetched := []string{}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/search/repositories":
+				Equals(c, r.URL.Query().Get("q"), "language:Modelica")
+				switch r.URL.Query().Get("page") {
+				case "1":
+					// The search rate limit has been reached (but has
+					// already been reset)
+					w.Header().Set("X-RateLimit-Limit", "10")
+					w.Header().Set("X-RateLimit-Remaining", "0")
+					w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", reset))
+					w.Header().Set("Link", fmt.Sprintf(`<http://%s/search/repositories?q=x&page=2>; rel="next"`, r.Host))
+					fmt.Fprint(w, `{"total_count": 3, "items": [
+{"name": "A", "full_name": "x/A", "owner": {"login": "x"}},
+{"name": "B", "full_name": "y/B", "owner": {"login": "y"}}]}`)
+				default:
+					// Results can move between pages
+					fmt.Fprint(w, `{"total_count": 3, "items": [
+{"name": "B", "full_name": "y/B", "owner": {"login": "y"}},
+{"name": "C", "full_name": "z/C", "owner": {"login": "z"}}]}`)
+				}
+			default:
+				mutex.Lock()
+				fetched = append(fetched, r.URL.Path)
+				mutex.Unlock()
+				http.NotFound(w, r)
+			}
+		}))
+		defer server.Close()
+
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		client := github.NewClient(nil)
+		base, err := url.Parse(server.URL + "/")
+		NoError(c, err)
+		client.BaseURL = base
+		gc := NewGitHubClient(client, 0, logger)
+		gc.SetRetries(0, 0)
+
+		cr, err := MakeGitHubSearchCrawler("language:Modelica", nil, "")
+		NoError(c, err)
+		Equals(c, cr.String(), "github search 'language:Modelica'/.+")
+		cr.failures = &repoErrors{}
+		err = cr.crawlSearch(gc, recorder.NullRecorder{}, logger)
+		NoError(c, err)
+
+		// Each repository is only processed once (as a repository of
+		// its owner)
+		Equals(c, cr.stats.ReposListed, int64(3))
+		Equals(c, cr.stats.ReposExamined, int64(3))
+		sort.Strings(fetched)
+		Resembles(c, fetched, []string{"/repos/x/A", "/repos/y/B", "/repos/z/C"})
+		failures := cr.failures.list()
+		Equals(c, len(failures), 3)
+		Equals(c, failures[0].User, "x")
+
+		// Waiting for the search rate limit is subject to the maximum wait
+		reset = time.Now().Add(time.Hour).Unix()
+		gc = NewGitHubClient(client, time.Second, logger)
+		_, err = cr.searchRepos(gc, logger)
+		IsError(c, err)
+	})
+}
@@ -0,0 +1,104 @@
+package crawl
+
+import (
+	"bufio"
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"path"
+	"strings"
+)
+
+// The name of the file (next to impact.json) holding a detached signature
+// of impact.json
+var signatureFile = "impact.json.sig"
+
+// The PublisherKeys type holds the (Ed25519) public keys of trusted
+// publishers.  The metadata of a version (i.e., its impact.json) is
+// verified if it comes with a detached signature made with any of these
+// keys (see SetPublisherKeys).
+type PublisherKeys []ed25519.PublicKey
+
+// The ParsePublisherKeys function parses a list of public keys, one
+// (base64 encoded) key per line.  Empty lines and lines starting with #
+// are ignored.
+func ParsePublisherKeys(raw string) (PublisherKeys, error) {
+	keys := PublisherKeys{}
+	scanner := bufio.NewScanner(strings.NewReader(raw))
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		key, err := base64.StdEncoding.DecodeString(text)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid public key on line %d: %v", line, err)
+		}
+		if len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("Invalid public key on line %d: expected %d bytes, got %d",
+				line, ed25519.PublicKeySize, len(key))
+		}
+		keys = append(keys, ed25519.PublicKey(key))
+	}
+	return keys, nil
+}
+
+// The ReadPublisherKeys function reads the public keys in the named file
+// (see ParsePublisherKeys).
+func ReadPublisherKeys(filename string) (PublisherKeys, error) {
+	raw, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read publisher keys from %s: %v", filename, err)
+	}
+	keys, err := ParsePublisherKeys(string(raw))
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read publisher keys from %s: %v", filename, err)
+	}
+	return keys, nil
+}
+
+// The verify method returns true if the given signature of the given
+// metadata was made with any of these keys.
+func (k PublisherKeys) verify(metadata []byte, sig []byte) bool {
+	if len(sig) != ed25519.SignatureSize {
+		return false
+	}
+	for _, key := range k {
+		if ed25519.Verify(key, metadata, sig) {
+			return true
+		}
+	}
+	return false
+}
+
+// This function determines whether the given metadata (i.e., the contents
+// of impact.json in the given directory) was signed by a trusted
+// publisher.  The signature is read from signatureFile, either base64
+// encoded or as raw bytes.  Nothing is read if there are no trusted
+// publishers.  Metadata that isn't signed (or whose signature doesn't
+// check out) is still used, it just isn't verified.
+func verifyMetadata(src contents, root string, metadata []byte, keys PublisherKeys,
+	repostr string, logger CrawlLogger) bool {
+	if len(keys) == 0 {
+		return false
+	}
+
+	raw, err := src.ReadFile(path.Join(root, signatureFile))
+	if err != nil {
+		logger.Debugf("  impact.json in %s isn't signed", repostr)
+		return false
+	}
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(raw)))
+	if err != nil {
+		sig = raw
+	}
+	if !keys.verify(metadata, sig) {
+		logger.Warnf("Signature of impact.json in %s wasn't made by a trusted publisher", repostr)
+		return false
+	}
+	logger.Debugf("  impact.json in %s was signed by a trusted publisher", repostr)
+	return true
+}
@@ -0,0 +1,84 @@
+package crawl
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+)
+
+func TestPublisherKeys(t *testing.T) {
+	Convey("Testing reading the keys of trusted publishers", t, func(c C) {
+		pub, _, err := ed25519.GenerateKey(nil)
+		NoError(c, err)
+		encoded := base64.StdEncoding.EncodeToString(pub)
+
+		keys, err := ParsePublisherKeys("# Trusted publishers\n\n" + encoded + "\n")
+		NoError(c, err)
+		Equals(c, len(keys), 1)
+		IsTrue(c, bytes.Equal(keys[0], pub))
+
+		_, err = ParsePublisherKeys("not a key")
+		IsError(c, err)
+		_, err = ParsePublisherKeys(base64.StdEncoding.EncodeToString([]byte("short")))
+		IsError(c, err)
+	})
+}
+
+func TestSignedMetadata(t *testing.T) {
+	Convey("Testing verifying signed impact.json files", t, func(c C) {
+		root, err := ioutil.TempDir("", "impact")
+		NoError(c, err)
+		defer os.RemoveAll(root)
+
+		metadata := `{"owner_uri": "https://github.com/a"}`
+		writeFile(c, filepath.Join(root, "impact.json"), metadata)
+		writeFile(c, filepath.Join(root, "Foo", "package.mo"), "within;\npackage Foo\nend Foo;")
+
+		pub, priv, err := ed25519.GenerateKey(nil)
+		NoError(c, err)
+		other, _, err := ed25519.GenerateKey(nil)
+		NoError(c, err)
+		sign := func(data string) string {
+			return base64.StdEncoding.EncodeToString(ed25519.Sign(priv, []byte(data)))
+		}
+
+		buf := bytes.Buffer{}
+		logger := StandardLogger(log.New(&buf, "", 0), Quiet)
+		extract := func(keys PublisherKeys) bool {
+			di := extractInfo(fileSystemContents{root: root}, "a", "Foo", "", "", "", "", keys, logger)
+			Equals(c, len(di.Libraries), 1)
+			return di.Verified
+		}
+
+		// Without a signature, the metadata isn't verified (but is still used)
+		IsFalse(c, extract(PublisherKeys{pub}))
+
+		writeFile(c, filepath.Join(root, signatureFile), sign(metadata)+"\n")
+		IsTrue(c, extract(PublisherKeys{other, pub}))
+		// Nothing is verified unless publishers are trusted
+		IsFalse(c, extract(nil))
+		IsFalse(c, extract(PublisherKeys{other}))
+		IsTrue(c, strings.Contains(buf.String(), "wasn't made by a trusted publisher"))
+
+		// Signatures may also be stored as raw bytes
+		writeFile(c, filepath.Join(root, signatureFile), string(ed25519.Sign(priv, []byte(metadata))))
+		IsTrue(c, extract(PublisherKeys{pub}))
+
+		// Changing the metadata invalidates the signature
+		writeFile(c, filepath.Join(root, "impact.json"), `{"owner_uri": "https://github.com/b"}`)
+		IsFalse(c, extract(PublisherKeys{pub}))
+
+		// Whether metadata is verified can't be claimed in impact.json
+		writeFile(c, filepath.Join(root, "impact.json"), `{"verified": true, "Verified": true}`)
+		IsFalse(c, extract(PublisherKeys{pub}))
+	})
+}
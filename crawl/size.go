@@ -0,0 +1,80 @@
+package crawl
+
+import (
+	"fmt"
+
+	"github.com/google/go-github/github"
+)
+
+// The version of go-github we use doesn't say whether a (recursive) tree
+// was truncated, so trees are read into this instead.
+type gitTree struct {
+	Entries []struct {
+		Type string `json:"type"`
+		Size int64  `json:"size"`
+	} `json:"tree"`
+	Truncated bool `json:"truncated"`
+}
+
+// This function returns the size (in bytes) of the given version of a
+// repository.  If exact sizes were requested (see SetExactSizes), the
+// sizes of all files in the tree of the commit are added up.  This takes
+// another API call for each version (unless the size was computed by a
+// previous crawl for the same SHA).  Otherwise, or if the tree can't be
+// fetched completely, the size GitHub reports for the repository as a
+// whole is used instead.
+func (c GitHubCrawler) versionSize(client *GitHubClient, key string, owner string, rname string,
+	sha string, repo github.Repository, logger CrawlLogger) int64 {
+	// GitHub reports the size of repositories in kilobytes
+	fallback := int64(intOf(repo.Size)) * 1024
+	if !c.exactSizes || sha == "" {
+		return fallback
+	}
+
+	size, cached := c.cache.size(key, sha)
+	if cached {
+		logger.Debugf("    Using cached size of %s", key)
+		return size
+	}
+
+	size, err := treeSize(client, owner, rname, sha)
+	if err != nil {
+		if client.ctx.Err() == nil {
+			logger.Warnf("Unable to determine size of %s, using the size of the repository: %v",
+				key, err)
+		}
+		return fallback
+	}
+	c.cache.setSize(key, sha, size)
+	return size
+}
+
+// This function adds up the sizes of all files in the tree of the given
+// commit.
+func treeSize(client *GitHubClient, owner string, rname string, sha string) (int64, error) {
+	tree := gitTree{}
+	err := client.call(func() error {
+		tree = gitTree{}
+		req, err := client.client.NewRequest("GET",
+			fmt.Sprintf("repos/%s/%s/git/trees/%s?recursive=1", owner, rname, sha), nil)
+		if err != nil {
+			return err
+		}
+		_, err = client.client.Do(req, &tree)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+	if tree.Truncated {
+		return 0, fmt.Errorf("Tree of %s is too large to list", sha)
+	}
+
+	size := int64(0)
+	for _, entry := range tree.Entries {
+		if entry.Type == "blob" {
+			size += entry.Size
+		}
+	}
+	return size, nil
+}
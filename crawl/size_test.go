@@ -0,0 +1,79 @@
+package crawl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-github/github"
+
+	"github.com/impact/impact/dirinfo"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+)
+
+func TestVersionSize(t *testing.T) {
+	Convey("Testing recording the size of versions", t, func(c C) {
+		trees := 0
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/repos/a/Foo/git/trees/abc":
+				trees++
+				fmt.Fprint(w, `{"sha": "abc", "truncated": false, "tree": [
+				  {"path": "Foo", "type": "tree"},
+				  {"path": "Foo/package.mo", "type": "blob", "size": 1000},
+				  {"path": "Foo/A.mo", "type": "blob", "size": 234},
+				  {"path": "Lib", "type": "commit"}
+				]}`)
+			case "/repos/a/Foo/git/trees/def":
+				fmt.Fprint(w, `{"sha": "def", "truncated": true, "tree": []}`)
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		defer server.Close()
+
+		dir, err := ioutil.TempDir("", "impact")
+		NoError(c, err)
+		defer os.RemoveAll(dir)
+
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		client := github.NewClient(nil)
+		base, err := url.Parse(server.URL + "/")
+		NoError(c, err)
+		client.BaseURL = base
+		gc := NewGitHubClient(client, 0, logger)
+		gc.SetRetries(0, 0)
+
+		cr, err := MakeGitHubCrawler("a", nil, "")
+		NoError(c, err)
+		cr.cache, err = loadCache(filepath.Join(dir, "cache.json"), logger)
+		NoError(c, err)
+		cr.cache.setVersion("a/Foo/1.0.0", "abc", "", dirinfo.MakeDirectoryInfo())
+		repo := github.Repository{Name: github.String("Foo"), Size: github.Int(5)}
+
+		// By default, the size of the repository is used
+		Equals(c, cr.versionSize(gc, "a/Foo/1.0.0", "a", "Foo", "abc", repo, logger), int64(5120))
+		Equals(c, trees, 0)
+
+		// Otherwise, the files in the version are added up (once)
+		cr.SetExactSizes(true)
+		Equals(c, cr.versionSize(gc, "a/Foo/1.0.0", "a", "Foo", "abc", repo, logger), int64(1234))
+		Equals(c, trees, 1)
+		cr.cache.setVersion("a/Foo/1.0.0", "abc", "", dirinfo.MakeDirectoryInfo())
+		Equals(c, cr.versionSize(gc, "a/Foo/1.0.0", "a", "Foo", "abc", repo, logger), int64(1234))
+		Equals(c, trees, 1)
+
+		// Trees that can't be listed completely fall back to the size of
+		// the repository
+		Equals(c, cr.versionSize(gc, "a/Foo/2.0.0", "a", "Foo", "def", repo, logger), int64(5120))
+		Equals(c, cr.versionSize(gc, "a/Foo/3.0.0", "a", "Foo", "ghi", repo, logger), int64(5120))
+	})
+}
@@ -0,0 +1,92 @@
+package crawl
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// The CrawlStats type summarizes how much work was done during a crawl.
+// All counters are updated atomically (since repositories may be
+// processed concurrently).  The failures are only filled in once the
+// crawl is complete.
+type CrawlStats struct {
+	ReposListed            int64 // Repositories listed (i.e., to be examined)
+	ReposExamined          int64 // Repositories examined
+	ReposSkippedPattern    int64 // Repositories that didn't match the pattern
+	ReposSkippedExclusion  int64 // Repositories for which every tag was excluded
+	ReposSkippedTopics     int64 // Repositories skipped because of their topics
+	ReposSkippedLanguage   int64 // Repositories without enough Modelica code
+	ReposUnchanged         int64 // Repositories not pushed to since the previous crawl
+	TagsProcessed          int64 // Tags processed
+	TagsSkippedLightweight int64 // Lightweight tags skipped (see SetAnnotatedOnly)
+	VersionsRecorded       int64 // Versions recorded
+	VersionsIgnored        int64 // Versions ignored because they weren't semantic versions
+	APICalls               int64 // Calls made to the GitHub API
+	// Repositories that were skipped because of errors
+	Failures []RepoError
+}
+
+// This function increments the given counter
+func count(counter *int64) {
+	atomic.AddInt64(counter, 1)
+}
+
+// The String method formats the statistics as a single line (suitable for
+// reporting as metrics).
+func (s CrawlStats) String() string {
+	return fmt.Sprintf("repos_listed=%d repos_examined=%d repos_skipped_pattern=%d "+
+		"repos_skipped_exclusion=%d repos_skipped_topics=%d repos_skipped_language=%d "+
+		"repos_unchanged=%d tags_processed=%d "+
+		"tags_skipped_lightweight=%d versions_recorded=%d versions_ignored=%d api_calls=%d",
+		s.ReposListed, s.ReposExamined, s.ReposSkippedPattern, s.ReposSkippedExclusion,
+		s.ReposSkippedTopics, s.ReposSkippedLanguage, s.ReposUnchanged,
+		s.TagsProcessed, s.TagsSkippedLightweight, s.VersionsRecorded, s.VersionsIgnored, s.APICalls)
+}
+
+// The progress method summarizes how far along the crawl is.  Since it
+// may be called while the crawl is running, the counters are read
+// atomically.
+func (s *CrawlStats) progress() string {
+	return fmt.Sprintf("Processed %d/%d repos, %d versions recorded",
+		atomic.LoadInt64(&s.ReposExamined), atomic.LoadInt64(&s.ReposListed),
+		atomic.LoadInt64(&s.VersionsRecorded))
+}
+
+// This function logs the progress of the crawl (see progress) at the
+// given interval until either the returned function is called or the
+// context is done.  The returned function doesn't return until nothing
+// more will be logged.  If the interval isn't positive, nothing is
+// logged at all.
+func startHeartbeat(ctx context.Context, interval time.Duration, stats *CrawlStats,
+	logger CrawlLogger) func() {
+	if interval <= 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				logger.Infof("%s", stats.progress())
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		wg.Wait()
+	}
+}
@@ -0,0 +1,49 @@
+package crawl
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+)
+
+// This logger passes informational messages on to a channel (dropping
+// any that nobody is waiting for)
+type progressLogger struct {
+	CrawlLogger
+	messages chan string
+}
+
+func (p progressLogger) Infof(format string, args ...interface{}) {
+	select {
+	case p.messages <- format:
+	default:
+	}
+}
+
+func TestHeartbeat(t *testing.T) {
+	Convey("Testing progress reporting", t, func(c C) {
+		stats := &CrawlStats{ReposListed: 5, ReposExamined: 2, VersionsRecorded: 7}
+		Equals(c, stats.progress(), "Processed 2/5 repos, 7 versions recorded")
+		IsTrue(c, strings.HasPrefix(stats.String(), "repos_listed=5 repos_examined=2 "))
+
+		logger := progressLogger{messages: make(chan string)}
+
+		// Nothing is reported without an interval
+		startHeartbeat(context.Background(), 0, stats, logger)()
+
+		stop := startHeartbeat(context.Background(), time.Millisecond, stats, logger)
+		<-logger.messages
+		stop()
+
+		// Reporting stops when the context is cancelled
+		ctx, cancel := context.WithCancel(context.Background())
+		stop = startHeartbeat(ctx, time.Millisecond, stats, logger)
+		<-logger.messages
+		cancel()
+		stop()
+	})
+}
@@ -0,0 +1,79 @@
+package crawl
+
+import (
+	"bufio"
+	"path"
+	"strings"
+)
+
+// A submodule is another repository included in a repository (at the
+// given path) as a git submodule.
+type submodule struct {
+	Name string
+	Path string
+	URL  string
+}
+
+// This function parses the contents of a .gitmodules file.  Only the path
+// and URL of each submodule are kept.  Submodules without a path are
+// skipped.
+func parseGitModules(raw string) []submodule {
+	ret := []submodule{}
+	var cur *submodule
+	add := func() {
+		if cur != nil && cur.Path != "" {
+			ret = append(ret, *cur)
+		}
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(raw))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") {
+			add()
+			cur = nil
+			section := strings.Trim(line, "[]")
+			if strings.HasPrefix(section, "submodule") {
+				name := strings.TrimSpace(strings.TrimPrefix(section, "submodule"))
+				cur = &submodule{Name: strings.Trim(name, `"`)}
+			}
+			continue
+		}
+		if cur == nil {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		value := strings.Trim(strings.TrimSpace(parts[1]), `"`)
+		switch strings.TrimSpace(parts[0]) {
+		case "path":
+			cur.Path = path.Clean(value)
+		case "url":
+			cur.URL = value
+		}
+	}
+	add()
+	return ret
+}
+
+// This function returns the submodules of a repository (if it has any)
+// keyed by their path.  The libraries in submodules are bundled
+// dependencies, not libraries of the repository itself (see
+// findLibraries).
+func readSubmodules(src contents, repostr string, logger CrawlLogger) map[string]submodule {
+	ret := map[string]submodule{}
+	raw, err := src.ReadFile(".gitmodules")
+	if err != nil {
+		return ret
+	}
+	for _, sub := range parseGitModules(string(raw)) {
+		logger.Debugf("  %s in %s is a submodule (%s)", sub.Path, repostr, sub.URL)
+		ret[sub.Path] = sub
+	}
+	return ret
+}
@@ -0,0 +1,124 @@
+package crawl
+
+import (
+	"github.com/impact/impact/parsing"
+)
+
+// The tagFilter type holds the settings, shared by the crawlers that
+// index tags, that determine which tags are indexed and how the versions
+// they represent are recorded.  It is embedded in each of those crawlers
+// (so its setters are methods of the crawlers).
+type tagFilter struct {
+	// Used to map tag names to versions (if nil, DefaultTagMapper is used)
+	tagMapper TagMapper
+	// Used to parse versions (if nil, parsing.SemanticVersions is used)
+	scheme parsing.VersionScheme
+	// Versions that should not be indexed
+	exclusions exclusionSet
+	// Whether to index pre-release versions (e.g., 2.1.0-rc1)
+	prereleases bool
+	// What to do about libraries that declare a different version
+	mismatches MismatchPolicy
+	// Called after each version is recorded (if not nil)
+	hook *versionHook
+}
+
+// This function returns the filter crawlers start out with (i.e., the
+// default exclusions and pre-releases included).
+func defaultTagFilter() tagFilter {
+	return tagFilter{
+		exclusions:  defaultExclusions(),
+		prereleases: true,
+	}
+}
+
+// The tagVerdict type indicates whether a tag is indexed (and, if not,
+// why not).
+type tagVerdict int
+
+const (
+	// The tag is indexed
+	tagAccepted tagVerdict = iota
+	// The tag doesn't represent a version (according to the tag mapper)
+	tagUnmapped
+	// The version is excluded
+	tagExcluded
+	// The version is a pre-release (and those aren't indexed)
+	tagPrerelease
+)
+
+// The accept method determines whether the named tag of the given
+// repository is indexed.  The version the tag represents is returned
+// along with the verdict (unless the tag doesn't represent one).
+func (f tagFilter) accept(owner string, repo string, tag string) (string, tagVerdict) {
+	versionString, ok := mapTag(f.tagMapper, tag)
+	if !ok {
+		return "", tagUnmapped
+	}
+	if f.exclusions.excludes(owner, repo, versionString) {
+		return versionString, tagExcluded
+	}
+	if !f.prereleases && isPrerelease(f.scheme, versionString) {
+		return versionString, tagPrerelease
+	}
+	return versionString, tagAccepted
+}
+
+// The policy method returns the policy used to record the libraries
+// found in a version (given the function, if any, that determines whether
+// duplicates are replaced).
+func (f tagFilter) policy(replace func() bool) recordPolicy {
+	return recordPolicy{scheme: f.scheme, mismatches: f.mismatches, replace: replace, hook: f.hook}
+}
+
+// The LoadExclusions method reads additional exclusions (see
+// readExclusions) from the named file.  These are used in addition to
+// the default exclusions.  Malformed entries are logged to the given
+// logger.
+func (f *tagFilter) LoadExclusions(filename string, logger CrawlLogger) error {
+	ex, err := readExclusions(filename, logger)
+	if err != nil {
+		return err
+	}
+	f.exclusions = append(f.exclusions, ex...)
+	return nil
+}
+
+// The SetIncludePrereleases method specifies whether pre-release versions
+// (e.g., 2.1.0-rc1) are indexed.  They are indexed by default.  This
+// doesn't affect the HEAD of the default branch of a GitHub repository
+// (see GitHubCrawler.SetIncludeHead).
+func (f *tagFilter) SetIncludePrereleases(include bool) {
+	f.prereleases = include
+}
+
+// The SetMismatchPolicy method specifies what to do when a library
+// declares a version that differs from the version of the tag it was
+// found in (see MismatchPolicy).  The default is TrustTag.
+func (f *tagFilter) SetMismatchPolicy(policy MismatchPolicy) {
+	f.mismatches = policy
+}
+
+// The SetTagMapper method specifies the function used to determine the
+// version represented by each tag (and which tags to skip).  A nil mapper
+// means DefaultTagMapper is used.
+func (f *tagFilter) SetTagMapper(mapper TagMapper) {
+	f.tagMapper = mapper
+}
+
+// The SetVersionScheme method specifies how the versions represented by
+// tags are parsed (and therefore which tags represent versions and how
+// they are ordered).  This allows libraries that don't use semantic
+// versions to be indexed.  A nil scheme means parsing.SemanticVersions is
+// used.
+func (f *tagFilter) SetVersionScheme(scheme parsing.VersionScheme) {
+	f.scheme = scheme
+}
+
+// The SetVersionHook method specifies a function to call right after each
+// version is recorded (see VersionHook).  If abort is true, an error
+// returned by the hook aborts the crawl (and is returned by it).
+// Otherwise, errors are only logged.
+func (f *tagFilter) SetVersionHook(hook VersionHook, abort bool) {
+	f.hook = newVersionHook(hook, abort)
+}
@@ -0,0 +1,52 @@
+package crawl
+
+import (
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+)
+
+func TestTagFilter(t *testing.T) {
+	Convey("Testing which tags are indexed", t, func(c C) {
+		f := defaultTagFilter()
+		accept := func(owner string, repo string, tag string) tagVerdict {
+			_, verdict := f.accept(owner, repo, tag)
+			return verdict
+		}
+
+		v, verdict := f.accept("a", "Foo", "v1.0.0")
+		Equals(c, v, "1.0.0")
+		Equals(c, verdict, tagAccepted)
+		Equals(c, accept("a", "Foo", " "), tagUnmapped)
+		Equals(c, accept("modelica-3rdparty", "NCLib", "v0.82"), tagExcluded)
+		Equals(c, accept("a", "Foo", "v2.0.0-rc1"), tagAccepted)
+
+		f.SetIncludePrereleases(false)
+		Equals(c, accept("a", "Foo", "v2.0.0-rc1"), tagPrerelease)
+		Equals(c, accept("a", "Foo", "v2.0.0"), tagAccepted)
+
+		f.SetTagMapper(func(tag string) (string, bool) {
+			if strings.HasPrefix(tag, "release/") {
+				return strings.TrimPrefix(tag, "release/"), true
+			}
+			return "", false
+		})
+		v, verdict = f.accept("a", "Foo", "release/1.1.0")
+		Equals(c, v, "1.1.0")
+		Equals(c, verdict, tagAccepted)
+		Equals(c, accept("a", "Foo", "v1.0.0"), tagUnmapped)
+
+		// The filter is shared by all the crawlers that index tags
+		gl, err := MakeGitLabCrawler("https://gitlab.com", "grp", "", "")
+		NoError(c, err)
+		gl.SetIncludePrereleases(false)
+		_, verdict = gl.accept("a", "Foo", "v2.0.0-rc1")
+		Equals(c, verdict, tagPrerelease)
+		gh, err := MakeGitHubCrawler("a", nil, "")
+		NoError(c, err)
+		gh.SetMismatchPolicy(SkipMismatches)
+		Equals(c, gh.policy(nil).mismatches, SkipMismatches)
+	})
+}
@@ -0,0 +1,164 @@
+package crawl
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/github"
+
+	"github.com/impact/impact/recorder"
+)
+
+// The default maximum time to spend processing a single repository (see
+// SetRepoTimeout)
+var defaultRepoTimeout = 5 * time.Minute
+
+// The pendingRecords type holds everything found in a repository until
+// the repository has been completely processed so that nothing is
+// recorded for a repository that is abandoned part way through.  It can
+// safely be used from multiple goroutines.  A nil value doesn't hold
+// anything back (i.e., everything is recorded immediately).
+type pendingRecords struct {
+	mutex   sync.Mutex
+	records []func()
+}
+
+// The add method holds back the given function (which records something)
+// until flush is called.
+func (p *pendingRecords) add(record func()) {
+	if p == nil {
+		record()
+		return
+	}
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	p.records = append(p.records, record)
+}
+
+// The flush method records everything held back so far (in the order it
+// was found).
+func (p *pendingRecords) flush() {
+	if p == nil {
+		return
+	}
+	p.mutex.Lock()
+	records := p.records
+	p.records = nil
+	p.mutex.Unlock()
+
+	for _, record := range records {
+		record()
+	}
+}
+
+// The repoTimer type cancels a context once a repository has been
+// processed for longer than the repository timeout.  Time spent waiting
+// for rate limits to reset doesn't count since the repository has no
+// influence on that (see pause).  A nil value never cancels anything.
+type repoTimer struct {
+	mutex     sync.Mutex
+	timer     *time.Timer
+	remaining time.Duration
+	started   time.Time
+	paused    int
+	expired   bool
+}
+
+// This function returns a context that is cancelled once the given
+// timeout is exceeded (not counting pauses) along with the timer keeping
+// track of it.  The context is also cancelled when the parent context is.
+func withRepoTimeout(parent context.Context, timeout time.Duration) (context.Context,
+	context.CancelFunc, *repoTimer) {
+	ctx, cancel := context.WithCancel(parent)
+	t := &repoTimer{remaining: timeout, started: time.Now()}
+	t.timer = time.AfterFunc(timeout, func() {
+		t.mutex.Lock()
+		t.expired = true
+		t.mutex.Unlock()
+		cancel()
+	})
+	return ctx, func() {
+		t.timer.Stop()
+		cancel()
+	}, t
+}
+
+// The pause method stops the timer (e.g., while waiting for a rate limit
+// to reset) until resume is called.  Pauses may overlap (e.g., when
+// several tags are processed at once), the timer only runs again once
+// all of them are over.
+func (t *repoTimer) pause() {
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.paused++
+	if t.paused == 1 && t.timer.Stop() {
+		t.remaining -= time.Since(t.started)
+	}
+}
+
+// The resume method ends a pause (see pause).
+func (t *repoTimer) resume() {
+	if t == nil {
+		return
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	t.paused--
+	if t.paused == 0 && !t.expired {
+		t.started = time.Now()
+		t.timer.Reset(t.remaining)
+	}
+}
+
+// The timedOut method returns true if the timeout was exceeded.
+func (t *repoTimer) timedOut() bool {
+	if t == nil {
+		return false
+	}
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	return t.expired
+}
+
+// This function processes a single repository (see processRepo), but
+// gives up once the repository timeout (if any) is exceeded.  In that
+// case, nothing is recorded for the repository and the crawl moves on
+// to the next one.  Waiting for rate limits to reset doesn't count
+// towards the timeout.
+func (c GitHubCrawler) processRepoWithin(gc *GitHubClient, r recorder.Recorder,
+	minrepo github.Repository, logger CrawlLogger) error {
+	if c.repoTimeout <= 0 {
+		return c.processRepo(gc, r, minrepo, logger)
+	}
+
+	ctx, cancel, timer := withRepoTimeout(gc.ctx, c.repoTimeout)
+	defer cancel()
+
+	rc := c
+	rc.pending = &pendingRecords{}
+	rgc := gc.withContext(ctx)
+	rgc.timer = timer
+	err := rc.processRepo(rgc, r, minrepo, logger)
+
+	// Only the repository timing out is handled here, the crawl itself
+	// being cancelled is reported as usual
+	if gc.ctx.Err() == nil && timer.timedOut() {
+		rname := stringOf(minrepo.Name)
+		logger.Errorf("Timed out after %v processing repository %s/%s, nothing recorded",
+			c.repoTimeout, c.user, rname)
+		c.failures.add(RepoError{User: c.user, Repo: rname, Phase: PhaseTimeout,
+			Err: fmt.Errorf("Timed out after %v", c.repoTimeout)})
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	rc.pending.flush()
+	return nil
+}
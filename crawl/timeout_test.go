@@ -0,0 +1,156 @@
+package crawl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/github"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+
+	"github.com/impact/impact/dirinfo"
+	"github.com/impact/impact/recorder"
+)
+
+func TestRepoTimeout(t *testing.T) {
+	Convey("Testing abandoning repositories that take too long", t, func(c C) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/users/a/repos":
+				fmt.Fprint(w, `[{"name": "Slow"}, {"name": "Fast"}]`)
+			case "/repos/a/Slow", "/repos/a/Fast":
+				name := path.Base(r.URL.Path)
+				fmt.Fprintf(w, `{"name": "%s", "owner": {"login": "a"}, "html_url": "https://github.com/a/%s"}`,
+					name, name)
+			case "/repos/a/Slow/tags":
+				fmt.Fprint(w, `[{"name": "v1.0.0", "commit": {"sha": "abc"}},
+				                {"name": "v1.1.0", "commit": {"sha": "def"}}]`)
+			case "/repos/a/Fast/tags":
+				fmt.Fprint(w, `[{"name": "v1.0.0", "commit": {"sha": "abc"}}]`)
+			case "/repos/a/Slow/git/commits/def":
+				// Never respond (until the request is cancelled)
+				<-r.Context().Done()
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		defer server.Close()
+
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		client := github.NewClient(nil)
+		base, err := url.Parse(server.URL + "/")
+		NoError(c, err)
+		client.BaseURL = base
+		gc := NewGitHubClient(client, 0, logger)
+		gc.SetRetries(0, 0)
+
+		dir, err := ioutil.TempDir("", "impact")
+		NoError(c, err)
+		defer os.RemoveAll(dir)
+
+		cr, err := MakeGitHubCrawler("a", nil, "")
+		NoError(c, err)
+		cr.SetRepoTimeout(100 * time.Millisecond)
+		cr.empty = &emptyRepositories{}
+		cr.failures = &repoErrors{}
+
+		// Use cached information so that no contents are needed
+		cr.cache, err = loadCache(filepath.Join(dir, "cache.json"), logger)
+		NoError(c, err)
+		for _, v := range []struct{ repo, version, sha string }{
+			{"Slow", "1.0.0", "abc"}, {"Slow", "1.1.0", "def"}, {"Fast", "1.0.0", "abc"},
+		} {
+			di := dirinfo.MakeDirectoryInfo()
+			di.Libraries = []*dirinfo.LocalLibrary{{Name: v.repo, Path: v.repo}}
+			cr.cache.setVersion("a/"+v.repo+"/"+v.version, v.sha, "", di)
+		}
+
+		m := recorder.NewMemoryRecorder()
+		NoError(c, cr.crawlUser(gc, m, logger))
+
+		// Even the version of the slow repository that was found before
+		// the timeout isn't recorded
+		IsNil(c, m.Find("Slow"))
+		NotNil(c, m.Find("Fast"))
+		Equals(c, len(m.Find("Fast").Versions), 1)
+
+		failures := cr.failures.list()
+		Equals(c, len(failures), 1)
+		Equals(c, failures[0].Repo, "Slow")
+		Equals(c, failures[0].Phase, PhaseTimeout)
+
+		// Requests made while processing each repository still count
+		IsTrue(c, gc.Calls() > 6)
+	})
+}
+
+func TestRepoTimeoutRateLimit(t *testing.T) {
+	Convey("Testing that rate limit waits don't count towards the repository timeout", t, func(c C) {
+		limited := true
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/users/a/repos":
+				fmt.Fprint(w, `[{"name": "Foo"}]`)
+			case "/repos/a/Foo":
+				fmt.Fprint(w, `{"name": "Foo", "owner": {"login": "a"}, "html_url": "https://github.com/a/Foo"}`)
+			case "/repos/a/Foo/tags":
+				// The rate limit resets well after the repository timeout
+				if limited {
+					limited = false
+					w.Header().Set("X-RateLimit-Limit", "60")
+					w.Header().Set("X-RateLimit-Remaining", "0")
+					w.Header().Set("X-RateLimit-Reset", fmt.Sprintf("%d", time.Now().Add(2*time.Second).Unix()))
+					w.WriteHeader(http.StatusForbidden)
+					fmt.Fprint(w, `{"message": "API rate limit exceeded for 127.0.0.1."}`)
+					return
+				}
+				fmt.Fprint(w, `[{"name": "v1.0.0", "commit": {"sha": "abc"}}]`)
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		defer server.Close()
+
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		client := github.NewClient(nil)
+		base, err := url.Parse(server.URL + "/")
+		NoError(c, err)
+		client.BaseURL = base
+		gc := NewGitHubClient(client, 0, logger)
+		gc.SetRetries(0, 0)
+
+		dir, err := ioutil.TempDir("", "impact")
+		NoError(c, err)
+		defer os.RemoveAll(dir)
+
+		cr, err := MakeGitHubCrawler("a", nil, "")
+		NoError(c, err)
+		cr.SetRepoTimeout(500 * time.Millisecond)
+		cr.empty = &emptyRepositories{}
+		cr.failures = &repoErrors{}
+
+		cr.cache, err = loadCache(filepath.Join(dir, "cache.json"), logger)
+		NoError(c, err)
+		di := dirinfo.MakeDirectoryInfo()
+		di.Libraries = []*dirinfo.LocalLibrary{{Name: "Foo", Path: "Foo"}}
+		cr.cache.setVersion("a/Foo/1.0.0", "abc", "", di)
+
+		m := recorder.NewMemoryRecorder()
+		NoError(c, cr.crawlUser(gc, m, logger))
+
+		IsFalse(c, limited)
+		NotNil(c, m.Find("Foo"))
+		Equals(c, len(m.Find("Foo").Versions), 1)
+		Equals(c, len(cr.failures.list()), 0)
+	})
+}
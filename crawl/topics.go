@@ -0,0 +1,91 @@
+package crawl
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/google/go-github/github"
+)
+
+// The topics API is still a preview, so it has to be asked for explicitly
+const topicsMediaType = "application/vnd.github.mercy-preview+json"
+
+// The topics of a repository (as returned by the GitHub API)
+type repositoryTopics struct {
+	Names []string `json:"names"`
+}
+
+// This function returns the topics of the given repository.  The version
+// of go-github we use doesn't know about topics, so the request is made
+// directly.
+func repoTopics(client *GitHubClient, owner string, rname string) ([]string, error) {
+	u := fmt.Sprintf("repos/%s/%s/topics", owner, rname)
+	topics := repositoryTopics{}
+	err := client.call(func() error {
+		req, err := client.client.NewRequest("GET", u, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Accept", topicsMediaType)
+		_, err = client.client.Do(req, &topics)
+		return err
+	})
+	return topics.Names, err
+}
+
+// This function returns the topics of the named repository.  These come
+// from the GraphQL listing if the repository was listed that way (see
+// SetGraphQL).
+func (c GitHubCrawler) repositoryTopics(client *GitHubClient, rname string) ([]string, error) {
+	if pre, exists := c.prefetched[rname]; exists {
+		return pre.topics, nil
+	}
+	return repoTopics(client, c.user, rname)
+}
+
+// This function returns the first of the given topics found in the list
+// (ignoring case), if any.
+func findTopic(topics []string, list []string) (string, bool) {
+	for _, topic := range topics {
+		for _, entry := range list {
+			if strings.EqualFold(topic, entry) {
+				return topic, true
+			}
+		}
+	}
+	return "", false
+}
+
+// This function determines whether the given repository should be
+// indexed based on its topics (see SetExcludedTopics and
+// SetRequiredTopics).  The topics are only fetched if any topics are
+// excluded or required.  Repositories whose topics can't be fetched are
+// skipped (and recorded as failures).
+func (c GitHubCrawler) topicsAllowed(client *GitHubClient, repo github.Repository,
+	logger CrawlLogger) bool {
+	if len(c.excludedTopics) == 0 && len(c.requiredTopics) == 0 {
+		return true
+	}
+
+	rname := stringOf(repo.Name)
+	topics, err := c.repositoryTopics(client, rname)
+	if err != nil {
+		logger.Warnf("Unable to fetch topics for repo %s/%s: %v", c.user, rname, err)
+		c.fail(client, rname, PhaseTopics, err)
+		return false
+	}
+
+	if topic, found := findTopic(topics, c.excludedTopics); found {
+		logger.Infof("Skipping: %s (%s), has excluded topic '%s'",
+			rname, stringOf(repo.HTMLURL), topic)
+		return false
+	}
+	if len(c.requiredTopics) > 0 {
+		if _, found := findTopic(topics, c.requiredTopics); !found {
+			logger.Infof("Skipping: %s (%s), has none of the topics %s",
+				rname, stringOf(repo.HTMLURL), strings.Join(c.requiredTopics, ", "))
+			return false
+		}
+	}
+	return true
+}
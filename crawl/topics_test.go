@@ -0,0 +1,74 @@
+package crawl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/github"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+)
+
+func TestTopics(t *testing.T) {
+	Convey("Testing skipping repositories based on their topics", t, func(c C) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Header.Get("Accept") != topicsMediaType {
+				http.Error(w, "preview required", http.StatusUnsupportedMediaType)
+				return
+			}
+			switch r.URL.Path {
+			case "/repos/a/Foo/topics":
+				fmt.Fprint(w, `{"names": ["modelica", "Deprecated"]}`)
+			case "/repos/a/Bar/topics":
+				fmt.Fprint(w, `{"names": ["modelica"]}`)
+			case "/repos/a/Baz/topics":
+				fmt.Fprint(w, `{"names": []}`)
+			default:
+				http.NotFound(w, r)
+			}
+		}))
+		defer server.Close()
+
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		client := github.NewClient(nil)
+		base, err := url.Parse(server.URL + "/")
+		NoError(c, err)
+		client.BaseURL = base
+		gc := NewGitHubClient(client, 0, logger)
+		gc.SetRetries(0, 0)
+
+		repo := func(name string) github.Repository {
+			return github.Repository{Name: github.String(name)}
+		}
+
+		cr, err := MakeGitHubCrawler("a", nil, "")
+		NoError(c, err)
+		cr.failures = &repoErrors{}
+
+		// Without any topics, nothing needs to be fetched
+		IsTrue(c, cr.topicsAllowed(gc, repo("Missing"), logger))
+		Equals(c, gc.Calls(), int64(0))
+
+		cr.SetExcludedTopics("deprecated", "example")
+		IsFalse(c, cr.topicsAllowed(gc, repo("Foo"), logger))
+		IsTrue(c, cr.topicsAllowed(gc, repo("Bar"), logger))
+		IsTrue(c, cr.topicsAllowed(gc, repo("Baz"), logger))
+
+		cr.SetRequiredTopics("modelica")
+		IsFalse(c, cr.topicsAllowed(gc, repo("Foo"), logger))
+		IsTrue(c, cr.topicsAllowed(gc, repo("Bar"), logger))
+		IsFalse(c, cr.topicsAllowed(gc, repo("Baz"), logger))
+
+		// Repositories whose topics can't be fetched are skipped
+		IsFalse(c, cr.topicsAllowed(gc, repo("Missing"), logger))
+		failures := cr.failures.list()
+		Equals(c, len(failures), 1)
+		Equals(c, failures[0].Phase, PhaseTopics)
+	})
+}
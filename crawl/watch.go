@@ -0,0 +1,271 @@
+package crawl
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/blang/semver"
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/impact/impact/recorder"
+)
+
+// The SetDebounce method specifies how long the directory tree has to
+// stay unchanged before changes are indexed while watching it (see
+// Watch).  This keeps a library from being indexed over and over while
+// its files are still being written (e.g., when saving several files or
+// checking out another branch).
+func (c *FileSystemCrawler) SetDebounce(debounce time.Duration) {
+	c.debounce = debounce
+}
+
+// The Watch method indexes every library under the root directory (just
+// like Crawl) and then keeps watching the directory tree until the given
+// context is done.  Whenever the files of a library change, only that
+// library is indexed again.  Whatever was recorded for a library that has
+// been deleted is removed (provided r supports this, see
+// recorder.VersionRemover).  This keeps a recorder that is being served
+// (e.g., by a local development server) up to date.  The recorder is only
+// used from a single goroutine, so it must be synchronized (see
+// recorder.Synchronized) if it is also read elsewhere.
+//
+// The operating system reports changes to the directory tree (hidden
+// directories, e.g. .git, aside) and they are only indexed once it has
+// stayed unchanged for a while (see SetDebounce).
+func (c FileSystemCrawler) Watch(ctx context.Context, r recorder.Recorder, verbosity Verbosity,
+	stdlogger *log.Logger) error {
+	logger := StandardLogger(stdlogger, verbosity)
+	info, err := os.Stat(c.root)
+	if err != nil {
+		return fmt.Errorf("Unable to read directory %s: %v", c.root, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s is not a directory", c.root)
+	}
+
+	notifier, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("Unable to watch directory %s: %v", c.root, err)
+	}
+	defer notifier.Close()
+
+	// The directories are watched before anything is indexed so that no
+	// changes are missed
+	watchDirs(notifier, c.root, logger)
+	w := c.watcher(r, logger)
+
+	settled := time.NewTimer(c.debounce)
+	settled.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-notifier.Events:
+			if !ok {
+				return nil
+			}
+			// New directories need to be watched too (and ones that are
+			// renamed are watched under their new name)
+			if event.Op&fsnotify.Create != 0 {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					watchDirs(notifier, event.Name, logger)
+				}
+			}
+			if event.Op&(fsnotify.Remove|fsnotify.Rename) != 0 {
+				notifier.Remove(event.Name)
+			}
+			w.changed(event.Name, time.Now())
+			settled.Reset(c.debounce)
+		case err, ok := <-notifier.Errors:
+			if !ok {
+				return nil
+			}
+			logger.Warnf("Watching %s: %v", c.root, err)
+		case now := <-settled.C:
+			w.update(now)
+		}
+	}
+}
+
+// This function watches the given directory and all of its
+// subdirectories (other than hidden ones, see libraryDirs).
+func watchDirs(notifier *fsnotify.Watcher, dir string, logger CrawlLogger) {
+	filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil || !info.IsDir() {
+			return nil
+		}
+		if p != dir && strings.HasPrefix(info.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if err := notifier.Add(p); err != nil {
+			logger.Warnf("Unable to watch %s: %v", p, err)
+		}
+		return nil
+	})
+}
+
+// A recordedVersion identifies a version that was recorded for a library
+type recordedVersion struct {
+	name    string
+	owner   string
+	version semver.Version
+}
+
+// The fsWatcher type keeps track of the libraries found under the root
+// directory of a FileSystemCrawler (see Watch).
+type fsWatcher struct {
+	crawler FileSystemCrawler
+	r       recorder.Recorder
+	logger  CrawlLogger
+	// The library directories found the last time the tree was indexed
+	libraries map[string]bool
+	// What was recorded for each library directory
+	recorded map[string][]recordedVersion
+	// Paths that changed since then (and when the last change was seen)
+	changes    map[string]bool
+	lastChange time.Time
+}
+
+// This function indexes every library under the root directory and
+// returns a watcher to keep them up to date.
+func (c FileSystemCrawler) watcher(r recorder.Recorder, logger CrawlLogger) *fsWatcher {
+	w := &fsWatcher{
+		crawler:   c,
+		r:         r,
+		logger:    logger,
+		libraries: map[string]bool{},
+		recorded:  map[string][]recordedVersion{},
+		changes:   map[string]bool{},
+	}
+	for _, dir := range c.libraryDirs(logger) {
+		w.libraries[dir] = true
+		w.index(dir)
+	}
+	return w
+}
+
+// The changed method notes that the given path was added, changed or
+// deleted (at the given time).
+func (w *fsWatcher) changed(p string, now time.Time) {
+	w.changes[p] = true
+	w.lastChange = now
+}
+
+// The update method indexes the libraries affected by the changes seen
+// so far, once nothing has changed for the debounce period (as of the
+// given time).  These are the libraries containing a path that changed
+// along with any library directories that were added or deleted.
+func (w *fsWatcher) update(now time.Time) {
+	if len(w.changes) == 0 || now.Sub(w.lastChange) < w.crawler.debounce {
+		return
+	}
+
+	current := map[string]bool{}
+	affected := []string{}
+	for _, dir := range w.crawler.libraryDirs(w.logger) {
+		current[dir] = true
+		if !w.libraries[dir] || w.changedWithin(dir) {
+			affected = append(affected, dir)
+		}
+	}
+	for dir := range w.libraries {
+		if !current[dir] {
+			affected = append(affected, dir)
+		}
+	}
+	w.libraries = current
+	w.changes = map[string]bool{}
+
+	sort.Strings(affected)
+	for _, dir := range affected {
+		w.index(dir)
+	}
+}
+
+// The changedWithin method returns true if any of the paths that changed
+// is the given directory or inside it.
+func (w *fsWatcher) changedWithin(dir string) bool {
+	for p := range w.changes {
+		if p == dir || strings.HasPrefix(p, dir+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// The index method records the library in the given directory again.
+// Whatever was previously recorded for it is removed first so that
+// nothing is left behind if, e.g., it was renamed.  If the directory no
+// longer contains a library, it is only removed.
+func (w *fsWatcher) index(dir string) {
+	rel, err := filepath.Rel(w.crawler.root, dir)
+	if err != nil {
+		rel = dir
+	}
+
+	old := w.recorded[dir]
+	delete(w.recorded, dir)
+	for _, rv := range old {
+		if !recorder.Remove(w.r, rv.name, rv.owner, rv.version) {
+			w.logger.Warnf("Unable to remove version %v of %s (found in %s), the recorder doesn't support it",
+				rv.version, rv.name, rel)
+			break
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "package.mo")); err != nil {
+		if len(old) > 0 {
+			w.logger.Infof("Removed library in %s", rel)
+		}
+		return
+	}
+
+	t := &trackingRecorder{r: w.r}
+	w.crawler.processLibrary(t, dir, w.logger)
+	if len(t.recorded) > 0 {
+		w.recorded[dir] = t.recorded
+	}
+	if len(old) > 0 {
+		w.logger.Infof("Indexed library in %s again", rel)
+	}
+}
+
+// The trackingRecorder passes everything on to another Recorder while
+// keeping track of which versions were recorded (see fsWatcher).
+type trackingRecorder struct {
+	r        recorder.Recorder
+	recorded []recordedVersion
+}
+
+func (t *trackingRecorder) GetLibrary(name string, uri string, owner_uri string) recorder.LibraryRecorder {
+	return &trackingLibrary{
+		LibraryRecorder: t.r.GetLibrary(name, uri, owner_uri),
+		t:               t,
+		name:            name,
+		owner:           owner_uri,
+	}
+}
+
+func (t *trackingRecorder) Finish(uri string) {
+	recorder.Finish(t.r, uri)
+}
+
+type trackingLibrary struct {
+	recorder.LibraryRecorder
+	t     *trackingRecorder
+	name  string
+	owner string
+}
+
+func (l *trackingLibrary) AddVersion(v semver.Version) recorder.VersionRecorder {
+	l.t.recorded = append(l.t.recorded, recordedVersion{name: l.name, owner: l.owner, version: v})
+	return l.LibraryRecorder.AddVersion(v)
+}
+
+var _ recorder.Finisher = (*trackingRecorder)(nil)
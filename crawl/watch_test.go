@@ -0,0 +1,149 @@
+package crawl
+
+import (
+	"context"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/blang/semver"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+
+	"github.com/impact/impact/recorder"
+)
+
+func TestWatch(t *testing.T) {
+	Convey("Testing watching a directory tree", t, func(c C) {
+		root, err := ioutil.TempDir("", "impact")
+		NoError(c, err)
+		defer os.RemoveAll(root)
+
+		writeFile(c, filepath.Join(root, "Foo 1.2", "package.mo"), `within;
+package Foo
+end Foo;`)
+		writeFile(c, filepath.Join(root, "Bar 0.5", "package.mo"), `within;
+package Bar
+end Bar;`)
+
+		logger := StandardLogger(log.New(ioutil.Discard, "", 0), Quiet)
+		cr, err := MakeFileSystemCrawler(root)
+		NoError(c, err)
+		cr.SetDebounce(time.Second)
+
+		// Everything is indexed to begin with
+		m := recorder.NewMemoryRecorder()
+		w := cr.watcher(m, logger)
+		Equals(c, len(m.Libraries), 2)
+		IsTrue(c, m.Find("Foo").Versions["1.2.0"] != nil)
+
+		// Changes are only indexed once things have settled down
+		start := time.Now()
+		writeFile(c, filepath.Join(root, "Foo 1.2", "package.mo"), `within;
+package Foo "A library"
+end Foo;`)
+		w.changed(filepath.Join(root, "Foo 1.2", "package.mo"), start)
+		w.update(start)
+		IsTrue(c, m.Find("Foo").Versions["1.2.0"] != nil)
+		writeFile(c, filepath.Join(root, "Foo 1.2", ".impact"), "1.3.0")
+		w.changed(filepath.Join(root, "Foo 1.2", ".impact"), start.Add(500*time.Millisecond))
+		w.update(start.Add(time.Second))
+		IsTrue(c, m.Find("Foo").Versions["1.3.0"] == nil)
+		w.update(start.Add(1500 * time.Millisecond))
+		IsTrue(c, m.Find("Foo").Versions["1.2.0"] == nil)
+		IsTrue(c, m.Find("Foo").Versions["1.3.0"] != nil)
+		Equals(c, m.Find("Foo").Description, "A library")
+
+		// Only the library that changed is indexed again
+		m.Find("Bar").SetDescription("Untouched")
+		writeFile(c, filepath.Join(root, "Baz 2.0", "package.mo"), `within;
+package Baz
+end Baz;`)
+		w.changed(filepath.Join(root, "Baz 2.0"), start.Add(2*time.Second))
+		w.update(start.Add(3 * time.Second))
+		Equals(c, len(m.Libraries), 3)
+		Equals(c, m.Find("Bar").Description, "Untouched")
+
+		// Deleted libraries are removed
+		NoError(c, os.RemoveAll(filepath.Join(root, "Foo 1.2")))
+		w.changed(filepath.Join(root, "Foo 1.2"), start.Add(4*time.Second))
+		w.update(start.Add(5 * time.Second))
+		Equals(c, len(m.Libraries), 2)
+		IsTrue(c, m.Find("Foo") == nil)
+
+		// Watching stops along with the context
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		NoError(c, cr.Watch(ctx, m, Quiet, log.New(ioutil.Discard, "", 0)))
+	})
+}
+
+// This recorder reports every library that is finished
+type finishedRecorder struct {
+	recorder.Recorder
+	finished chan string
+}
+
+func (f finishedRecorder) Finish(uri string) {
+	recorder.Finish(f.Recorder, uri)
+	f.finished <- uri
+}
+
+func (f finishedRecorder) RemoveVersion(name string, owner_uri string, v semver.Version) {
+	recorder.Remove(f.Recorder, name, owner_uri, v)
+}
+
+func TestWatchNotifications(t *testing.T) {
+	Convey("Testing watching a directory tree for changes", t, func(c C) {
+		root, err := ioutil.TempDir("", "impact")
+		NoError(c, err)
+		defer os.RemoveAll(root)
+
+		writeFile(c, filepath.Join(root, "Foo 1.2", "package.mo"), `within;
+package Foo
+end Foo;`)
+
+		cr, err := MakeFileSystemCrawler(root)
+		NoError(c, err)
+		cr.SetDebounce(50 * time.Millisecond)
+
+		m := recorder.NewMemoryRecorder()
+		r := finishedRecorder{Recorder: m, finished: make(chan string, 10)}
+		ctx, cancel := context.WithCancel(context.Background())
+		done := make(chan error)
+		go func() {
+			done <- cr.Watch(ctx, r, Quiet, log.New(ioutil.Discard, "", 0))
+		}()
+		finished := func() string {
+			select {
+			case uri := <-r.finished:
+				return uri
+			case <-time.After(5 * time.Second):
+				return ""
+			}
+		}
+		foo := "file://" + filepath.ToSlash(filepath.Join(cr.root, "Foo 1.2"))
+		Equals(c, finished(), foo)
+
+		// Libraries added to new directories are found
+		writeFile(c, filepath.Join(root, "libs", "Bar 0.5", "package.mo"), `within;
+package Bar
+end Bar;`)
+		Equals(c, finished(), "file://"+filepath.ToSlash(filepath.Join(cr.root, "libs", "Bar 0.5")))
+
+		// So are changes to the version marker of a library
+		writeFile(c, filepath.Join(root, "Foo 1.2", ".impact"), "1.3.0")
+		Equals(c, finished(), foo)
+
+		cancel()
+		NoError(c, <-done)
+		Equals(c, len(m.Libraries), 2)
+		IsTrue(c, m.Find("Foo").Versions["1.2.0"] == nil)
+		IsTrue(c, m.Find("Foo").Versions["1.3.0"] != nil)
+		IsTrue(c, m.Find("Bar") != nil)
+	})
+}
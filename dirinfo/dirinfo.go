@@ -17,6 +17,7 @@ package dirinfo
 
 import (
 	"encoding/json"
+	"fmt"
 
 	"github.com/blang/semver"
 )
@@ -26,6 +27,9 @@ type LocalLibrary struct {
 	Path         string       `json:"path"`         // Path to library (relative to impact.json)
 	IsFile       bool         `json:"isFile"`       // If the library is stored as a single file
 	IssuesURL    string       `json:"issues_url"`   // URL to issue tracker
+	Version      string       `json:"version"`      // Version declared by the library (if any)
+	Description  string       `json:"description"`  // Description of the library (if any)
+	Modelica     string       `json:"modelica"`     // Version of the Modelica Standard Library used (if any)
 	Dependencies []Dependency `json:"dependencies"` // Dependencies of this library
 }
 
@@ -36,10 +40,44 @@ type Dependency struct {
 }
 
 type DirectoryInfo struct {
-	OwnerURI  string            `json:"owner_uri"` // Owner of this content
-	Email     string            `json:"email"`     // Email address for contact
-	Libraries []*LocalLibrary   `json:"libraries"` // Libraries defined here
-	Alias     map[string]string `json:"alias"`     // key: library name, value: URI of library
+	OwnerURI    string            `json:"owner_uri"`   // Owner of this content
+	Email       string            `json:"email"`       // Email address for contact
+	Maintainers []Maintainer      `json:"maintainers"` // Everyone responsible for the libraries
+	License     string            `json:"license"`     // SPDX identifier of the license
+	Libraries   []*LocalLibrary   `json:"libraries"`   // Libraries defined here
+	Alias       map[string]string `json:"alias"`       // key: library name, value: URI of library
+	// Libraries that couldn't be parsed (and are therefore not listed in
+	// Libraries)
+	Errors []LibraryError `json:"errors,omitempty"`
+	// Whether this information was signed by a trusted publisher.  This
+	// is determined while crawling and is never read from impact.json.
+	Verified bool `json:"-"`
+}
+
+// A Maintainer is someone responsible for the libraries
+type Maintainer struct {
+	Name  string `json:"name"`  // Name of maintainer
+	Email string `json:"email"` // Email address of maintainer
+}
+
+// A LibraryError describes a library that couldn't be parsed
+type LibraryError struct {
+	Name    string `json:"name"`    // Name of library (if known)
+	Path    string `json:"path"`    // Path to library
+	Message string `json:"message"` // What went wrong
+}
+
+// The Library method returns the best description of the library (its
+// name or, if that isn't known, its path).
+func (e LibraryError) Library() string {
+	if e.Name != "" {
+		return e.Name
+	}
+	return e.Path
+}
+
+func (e LibraryError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Library(), e.Message)
 }
 
 func (di DirectoryInfo) JSON() string {
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/impact/impact/index"
+)
+
+type AnnotateCommand struct {
+	Positional struct {
+		Index   string `description:"Index file to update"`
+		Results string `description:"File containing build results (in JSON)"`
+	} `positional-args:"true" required:"true"`
+	Output string `short:"o" long:"output" description:"Output file for annotated index (defaults to the input file)"`
+}
+
+func (x AnnotateCommand) Execute(args []string) error {
+	src := x.Positional.Index
+
+	ind, err := index.ReadIndex(src)
+	if err != nil {
+		return err
+	}
+
+	data, err := ioutil.ReadFile(x.Positional.Results)
+	if err != nil {
+		return fmt.Errorf("Error reading build results %s: %v", x.Positional.Results, err)
+	}
+	results, err := index.ReadBuildResults(data)
+	if err != nil {
+		return err
+	}
+
+	// Results that don't apply are reported, but the rest are still kept
+	aerr := ind.Annotate(results)
+	if aerr != nil {
+		fmt.Printf("Warning: %v\n", aerr)
+	}
+
+	output := x.Output
+	if output == "" {
+		if strings.Contains(src, "://") {
+			return fmt.Errorf("An output file must be specified to annotate a remote index")
+		}
+		output = src
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf("Error creating %s: %v", output, err)
+	}
+	defer f.Close()
+	return ind.Dump(f)
+}
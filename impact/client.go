@@ -26,6 +26,26 @@ func main() {
 		"Build library index",
 		&IndexCommand{})
 
+	parser.AddCommand("validate",
+		"Check an index for consistency",
+		"Check an index for consistency",
+		&ValidateCommand{})
+
+	parser.AddCommand("deprecate",
+		"Mark a version of a library in an index as deprecated",
+		"Mark a version of a library in an index as deprecated",
+		&DeprecateCommand{})
+
+	parser.AddCommand("annotate",
+		"Record build results for versions in an index",
+		"Record build results for versions in an index",
+		&AnnotateCommand{})
+
+	parser.AddCommand("diff",
+		"Compare two indices",
+		"Compare two indices",
+		&DiffCommand{})
+
 	parser.AddCommand("version",
 		"Version information about impact itself",
 		"Version information about impact itself",
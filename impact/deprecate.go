@@ -0,0 +1,52 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/impact/impact/index"
+	"github.com/impact/impact/parsing"
+)
+
+type DeprecateCommand struct {
+	Positional struct {
+		Index   string `description:"Index file to update"`
+		Library string `description:"Name of the library"`
+		Version string `description:"Version to deprecate"`
+	} `positional-args:"true" required:"true"`
+	Reason string `short:"r" long:"reason" description:"Why the version is deprecated"`
+}
+
+func (x DeprecateCommand) Execute(args []string) error {
+	src := x.Positional.Index
+
+	v, err := parsing.NormalizeVersion(x.Positional.Version)
+	if err != nil {
+		return fmt.Errorf("Invalid version %s: %v", x.Positional.Version, err)
+	}
+
+	data, err := ioutil.ReadFile(src)
+	if err != nil {
+		return fmt.Errorf("Error reading index %s: %v", src, err)
+	}
+
+	ind := index.NewIndex()
+	err = json.Unmarshal(data, ind)
+	if err != nil {
+		return fmt.Errorf("Error parsing index %s: %v", src, err)
+	}
+
+	err = ind.Deprecate(x.Positional.Library, v, x.Reason)
+	if err != nil {
+		return fmt.Errorf("Error deprecating %s %s: %v", x.Positional.Library, v.String(), err)
+	}
+
+	f, err := os.Create(src)
+	if err != nil {
+		return fmt.Errorf("Error creating %s: %v", src, err)
+	}
+	defer f.Close()
+	return ind.Dump(f)
+}
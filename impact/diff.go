@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/impact/impact/index"
+)
+
+type DiffCommand struct {
+	Positional struct {
+		Old string `description:"Previous index file (or URL)"`
+		New string `description:"Current index file (or URL)"`
+	} `positional-args:"true" required:"true"`
+}
+
+func (x DiffCommand) Execute(args []string) error {
+	old, err := index.ReadIndex(x.Positional.Old)
+	if err != nil {
+		return err
+	}
+	current, err := index.ReadIndex(x.Positional.New)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print(index.DiffIndexes(*old, *current).String())
+	return nil
+}
@@ -23,14 +23,14 @@ func TestGitHub(t *testing.T) {
 		logger := log.New(os.Stdout, "impact: ", 0)
 		ind := index.NewIndex()
 
-		cr, err := crawl.MakeGitHubCrawler("modelica-3rdparty", "Buildings", "")
+		cr, err := crawl.MakeGitHubCrawler("modelica-3rdparty", []string{"Buildings"}, "")
 		NoError(c, err)
-		err = cr.Crawl(ind, false, logger)
+		err = cr.Crawl(ind, crawl.Quiet, logger)
 		NoError(c, err)
 
-		cr, err = crawl.MakeGitHubCrawler("modelica", "", "")
+		cr, err = crawl.MakeGitHubCrawler("modelica", nil, "")
 		NoError(c, err)
-		err = cr.Crawl(ind, false, logger)
+		err = cr.Crawl(ind, crawl.Quiet, logger)
 		NoError(c, err)
 
 		str, err := ind.JSON()
@@ -2,17 +2,37 @@ package main
 
 import (
 	"fmt"
-	"io/ioutil"
 	"log"
 	"os"
+	"time"
 
 	"github.com/impact/impact/config"
+	"github.com/impact/impact/crawl"
 	"github.com/impact/impact/index"
+	"github.com/impact/impact/parsing"
+	"github.com/impact/impact/recorder"
 )
 
 type IndexCommand struct {
-	Output  string `short:"o" long:"output" description:"Output file"`
-	Verbose bool   `short:"v" long:"verbose" description:"Turn on verbose output"`
+	Output    string        `short:"o" long:"output" description:"Output file"`
+	TokenFile string        `long:"token-file" description:"File containing the GitHub token"`
+	Stream    bool          `long:"jsonl" description:"Write one library per line (JSON Lines) as it is indexed"`
+	Heartbeat time.Duration `long:"heartbeat" description:"Report progress at this interval (e.g., 30s)"`
+	Timeout   time.Duration `long:"repo-timeout" description:"Abandon repositories that take longer than this (0 means no limit)" default:"5m"`
+	Checksums bool          `long:"checksums" description:"Download the tarball of every version to record its SHA-256"`
+	Sizes     bool          `long:"exact-sizes" description:"Compute the size of every version from its files (rather than using the size of the repository)"`
+	Notes     bool          `long:"release-notes" description:"Record the release notes (or tag message) of every version"`
+	MaxNotes  int           `long:"max-release-notes" description:"Truncate release notes longer than this (in bytes)" default:"4096"`
+	Strict    bool          `long:"strict-versions" description:"Skip tags that aren't canonical semantic versions (e.g., 1.2.03) rather than normalizing them"`
+	MinBytes  int           `long:"min-modelica-bytes" description:"Skip repositories with less Modelica code than this (as detected by GitHub)"`
+	Primary   bool          `long:"primary-language" description:"Skip repositories whose primary language isn't Modelica"`
+	GraphQL   bool          `long:"graphql" description:"List repositories (along with their details and tags) using the GraphQL API, which takes far fewer requests"`
+	Repos     []string      `long:"repo" description:"Only crawl this repository (name or owner/name, may be repeated)"`
+	Renames   bool          `long:"follow-renames" description:"Index renamed repositories under their new name"`
+	KeysFile  string        `long:"publisher-keys" description:"File listing the public keys of publishers whose signed impact.json is trusted"`
+	Merge     string        `long:"merge" description:"Existing index (file or URL) to update with the libraries crawled (the rest are left intact)"`
+	Conflicts string        `long:"conflicts" description:"Whether to keep or overwrite merged versions whose SHA has changed (keep or overwrite)" default:"keep"`
+	Verbose   []bool        `short:"v" long:"verbose" description:"Turn on verbose output (repeat for more detail: -v for repositories, -vv for tags, -vvv for files)"`
 }
 
 func (x IndexCommand) Execute(args []string) error {
@@ -22,6 +42,9 @@ func (x IndexCommand) Execute(args []string) error {
 
 	if x.Output == "" {
 		x.Output = "impact_index.json"
+		if x.Stream {
+			x.Output = "impact_index.jsonl"
+		}
 	}
 
 	settings, err := config.ReadSettings()
@@ -29,19 +52,111 @@ func (x IndexCommand) Execute(args []string) error {
 		return fmt.Errorf("Error reading settings: %v", err)
 	}
 
-	for _, cr := range settings.Sources {
-		err = cr.Crawl(ind, x.Verbose, logger)
+	if x.Stream {
+		if x.Merge != "" {
+			return fmt.Errorf("An existing index can't be merged with when writing JSON Lines")
+		}
+		return x.stream(settings, logger)
+	}
+
+	// Read the index to merge with first (so problems with it are found
+	// before crawling)
+	var base *index.Index
+	policy, err := index.ParseConflictPolicy(x.Conflicts)
+	if err != nil {
+		return err
+	}
+	if x.Merge != "" {
+		base, err = index.ReadIndex(x.Merge)
 		if err != nil {
-			return fmt.Errorf("Error indexing modelica-3rdparty: %v", err)
+			return err
 		}
 	}
 
-	str, _ := ind.JSON()
+	err = x.crawl(settings, ind, logger)
+	if err != nil {
+		return err
+	}
+
+	if base != nil {
+		for _, conflict := range base.Update(*ind, policy) {
+			logger.Printf("Warning: %v", conflict)
+		}
+		ind = base
+	}
 
 	if x.Output == "-" {
-		fmt.Printf("%s\n", str)
-	} else {
-		ioutil.WriteFile(x.Output, []byte(str), os.ModePerm)
+		return ind.Dump(os.Stdout)
+	}
+
+	f, err := os.Create(x.Output)
+	if err != nil {
+		return fmt.Errorf("Error creating %s: %v", x.Output, err)
+	}
+	defer f.Close()
+	return ind.Dump(f)
+}
+
+func (x IndexCommand) crawl(settings config.Settings, r recorder.Recorder, logger *log.Logger) error {
+	var keys crawl.PublisherKeys
+	if x.KeysFile != "" {
+		var err error
+		keys, err = crawl.ReadPublisherKeys(x.KeysFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, cr := range settings.Sources {
+		if gh, ok := cr.(crawl.GitHubCrawler); ok {
+			if x.TokenFile != "" {
+				gh.SetTokenFile(x.TokenFile)
+			}
+			gh.SetHeartbeat(x.Heartbeat)
+			gh.SetRepoTimeout(x.Timeout)
+			gh.SetArchiveChecksums(x.Checksums)
+			gh.SetExactSizes(x.Sizes)
+			gh.SetReleaseNotes(x.Notes, x.MaxNotes)
+			gh.SetGeneratorVersion(version)
+			gh.SetPublisherKeys(keys)
+			gh.SetFollowRenames(x.Renames)
+			gh.SetGraphQL(x.GraphQL)
+			if x.Strict {
+				gh.SetVersionScheme(parsing.StrictSemanticVersions)
+			}
+			gh.SetMinModelicaBytes(x.MinBytes)
+			gh.SetPrimaryLanguageOnly(x.Primary)
+			if len(x.Repos) > 0 {
+				gh.SetRepositories(x.Repos...)
+			}
+			cr = gh
+		}
+		err := cr.Crawl(r, crawl.Verbosity(len(x.Verbose)), logger)
+		if err != nil {
+			return fmt.Errorf("Error indexing modelica-3rdparty: %v", err)
+		}
 	}
 	return nil
 }
+
+// The stream method writes libraries to the output as they are indexed
+// (rather than building the complete index in memory first).
+func (x IndexCommand) stream(settings config.Settings, logger *log.Logger) error {
+	w := os.Stdout
+	if x.Output != "-" {
+		f, err := os.Create(x.Output)
+		if err != nil {
+			return fmt.Errorf("Error creating %s: %v", x.Output, err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	sr := index.NewStreamRecorder(w)
+	err := x.crawl(settings, sr, logger)
+	cerr := sr.Close()
+	if err != nil {
+		return err
+	}
+	return cerr
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"errors"
 	"fmt"
+	"strings"
 
 	"github.com/wsxiaoys/terminal/color"
 
@@ -14,8 +15,13 @@ import (
 
 /* Define a struct listing all command line options for 'install' */
 type InstallCommand struct {
-	Verbose bool `short:"v" long:"verbose" description:"Turn on verbose output"`
-	DryRun  bool `short:"d" long:"dryrun" description:"Resolve dependencies but don't install"`
+	Verbose    bool   `short:"v" long:"verbose" description:"Turn on verbose output"`
+	DryRun     bool   `short:"d" long:"dryrun" description:"Resolve dependencies but don't install"`
+	Deprecated bool   `long:"deprecated" description:"Allow deprecated versions to be installed"`
+	Constraint string `short:"c" long:"constraint" description:"Versions to choose from when installing a single library (e.g., \">=2.0 <3.0\", only its direct dependencies are installed)"`
+	Target     string `short:"t" long:"target" description:"Directory to install into" default:"."`
+	Index      string `short:"i" long:"index" description:"Index file (or URL) to install from (defaults to the indices in the settings)"`
+	Cache      string `long:"cache-dir" description:"Directory to cache remote indices in (they are only downloaded again if they have changed)"`
 }
 
 func (x InstallCommand) Execute(args []string) error {
@@ -31,7 +37,15 @@ func (x InstallCommand) Execute(args []string) error {
 	}
 
 	// Load index
-	ind, err := index.LoadIndex(x.Verbose)
+	var ind *index.Index
+	switch {
+	case x.Index != "" && x.Cache != "" && strings.Contains(x.Index, "://"):
+		ind, err = index.FetchIndex(x.Index, x.Cache)
+	case x.Index != "":
+		ind, err = index.ReadIndex(x.Index)
+	default:
+		ind, err = index.LoadIndex(x.Verbose)
+	}
 	if err != nil {
 		return fmt.Errorf("Error loading indices: %v", err)
 	}
@@ -41,6 +55,21 @@ func (x InstallCommand) Execute(args []string) error {
 	// for example, when there is a fork of a library.
 	ind = ind.Reduce(settings.Choices)
 
+	// A library installed in a version matching a constraint is installed
+	// along with its direct dependencies only
+	if x.Constraint != "" {
+		if len(args) != 1 {
+			return errors.New("A version constraint can only be given for a single library")
+		}
+		return x.installMatching(ind, args[0])
+	}
+
+	// Unless they are explicitly allowed, deprecated versions are never
+	// chosen
+	if !x.Deprecated {
+		ind = ind.WithoutDeprecated()
+	}
+
 	// Build dependency graph from index
 	resolver, err := ind.BuildGraph(x.Verbose)
 	if err != nil {
@@ -70,9 +99,31 @@ func (x InstallCommand) Execute(args []string) error {
 				version, name)
 		}
 		if !x.DryRun {
-			install.Install(string(name), lv, ind, ".", x.Verbose)
+			err = install.Install(string(name), lv, ind, x.Target, x.Verbose)
+			if err != nil {
+				return fmt.Errorf("Error installing %s %v: %v", name, version, err)
+			}
 		}
 	}
 
 	return nil
 }
+
+// This method installs the version of the named library that best
+// matches the version constraint (along with its direct dependencies).
+func (x InstallCommand) installMatching(ind *index.Index, libname string) error {
+	if x.DryRun {
+		details, err := install.SelectVersion(ind, libname, x.Constraint, x.Deprecated)
+		if err != nil {
+			return err
+		}
+		color.Printf("@{g}Selected @{!g}%s %s\n", libname, details.Version.String())
+		return nil
+	}
+
+	installed, err := install.InstallLibrary(ind, libname, x.Constraint, x.Target, x.Deprecated, x.Verbose)
+	for _, lib := range installed {
+		color.Printf("@{g}Installed @{!g}%s %s@{g} in @{!g}%s\n", lib.Name, lib.Version.String(), lib.Path)
+	}
+	return err
+}
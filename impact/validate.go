@@ -0,0 +1,105 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/impact/impact/index"
+	"github.com/impact/impact/resolve"
+)
+
+type ValidateCommand struct {
+	Positional struct {
+		Index string `description:"Index file (or URL) to validate"`
+	} `positional-args:"true" required:"true"`
+	URLs    bool   `short:"u" long:"urls" description:"Check that archive URLs exist"`
+	Schema  bool   `short:"s" long:"schema" description:"Check that the index conforms to the index format"`
+	Cycles  bool   `short:"c" long:"cycles" description:"Check for libraries that (indirectly) depend on each other"`
+	Fix     bool   `short:"f" long:"fix" description:"Drop entries with errors"`
+	Output  string `short:"o" long:"output" description:"Output file for fixed index (defaults to the input file)"`
+	Verbose bool   `short:"v" long:"verbose" description:"Turn on verbose output"`
+}
+
+func (x ValidateCommand) Execute(args []string) error {
+	src := x.Positional.Index
+	remote := strings.Contains(src, "://")
+
+	var data []byte
+	var err error
+	if remote {
+		data, err = index.Fetch(src)
+	} else {
+		data, err = ioutil.ReadFile(src)
+	}
+	if err != nil {
+		return fmt.Errorf("Error reading index %s: %v", src, err)
+	}
+
+	fixed, issues, err := index.Validate(data, x.URLs)
+	if err != nil {
+		return fmt.Errorf("Error validating index %s: %v", src, err)
+	}
+
+	// Schema violations can't be fixed, but they make the index invalid
+	violations := []index.SchemaViolation{}
+	if x.Schema {
+		violations, err = index.CheckSchema(data)
+		if err != nil {
+			return fmt.Errorf("Error checking schema of index %s: %v", src, err)
+		}
+		for _, violation := range violations {
+			fmt.Println("error: " + violation.String())
+		}
+		if x.Verbose || len(violations) > 0 {
+			fmt.Printf("%d schema violations found\n", len(violations))
+		}
+	}
+
+	// Neither can circular dependencies (only the entries without errors
+	// are checked)
+	cycles := [][]string{}
+	if x.Cycles {
+		cycles = resolve.Cycles(fixed)
+		for _, cycle := range cycles {
+			fmt.Println("error: circular dependency: " + strings.Join(cycle, " -> "))
+		}
+		if x.Verbose || len(cycles) > 0 {
+			fmt.Printf("%d circular dependencies found\n", len(cycles))
+		}
+	}
+
+	errors := 0
+	for _, issue := range issues {
+		if issue.Severity == index.SeverityError {
+			errors++
+		}
+		fmt.Println(issue.String())
+	}
+	if x.Verbose || len(issues) > 0 {
+		fmt.Printf("%d issues found (%d errors)\n", len(issues), errors)
+	}
+
+	if !x.Fix {
+		if errors > 0 || len(violations) > 0 || len(cycles) > 0 {
+			return fmt.Errorf("Index %s is not valid", src)
+		}
+		return nil
+	}
+
+	output := x.Output
+	if output == "" {
+		if remote {
+			return fmt.Errorf("An output file must be specified to fix a remote index")
+		}
+		output = src
+	}
+
+	f, err := os.Create(output)
+	if err != nil {
+		return fmt.Errorf("Error creating %s: %v", output, err)
+	}
+	defer f.Close()
+	return fixed.Dump(f)
+}
@@ -0,0 +1,81 @@
+package index
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/blang/semver"
+
+	"github.com/impact/impact/parsing"
+)
+
+// The BuildResult type holds the result of checking whether a version of
+// a library builds (e.g., whether it loads in OpenModelica).  A list of
+// these (in JSON form) is what a CI job produces to annotate an index.
+type BuildResult struct {
+	Library string `json:"library"`
+	Version string `json:"version"`
+	// One of BuildPassing, BuildFailing or BuildUnknown (from the recorder
+	// package)
+	Status  string `json:"status"`
+	Details string `json:"details,omitempty"`
+}
+
+// The ReadBuildResults function parses a list of build results (in JSON
+// form).
+func ReadBuildResults(data []byte) ([]BuildResult, error) {
+	results := []BuildResult{}
+	err := json.Unmarshal(data, &results)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse build results: %v", err)
+	}
+	return results, nil
+}
+
+// The SetBuildStatus method records the build status of the given
+// version of the named library (wherever it is found in this index).
+func (i *Index) SetBuildStatus(name string, version semver.Version, status string,
+	details string) error {
+	found := false
+	for _, lib := range i.Libraries {
+		if lib.Name != name {
+			continue
+		}
+		for _, vd := range lib.Versions {
+			if vd.Version.EQ(version) {
+				vd.SetBuildStatus(status, details)
+				found = true
+			}
+		}
+	}
+	if !found {
+		return MissingVersionError{Name: name, Version: version.String()}
+	}
+	return nil
+}
+
+// The Annotate method applies the given build results to this index.
+// Results that can't be applied (e.g., because the version is no longer
+// in the index) don't prevent the others from being applied, but they
+// are reported in the error returned.
+func (i *Index) Annotate(results []BuildResult) error {
+	problems := []string{}
+	for _, result := range results {
+		v, err := parsing.NormalizeVersion(result.Version)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("Invalid version '%s' of %s: %v",
+				result.Version, result.Library, err))
+			continue
+		}
+		err = i.SetBuildStatus(result.Library, v, result.Status, result.Details)
+		if err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("Unable to apply %d build results: %s", len(problems),
+			strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package index
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/blang/semver"
+
+	"github.com/impact/impact/recorder"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+)
+
+func TestAnnotate(t *testing.T) {
+	Convey("Testing annotating an index with build results", t, func(c C) {
+		ind := buildIndex([]string{"1.0.0", "1.1.0"}, []string{})
+
+		err := ind.SetBuildStatus("Foo", semver.MustParse("2.0.0"), recorder.BuildPassing, "")
+		IsError(c, err)
+
+		results, err := ReadBuildResults([]byte(`[
+  {"library": "Foo", "version": "1.0.0", "status": "passing"},
+  {"library": "Foo", "version": "1.1", "status": "failing", "details": "Unable to load Foo"},
+  {"library": "Foo", "version": "3.0.0", "status": "unknown"},
+  {"library": "Bar", "version": "1.0.0", "status": "passing"}
+]`))
+		NoError(c, err)
+		Equals(c, len(results), 4)
+
+		// The results that could be applied still are
+		err = ind.Annotate(results)
+		IsError(c, err)
+		IsTrue(c, strings.Contains(err.Error(), "Unable to apply 2 build results"))
+
+		foo := ind.Libraries[0]
+		Equals(c, foo.Versions["1.0.0"].BuildStatus, recorder.BuildPassing)
+		Equals(c, foo.Versions["1.0.0"].BuildDetails, "")
+		Equals(c, foo.Versions["1.1.0"].BuildStatus, recorder.BuildFailing)
+		Equals(c, foo.Versions["1.1.0"].BuildDetails, "Unable to load Foo")
+
+		// The status survives a round trip
+		str, err := ind.JSON()
+		NoError(c, err)
+		IsTrue(c, strings.Contains(str, `"build_status": "failing"`))
+		IsTrue(c, strings.Contains(str, `"build_details": "Unable to load Foo"`))
+		read := Index{}
+		NoError(c, json.Unmarshal([]byte(str), &read))
+		Equals(c, read.Libraries[0].Versions["1.1.0"].BuildStatus, recorder.BuildFailing)
+
+		_, err = ReadBuildResults([]byte(`{"library": "Foo"}`))
+		IsError(c, err)
+	})
+}
@@ -0,0 +1,252 @@
+package index
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver"
+)
+
+// The IndexDiff type describes how one index differs from another (e.g.,
+// the index produced by the previous crawl).  Libraries are identified by
+// their name and URI (since the same library may be found in more than
+// one place).  Everything is sorted by library name (then URI) and
+// versions are sorted by semantic version.
+type IndexDiff struct {
+	AddedLibraries   []LibraryVersions
+	RemovedLibraries []LibraryVersions
+	// Libraries found in both indices that have changed
+	ChangedLibraries []LibraryDiff
+}
+
+// The LibraryVersions type lists the versions of a library that were
+// added (or removed).
+type LibraryVersions struct {
+	Name     string
+	URI      string
+	Versions []semver.Version
+}
+
+// The LibraryDiff type describes how a library found in both indices
+// has changed.
+type LibraryDiff struct {
+	Name            string
+	URI             string
+	AddedVersions   []semver.Version
+	RemovedVersions []semver.Version
+	// Versions found in both indices whose dependencies have changed
+	DependencyChanges []DependencyChange
+}
+
+// The DependencyChange type describes how the dependencies of a version
+// have changed.  A change in the version of a dependency shows up as the
+// removal of the old dependency and the addition of the new one.
+type DependencyChange struct {
+	Version semver.Version
+	Added   []Dependency
+	Removed []Dependency
+}
+
+// The Empty method returns true if there are no differences.
+func (d IndexDiff) Empty() bool {
+	return len(d.AddedLibraries) == 0 && len(d.RemovedLibraries) == 0 &&
+		len(d.ChangedLibraries) == 0
+}
+
+// This function returns the (semantic) versions of a library in order.
+func sortedVersions(lib *Library) []semver.Version {
+	ret := []semver.Version{}
+	for _, details := range lib.Versions {
+		ret = append(ret, details.Version)
+	}
+	semver.Sort(ret)
+	return ret
+}
+
+// This function returns the versions of a library keyed by their string
+// representation.
+func versionsByString(lib *Library) map[string]*VersionDetails {
+	ret := map[string]*VersionDetails{}
+	for _, details := range lib.Versions {
+		ret[details.Version.String()] = details
+	}
+	return ret
+}
+
+// This function returns the dependencies found in a but not in b (sorted
+// by name).
+func missingDependencies(a []Dependency, b []Dependency) []Dependency {
+	present := map[Dependency]bool{}
+	for _, dep := range b {
+		present[dep] = true
+	}
+	ret := []Dependency{}
+	for _, dep := range a {
+		if !present[dep] {
+			ret = append(ret, dep)
+		}
+	}
+	sort.Sort(dependencyList(ret))
+	return ret
+}
+
+// This function compares the versions of a library found in both indices.
+func diffLibrary(old *Library, current *Library) LibraryDiff {
+	ret := LibraryDiff{
+		Name:            current.Name,
+		URI:             current.URI,
+		AddedVersions:   []semver.Version{},
+		RemovedVersions: []semver.Version{},
+	}
+
+	oldVersions := versionsByString(old)
+	currentVersions := versionsByString(current)
+
+	for _, v := range sortedVersions(current) {
+		details, exists := oldVersions[v.String()]
+		if !exists {
+			ret.AddedVersions = append(ret.AddedVersions, v)
+			continue
+		}
+		change := DependencyChange{
+			Version: v,
+			Added:   missingDependencies(currentVersions[v.String()].Dependencies, details.Dependencies),
+			Removed: missingDependencies(details.Dependencies, currentVersions[v.String()].Dependencies),
+		}
+		if len(change.Added) > 0 || len(change.Removed) > 0 {
+			ret.DependencyChanges = append(ret.DependencyChanges, change)
+		}
+	}
+	for _, v := range sortedVersions(old) {
+		if _, exists := currentVersions[v.String()]; !exists {
+			ret.RemovedVersions = append(ret.RemovedVersions, v)
+		}
+	}
+	return ret
+}
+
+// This function returns the libraries of an index keyed by name and URI
+// (along with the keys in order).
+func librariesByKey(i Index) (map[string]*Library, []string) {
+	libs := map[string]*Library{}
+	keys := []string{}
+	for _, lib := range i.Libraries {
+		key := lib.Name + " " + lib.URI
+		if _, exists := libs[key]; !exists {
+			keys = append(keys, key)
+		}
+		libs[key] = lib
+	}
+	sort.Strings(keys)
+	return libs, keys
+}
+
+// The DiffIndexes function compares two indices and returns the
+// libraries and versions that were added or removed along with any
+// changes to the dependencies of versions found in both.
+func DiffIndexes(old Index, current Index) IndexDiff {
+	ret := IndexDiff{
+		AddedLibraries:   []LibraryVersions{},
+		RemovedLibraries: []LibraryVersions{},
+		ChangedLibraries: []LibraryDiff{},
+	}
+
+	oldLibs, oldKeys := librariesByKey(old)
+	currentLibs, currentKeys := librariesByKey(current)
+
+	for _, key := range currentKeys {
+		lib := currentLibs[key]
+		prev, exists := oldLibs[key]
+		if !exists {
+			ret.AddedLibraries = append(ret.AddedLibraries, LibraryVersions{
+				Name:     lib.Name,
+				URI:      lib.URI,
+				Versions: sortedVersions(lib),
+			})
+			continue
+		}
+		ld := diffLibrary(prev, lib)
+		if len(ld.AddedVersions) > 0 || len(ld.RemovedVersions) > 0 ||
+			len(ld.DependencyChanges) > 0 {
+			ret.ChangedLibraries = append(ret.ChangedLibraries, ld)
+		}
+	}
+	for _, key := range oldKeys {
+		if _, exists := currentLibs[key]; !exists {
+			lib := oldLibs[key]
+			ret.RemovedLibraries = append(ret.RemovedLibraries, LibraryVersions{
+				Name:     lib.Name,
+				URI:      lib.URI,
+				Versions: sortedVersions(lib),
+			})
+		}
+	}
+	return ret
+}
+
+// This function formats a list of versions (separated by commas).
+func versionList(versions []semver.Version) string {
+	strs := []string{}
+	for _, v := range versions {
+		strs = append(strs, v.String())
+	}
+	return strings.Join(strs, ", ")
+}
+
+// This function formats a list of dependencies (separated by commas).
+func dependencyString(deps []Dependency) string {
+	strs := []string{}
+	for _, dep := range deps {
+		strs = append(strs, dep.Name+" "+dep.Version)
+	}
+	return strings.Join(strs, ", ")
+}
+
+// The String method summarizes the differences in a human readable form.
+func (d IndexDiff) String() string {
+	if d.Empty() {
+		return "No changes\n"
+	}
+
+	buf := bytes.Buffer{}
+	if len(d.AddedLibraries) > 0 {
+		buf.WriteString("Added libraries:\n")
+		for _, lib := range d.AddedLibraries {
+			fmt.Fprintf(&buf, "  %s (%s): %s\n", lib.Name, lib.URI, versionList(lib.Versions))
+		}
+	}
+	if len(d.RemovedLibraries) > 0 {
+		buf.WriteString("Removed libraries:\n")
+		for _, lib := range d.RemovedLibraries {
+			fmt.Fprintf(&buf, "  %s (%s): %s\n", lib.Name, lib.URI, versionList(lib.Versions))
+		}
+	}
+	if len(d.ChangedLibraries) > 0 {
+		buf.WriteString("Changed libraries:\n")
+		for _, lib := range d.ChangedLibraries {
+			fmt.Fprintf(&buf, "  %s (%s)\n", lib.Name, lib.URI)
+			if len(lib.AddedVersions) > 0 {
+				fmt.Fprintf(&buf, "    Added versions: %s\n", versionList(lib.AddedVersions))
+			}
+			if len(lib.RemovedVersions) > 0 {
+				fmt.Fprintf(&buf, "    Removed versions: %s\n", versionList(lib.RemovedVersions))
+			}
+			for _, change := range lib.DependencyChanges {
+				fmt.Fprintf(&buf, "    Dependencies of %s:", change.Version.String())
+				if len(change.Added) > 0 {
+					fmt.Fprintf(&buf, " added %s", dependencyString(change.Added))
+					if len(change.Removed) > 0 {
+						buf.WriteString(";")
+					}
+				}
+				if len(change.Removed) > 0 {
+					fmt.Fprintf(&buf, " removed %s", dependencyString(change.Removed))
+				}
+				buf.WriteString("\n")
+			}
+		}
+	}
+	return buf.String()
+}
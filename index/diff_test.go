@@ -0,0 +1,58 @@
+package index
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/blang/semver"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+)
+
+func TestDiff(t *testing.T) {
+	Convey("Testing index comparison", t, func(c C) {
+		old := buildIndex([]string{"1.0.0", "1.2.0", "1.10.0"}, []string{"Modelica"})
+		bar := old.GetLibrary("Bar", "https://github.com/a/Bar", "https://github.com/a")
+		bar.AddVersion(semver.MustParse("0.1.0"))
+
+		current := buildIndex([]string{"1.2.0", "1.10.0", "1.9.0", "2.0.0"}, []string{"Modelica"})
+		// Replacing a version drops the dependencies it had
+		foo := current.GetLibrary("Foo", "https://github.com/a/Foo", "https://github.com/a")
+		foo.AddVersion(semver.MustParse("1.2.0")).AddDependency("Complex", semver.MustParse("1.0.0"))
+		baz := current.GetLibrary("Baz", "https://github.com/b/Baz", "https://github.com/b")
+		baz.AddVersion(semver.MustParse("3.0.0"))
+		baz.AddVersion(semver.MustParse("2.0.0"))
+
+		diff := DiffIndexes(*old, *current)
+		IsFalse(c, diff.Empty())
+
+		Equals(c, len(diff.AddedLibraries), 1)
+		Equals(c, diff.AddedLibraries[0].Name, "Baz")
+		Equals(c, versionList(diff.AddedLibraries[0].Versions), "2.0.0, 3.0.0")
+
+		Equals(c, len(diff.RemovedLibraries), 1)
+		Equals(c, diff.RemovedLibraries[0].Name, "Bar")
+
+		Equals(c, len(diff.ChangedLibraries), 1)
+		ld := diff.ChangedLibraries[0]
+		Equals(c, ld.Name, "Foo")
+		Equals(c, versionList(ld.AddedVersions), "1.9.0, 2.0.0")
+		Equals(c, versionList(ld.RemovedVersions), "1.0.0")
+		Equals(c, len(ld.DependencyChanges), 1)
+		Equals(c, ld.DependencyChanges[0].Version.String(), "1.2.0")
+		Equals(c, dependencyString(ld.DependencyChanges[0].Added), "Complex 1.0.0")
+		Equals(c, dependencyString(ld.DependencyChanges[0].Removed), "Modelica 1.0.0")
+
+		str := diff.String()
+		IsTrue(c, strings.Contains(str, "Added libraries:\n  Baz (https://github.com/b/Baz): 2.0.0, 3.0.0\n"))
+		IsTrue(c, strings.Contains(str, "Removed libraries:\n  Bar (https://github.com/a/Bar): 0.1.0\n"))
+		IsTrue(c, strings.Contains(str, "    Added versions: 1.9.0, 2.0.0\n"))
+		IsTrue(c, strings.Contains(str, "    Dependencies of 1.2.0: added Complex 1.0.0; removed Modelica 1.0.0\n"))
+
+		// An index doesn't differ from itself
+		same := DiffIndexes(*current, *current)
+		IsTrue(c, same.Empty())
+		Equals(c, same.String(), "No changes\n")
+	})
+}
@@ -0,0 +1,139 @@
+package index
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// The Dump method writes the complete index (in the same format as
+// index.json) to w.  The output is deterministic: versions are listed in
+// descending order (by semantic version) and dependencies are sorted by
+// name.  Libraries are sorted by name (see orderLibraries) so that the
+// order they were crawled in doesn't matter.
+func (i Index) Dump(w io.Writer) error {
+	str, err := i.JSON()
+	if err != nil {
+		return fmt.Errorf("Unable to serialize index: %v", err)
+	}
+	_, err = fmt.Fprintln(w, str)
+	if err != nil {
+		return fmt.Errorf("Unable to write index: %v", err)
+	}
+	return nil
+}
+
+// This function returns the given libraries sorted by name.  The order
+// in which libraries with the same name were recorded is kept since that
+// determines which of them is preferred (see Group).
+func orderLibraries(libs []*Library) []*Library {
+	if libs == nil {
+		return nil
+	}
+	ret := make([]*Library, len(libs))
+	copy(ret, libs)
+	sort.Stable(libraryList(ret))
+	return ret
+}
+
+// The libraryList type is used to sort libraries by name
+type libraryList []*Library
+
+func (l libraryList) Len() int               { return len(l) }
+func (l libraryList) Swap(i int, j int)      { l[i], l[j] = l[j], l[i] }
+func (l libraryList) Less(i int, j int) bool { return l[i].Name < l[j].Name }
+
+func (i Index) MarshalJSON() ([]byte, error) {
+	// The plain type has the same fields as Index, but not this method
+	type plain Index
+	p := plain(i)
+	p.Libraries = orderLibraries(i.Libraries)
+	return json.Marshal(p)
+}
+
+// The orderedVersions type is used to serialize the versions of a library
+// (which are stored as a map) with the newest version first.
+type orderedVersions []versionEntry
+
+type versionEntry struct {
+	key     string
+	details *VersionDetails
+}
+
+func (o orderedVersions) Len() int          { return len(o) }
+func (o orderedVersions) Swap(i int, j int) { o[i], o[j] = o[j], o[i] }
+func (o orderedVersions) Less(i int, j int) bool {
+	// Versions listed under more than one key are still ordered consistently
+	if o[i].details.Version.EQ(o[j].details.Version) {
+		return o[i].key < o[j].key
+	}
+	return o[i].details.Version.GT(o[j].details.Version)
+}
+
+func (o orderedVersions) MarshalJSON() ([]byte, error) {
+	buf := bytes.Buffer{}
+	buf.WriteString("{")
+	for n, v := range o {
+		if n > 0 {
+			buf.WriteString(",")
+		}
+		key, err := json.Marshal(v.key)
+		if err != nil {
+			return nil, err
+		}
+		val, err := json.Marshal(v.details)
+		if err != nil {
+			return nil, err
+		}
+		buf.Write(key)
+		buf.WriteString(":")
+		buf.Write(val)
+	}
+	buf.WriteString("}")
+	return buf.Bytes(), nil
+}
+
+// The dependencyList type is used to sort dependencies by name
+type dependencyList []Dependency
+
+func (d dependencyList) Len() int               { return len(d) }
+func (d dependencyList) Swap(i int, j int)      { d[i], d[j] = d[j], d[i] }
+func (d dependencyList) Less(i int, j int) bool { return d[i].Name < d[j].Name }
+
+// This function returns the given versions with the newest version first.
+func orderVersions(versions map[string]*VersionDetails) orderedVersions {
+	ret := orderedVersions{}
+	for k, v := range versions {
+		ret = append(ret, versionEntry{key: k, details: v})
+	}
+	sort.Sort(ret)
+	return ret
+}
+
+func (lib Library) MarshalJSON() ([]byte, error) {
+	versions := orderVersions(lib.Versions)
+
+	// The plain type has the same fields as Library, but not this method
+	type plain Library
+	return json.Marshal(struct {
+		plain
+		Versions orderedVersions `json:"versions"`
+	}{
+		plain:    plain(lib),
+		Versions: versions,
+	})
+}
+
+func (v VersionDetails) MarshalJSON() ([]byte, error) {
+	deps := make([]Dependency, len(v.Dependencies))
+	copy(deps, v.Dependencies)
+	sort.Stable(dependencyList(deps))
+
+	// The plain type has the same fields as VersionDetails, but not this method
+	type plain VersionDetails
+	p := plain(v)
+	p.Dependencies = deps
+	return json.Marshal(p)
+}
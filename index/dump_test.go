@@ -0,0 +1,216 @@
+package index
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blang/semver"
+
+	"github.com/impact/impact/recorder"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+)
+
+func buildIndex(versions []string, deps []string) *Index {
+	ind := NewIndex()
+	lib := ind.GetLibrary("Foo", "https://github.com/a/Foo", "https://github.com/a")
+	lib.SetStars(3)
+	lib.SetEmail("foo@example.com")
+	for _, v := range versions {
+		vr := lib.AddVersion(semver.MustParse(v))
+		for _, dep := range deps {
+			vr.AddDependency(dep, semver.MustParse("1.0.0"))
+		}
+	}
+	return ind
+}
+
+func TestDump(t *testing.T) {
+	Convey("Testing index serialization", t, func(c C) {
+		a := buildIndex([]string{"1.0.0", "1.10.0", "1.2.0"}, []string{"Modelica", "Complex"})
+		b := buildIndex([]string{"1.2.0", "1.0.0", "1.10.0"}, []string{"Complex", "Modelica"})
+
+		abuf := bytes.Buffer{}
+		NoError(c, a.Dump(&abuf))
+		bbuf := bytes.Buffer{}
+		NoError(c, b.Dump(&bbuf))
+
+		// Output doesn't depend on the order things were recorded in
+		Equals(c, abuf.String(), bbuf.String())
+
+		str := abuf.String()
+		i10 := strings.Index(str, `"1.10.0": {`)
+		i2 := strings.Index(str, `"1.2.0": {`)
+		i0 := strings.Index(str, `"1.0.0": {`)
+		IsTrue(c, i10 >= 0 && i10 < i2 && i2 < i0)
+		IsTrue(c, strings.Index(str, `"Complex"`) < strings.Index(str, `"Modelica"`))
+		IsTrue(c, strings.Contains(str, `"stars": 3`))
+		IsTrue(c, strings.Contains(str, `"forks": 0`))
+		IsTrue(c, strings.Contains(str, `"open_issues": 0`))
+		IsTrue(c, strings.Contains(str, `"email": "foo@example.com"`))
+
+		// The output can still be read back in
+		ind := Index{}
+		NoError(c, json.Unmarshal(abuf.Bytes(), &ind))
+		Equals(c, len(ind.Libraries), 1)
+		Equals(c, len(ind.Libraries[0].Versions), 3)
+	})
+
+	Convey("Testing the order of libraries", t, func(c C) {
+		a := NewIndex()
+		a.GetLibrary("Foo", "https://github.com/a/Foo", "https://github.com/a")
+		a.GetLibrary("Bar", "https://github.com/b/Bar", "https://github.com/b")
+		a.GetLibrary("Bar", "https://github.com/a/Bar", "https://github.com/a")
+		b := NewIndex()
+		b.GetLibrary("Bar", "https://github.com/b/Bar", "https://github.com/b")
+		b.GetLibrary("Foo", "https://github.com/a/Foo", "https://github.com/a")
+		b.GetLibrary("Bar", "https://github.com/a/Bar", "https://github.com/a")
+
+		abuf := bytes.Buffer{}
+		NoError(c, a.Dump(&abuf))
+		bbuf := bytes.Buffer{}
+		NoError(c, b.Dump(&bbuf))
+		Equals(c, abuf.String(), bbuf.String())
+
+		// Libraries with the same name stay in the order they were recorded
+		ind := Index{}
+		NoError(c, json.Unmarshal(abuf.Bytes(), &ind))
+		Equals(c, len(ind.Libraries), 3)
+		Equals(c, ind.Libraries[0].URI, "https://github.com/b/Bar")
+		Equals(c, ind.Libraries[1].URI, "https://github.com/a/Bar")
+		Equals(c, ind.Libraries[2].Name, "Foo")
+		// The index itself is unchanged
+		Equals(c, a.Libraries[0].Name, "Foo")
+	})
+
+	Convey("Testing release dates", t, func(c C) {
+		ind := buildIndex([]string{"1.0.0", "2.0.0"}, []string{})
+		lib := ind.Libraries[0]
+		loc := time.FixedZone("EST", -5*3600)
+		lib.Versions["1.0.0"].SetReleaseDate(time.Date(2016, 3, 1, 7, 30, 0, 0, loc))
+
+		buf := bytes.Buffer{}
+		NoError(c, ind.Dump(&buf))
+		str := buf.String()
+		IsTrue(c, strings.Contains(str, `"release_date": "2016-03-01T12:30:00Z"`))
+		// Unknown dates are left out
+		Equals(c, strings.Count(str, `"release_date"`), 1)
+		Equals(c, strings.Count(str, `"modelica_version"`), 0)
+	})
+}
+
+func TestDeprecate(t *testing.T) {
+	Convey("Testing deprecated versions", t, func(c C) {
+		ind := buildIndex([]string{"1.0.0", "1.1.0"}, []string{})
+
+		err := ind.Deprecate("Foo", semver.MustParse("1.1.0"), "Broken")
+		NoError(c, err)
+		err = ind.Deprecate("Foo", semver.MustParse("2.0.0"), "Missing")
+		IsError(c, err)
+
+		str, err := ind.JSON()
+		NoError(c, err)
+		IsTrue(c, strings.Contains(str, `"deprecated": true`))
+		IsTrue(c, strings.Contains(str, `"deprecation_reason": "Broken"`))
+
+		// The flag survives a round trip
+		read := Index{}
+		NoError(c, json.Unmarshal([]byte(str), &read))
+		IsTrue(c, read.Libraries[0].Versions["1.1.0"].Deprecated)
+		IsTrue(c, !read.Libraries[0].Versions["1.0.0"].Deprecated)
+
+		reduced := ind.WithoutDeprecated()
+		Equals(c, len(reduced.Libraries[0].Versions), 1)
+		_, exists := reduced.Libraries[0].Versions["1.0.0"]
+		IsTrue(c, exists)
+		// The original is unchanged
+		Equals(c, len(ind.Libraries[0].Versions), 2)
+	})
+}
+
+func TestDuplicateDependencies(t *testing.T) {
+	Convey("Testing that dependencies are only listed once", t, func(c C) {
+		ind := buildIndex([]string{"1.0.0"}, []string{"Modelica", "Modelica"})
+		vr := ind.Libraries[0].AddVersion(semver.MustParse("1.1.0"))
+		vr.AddDependency("Modelica", semver.MustParse("3.2.0"))
+		vr.AddDependency("Modelica", semver.MustParse("3.2.2"))
+		vr.AddDependency("Modelica", semver.MustParse("3.1.0"))
+		vr.AddDependency("Modelica", semver.MustParse("4.0.0"))
+
+		deps := ind.Libraries[0].Versions["1.0.0"].Dependencies
+		Equals(c, len(deps), 1)
+		// The stricter version is kept
+		deps = ind.Libraries[0].Versions["1.1.0"].Dependencies
+		Equals(c, len(deps), 1)
+		Equals(c, deps[0].Version, "3.2.2")
+
+		str, err := ind.JSON()
+		NoError(c, err)
+		Equals(c, strings.Count(str, `"name": "Modelica"`), 2)
+	})
+}
+
+func TestMirrors(t *testing.T) {
+	Convey("Testing mirrors of tarballs", t, func(c C) {
+		ind := buildIndex([]string{"1.0.0", "1.1.0"}, []string{})
+		v := ind.Libraries[0].Versions["1.1.0"]
+		v.SetTarballURL("https://a.example.com/Foo.tar.gz")
+		v.AddMirror("https://b.example.com/Foo.tar.gz")
+		v.AddMirror("https://c.example.com/Foo.tar.gz")
+		v.AddMirror("https://b.example.com/Foo.tar.gz")
+		Resembles(c, v.TarballURLs(), []string{"https://a.example.com/Foo.tar.gz",
+			"https://b.example.com/Foo.tar.gz", "https://c.example.com/Foo.tar.gz"})
+
+		// Setting the tarball URL replaces the first entry
+		v.SetTarballURL("https://d.example.com/Foo.tar.gz")
+		Equals(c, v.Tarball, "https://d.example.com/Foo.tar.gz")
+		Resembles(c, v.TarballURLs(), []string{"https://d.example.com/Foo.tar.gz",
+			"https://b.example.com/Foo.tar.gz", "https://c.example.com/Foo.tar.gz"})
+
+		// Versions without mirrors are listed as before
+		ind.Libraries[0].Versions["1.0.0"].SetTarballURL("https://a.example.com/Foo-1.0.tar.gz")
+		Resembles(c, ind.Libraries[0].Versions["1.0.0"].TarballURLs(),
+			[]string{"https://a.example.com/Foo-1.0.tar.gz"})
+		str, err := ind.JSON()
+		NoError(c, err)
+		Equals(c, strings.Count(str, `"tarball_urls"`), 1)
+
+		// The order survives a round trip
+		read := Index{}
+		NoError(c, json.Unmarshal([]byte(str), &read))
+		Resembles(c, read.Libraries[0].Versions["1.1.0"].TarballURLs(), v.TarballURLs())
+	})
+}
+
+func TestGenerated(t *testing.T) {
+	Convey("Testing recording how an index was generated", t, func(c C) {
+		ind := buildIndex([]string{"1.0.0"}, []string{})
+		str, err := ind.JSON()
+		NoError(c, err)
+		IsFalse(c, strings.Contains(str, "generated_at"))
+		IsFalse(c, strings.Contains(str, "generator_version"))
+
+		at := time.Date(2026, 3, 1, 13, 0, 0, 0, time.FixedZone("CET", 3600))
+		IsTrue(c, recorder.Stamp(ind, at, "1.2.3"))
+		Equals(c, ind.GeneratedAt, "2026-03-01T12:00:00Z")
+		Equals(c, ind.GeneratorVersion, "1.2.3")
+		Equals(c, ind.WithoutDeprecated().GeneratedAt, ind.GeneratedAt)
+
+		str, err = ind.JSON()
+		NoError(c, err)
+		read := Index{}
+		NoError(c, json.Unmarshal([]byte(str), &read))
+		Equals(c, read.GeneratedAt, "2026-03-01T12:00:00Z")
+		Equals(c, read.GeneratorVersion, "1.2.3")
+		violations, err := CheckSchema([]byte(str))
+		NoError(c, err)
+		Equals(c, len(violations), 0)
+
+		// Recorders that can't record this are left alone
+		IsFalse(c, recorder.Stamp(recorder.NullRecorder{}, at, "1.2.3"))
+	})
+}
@@ -0,0 +1,164 @@
+package index
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// The cachedIndex type holds what is needed to ask whether an index
+// downloaded earlier has changed (see FetchIndex).
+type cachedIndex struct {
+	URL          string `json:"url"`
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// This function returns the names of the files (in the cache directory)
+// holding the contents of the index at the given URL and the information
+// needed to ask whether it has changed.
+func cacheFiles(cacheDir string, index_url string) (string, string) {
+	sum := sha256.Sum256([]byte(index_url))
+	base := filepath.Join(cacheDir, hex.EncodeToString(sum[:8]))
+	return base + ".json", base + ".meta.json"
+}
+
+// This function reads what was cached for the index at the given URL.
+// It returns false if nothing (usable) was cached.
+func readCachedIndex(cacheDir string, index_url string) ([]byte, cachedIndex, bool) {
+	dfile, mfile := cacheFiles(cacheDir, index_url)
+	meta := cachedIndex{}
+	raw, err := ioutil.ReadFile(mfile)
+	if err != nil || json.Unmarshal(raw, &meta) != nil || meta.URL != index_url {
+		return nil, meta, false
+	}
+	data, err := ioutil.ReadFile(dfile)
+	if err != nil {
+		return nil, meta, false
+	}
+	return data, meta, true
+}
+
+// This function writes the given file so that it is never seen partially
+// written.
+func writeAtomically(name string, data []byte) error {
+	f, err := ioutil.TempFile(filepath.Dir(name), ".index")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	cerr := f.Close()
+	if err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), name)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// This function caches the contents of the index at the given URL along
+// with the information needed to ask whether it has changed.
+func writeCachedIndex(cacheDir string, data []byte, meta cachedIndex) error {
+	err := os.MkdirAll(cacheDir, 0755)
+	if err != nil {
+		return err
+	}
+	dfile, mfile := cacheFiles(cacheDir, meta.URL)
+	raw, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	err = writeAtomically(dfile, data)
+	if err != nil {
+		return err
+	}
+	return writeAtomically(mfile, raw)
+}
+
+// This function parses the contents of an index.
+func parseIndex(data []byte, index_url string) (*Index, error) {
+	ind := NewIndex()
+	err := json.Unmarshal(data, ind)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse JSON at %s: %v", index_url, err)
+	}
+	return ind, nil
+}
+
+// The FetchIndex function reads the index found at the given URL.  Indices
+// downloaded over HTTP(S) are kept in the given cache directory along
+// with their ETag and Last-Modified headers.  The next time the same
+// index is fetched, it is only downloaded again if it has changed (i.e.,
+// unless the server responds to a conditional GET with 304 Not Modified,
+// the cached index is used).  Since indices can be large, this avoids
+// downloading the same index repeatedly.  Other URLs (and any URL if no
+// cache directory is given) are simply read (see Fetch).
+func FetchIndex(index_url string, cacheDir string) (*Index, error) {
+	http_url := strings.HasPrefix(index_url, "http://") || strings.HasPrefix(index_url, "https://")
+	if cacheDir == "" || !http_url {
+		data, err := Fetch(index_url)
+		if err != nil {
+			return nil, err
+		}
+		return parseIndex(data, index_url)
+	}
+
+	req, err := http.NewRequest("GET", index_url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing url: %v", err)
+	}
+	cached, meta, found := readCachedIndex(cacheDir, index_url)
+	if found {
+		if meta.ETag != "" {
+			req.Header.Set("If-None-Match", meta.ETag)
+		}
+		if meta.LastModified != "" {
+			req.Header.Set("If-Modified-Since", meta.LastModified)
+		}
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("Error for GET %s: %v", index_url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified && found {
+		return parseIndex(cached, index_url)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Error for GET %s: %s", index_url, resp.Status)
+	}
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read body of response from GET %s: %v", index_url, err)
+	}
+	ind, err := parseIndex(data, index_url)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only indices that can be asked about later are worth caching.
+	// Failing to cache an index doesn't prevent it from being used (it
+	// is just downloaded again next time).
+	meta = cachedIndex{
+		URL:          index_url,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	if meta.ETag != "" || meta.LastModified != "" {
+		writeCachedIndex(cacheDir, data, meta)
+	}
+	return ind, nil
+}
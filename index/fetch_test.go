@@ -0,0 +1,71 @@
+package index
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+)
+
+func TestFetchIndex(t *testing.T) {
+	Convey("Testing fetching indices only when they have changed", t, func(c C) {
+		body := bytes.Buffer{}
+		NoError(c, buildIndex([]string{"1.0.0"}, nil).Dump(&body))
+
+		downloads := 0
+		etag := `"v1"`
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/untagged.json" {
+				downloads++
+				w.Write(body.Bytes())
+				return
+			}
+			if r.Header.Get("If-None-Match") == etag {
+				w.WriteHeader(http.StatusNotModified)
+				return
+			}
+			downloads++
+			w.Header().Set("ETag", etag)
+			w.Write(body.Bytes())
+		}))
+		defer server.Close()
+
+		dir, err := ioutil.TempDir("", "impact")
+		NoError(c, err)
+		defer os.RemoveAll(dir)
+
+		url := server.URL + "/index.json"
+		ind, err := FetchIndex(url, dir)
+		NoError(c, err)
+		Equals(c, len(ind.Libraries), 1)
+		Equals(c, downloads, 1)
+
+		// Unchanged indices are taken from the cache
+		ind, err = FetchIndex(url, dir)
+		NoError(c, err)
+		Equals(c, len(ind.Libraries), 1)
+		Equals(c, ind.Libraries[0].Name, "Foo")
+		Equals(c, downloads, 1)
+
+		etag = `"v2"`
+		_, err = FetchIndex(url, dir)
+		NoError(c, err)
+		Equals(c, downloads, 2)
+
+		// Without a cache directory (or validators), indices are always
+		// downloaded
+		_, err = FetchIndex(url, "")
+		NoError(c, err)
+		Equals(c, downloads, 3)
+		for n := 0; n < 2; n++ {
+			_, err = FetchIndex(server.URL+"/untagged.json", dir)
+			NoError(c, err)
+		}
+		Equals(c, downloads, 5)
+	})
+}
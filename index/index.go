@@ -3,6 +3,7 @@ package index
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/blang/semver"
 
@@ -10,8 +11,12 @@ import (
 )
 
 type Index struct {
-	Version   string     `json:"version"`
-	Libraries []*Library `json:"libraries"`
+	Version string `json:"version"`
+	// When (in RFC3339 format) and by which version of impact this index
+	// was generated (see SetGeneratedAt and SetGeneratorVersion)
+	GeneratedAt      string     `json:"generated_at,omitempty"`
+	GeneratorVersion string     `json:"generator_version,omitempty"`
+	Libraries        []*Library `json:"libraries"`
 }
 
 func (i Index) Find(name string, version semver.Version) (VersionDetails, error) {
@@ -40,11 +45,89 @@ func (i *Index) GetLibrary(name string, uri string, owner_uri string) recorder.L
 	return lib
 }
 
+// The RemoveVersion method removes the given version of the named library
+// (owned by owner_uri) from this index.  Once its last version is
+// removed, the library itself is removed as well.
+func (i *Index) RemoveVersion(name string, owner_uri string, v semver.Version) {
+	for j, lib := range i.Libraries {
+		if lib.OwnerURI != owner_uri || lib.Name != name {
+			continue
+		}
+		delete(lib.Versions, v.String())
+		if len(lib.Versions) == 0 {
+			i.Libraries = append(i.Libraries[:j], i.Libraries[j+1:]...)
+		}
+		return
+	}
+}
+
+// The SetGeneratedAt method records when this index was generated (a
+// zero time clears it).
+func (i *Index) SetGeneratedAt(t time.Time) {
+	if t.IsZero() {
+		i.GeneratedAt = ""
+		return
+	}
+	i.GeneratedAt = t.UTC().Format(time.RFC3339)
+}
+
+// The SetGeneratorVersion method records which version of impact
+// generated this index.
+func (i *Index) SetGeneratorVersion(version string) {
+	i.GeneratorVersion = version
+}
+
 func (i Index) Reduce(disamb map[string]string) *Index {
 	g := i.Group(disamb)
 	return g.Selected()
 }
 
+// The Deprecate method marks the given version of the named library as
+// deprecated for the given reason (wherever it is found in this index).
+// This allows a broken version to be pulled from the index without
+// crawling again.
+func (i *Index) Deprecate(name string, version semver.Version, reason string) error {
+	found := false
+	for _, lib := range i.Libraries {
+		if lib.Name != name {
+			continue
+		}
+		for _, details := range lib.Versions {
+			if details.Version.EQ(version) {
+				details.SetDeprecated(reason)
+				found = true
+			}
+		}
+	}
+	if !found {
+		return MissingVersionError{Name: name, Version: version.String()}
+	}
+	return nil
+}
+
+// The WithoutDeprecated method returns a copy of this index that leaves
+// out every deprecated version.  This keeps deprecated versions from
+// being selected when resolving dependencies.
+func (i Index) WithoutDeprecated() *Index {
+	ret := &Index{
+		Version:          i.Version,
+		GeneratedAt:      i.GeneratedAt,
+		GeneratorVersion: i.GeneratorVersion,
+		Libraries:        []*Library{},
+	}
+	for _, lib := range i.Libraries {
+		reduced := *lib
+		reduced.Versions = map[string]*VersionDetails{}
+		for k, details := range lib.Versions {
+			if !details.Deprecated {
+				reduced.Versions[k] = details
+			}
+		}
+		ret.Libraries = append(ret.Libraries, &reduced)
+	}
+	return ret
+}
+
 func (i Index) JSON() (string, error) {
 	b, err := json.MarshalIndent(i, "", "  ")
 	if err != nil {
@@ -60,4 +143,5 @@ func NewIndex() *Index {
 	}
 }
 
-var _ recorder.Recorder = (*Index)(nil)
+var _ recorder.Stamper = (*Index)(nil)
+var _ recorder.VersionRemover = (*Index)(nil)
@@ -21,6 +21,9 @@ type Library struct {
 	OwnerURI string `json:"owner_uri"`
 	// Email for library contact
 	Email string `json:"email"`
+	// Everyone responsible for the library (the contact is normally one of
+	// them)
+	Maintainers []recorder.Maintainer `json:"maintainers,omitempty"`
 	// Web site
 	Homepage string `json:"homepage"`
 	// Repository
@@ -31,16 +34,37 @@ type Library struct {
 	Description string `json:"description"`
 	// Stars (if applicable, otherwise -1)
 	Stars int `json:"stars"`
+	// Number of forks and open issues of the repository (zero if unknown)
+	Forks      int `json:"forks"`
+	OpenIssues int `json:"open_issues"`
+	// SPDX identifier of the license (if known)
+	License string `json:"license"`
 }
 
 func (lib *Library) SetEmail(email string) {
 	lib.Email = email
 }
 
+func (lib *Library) SetMaintainers(maintainers []recorder.Maintainer) {
+	lib.Maintainers = maintainers
+}
+
 func (lib *Library) SetStars(stars int) {
 	lib.Stars = stars
 }
 
+func (lib *Library) SetForks(forks int) {
+	lib.Forks = forks
+}
+
+func (lib *Library) SetOpenIssues(issues int) {
+	lib.OpenIssues = issues
+}
+
+func (lib *Library) SetLicense(license string) {
+	lib.License = license
+}
+
 func (lib *Library) SetDescription(desc string) {
 	lib.Description = desc
 }
@@ -54,6 +78,11 @@ func (lib *Library) SetRepository(url string, format string) {
 	lib.Format = format
 }
 
+func (lib *Library) HasVersion(v semver.Version) bool {
+	_, exists := lib.Versions[v.String()]
+	return exists
+}
+
 func (lib *Library) AddVersion(v semver.Version) recorder.VersionRecorder {
 	details := NewVersionDetails(v)
 	lib.Versions[v.String()] = details
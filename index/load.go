@@ -1,8 +1,11 @@
 package index
 
 import (
+	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"log"
+	"strings"
 
 	"github.com/impact/impact/config"
 )
@@ -34,3 +37,27 @@ func LoadIndex(verbose bool) (*Index, error) {
 
 	return ind, nil
 }
+
+// The ReadIndex function reads an existing index from a file (or URL).
+// Since an Index is also a Recorder, the result can be used as the
+// baseline for a crawl or have crawled libraries merged into it (see
+// Update).
+func ReadIndex(src string) (*Index, error) {
+	var data []byte
+	var err error
+	if strings.Contains(src, "://") {
+		data, err = Fetch(src)
+	} else {
+		data, err = ioutil.ReadFile(src)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Error reading index %s: %v", src, err)
+	}
+
+	ind := NewIndex()
+	err = json.Unmarshal(data, ind)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing index %s: %v", src, err)
+	}
+	return ind, nil
+}
@@ -1,5 +1,12 @@
 package index
 
+import (
+	"fmt"
+	"strings"
+
+	"github.com/blang/semver"
+)
+
 // This function merges two indices.  The libraries from i2 will be
 // appended to the list from i1.
 func (i1 *Index) Merge(i2 Index) error {
@@ -9,3 +16,143 @@ func (i1 *Index) Merge(i2 Index) error {
 
 	return nil
 }
+
+// The ConflictPolicy type indicates what to do when a crawl finds a
+// version that is already in the index being updated but with a
+// different SHA (see Update).
+type ConflictPolicy int
+
+const (
+	// Keep the version already in the index
+	KeepExisting ConflictPolicy = iota
+	// Replace it with the version just crawled
+	OverwriteExisting
+)
+
+func (p ConflictPolicy) String() string {
+	switch p {
+	case KeepExisting:
+		return "keep"
+	case OverwriteExisting:
+		return "overwrite"
+	default:
+		return fmt.Sprintf("ConflictPolicy(%d)", int(p))
+	}
+}
+
+// The ParseConflictPolicy function returns the policy with the given name
+// ("keep" or "overwrite").
+func ParseConflictPolicy(name string) (ConflictPolicy, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "keep", "keep-existing":
+		return KeepExisting, nil
+	case "overwrite":
+		return OverwriteExisting, nil
+	default:
+		return KeepExisting, fmt.Errorf("Unknown conflict policy '%s' (expected keep or overwrite)", name)
+	}
+}
+
+// The MergeConflict type describes a version found by a crawl that was
+// already in the index being updated with a different SHA.
+type MergeConflict struct {
+	Name        string
+	URI         string
+	Version     semver.Version
+	ExistingSha string
+	CrawledSha  string
+	// Whether the existing version was kept (see ConflictPolicy)
+	Kept bool
+}
+
+func (m MergeConflict) String() string {
+	resolution := "using " + m.CrawledSha
+	if m.Kept {
+		resolution = "keeping " + m.ExistingSha
+	}
+	return fmt.Sprintf("Version %s of %s (%s) is %s in the index but %s was crawled, %s",
+		m.Version, m.Name, m.URI, m.ExistingSha, m.CrawledSha, resolution)
+}
+
+// This function returns the library with the given name and owner (if
+// any).  This is how libraries are identified when recording them (see
+// GetLibrary).
+func (i Index) findLibrary(name string, owner_uri string) *Library {
+	for _, lib := range i.Libraries {
+		if lib.OwnerURI == owner_uri && lib.Name == name {
+			return lib
+		}
+	}
+	return nil
+}
+
+// This function copies whatever was recorded about a version after it was
+// crawled (its build status, whether it is deprecated and its checksums)
+// so that it isn't lost when the same commit is crawled again.
+func keepAnnotations(existing *VersionDetails, crawled *VersionDetails) {
+	if crawled.BuildStatus == "" {
+		crawled.BuildStatus = existing.BuildStatus
+		crawled.BuildDetails = existing.BuildDetails
+	}
+	if !crawled.Deprecated && existing.Deprecated {
+		crawled.SetDeprecated(existing.DeprecationReason)
+	}
+	if len(crawled.Checksums) == 0 {
+		crawled.Checksums = existing.Checksums
+	}
+}
+
+// The Update method merges the libraries found by a crawl (e.g., of just
+// some of the users) into this index.  Libraries the crawl didn't find
+// are left alone.  For the libraries it did find, the details of the
+// library are taken from the crawl and the versions crawled are added to
+// (or replace) the versions already in this index.  Versions that are
+// already in this index with a different SHA are resolved according to
+// the given policy and returned (in order) so that they can be reported.
+// If the crawl recorded when (and by what) it was generated, that is
+// recorded for this index as well.
+func (i *Index) Update(crawled Index, policy ConflictPolicy) []MergeConflict {
+	if crawled.GeneratedAt != "" {
+		i.GeneratedAt = crawled.GeneratedAt
+		i.GeneratorVersion = crawled.GeneratorVersion
+	}
+
+	conflicts := []MergeConflict{}
+	for _, clib := range orderLibraries(crawled.Libraries) {
+		lib := i.findLibrary(clib.Name, clib.OwnerURI)
+		if lib == nil {
+			i.Libraries = append(i.Libraries, clib)
+			continue
+		}
+
+		versions := lib.Versions
+		if versions == nil {
+			versions = map[string]*VersionDetails{}
+		}
+		*lib = *clib
+		lib.Versions = versions
+
+		for _, entry := range orderVersions(clib.Versions) {
+			details := entry.details
+			existing, found := versions[entry.key]
+			if found && existing.Sha != details.Sha {
+				kept := policy == KeepExisting
+				conflicts = append(conflicts, MergeConflict{
+					Name:        clib.Name,
+					URI:         clib.URI,
+					Version:     details.Version,
+					ExistingSha: existing.Sha,
+					CrawledSha:  details.Sha,
+					Kept:        kept,
+				})
+				if kept {
+					continue
+				}
+			} else if found {
+				keepAnnotations(existing, details)
+			}
+			versions[entry.key] = details
+		}
+	}
+	return conflicts
+}
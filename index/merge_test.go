@@ -0,0 +1,86 @@
+package index
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/blang/semver"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+)
+
+func TestUpdate(t *testing.T) {
+	Convey("Testing merging a crawl into an existing index", t, func(c C) {
+		build := func(policy ConflictPolicy) (*Index, []MergeConflict) {
+			existing := buildIndex([]string{"1.0.0", "1.1.0", "2.0.0"}, nil)
+			foo := existing.Libraries[0]
+			foo.Versions["1.0.0"].SetHash("aaa")
+			foo.Versions["1.1.0"].SetHash("bbb")
+			foo.Versions["1.1.0"].SetBuildStatus("passing", "")
+			foo.Versions["2.0.0"].SetHash("ccc")
+			bar := existing.GetLibrary("Bar", "https://github.com/b/Bar", "https://github.com/b")
+			bar.AddVersion(semver.MustParse("0.1.0")).SetHash("ddd")
+
+			crawled := NewIndex()
+			lib := crawled.GetLibrary("Foo", "https://github.com/a/Foo", "https://github.com/a")
+			lib.SetStars(10)
+			lib.AddVersion(semver.MustParse("1.1.0")).SetHash("bbb")
+			lib.AddVersion(semver.MustParse("2.0.0")).SetHash("eee")
+			lib.AddVersion(semver.MustParse("2.1.0")).SetHash("fff")
+			baz := crawled.GetLibrary("Baz", "https://github.com/a/Baz", "https://github.com/a")
+			baz.AddVersion(semver.MustParse("1.0.0"))
+
+			crawled.SetGeneratedAt(time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC))
+			crawled.SetGeneratorVersion("1.2.3")
+			return existing, existing.Update(*crawled, policy)
+		}
+
+		ind, conflicts := build(KeepExisting)
+		Equals(c, len(ind.Libraries), 3)
+		Equals(c, ind.GeneratedAt, "2026-03-01T12:00:00Z")
+		Equals(c, ind.GeneratorVersion, "1.2.3")
+
+		// Libraries that weren't crawled are left intact
+		bar := ind.findLibrary("Bar", "https://github.com/b")
+		NotNil(c, bar)
+		Equals(c, bar.Versions["0.1.0"].Sha, "ddd")
+		NotNil(c, ind.findLibrary("Baz", "https://github.com/a"))
+
+		// Crawled libraries are updated, keeping versions not crawled again
+		foo := ind.findLibrary("Foo", "https://github.com/a")
+		Equals(c, foo.Stars, 10)
+		Equals(c, len(foo.Versions), 4)
+		Equals(c, foo.Versions["1.0.0"].Sha, "aaa")
+		Equals(c, foo.Versions["2.1.0"].Sha, "fff")
+		// Annotations of versions that haven't changed are kept
+		Equals(c, foo.Versions["1.1.0"].BuildStatus, "passing")
+
+		Equals(c, len(conflicts), 1)
+		Equals(c, conflicts[0].Version.String(), "2.0.0")
+		Equals(c, conflicts[0].ExistingSha, "ccc")
+		Equals(c, conflicts[0].CrawledSha, "eee")
+		IsTrue(c, conflicts[0].Kept)
+		IsTrue(c, strings.HasSuffix(conflicts[0].String(), "keeping ccc"))
+		Equals(c, foo.Versions["2.0.0"].Sha, "ccc")
+
+		ind, conflicts = build(OverwriteExisting)
+		foo = ind.findLibrary("Foo", "https://github.com/a")
+		Equals(c, len(conflicts), 1)
+		IsFalse(c, conflicts[0].Kept)
+		Equals(c, foo.Versions["2.0.0"].Sha, "eee")
+	})
+
+	Convey("Testing conflict policies", t, func(c C) {
+		p, err := ParseConflictPolicy("keep")
+		NoError(c, err)
+		Equals(c, p, KeepExisting)
+		p, err = ParseConflictPolicy("Overwrite")
+		NoError(c, err)
+		Equals(c, p, OverwriteExisting)
+		Equals(c, p.String(), "overwrite")
+		_, err = ParseConflictPolicy("newest")
+		IsError(c, err)
+	})
+}
@@ -16,10 +16,40 @@ import (
 // index versions (by parsing the Version field in the index first and
 // then determining how, or if, to parse it).
 func (index *Index) ParseIndex(index_url string) error {
+	bytes, err := Fetch(index_url)
+	if err != nil {
+		return err
+	}
+
+	// Now create an empty index to read the bytes into
+	contents := Index{}
+
+	// Unmarshal the bytes as JSON into the new empty index
+	err = json.Unmarshal(bytes, &contents)
+	if err != nil {
+		return fmt.Errorf("Unable to parse JSON at %s: %v", index_url, err)
+	}
+
+	// Assuming everything worked, merge the next information into the existing
+	// index.
+	// N.B. - The entries in contents will be appended to the index.  So a search
+	// for the first matching entry will always favor the original index over the
+	// entries just parsed.  This is by design.
+	err = index.Merge(contents)
+	if err != nil {
+		return fmt.Errorf("Error merging indices: %v", err)
+	}
+
+	return nil
+}
+
+// The Fetch function returns the raw contents of the index found at the
+// given URL (which may be a file:// URL).
+func Fetch(index_url string) ([]byte, error) {
 	// Parse the URL to break it down
 	u, err := url.Parse(index_url)
 	if err != nil {
-		return fmt.Errorf("Error parsing url: %v", err)
+		return nil, fmt.Errorf("Error parsing url: %v", err)
 	}
 
 	var bytes []byte
@@ -30,13 +60,13 @@ func (index *Index) ParseIndex(index_url string) error {
 		// If it is a file, open the file...
 		f, err := os.Open(u.Path)
 		if err != nil {
-			return fmt.Errorf("Unable to open file '%s': %v", u.Path, err)
+			return nil, fmt.Errorf("Unable to open file '%s': %v", u.Path, err)
 		}
 
 		// ...and read the contents
 		data, err := ioutil.ReadAll(f)
 		if err != nil {
-			return fmt.Errorf("Unable to read file '%s': %v", u.Path, err)
+			return nil, fmt.Errorf("Unable to read file '%s': %v", u.Path, err)
 		}
 
 		bytes = data
@@ -45,37 +75,18 @@ func (index *Index) ParseIndex(index_url string) error {
 	case "https":
 		resp, err := http.Get(index_url)
 		if err != nil {
-			return fmt.Errorf("Error for GET %s: %v", index_url, err)
+			return nil, fmt.Errorf("Error for GET %s: %v", index_url, err)
 		}
 		defer resp.Body.Close()
 		body, err := ioutil.ReadAll(resp.Body)
 		if err != nil {
-			return fmt.Errorf("Unable to read body of response from GET %s: %v", index_url, err)
+			return nil, fmt.Errorf("Unable to read body of response from GET %s: %v", index_url, err)
 		}
 		bytes = body
 	default:
 		// If we don't suppor the scheme, throw an error
-		return fmt.Errorf("Unsupported URL scheme '%s', unable to download", u.Scheme)
-	}
-
-	// Now create an empty index to read the bytes into
-	contents := Index{}
-
-	// Unmarshal the bytes as JSON into the new empty index
-	err = json.Unmarshal(bytes, &contents)
-	if err != nil {
-		return fmt.Errorf("Unable to parse JSON at %s: %v", index_url, err)
+		return nil, fmt.Errorf("Unsupported URL scheme '%s', unable to download", u.Scheme)
 	}
 
-	// Assuming everything worked, merge the next information into the existing
-	// index.
-	// N.B. - The entries in contents will be appended to the index.  So a search
-	// for the first matching entry will always favor the original index over the
-	// entries just parsed.  This is by design.
-	err = index.Merge(contents)
-	if err != nil {
-		return fmt.Errorf("Error merging indices: %v", err)
-	}
-
-	return nil
+	return bytes, nil
 }
@@ -0,0 +1,86 @@
+package index
+
+import (
+	"time"
+
+	"github.com/impact/impact/parsing"
+	"github.com/impact/impact/recorder"
+)
+
+// The Replay method records every library (and version) found at the
+// given URI in this index with the given recorder.  It returns false if
+// this index doesn't contain any library found at that URI.  This allows
+// an index to be used as the baseline for a crawl.
+func (i Index) Replay(uri string, r recorder.Recorder) bool {
+	found := false
+	for _, lib := range i.Libraries {
+		if lib.URI != uri {
+			continue
+		}
+		found = true
+
+		lr := r.GetLibrary(lib.Name, lib.URI, lib.OwnerURI)
+		if lib.Stars >= 0 {
+			lr.SetStars(lib.Stars)
+		}
+		lr.SetForks(lib.Forks)
+		lr.SetOpenIssues(lib.OpenIssues)
+		if lib.Description != "" {
+			lr.SetDescription(lib.Description)
+		}
+		lr.SetHomepage(lib.Homepage)
+		if lib.Repository != "" {
+			lr.SetRepository(lib.Repository, lib.Format)
+		}
+		lr.SetEmail(lib.Email)
+		lr.SetMaintainers(lib.Maintainers)
+		lr.SetLicense(lib.License)
+
+		for _, entry := range orderVersions(lib.Versions) {
+			details := entry.details
+			vr := lr.AddVersion(details.Version)
+			vr.SetPath(details.Path, details.IsFile)
+			vr.SetHash(details.Sha)
+			if details.ReleaseDate != "" {
+				date, err := time.Parse(time.RFC3339, details.ReleaseDate)
+				if err == nil {
+					vr.SetReleaseDate(date)
+				}
+			}
+			vr.SetTarballURL(details.Tarball)
+			for _, url := range details.TarballURLs() {
+				if url != details.Tarball {
+					vr.AddMirror(url)
+				}
+			}
+			vr.SetZipballURL(details.Zipball)
+			for algo, hex := range details.Checksums {
+				vr.SetArchiveChecksum(algo, hex)
+			}
+			if details.Size > 0 {
+				vr.SetSize(details.Size)
+			}
+			vr.SetVerified(details.Verified)
+			if details.ReleaseNotes != "" {
+				vr.SetReleaseNotes(details.ReleaseNotes)
+			}
+			if details.TagName != "" {
+				vr.SetTagName(details.TagName)
+			}
+			vr.SetModelicaCompat(details.ModelicaCompat)
+			if details.BuildStatus != "" {
+				vr.SetBuildStatus(details.BuildStatus, details.BuildDetails)
+			}
+			if details.Deprecated {
+				recorder.Deprecate(vr, details.DeprecationReason)
+			}
+			for _, dep := range details.Dependencies {
+				v, err := parsing.NormalizeVersion(dep.Version)
+				if err == nil {
+					vr.AddDependency(dep.Name, v)
+				}
+			}
+		}
+	}
+	return found
+}
@@ -0,0 +1,69 @@
+package index
+
+import (
+	"testing"
+	"time"
+
+	"github.com/blang/semver"
+
+	"github.com/impact/impact/recorder"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+)
+
+func TestReplay(t *testing.T) {
+	Convey("Testing replaying an index into a recorder", t, func(c C) {
+		date := time.Date(2016, 3, 1, 12, 0, 0, 0, time.UTC)
+		ind := buildIndex([]string{"1.0.0", "1.1.0"}, []string{"Modelica"})
+		ind.Libraries[0].SetDescription("A library")
+		ind.Libraries[0].SetForks(4)
+		ind.Libraries[0].SetOpenIssues(2)
+		ind.Libraries[0].SetMaintainers([]recorder.Maintainer{{Name: "Jane Doe"}})
+		ind.Libraries[0].Versions["1.1.0"].SetReleaseDate(date)
+		ind.Libraries[0].Versions["1.1.0"].SetModelicaCompat("4.0.0")
+		ind.Libraries[0].Versions["1.1.0"].SetArchiveChecksum(recorder.ChecksumSHA256, "0123")
+		ind.Libraries[0].Versions["1.1.0"].SetBuildStatus(recorder.BuildFailing, "Unable to load Foo")
+		ind.Libraries[0].Versions["1.1.0"].SetTarballURL("https://a.example.com/Foo.tar.gz")
+		ind.Libraries[0].Versions["1.1.0"].AddMirror("https://b.example.com/Foo.tar.gz")
+		ind.Libraries[0].Versions["1.1.0"].SetSize(2048)
+		ind.Libraries[0].Versions["1.1.0"].SetVerified(true)
+		ind.Libraries[0].Versions["1.1.0"].SetReleaseNotes("Fixed everything")
+		ind.Libraries[0].Versions["1.1.0"].SetTagName("v1.1")
+
+		m := recorder.NewMemoryRecorder()
+		IsTrue(c, !ind.Replay("https://github.com/a/Other", m))
+		Equals(c, len(m.Libraries), 0)
+
+		IsTrue(c, ind.Replay("https://github.com/a/Foo", m))
+		foo := m.Find("Foo")
+		NotNil(c, foo)
+		Equals(c, foo.OwnerURI, "https://github.com/a")
+		Equals(c, foo.Stars, 3)
+		Equals(c, foo.Forks, 4)
+		Equals(c, foo.OpenIssues, 2)
+		Equals(c, foo.Description, "A library")
+		Resembles(c, foo.Maintainers, []recorder.Maintainer{{Name: "Jane Doe"}})
+		Equals(c, len(foo.Versions), 2)
+		v := foo.Versions["1.1.0"]
+		IsTrue(c, v.ReleaseDate.Equal(date))
+		Equals(c, v.ModelicaCompat, "4.0.0")
+		Equals(c, foo.Versions["1.0.0"].ModelicaCompat, "")
+		Resembles(c, v.Checksums, map[string]string{recorder.ChecksumSHA256: "0123"})
+		IsTrue(c, foo.Versions["1.0.0"].Checksums == nil)
+		Equals(c, v.BuildStatus, recorder.BuildFailing)
+		Equals(c, v.BuildDetails, "Unable to load Foo")
+		Equals(c, foo.Versions["1.0.0"].BuildStatus, "")
+		Equals(c, v.TarballURL, "https://a.example.com/Foo.tar.gz")
+		Resembles(c, v.Mirrors, []string{"https://b.example.com/Foo.tar.gz"})
+		IsTrue(c, foo.Versions["1.0.0"].Mirrors == nil)
+		Equals(c, v.Size, int64(2048))
+		Equals(c, foo.Versions["1.0.0"].Size, int64(0))
+		IsTrue(c, v.Verified)
+		IsFalse(c, foo.Versions["1.0.0"].Verified)
+		Equals(c, v.ReleaseNotes, "Fixed everything")
+		Equals(c, v.TagName, "v1.1")
+		Equals(c, len(v.Dependencies), 1)
+		IsTrue(c, v.Dependencies[0].Version.EQ(semver.MustParse("1.0.0")))
+	})
+}
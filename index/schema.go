@@ -0,0 +1,377 @@
+package index
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/blang/semver"
+
+	"github.com/impact/impact/recorder"
+)
+
+// A SchemaViolation is a place where an index (in JSON form) doesn't
+// conform to the index format the impact client expects.  The path
+// identifies the offending value (e.g., $.libraries[2].versions["1.0.0"].sha).
+type SchemaViolation struct {
+	Path    string
+	Message string
+}
+
+func (v SchemaViolation) String() string {
+	return fmt.Sprintf("%s: %s", v.Path, v.Message)
+}
+
+// The kinds of JSON value a field can have
+type valueKind int
+
+const (
+	kindString valueKind = iota
+	kindBool
+	kindInt
+	kindArray
+	kindObject
+)
+
+func (k valueKind) String() string {
+	switch k {
+	case kindString:
+		return "a string"
+	case kindBool:
+		return "a boolean"
+	case kindInt:
+		return "an integer"
+	case kindArray:
+		return "an array"
+	default:
+		return "an object"
+	}
+}
+
+// The fieldSchema type describes a single field of an object in the
+// index.  If check is not nil, it is called (with a value of the right
+// kind) to check any further constraints and returns a description of
+// the problem (or the empty string if there is none).
+type fieldSchema struct {
+	kind     valueKind
+	required bool
+	check    func(value interface{}) string
+}
+
+type objectSchema map[string]fieldSchema
+
+func nonEmpty(value interface{}) string {
+	if value.(string) == "" {
+		return "must not be empty"
+	}
+	return ""
+}
+
+func semanticVersion(value interface{}) string {
+	_, err := semver.Parse(value.(string))
+	if err != nil {
+		return fmt.Sprintf("'%s' is not a semantic version", value)
+	}
+	return ""
+}
+
+// This function accepts the empty string (i.e., no URL) or an absolute
+// URL.
+func optionalURL(value interface{}) string {
+	str := value.(string)
+	if str == "" {
+		return ""
+	}
+	u, err := url.Parse(str)
+	if err != nil || u.Scheme == "" || (u.Host == "" && u.Scheme != "file") {
+		return fmt.Sprintf("'%s' is not an absolute URL", str)
+	}
+	return ""
+}
+
+func requiredURL(value interface{}) string {
+	if value.(string) == "" {
+		return "must not be empty"
+	}
+	return optionalURL(value)
+}
+
+// This function checks that each entry of a list is an absolute URL.
+func urlList(value interface{}) string {
+	for n, entry := range value.([]interface{}) {
+		str, ok := entry.(string)
+		if !ok || str == "" {
+			return fmt.Sprintf("entry %d must be a non-empty string", n)
+		}
+		if msg := optionalURL(str); msg != "" {
+			return fmt.Sprintf("entry %d: %s", n, msg)
+		}
+	}
+	return ""
+}
+
+func releaseDate(value interface{}) string {
+	_, err := time.Parse(time.RFC3339, value.(string))
+	if err != nil {
+		return fmt.Sprintf("'%s' is not an RFC3339 date", value)
+	}
+	return ""
+}
+
+func buildStatus(value interface{}) string {
+	switch value.(string) {
+	case recorder.BuildPassing, recorder.BuildFailing, recorder.BuildUnknown:
+		return ""
+	}
+	return fmt.Sprintf("'%s' is not one of %s, %s or %s", value,
+		recorder.BuildPassing, recorder.BuildFailing, recorder.BuildUnknown)
+}
+
+// This function checks that each checksum is a (non-empty) hex string.
+func checksums(value interface{}) string {
+	obj := value.(map[string]interface{})
+	for _, algo := range sortedKeys(obj) {
+		sum, ok := obj[algo].(string)
+		if !ok || sum == "" {
+			return fmt.Sprintf("the %s checksum must be a non-empty string", algo)
+		}
+		if _, err := hex.DecodeString(sum); err != nil {
+			return fmt.Sprintf("the %s checksum '%s' is not hexadecimal", algo, sum)
+		}
+	}
+	return ""
+}
+
+func atLeast(min int64) func(value interface{}) string {
+	return func(value interface{}) string {
+		if value.(int64) < min {
+			return fmt.Sprintf("%d is less than %d", value, min)
+		}
+		return ""
+	}
+}
+
+var indexSchema = objectSchema{
+	"version":           {kind: kindString, required: true, check: semanticVersion},
+	"generated_at":      {kind: kindString, check: releaseDate},
+	"generator_version": {kind: kindString},
+	"libraries":         {kind: kindArray, required: true},
+}
+
+var librarySchema = objectSchema{
+	"name":              {kind: kindString, required: true, check: nonEmpty},
+	"uri":               {kind: kindString, required: true, check: requiredURL},
+	"versions":          {kind: kindObject, required: true},
+	"owner_uri":         {kind: kindString, check: optionalURL},
+	"email":             {kind: kindString},
+	"maintainers":       {kind: kindArray},
+	"homepage":          {kind: kindString, check: optionalURL},
+	"repository_uri":    {kind: kindString, check: optionalURL},
+	"repository_format": {kind: kindString},
+	"description":       {kind: kindString},
+	// Stars are -1 if not applicable
+	"stars":       {kind: kindInt, check: atLeast(-1)},
+	"forks":       {kind: kindInt, check: atLeast(0)},
+	"open_issues": {kind: kindInt, check: atLeast(0)},
+	"license":     {kind: kindString},
+}
+
+var maintainerSchema = objectSchema{
+	"name":  {kind: kindString},
+	"email": {kind: kindString},
+}
+
+var versionSchema = objectSchema{
+	"version":            {kind: kindString, required: true, check: semanticVersion},
+	"tarball_url":        {kind: kindString, required: true, check: optionalURL},
+	"zipball_url":        {kind: kindString, required: true, check: optionalURL},
+	"tarball_urls":       {kind: kindArray, check: urlList},
+	"checksums":          {kind: kindObject, check: checksums},
+	"size":               {kind: kindInt, check: atLeast(0)},
+	"path":               {kind: kindString, required: true},
+	"isfile":             {kind: kindBool},
+	"dependencies":       {kind: kindArray, required: true},
+	"sha":                {kind: kindString},
+	"release_date":       {kind: kindString, check: releaseDate},
+	"verified":           {kind: kindBool},
+	"release_notes":      {kind: kindString},
+	"tag":                {kind: kindString},
+	"modelica_version":   {kind: kindString, check: semanticVersion},
+	"build_status":       {kind: kindString, check: buildStatus},
+	"build_details":      {kind: kindString},
+	"deprecated":         {kind: kindBool},
+	"deprecation_reason": {kind: kindString},
+}
+
+var dependencySchema = objectSchema{
+	"name":    {kind: kindString, required: true, check: nonEmpty},
+	"version": {kind: kindString, required: true, check: semanticVersion},
+}
+
+// The schemaChecker type collects the violations found while checking an
+// index.
+type schemaChecker struct {
+	violations []SchemaViolation
+}
+
+func (s *schemaChecker) report(path string, format string, args ...interface{}) {
+	s.violations = append(s.violations, SchemaViolation{
+		Path:    path,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// This function returns the keys of the given object in order.
+func sortedKeys(obj map[string]interface{}) []string {
+	keys := []string{}
+	for k := range obj {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// This function returns the given value converted to the given kind (and
+// whether it was of that kind).
+func asKind(value interface{}, kind valueKind) (interface{}, bool) {
+	switch kind {
+	case kindString:
+		v, ok := value.(string)
+		return v, ok
+	case kindBool:
+		v, ok := value.(bool)
+		return v, ok
+	case kindInt:
+		n, ok := value.(json.Number)
+		if !ok {
+			return nil, false
+		}
+		v, err := n.Int64()
+		return v, err == nil
+	case kindArray:
+		v, ok := value.([]interface{})
+		return v, ok
+	default:
+		v, ok := value.(map[string]interface{})
+		return v, ok
+	}
+}
+
+// This function checks the fields of an object against the given schema.
+// It returns the object (or nil if the value isn't an object).  Fields
+// that aren't in the schema are ignored since the client ignores them as
+// well.
+func (s *schemaChecker) object(path string, value interface{}, schema objectSchema) map[string]interface{} {
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		s.report(path, "must be an object")
+		return nil
+	}
+
+	names := []string{}
+	for name := range schema {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		field := schema[name]
+		fpath := path + "." + name
+		raw, present := obj[name]
+		if !present || raw == nil {
+			if field.required {
+				s.report(fpath, "is required")
+			}
+			continue
+		}
+		v, ok := asKind(raw, field.kind)
+		if !ok {
+			s.report(fpath, "must be %s", field.kind)
+			continue
+		}
+		if field.check != nil {
+			problem := field.check(v)
+			if problem != "" {
+				s.report(fpath, "%s", problem)
+			}
+		}
+	}
+	return obj
+}
+
+func (s *schemaChecker) index(value interface{}) {
+	obj := s.object("$", value, indexSchema)
+	if obj == nil {
+		return
+	}
+	libs, _ := obj["libraries"].([]interface{})
+	for n, lib := range libs {
+		s.library(fmt.Sprintf("$.libraries[%d]", n), lib)
+	}
+}
+
+func (s *schemaChecker) library(path string, value interface{}) {
+	obj := s.object(path, value, librarySchema)
+	if obj == nil {
+		return
+	}
+	maintainers, _ := obj["maintainers"].([]interface{})
+	for n, m := range maintainers {
+		s.object(fmt.Sprintf("%s.maintainers[%d]", path, n), m, maintainerSchema)
+	}
+	versions, _ := obj["versions"].(map[string]interface{})
+	for _, key := range sortedKeys(versions) {
+		s.version(fmt.Sprintf("%s.versions[%q]", path, key), key, versions[key])
+	}
+}
+
+func (s *schemaChecker) version(path string, key string, value interface{}) {
+	obj := s.object(path, value, versionSchema)
+	if obj == nil {
+		return
+	}
+	if v, ok := obj["version"].(string); ok && v != key {
+		s.report(path+".version", "'%s' doesn't match the key '%s'", v, key)
+	}
+	deps, _ := obj["dependencies"].([]interface{})
+	for n, dep := range deps {
+		s.object(fmt.Sprintf("%s.dependencies[%d]", path, n), dep, dependencySchema)
+	}
+}
+
+// The CheckSchema function checks that the given index (in JSON form)
+// conforms to the index format: that all required fields of the index,
+// each library and each version are present, that all fields have the
+// right types and that versions, URLs and dates are well formed.  It
+// returns all the violations found.  An error is only returned if the
+// data isn't JSON at all.
+func CheckSchema(data []byte) ([]SchemaViolation, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// Keep numbers as they are so that integers can be told apart
+	dec.UseNumber()
+
+	var value interface{}
+	err := dec.Decode(&value)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to parse index: %v", err)
+	}
+
+	s := schemaChecker{violations: []SchemaViolation{}}
+	s.index(value)
+	return s.violations, nil
+}
+
+// The CheckSchema method checks that this index (as it would be
+// serialized) conforms to the index format (see the CheckSchema
+// function).
+func (i Index) CheckSchema() ([]SchemaViolation, error) {
+	str, err := i.JSON()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to serialize index: %v", err)
+	}
+	return CheckSchema([]byte(str))
+}
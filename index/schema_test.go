@@ -0,0 +1,83 @@
+package index
+
+import (
+	"testing"
+
+	"github.com/blang/semver"
+
+	"github.com/impact/impact/recorder"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+)
+
+func TestSchema(t *testing.T) {
+	Convey("Testing schema validation of an index", t, func(c C) {
+		ind := buildIndex([]string{"1.0.0", "1.1.0"}, []string{"Modelica"})
+		v := ind.Libraries[0].Versions["1.0.0"]
+		v.SetTarballURL("https://github.com/a/Foo/archive/v1.0.0.tar.gz")
+		v.SetBuildStatus("passing", "")
+		v.SetArchiveChecksum(recorder.ChecksumSHA256, "0123abcd")
+		violations, err := ind.CheckSchema()
+		NoError(c, err)
+		Equals(c, len(violations), 0)
+
+		// Problems introduced after the index was recorded are found
+		v.SetTarballURL("archive/v1.0.0.tar.gz")
+		v.SetBuildStatus("broken", "")
+		v.AddDependency("Bar", semver.MustParse("1.0.0"))
+		v.Dependencies[len(v.Dependencies)-1].Version = "1.0"
+		violations, err = ind.CheckSchema()
+		NoError(c, err)
+		Equals(c, len(violations), 3)
+		Equals(c, violations[0].String(),
+			`$.libraries[0].versions["1.0.0"].build_status: 'broken' is not one of passing, failing or unknown`)
+		Equals(c, violations[1].Path, `$.libraries[0].versions["1.0.0"].tarball_url`)
+		// Dependencies are serialized in order (so Bar comes first)
+		Equals(c, violations[2].Path, `$.libraries[0].versions["1.0.0"].dependencies[0].version`)
+
+		data := `{
+  "version": "1.0.0",
+  "libraries": [
+    {"uri": "https://github.com/a/Foo", "stars": 1.5, "versions": {
+      "1.0.0": {"version": "1.0.1", "tarball_url": "", "zipball_url": "", "path": "Foo",
+                "dependencies": [{"name": "Modelica"}], "isfile": "no"}
+    }},
+    {"name": "Bar", "uri": "https://github.com/a/Bar", "versions": {
+      "2.0.0": {"version": "2.0.0", "checksums": {"sha256": "xyz"}, "size": -1,
+                "verified": "yes", "tarball_urls": ["https://a.example.com/Bar.tar.gz", "mirror/Bar.tar.gz"]}
+    }},
+    "Baz"
+  ]
+}`
+		violations, err = CheckSchema([]byte(data))
+		NoError(c, err)
+		Resembles(c, violationStrings(violations), []string{
+			`$.libraries[0].name: is required`,
+			`$.libraries[0].stars: must be an integer`,
+			`$.libraries[0].versions["1.0.0"].isfile: must be a boolean`,
+			`$.libraries[0].versions["1.0.0"].version: '1.0.1' doesn't match the key '1.0.0'`,
+			`$.libraries[0].versions["1.0.0"].dependencies[0].version: is required`,
+			`$.libraries[1].versions["2.0.0"].checksums: the sha256 checksum 'xyz' is not hexadecimal`,
+			`$.libraries[1].versions["2.0.0"].dependencies: is required`,
+			`$.libraries[1].versions["2.0.0"].path: is required`,
+			`$.libraries[1].versions["2.0.0"].size: -1 is less than 0`,
+			`$.libraries[1].versions["2.0.0"].tarball_url: is required`,
+			`$.libraries[1].versions["2.0.0"].tarball_urls: entry 1: 'mirror/Bar.tar.gz' is not an absolute URL`,
+			`$.libraries[1].versions["2.0.0"].verified: must be a boolean`,
+			`$.libraries[1].versions["2.0.0"].zipball_url: is required`,
+			`$.libraries[2]: must be an object`,
+		})
+
+		_, err = CheckSchema([]byte("not json"))
+		IsError(c, err)
+	})
+}
+
+func violationStrings(violations []SchemaViolation) []string {
+	ret := []string{}
+	for _, v := range violations {
+		ret = append(ret, v.String())
+	}
+	return ret
+}
@@ -0,0 +1,76 @@
+package index
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/impact/impact/recorder"
+)
+
+// The StreamRecorder writes each library as a single line of JSON (i.e.,
+// as JSON Lines) as soon as the repository it was found in has been
+// completely processed (see recorder.Finisher).  Only libraries that are
+// still being recorded are kept in memory, so this is suitable for very
+// large indices.  Libraries are written in the order they are finished
+// in.  Like Index, this is not safe to use from multiple goroutines on
+// its own (see recorder.Synchronized).
+type StreamRecorder struct {
+	enc     *json.Encoder
+	pending []*Library
+	// The first error encountered while writing (if any)
+	err error
+}
+
+func NewStreamRecorder(w io.Writer) *StreamRecorder {
+	return &StreamRecorder{
+		enc:     json.NewEncoder(w),
+		pending: []*Library{},
+	}
+}
+
+func (s *StreamRecorder) GetLibrary(name string, uri string, owner_uri string) recorder.LibraryRecorder {
+	for _, lib := range s.pending {
+		if lib.OwnerURI == owner_uri && lib.Name == name {
+			return lib
+		}
+	}
+	lib := NewLibrary(name, uri, owner_uri)
+	s.pending = append(s.pending, lib)
+	return lib
+}
+
+// The Finish method writes every library found at the given URI.
+func (s *StreamRecorder) Finish(uri string) {
+	remaining := []*Library{}
+	for _, lib := range s.pending {
+		if lib.URI == uri {
+			s.write(lib)
+		} else {
+			remaining = append(remaining, lib)
+		}
+	}
+	s.pending = remaining
+}
+
+func (s *StreamRecorder) write(lib *Library) {
+	if s.err != nil {
+		return
+	}
+	err := s.enc.Encode(lib)
+	if err != nil {
+		s.err = fmt.Errorf("Error writing library %s: %v", lib.Name, err)
+	}
+}
+
+// The Close method writes any libraries that haven't been written yet.
+// It returns the first error encountered while writing (if any).
+func (s *StreamRecorder) Close() error {
+	for _, lib := range s.pending {
+		s.write(lib)
+	}
+	s.pending = []*Library{}
+	return s.err
+}
+
+var _ recorder.Finisher = (*StreamRecorder)(nil)
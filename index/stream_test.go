@@ -0,0 +1,46 @@
+package index
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/blang/semver"
+
+	"github.com/impact/impact/recorder"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+)
+
+func TestStreamRecorder(t *testing.T) {
+	Convey("Testing streaming of libraries as JSON Lines", t, func(c C) {
+		buf := bytes.Buffer{}
+		sr := NewStreamRecorder(&buf)
+		var r recorder.Recorder = recorder.Synchronized(sr)
+
+		foo := r.GetLibrary("Foo", "https://github.com/a/Foo", "https://github.com/a")
+		foo.AddVersion(semver.MustParse("1.0.0"))
+		bar := r.GetLibrary("Bar", "https://github.com/a/Bar", "https://github.com/a")
+		bar.AddVersion(semver.MustParse("2.0.0"))
+
+		// Nothing is written until a repository is finished
+		Equals(c, buf.Len(), 0)
+		recorder.Finish(r, "https://github.com/a/Bar")
+		Equals(c, strings.Count(buf.String(), "\n"), 1)
+		IsTrue(c, strings.HasPrefix(buf.String(), `{"name":"Bar"`))
+
+		// Versions added to the same library are kept together
+		r.GetLibrary("Foo", "https://github.com/a/Foo", "https://github.com/a").
+			AddVersion(semver.MustParse("1.1.0"))
+		NoError(c, sr.Close())
+
+		lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+		Equals(c, len(lines), 2)
+		lib := Library{}
+		NoError(c, json.Unmarshal([]byte(lines[1]), &lib))
+		Equals(c, lib.Name, "Foo")
+		Equals(c, len(lib.Versions), 2)
+	})
+}
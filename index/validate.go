@@ -0,0 +1,292 @@
+package index
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/blang/semver"
+
+	"github.com/impact/impact/parsing"
+)
+
+// The Severity of a validation issue
+type Severity int
+
+const (
+	// Something that is suspicious, but doesn't make the index unusable
+	SeverityWarning Severity = iota
+	// Something that makes (part of) the index unusable.  Entries with
+	// errors are left out of the fixed index returned by Validate.
+	SeverityError
+)
+
+func (s Severity) String() string {
+	if s == SeverityError {
+		return "error"
+	}
+	return "warning"
+}
+
+// An Issue is a problem found while validating an index.
+type Issue struct {
+	Severity Severity
+	// Library (and version, if any) where the problem was found
+	Library string
+	Version string
+	Message string
+}
+
+func (i Issue) String() string {
+	if i.Version == "" {
+		return fmt.Sprintf("%s: %s: %s", i.Severity, i.Library, i.Message)
+	}
+	return fmt.Sprintf("%s: %s %s: %s", i.Severity, i.Library, i.Version, i.Message)
+}
+
+// The rawVersion type has the same fields as VersionDetails, but the
+// version is kept as a string so that invalid versions can be reported
+// (rather than preventing the whole index from being read).
+type rawVersion struct {
+	Version      string            `json:"version"`
+	Tarball      string            `json:"tarball_url"`
+	Zipball      string            `json:"zipball_url"`
+	Tarballs     []string          `json:"tarball_urls,omitempty"`
+	Checksums    map[string]string `json:"checksums,omitempty"`
+	Size         int64             `json:"size,omitempty"`
+	Path         string            `json:"path"`
+	IsFile       bool              `json:"isfile"`
+	Dependencies []Dependency      `json:"dependencies"`
+	Sha          string            `json:"sha"`
+	ReleaseDate  string            `json:"release_date,omitempty"`
+	Verified     bool              `json:"verified,omitempty"`
+	ReleaseNotes string            `json:"release_notes,omitempty"`
+	TagName      string            `json:"tag,omitempty"`
+	Modelica     string            `json:"modelica_version,omitempty"`
+	BuildStatus  string            `json:"build_status,omitempty"`
+	BuildDetails string            `json:"build_details,omitempty"`
+	Deprecated   bool              `json:"deprecated,omitempty"`
+	Reason       string            `json:"deprecation_reason,omitempty"`
+}
+
+type rawLibrary struct {
+	Library
+	Versions map[string]rawVersion `json:"versions"`
+}
+
+type rawIndex struct {
+	Version          string       `json:"version"`
+	GeneratedAt      string       `json:"generated_at"`
+	GeneratorVersion string       `json:"generator_version"`
+	Libraries        []rawLibrary `json:"libraries"`
+}
+
+// The HTTP client used to check that archive URLs exist
+var urlClient = &http.Client{Timeout: 30 * time.Second}
+
+// This function checks whether the given URL exists.  A URL that
+// doesn't exist is an error but if the check itself fails, that is only
+// a warning.
+func checkURL(url string) (Severity, string, bool) {
+	resp, err := urlClient.Head(url)
+	if err != nil {
+		return SeverityWarning, fmt.Sprintf("Unable to check %s: %v", url, err), false
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone {
+		return SeverityError, fmt.Sprintf("%s not found (%s)", url, resp.Status), false
+	}
+	return SeverityWarning, "", true
+}
+
+// The Validate function checks the given index (in JSON form) for
+// consistency.  It looks for duplicate libraries, versions (and
+// dependency versions) that aren't semantic versions and dependencies on
+// libraries or versions that aren't present anywhere in the index.  If
+// checkURLs is true, it also checks that the tarball and zipball URLs of
+// every version exist.  It returns all the issues found along with a
+// fixed index that leaves out any library or version with an error.  An
+// error is only returned if the JSON itself can't be read.
+func Validate(data []byte, checkURLs bool) (*Index, []Issue, error) {
+	raw := rawIndex{}
+	err := json.Unmarshal(data, &raw)
+	if err != nil {
+		return nil, nil, fmt.Errorf("Unable to parse index: %v", err)
+	}
+
+	issues := []Issue{}
+	report := func(sev Severity, lib string, version string, format string, args ...interface{}) {
+		issues = append(issues, Issue{
+			Severity: sev,
+			Library:  lib,
+			Version:  version,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	fixed := NewIndex()
+	if raw.Version != "" {
+		fixed.Version = raw.Version
+	}
+	fixed.GeneratedAt = raw.GeneratedAt
+	fixed.GeneratorVersion = raw.GeneratorVersion
+
+	// First, check each library (and version) on its own
+	uris := map[string][]string{}
+	for _, rlib := range raw.Libraries {
+		name := rlib.Name
+		if name == "" {
+			report(SeverityError, "<unnamed>", "", "Library has no name")
+			continue
+		}
+
+		duplicate := false
+		for _, uri := range uris[name] {
+			if uri == rlib.URI {
+				duplicate = true
+			}
+		}
+		if duplicate {
+			report(SeverityError, name, "", "Duplicate entry for library at %s", rlib.URI)
+			continue
+		}
+		if len(uris[name]) > 0 {
+			report(SeverityWarning, name, "", "Library at %s is also found at %s",
+				rlib.URI, uris[name][0])
+		}
+		uris[name] = append(uris[name], rlib.URI)
+
+		if len(rlib.Versions) == 0 {
+			report(SeverityWarning, name, "", "Library has no versions")
+		}
+
+		lib := rlib.Library
+		lib.Versions = map[string]*VersionDetails{}
+
+		keys := []string{}
+		for k := range rlib.Versions {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			rv := rlib.Versions[k]
+			vstr := rv.Version
+			if vstr == "" {
+				vstr = k
+			}
+			v, err := semver.Parse(vstr)
+			if err != nil {
+				nv, nerr := parsing.NormalizeVersion(vstr)
+				if nerr != nil {
+					report(SeverityError, name, k, "Invalid version '%s': %v", vstr, nerr)
+					continue
+				}
+				report(SeverityWarning, name, k, "Version '%s' is not a semantic version (using %s)",
+					vstr, nv.String())
+				v = nv
+			}
+			if k != v.String() {
+				report(SeverityWarning, name, k, "Version is listed as %s but is %s", k, v.String())
+			}
+
+			valid := true
+			for _, dep := range rv.Dependencies {
+				_, err := parsing.NormalizeVersion(dep.Version)
+				if err != nil {
+					report(SeverityError, name, k, "Invalid version '%s' of dependency %s: %v",
+						dep.Version, dep.Name, err)
+					valid = false
+				}
+			}
+			if checkURLs {
+				for _, url := range append([]string{rv.Tarball, rv.Zipball}, rv.Tarballs...) {
+					if url == "" {
+						continue
+					}
+					sev, msg, ok := checkURL(url)
+					if !ok {
+						report(sev, name, k, "%s", msg)
+						if sev == SeverityError {
+							valid = false
+						}
+					}
+				}
+			}
+			if !valid {
+				continue
+			}
+
+			details := NewVersionDetails(v)
+			details.Tarball = rv.Tarball
+			details.Zipball = rv.Zipball
+			details.Tarballs = rv.Tarballs
+			for algo, hex := range rv.Checksums {
+				details.SetArchiveChecksum(algo, hex)
+			}
+			details.SetSize(rv.Size)
+			details.SetPath(rv.Path, rv.IsFile)
+			details.SetHash(rv.Sha)
+			details.ReleaseDate = rv.ReleaseDate
+			details.SetVerified(rv.Verified)
+			details.SetReleaseNotes(rv.ReleaseNotes)
+			details.SetTagName(rv.TagName)
+			details.SetModelicaCompat(rv.Modelica)
+			details.SetBuildStatus(rv.BuildStatus, rv.BuildDetails)
+			if rv.Deprecated {
+				details.SetDeprecated(rv.Reason)
+			}
+			details.Dependencies = append(details.Dependencies, rv.Dependencies...)
+			lib.Versions[v.String()] = details
+		}
+
+		fixed.Libraries = append(fixed.Libraries, &lib)
+	}
+
+	// Then make sure every dependency refers to a version that is present.
+	// Removing a version may break other versions that depend on it, so
+	// keep going until nothing else is removed.
+	for {
+		present := map[string]bool{}
+		for _, lib := range fixed.Libraries {
+			for _, details := range lib.Versions {
+				present[lib.Name+" "+details.Version.String()] = true
+				present[lib.Name] = true
+			}
+		}
+
+		removed := false
+		for _, lib := range fixed.Libraries {
+			keys := []string{}
+			for k := range lib.Versions {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+
+			for _, k := range keys {
+				for _, dep := range lib.Versions[k].Dependencies {
+					// The version was already checked above
+					dv, _ := parsing.NormalizeVersion(dep.Version)
+					if !present[dep.Name] {
+						report(SeverityError, lib.Name, k, "Dependency on unknown library %s", dep.Name)
+					} else if !present[dep.Name+" "+dv.String()] {
+						report(SeverityError, lib.Name, k, "Dependency on unknown version %s of %s",
+							dep.Version, dep.Name)
+					} else {
+						continue
+					}
+					delete(lib.Versions, k)
+					removed = true
+					break
+				}
+			}
+		}
+		if !removed {
+			break
+		}
+	}
+
+	return fixed, issues, nil
+}
@@ -0,0 +1,85 @@
+package index
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+)
+
+func TestValidate(t *testing.T) {
+	Convey("Testing index validation", t, func(c C) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/missing.tar.gz" {
+				http.NotFound(w, r)
+			}
+		}))
+		defer server.Close()
+
+		data := fmt.Sprintf(`{
+  "version": "1.0.0",
+  "libraries": [
+    {"name": "Modelica", "uri": "https://github.com/modelica/Modelica", "versions": {
+      "3.2.2": {"version": "3.2.2", "tarball_url": "%s/ok.tar.gz", "dependencies": []},
+      "3.2": {"version": "3.2", "dependencies": []},
+      "bad": {"version": "bad", "dependencies": []}
+    }},
+    {"name": "Modelica", "uri": "https://github.com/modelica/Modelica", "versions": {}},
+    {"name": "Modelica", "uri": "https://github.com/fork/Modelica", "versions": {
+      "3.2.2": {"version": "3.2.2", "dependencies": []}
+    }},
+    {"name": "Foo", "uri": "https://github.com/a/Foo", "versions": {
+      "1.0.0": {"version": "1.0.0", "dependencies": [{"name": "Modelica", "version": "3.2.2"}]},
+      "1.1.0": {"version": "1.1.0", "dependencies": [{"name": "Modelica", "version": "4.0.0"}]},
+      "1.2.0": {"version": "1.2.0", "dependencies": [{"name": "Unknown", "version": "1.0.0"}]},
+      "1.3.0": {"version": "1.3.0", "tarball_url": "%s/missing.tar.gz", "dependencies": []}
+    }},
+    {"name": "Bar", "uri": "https://github.com/a/Bar", "versions": {
+      "1.0.0": {"version": "1.0.0", "dependencies": [{"name": "Foo", "version": "1.2.0"}]}
+    }}
+  ]
+}`, server.URL, server.URL)
+
+		fixed, issues, err := Validate([]byte(data), true)
+		NoError(c, err)
+
+		errors := map[string]string{}
+		warnings := 0
+		for _, issue := range issues {
+			if issue.Severity == SeverityError {
+				errors[issue.Library+" "+issue.Version] = issue.Message
+			} else {
+				warnings++
+			}
+		}
+		// "3.2" isn't a semantic version (or the right key) and one
+		// library is found in two places
+		Equals(c, warnings, 3)
+		Equals(c, len(errors), 6)
+		Equals(c, errors["Modelica "], "Duplicate entry for library at https://github.com/modelica/Modelica")
+		Equals(c, errors["Modelica bad"], "Invalid version 'bad': Unable to normalize version string 'bad'")
+		Equals(c, errors["Foo 1.1.0"], "Dependency on unknown version 4.0.0 of Modelica")
+		Equals(c, errors["Foo 1.2.0"], "Dependency on unknown library Unknown")
+		Equals(c, errors["Foo 1.3.0"], server.URL+"/missing.tar.gz not found (404 Not Found)")
+		// This is only a problem once Foo 1.2.0 is removed
+		Equals(c, errors["Bar 1.0.0"], "Dependency on unknown version 1.2.0 of Foo")
+
+		// Everything with an error is left out of the fixed index
+		Equals(c, len(fixed.Libraries), 4)
+		Equals(c, len(fixed.Libraries[0].Versions), 2)
+		foo := fixed.Libraries[2]
+		Equals(c, foo.Name, "Foo")
+		Equals(c, len(foo.Versions), 1)
+		Equals(c, len(fixed.Libraries[3].Versions), 0)
+
+		// The fixed index has no errors
+		_, err = fixed.JSON()
+		NoError(c, err)
+
+		_, _, err = Validate([]byte("not json"), false)
+		IsError(c, err)
+	})
+}
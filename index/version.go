@@ -1,8 +1,11 @@
 package index
 
 import (
+	"time"
+
 	"github.com/blang/semver"
 
+	"github.com/impact/impact/parsing"
 	"github.com/impact/impact/recorder"
 )
 
@@ -11,6 +14,17 @@ type VersionDetails struct {
 	Tarball string         `json:"tarball_url"`
 	Zipball string         `json:"zipball_url"`
 
+	// If the tarball has mirrors, every URL it can be downloaded from in
+	// priority order (the first being the tarball URL)
+	Tarballs []string `json:"tarball_urls,omitempty"`
+
+	// Checksums (in hex) of the tarball keyed by algorithm (e.g.,
+	// "sha256") so that the client can verify what it downloads
+	Checksums map[string]string `json:"checksums,omitempty"`
+
+	// The size of this version in bytes (if known)
+	Size int64 `json:"size,omitempty"`
+
 	// This indicates where (within an archive) the library can be found:
 	Path string `json:"path"`
 	// This indicates whether the specified path is to a file or directory:
@@ -18,6 +32,38 @@ type VersionDetails struct {
 
 	Dependencies []Dependency `json:"dependencies"`
 	Sha          string       `json:"sha"`
+
+	// When this version was released (in RFC3339 format, if known)
+	ReleaseDate string `json:"release_date,omitempty"`
+
+	// Whether the metadata of this version (impact.json) was signed by a
+	// trusted publisher
+	Verified bool `json:"verified,omitempty"`
+
+	// The release notes of this version (if they were recorded), e.g.,
+	// for a changelog
+	ReleaseNotes string `json:"release_notes,omitempty"`
+
+	// The name of the tag this version was found as (if any).  This may
+	// differ from the version itself (e.g., v1.2.03 for 1.2.3) and it is
+	// what the archives of this version are named after.
+	TagName string `json:"tag,omitempty"`
+
+	// The version of the Modelica Standard Library this version uses (if
+	// any).  This is also listed as a dependency but is kept separately
+	// so that versions can be filtered by compatibility.
+	ModelicaCompat string `json:"modelica_version,omitempty"`
+
+	// Whether this version builds (e.g., "passing" or "failing") along
+	// with any details.  This is recorded by CI after the crawl (see
+	// Annotate).
+	BuildStatus  string `json:"build_status,omitempty"`
+	BuildDetails string `json:"build_details,omitempty"`
+
+	// Whether this version has been deprecated (and why).  Deprecated
+	// versions are only installed if explicitly requested.
+	Deprecated        bool   `json:"deprecated,omitempty"`
+	DeprecationReason string `json:"deprecation_reason,omitempty"`
 }
 
 func NewVersionDetails(v semver.Version) *VersionDetails {
@@ -33,22 +79,112 @@ func (v *VersionDetails) SetHash(hash string) {
 
 func (v *VersionDetails) SetTarballURL(url string) {
 	v.Tarball = url
+	if len(v.Tarballs) > 0 {
+		v.Tarballs[0] = url
+	}
+}
+
+func (v *VersionDetails) AddMirror(url string) {
+	if len(v.Tarballs) == 0 {
+		v.Tarballs = []string{v.Tarball}
+	}
+	for _, existing := range v.Tarballs {
+		if existing == url {
+			return
+		}
+	}
+	v.Tarballs = append(v.Tarballs, url)
+}
+
+// The TarballURLs method returns every URL the tarball of this version
+// can be downloaded from, in the order they should be tried.
+func (v VersionDetails) TarballURLs() []string {
+	ret := []string{}
+	for _, url := range v.Tarballs {
+		if url != "" {
+			ret = append(ret, url)
+		}
+	}
+	if len(ret) == 0 && v.Tarball != "" {
+		ret = append(ret, v.Tarball)
+	}
+	return ret
 }
 
 func (v *VersionDetails) SetZipballURL(url string) {
 	v.Zipball = url
 }
 
+func (v *VersionDetails) SetArchiveChecksum(algo string, hex string) {
+	if v.Checksums == nil {
+		v.Checksums = map[string]string{}
+	}
+	v.Checksums[algo] = hex
+}
+
+func (v *VersionDetails) SetSize(bytes int64) {
+	v.Size = bytes
+}
+
+func (v *VersionDetails) SetVerified(verified bool) {
+	v.Verified = verified
+}
+
+func (v *VersionDetails) SetReleaseNotes(notes string) {
+	v.ReleaseNotes = notes
+}
+
+func (v *VersionDetails) SetTagName(tag string) {
+	v.TagName = tag
+}
+
+func (v *VersionDetails) SetReleaseDate(date time.Time) {
+	if date.IsZero() {
+		v.ReleaseDate = ""
+		return
+	}
+	v.ReleaseDate = date.UTC().Format(time.RFC3339)
+}
+
 func (v *VersionDetails) SetPath(path string, file bool) {
 	v.Path = path
 	v.IsFile = file
 }
 
+func (v *VersionDetails) SetModelicaCompat(version string) {
+	v.ModelicaCompat = version
+}
+
+func (v *VersionDetails) SetBuildStatus(status string, details string) {
+	v.BuildStatus = status
+	v.BuildDetails = details
+}
+
+func (v *VersionDetails) SetDeprecated(reason string) {
+	v.Deprecated = true
+	v.DeprecationReason = reason
+}
+
+// The AddDependency method records a dependency on the given version of
+// a library.  Each library is only listed once.  If there already is a
+// dependency on a library, the stricter version (i.e., the newer version
+// with the same major version) is kept.  If the major versions differ, the
+// existing dependency is kept.
 func (v *VersionDetails) AddDependency(library string, version semver.Version) {
+	for i, dep := range v.Dependencies {
+		if dep.Name != library {
+			continue
+		}
+		existing, err := parsing.NormalizeVersion(dep.Version)
+		if err != nil || (existing.Major == version.Major && version.GT(existing)) {
+			v.Dependencies[i].Version = version.String()
+		}
+		return
+	}
 	v.Dependencies = append(v.Dependencies, Dependency{
 		Name:    library,
 		Version: version.String(),
 	})
 }
 
-var _ recorder.VersionRecorder = (*VersionDetails)(nil)
+var _ recorder.VersionDeprecator = (*VersionDetails)(nil)
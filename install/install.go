@@ -1,88 +1,208 @@
 package install
 
 import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
 
-	"github.com/opesun/copyrecur"
-	"github.com/pierrre/archivefile/zip"
 	"github.com/wsxiaoys/terminal/color"
 
 	"github.com/impact/impact/index"
+	"github.com/impact/impact/recorder"
 )
 
+// The Install function downloads the tarball of the given version of a
+// library and extracts the library into the target directory (replacing
+// whatever was installed there before).  Libraries stored as a directory
+// are installed as a directory named after the library while libraries
+// stored as a single file are installed as that file.  If mirrors of the
+// tarball were recorded, they are tried in turn until one of them can be
+// downloaded (and, if its checksum was recorded, verified).
 func Install(libname string, ver index.VersionDetails, ind *index.Index,
 	target string, verbose bool) error {
-	/* Download the Zipball to a temporary file */
-	if verbose {
-		color.Println("  @{y}Downloading source from: @{!y}" + string(ver.Zipball))
+	urls := ver.TarballURLs()
+	if len(urls) == 0 {
+		return fmt.Errorf("No tarball recorded")
 	}
 
-	/*   Do a GET request */
-	resp, err := http.Get(ver.Zipball)
+	err := os.MkdirAll(target, 0755)
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close() // Make sure this gets closed
+	for _, url := range urls {
+		err = installTarball(libname, ver, url, target, verbose)
+		if err == nil {
+			return nil
+		}
+		if verbose {
+			color.Printf("  @{r}Unable to install from @{!r}%s@{r}: %v\n", url, err)
+		}
+	}
+	return err
+}
+
+// This function returns where (within the target directory) the given
+// version of a library is installed.
+func destination(libname string, details index.VersionDetails, target string) string {
+	if details.IsFile {
+		return filepath.Join(target, path.Base(details.Path))
+	}
+	return filepath.Join(target, libname)
+}
+
+// This function downloads the tarball at the given URL and extracts the
+// library into the target directory.  If the checksum of the tarball was
+// recorded, the download is verified before anything is replaced.
+func installTarball(libname string, details index.VersionDetails, url string, target string,
+	verbose bool) error {
+	if verbose {
+		color.Println("  @{y}Downloading source from: @{!y}" + url)
+	}
 
-	/*   Open a temporary file to direct the download into */
-	tzf, err := ioutil.TempFile("", "impact")
-	defer func() {
-		tzf.Close()           // Make sure we close this file and...
-		os.Remove(tzf.Name()) // ...delete it.
-	}()
-	/*   Copy the bytes to temporary file */
-	zsize, err := io.Copy(tzf, resp.Body)
+	resp, err := http.Get(url)
 	if err != nil {
 		return err
 	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
 
-	/* Create a temporary directory to extract into */
-	tdir, err := ioutil.TempDir("", "impact")
-	defer func() {
-		os.RemoveAll(string(tdir)) // Make sure this gets removed in case of a panic
-	}()
+	// Extract next to the destination so it can simply be renamed
+	tdir, err := ioutil.TempDir(target, ".impact")
 	if err != nil {
 		return err
 	}
+	defer os.RemoveAll(tdir)
 
-	/* Extract the zip file into our temporary directory */
-	var adir string = ""
-	err = zip.Unarchive(tzf, zsize, string(tdir), func(x string) {
-		if adir == "" {
-			adir = strings.Split(x, "/")[0]
-		}
-	})
+	h := sha256.New()
+	body := io.TeeReader(resp.Body, h)
+	dst := destination(libname, details, target)
+	extracted := filepath.Join(tdir, filepath.Base(dst))
+	err = extract(body, details.Path, details.IsFile, extracted)
+	if err != nil {
+		return err
+	}
+	// Whatever follows the library in the archive is still part of the
+	// checksum
+	_, err = io.Copy(ioutil.Discard, body)
 	if err != nil {
 		return err
 	}
 
-	/* Figure out where the Modelica code is in our temporary directory */
-	keep := path.Join(string(tdir), adir, ver.Path)
+	if expected, ok := details.Checksums[recorder.ChecksumSHA256]; ok {
+		sum := hex.EncodeToString(h.Sum(nil))
+		if !strings.EqualFold(sum, expected) {
+			return fmt.Errorf("Checksum of %s is %s (expected %s)", url, sum, expected)
+		}
+	}
 
-	/* Figure out whether we are dealing with a package stored as a file or diretory */
-	fi, err := os.Stat(keep)
+	if verbose {
+		color.Printf("  @{y}Copying  @{!y}%s@{y} to @{!y}%s\n", details.Path, dst)
+	}
+	err = os.RemoveAll(dst)
 	if err != nil {
 		return err
 	}
+	return os.Rename(extracted, dst)
+}
 
-	dst := path.Join(target, libname)
-	/* Copy the Modelica code to our target installation directory */
-	if fi.IsDir() {
-		if verbose {
-			color.Printf("  @{y}Copying  @{!y}%s@{y} to @{!y}%s\n", ver.Path, dst)
+// This function extracts the given path from a (gzipped) tarball to dst.
+// Paths are relative to the top-level directory of the archive (which
+// GitHub names after the repository and version).  If the path is a
+// file, that file is extracted as dst.  Otherwise, the contents of that
+// directory are extracted into dst.  Anything other than files and
+// directories (e.g., symbolic links) is skipped.
+func extract(r io.Reader, src string, isFile bool, dst string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("Unable to read archive: %v", err)
+	}
+	tr := tar.NewReader(gz)
+
+	src = path.Clean(src)
+	found := false
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
 		}
-		copyrecur.CopyDir(keep, path.Join(target, libname))
-	} else {
-		if verbose {
-			color.Printf("  @{y}Copying  @{!y}%s@{y} to @{!y}%s\n", ver.Path, dst)
+		if err != nil {
+			return fmt.Errorf("Unable to read archive: %v", err)
+		}
+
+		// Strip the top-level directory (entries outside of it, like the
+		// global header of archives created by git, are skipped)
+		parts := strings.SplitN(strings.TrimPrefix(hdr.Name, "./"), "/", 2)
+		if len(parts) < 2 {
+			continue
+		}
+		rel := path.Clean(parts[1])
+		if rel == ".." || strings.HasPrefix(rel, "../") {
+			continue
 		}
-		copyrecur.CopyFile(keep, path.Join(target, fi.Name()))
+
+		out := ""
+		switch {
+		case isFile:
+			if rel != src {
+				continue
+			}
+			out = dst
+		case src == ".":
+			out = filepath.Join(dst, filepath.FromSlash(rel))
+		case rel == src:
+			out = dst
+		case strings.HasPrefix(rel, src+"/"):
+			out = filepath.Join(dst, filepath.FromSlash(strings.TrimPrefix(rel, src+"/")))
+		default:
+			continue
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			err = os.MkdirAll(out, 0755)
+		case tar.TypeReg, tar.TypeRegA:
+			err = writeFile(out, tr)
+		default:
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		found = true
 	}
 
+	if !found {
+		return fmt.Errorf("No %s found in archive", src)
+	}
 	return nil
 }
+
+// This function writes everything read from r to the named file (creating
+// the directory it is in, if necessary).
+func writeFile(name string, r io.Reader) error {
+	err := os.MkdirAll(filepath.Dir(name), 0755)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	cerr := f.Close()
+	if err != nil {
+		return err
+	}
+	return cerr
+}
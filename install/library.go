@@ -0,0 +1,88 @@
+package install
+
+import (
+	"fmt"
+
+	"github.com/blang/semver"
+	"github.com/wsxiaoys/terminal/color"
+
+	"github.com/impact/impact/index"
+	"github.com/impact/impact/parsing"
+	"github.com/impact/impact/resolve"
+)
+
+// The Installed type describes a library version that was installed and
+// where (i.e., the directory or, for libraries stored as a single file,
+// the file it was installed as).
+type Installed struct {
+	Name    string
+	Version semver.Version
+	Path    string
+}
+
+// The SelectVersion function returns the version of the named library
+// that best matches the given constraint (e.g., "3.2" or ">=2.0 <3.0").
+// The version is chosen exactly as resolve.Highest chooses it (so an
+// empty constraint matches the newest release, pre-releases are only
+// chosen if the constraint mentions one and deprecated versions only if
+// includeDeprecated is true).
+func SelectVersion(ind *index.Index, libname string, constraint string,
+	includeDeprecated bool) (index.VersionDetails, error) {
+	v, err := resolve.Highest(ind, libname, constraint, includeDeprecated)
+	if err != nil {
+		return index.VersionDetails{}, err
+	}
+	return ind.Find(libname, v)
+}
+
+// The InstallLibrary function installs the version of the named library
+// that best matches the given constraint (see SelectVersion) into the
+// target directory, along with the libraries it directly depends on.
+// Each dependency is installed in the version that best matches the
+// version depended on (see resolve.BestMatch) and each version is
+// installed as Install installs it.  Everything to install is selected before anything is downloaded.  The
+// libraries installed are returned (the library requested first).
+func InstallLibrary(ind *index.Index, libname string, constraint string, target string,
+	includeDeprecated bool, verbose bool) ([]Installed, error) {
+	details, err := SelectVersion(ind, libname, constraint, includeDeprecated)
+	if err != nil {
+		return nil, err
+	}
+
+	names := []string{libname}
+	selected := []index.VersionDetails{details}
+	for _, dep := range details.Dependencies {
+		if dep.Name == libname {
+			continue
+		}
+		dv, err := parsing.NormalizeVersion(dep.Version)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid version %s of dependency %s: %v", dep.Version, dep.Name, err)
+		}
+		v, found := resolve.BestMatch(ind, dep.Name, dv)
+		if !found {
+			return nil, fmt.Errorf("No version of %s compatible with %s (required by %s %s) found",
+				dep.Name, dv.String(), libname, details.Version.String())
+		}
+		dd, err := ind.Find(dep.Name, v)
+		if err != nil {
+			return nil, err
+		}
+		names = append(names, dep.Name)
+		selected = append(selected, dd)
+	}
+
+	ret := []Installed{}
+	for n, details := range selected {
+		if verbose {
+			color.Printf("  @{g}Library: @{!g}%s %s\n", names[n], details.Version.String())
+		}
+		err := Install(names[n], details, ind, target, verbose)
+		if err != nil {
+			return ret, fmt.Errorf("Error installing %s %s: %v", names[n], details.Version.String(), err)
+		}
+		ret = append(ret, Installed{Name: names[n], Version: details.Version,
+			Path: destination(names[n], details, target)})
+	}
+	return ret, nil
+}
@@ -0,0 +1,157 @@
+package install
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/blang/semver"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+
+	"github.com/impact/impact/index"
+	"github.com/impact/impact/recorder"
+)
+
+// This function returns a tarball (like the ones GitHub provides) with
+// the given files in a top-level directory
+func tarball(c C, top string, files map[string]string) []byte {
+	buf := bytes.Buffer{}
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	NoError(c, tw.WriteHeader(&tar.Header{Name: "pax_global_header", Typeflag: tar.TypeXGlobalHeader}))
+	NoError(c, tw.WriteHeader(&tar.Header{Name: top + "/", Typeflag: tar.TypeDir, Mode: 0755}))
+	for name, contents := range files {
+		NoError(c, tw.WriteHeader(&tar.Header{Name: top + "/" + name, Typeflag: tar.TypeReg,
+			Mode: 0644, Size: int64(len(contents))}))
+		_, err := tw.Write([]byte(contents))
+		NoError(c, err)
+	}
+	NoError(c, tw.Close())
+	NoError(c, gz.Close())
+	return buf.Bytes()
+}
+
+func TestInstallLibrary(t *testing.T) {
+	Convey("Testing installing libraries from an index", t, func(c C) {
+		archives := map[string][]byte{
+			"/a/Foo/2.0.0": tarball(c, "Foo-2.0.0", map[string]string{
+				"README.md":      "Foo",
+				"Foo/package.mo": "within;\npackage Foo\nend Foo;",
+				"Foo/Sub/A.mo":   "within Foo.Sub;\nmodel A\nend A;",
+			}),
+			"/b/Bar/1.0.0": tarball(c, "Bar-1.0.0", map[string]string{
+				"Bar.mo": "within;\npackage Bar\nend Bar;",
+			}),
+			"/mirror/b/Bar/2.0.0": tarball(c, "Bar-2.0.0", map[string]string{
+				"Bar.mo": "within;\npackage Bar \"2.0.0\"\nend Bar;",
+			}),
+		}
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			data, ok := archives[r.URL.Path]
+			if !ok {
+				http.NotFound(w, r)
+				return
+			}
+			w.Write(data)
+		}))
+		defer server.Close()
+
+		target, err := ioutil.TempDir("", "impact")
+		NoError(c, err)
+		defer os.RemoveAll(target)
+
+		ind := index.NewIndex()
+		foo := ind.GetLibrary("Foo", "https://github.com/a/Foo", "https://github.com/a")
+		for _, v := range []string{"1.0.0", "2.0.0", "3.0.0-rc1"} {
+			vr := foo.AddVersion(semver.MustParse(v))
+			vr.SetPath("Foo", false)
+			vr.SetTarballURL(server.URL + "/a/Foo/" + v)
+			vr.AddDependency("Bar", semver.MustParse("1.0.0"))
+		}
+		bar := ind.GetLibrary("Bar", "https://github.com/b/Bar", "https://github.com/b")
+		for _, v := range []string{"1.0.0", "2.0.0"} {
+			vr := bar.AddVersion(semver.MustParse(v))
+			vr.SetPath("Bar.mo", true)
+			vr.SetTarballURL(server.URL + "/b/Bar/" + v)
+		}
+		bar.(*index.Library).Versions["2.0.0"].AddMirror(server.URL + "/missing/b/Bar/2.0.0")
+		bar.(*index.Library).Versions["2.0.0"].AddMirror(server.URL + "/mirror/b/Bar/2.0.0")
+
+		// Pre-releases are only chosen if requested
+		details, err := SelectVersion(ind, "Foo", "", false)
+		NoError(c, err)
+		Equals(c, details.Version.String(), "2.0.0")
+		details, err = SelectVersion(ind, "Foo", ">=3.0.0-rc1", false)
+		NoError(c, err)
+		Equals(c, details.Version.String(), "3.0.0-rc1")
+		details, err = SelectVersion(ind, "Foo", "<2.0", false)
+		NoError(c, err)
+		Equals(c, details.Version.String(), "1.0.0")
+		_, err = SelectVersion(ind, "Foo", ">=4.0", false)
+		IsError(c, err)
+		_, err = SelectVersion(ind, "Missing", "", false)
+		IsError(c, err)
+
+		// Deprecated versions are only chosen if they are allowed (even if
+		// nothing else matches)
+		foo.(*index.Library).Versions["2.0.0"].Deprecated = true
+		details, err = SelectVersion(ind, "Foo", "", false)
+		NoError(c, err)
+		Equals(c, details.Version.String(), "1.0.0")
+		_, err = SelectVersion(ind, "Foo", ">=2.0 <3.0", false)
+		IsError(c, err)
+		details, err = SelectVersion(ind, "Foo", "", true)
+		NoError(c, err)
+		Equals(c, details.Version.String(), "2.0.0")
+		foo.(*index.Library).Versions["2.0.0"].Deprecated = false
+
+		// Directories are installed under the name of the library and
+		// single files as themselves
+		installed, err := InstallLibrary(ind, "Foo", "2", target, false, false)
+		NoError(c, err)
+		Equals(c, len(installed), 2)
+		Equals(c, installed[0].Path, filepath.Join(target, "Foo"))
+		Equals(c, installed[1].Name, "Bar")
+		Equals(c, installed[1].Version.String(), "1.0.0")
+		Equals(c, installed[1].Path, filepath.Join(target, "Bar.mo"))
+
+		data, err := ioutil.ReadFile(filepath.Join(target, "Foo", "Sub", "A.mo"))
+		NoError(c, err)
+		Equals(c, string(data), "within Foo.Sub;\nmodel A\nend A;")
+		_, err = os.Stat(filepath.Join(target, "Foo", "README.md"))
+		IsTrue(c, os.IsNotExist(err))
+		data, err = ioutil.ReadFile(filepath.Join(target, "Bar.mo"))
+		NoError(c, err)
+		Equals(c, string(data), "within;\npackage Bar\nend Bar;")
+		entries, err := ioutil.ReadDir(target)
+		NoError(c, err)
+		Equals(c, len(entries), 2)
+
+		// If a tarball can't be downloaded, its mirrors are tried in turn
+		installed, err = InstallLibrary(ind, "Bar", "2", target, false, false)
+		NoError(c, err)
+		Equals(c, len(installed), 1)
+		data, err = ioutil.ReadFile(filepath.Join(target, "Bar.mo"))
+		NoError(c, err)
+		Equals(c, string(data), "within;\npackage Bar \"2.0.0\"\nend Bar;")
+
+		// Archives that don't match their checksum aren't installed
+		bar.(*index.Library).Versions["1.0.0"].SetArchiveChecksum(recorder.ChecksumSHA256, "0123")
+		installed, err = InstallLibrary(ind, "Foo", "2", target, false, false)
+		IsError(c, err)
+		Equals(c, len(installed), 1)
+
+		// Neither are libraries whose dependencies can't be found
+		foo.AddVersion(semver.MustParse("2.1.0")).AddDependency("Baz", semver.MustParse("1.0.0"))
+		_, err = InstallLibrary(ind, "Foo", "2", target, false, false)
+		IsError(c, err)
+	})
+}
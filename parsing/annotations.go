@@ -17,6 +17,31 @@ func ParseUses(code string) (map[string]semver.Version, error) {
 	// Empty result to return on error
 	blank := map[string]semver.Version{}
 
+	specs, err := ParseUsesSpecs(code)
+	if err != nil {
+		return blank, err
+	}
+
+	ret := map[string]semver.Version{}
+	for libname, spec := range specs {
+		nv, err := NormalizeVersion(spec)
+		if err != nil {
+			return blank, fmt.Errorf("Unable to normalize version for %s: %v", libname, err)
+		}
+		ret[libname] = nv
+	}
+
+	return ret, nil
+}
+
+// This function is like ParseUses except that the version of each library
+// is returned exactly as it appears in the uses annotation (i.e., it is
+// not normalized).  This allows the caller to decide what to do about
+// versions that cannot be normalized.
+func ParseUsesSpecs(code string) (map[string]string, error) {
+	// Empty result to return on error
+	blank := map[string]string{}
+
 	// Compile regexp to identify version strings
 	ve, err := regexp.Compile(`version\s*=\s*"(.*)"`)
 	if err != nil {
@@ -43,7 +68,7 @@ func ParseUses(code string) (map[string]semver.Version, error) {
 	libs := strings.Split(rem, ")")
 
 	// Initialize return value
-	ret := map[string]semver.Version{}
+	ret := map[string]string{}
 
 	// Loop over all the split up chunks of text
 	for _, lib := range libs {
@@ -72,12 +97,120 @@ func ParseUses(code string) (map[string]semver.Version, error) {
 		}
 
 		// Record the (single) version we found
-		nv, err := NormalizeVersion(vers[0][1])
-		if err != nil {
-			return blank, fmt.Errorf("Unable to normalize version for %s: %v", libname, err)
-		}
-		ret[libname] = nv
+		ret[libname] = vers[0][1]
 	}
 
 	return ret, nil
 }
+
+// Annotations that may contain version modifiers that aren't the version
+// of the package itself
+var nestedVersions = regexp.MustCompile(`\b(uses|conversion)\s*\(`)
+
+// The version modifier of a package annotation
+var packageVersion = regexp.MustCompile(`\bversion\s*=\s*"([^"]*)"`)
+
+// This function parses a string that represents Modelica code and
+// extracts the version of the package (i.e., the version given in its
+// annotation).  Versions that appear in the uses and conversion
+// annotations are ignored.  The version is returned exactly as it
+// appears in the annotation (or as an empty string if there is no
+// version annotation).
+func ParseVersion(code string) string {
+	// Remove the uses and conversion annotations
+	for {
+		loc := nestedVersions.FindStringIndex(code)
+		if loc == nil {
+			break
+		}
+		end := closingParen(code, loc[1]-1)
+		code = code[:loc[0]] + code[end:]
+	}
+
+	m := packageVersion.FindStringSubmatch(code)
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
+
+// These match the start of an annotation (and of a uses annotation)
+var annotationStart = regexp.MustCompile(`\bannotation\s*\(`)
+var usesStart = regexp.MustCompile(`\buses\s*\(`)
+
+// This function returns true if the given Modelica code (which may only
+// be the start of a file) contains a complete annotation (i.e., one whose
+// parentheses are all closed) that includes a uses annotation.  The uses
+// and version annotations of a package are normally part of the same
+// annotation, so this indicates whether enough of a file has been read to
+// determine both.
+func HasUsesAnnotation(code string) bool {
+	code = withoutStrings(code)
+	for _, loc := range annotationStart.FindAllStringIndex(code, -1) {
+		end, closed := matchingParen(code, loc[1]-1)
+		if !closed {
+			return false
+		}
+		if usesStart.MatchString(code[loc[0]:end]) {
+			return true
+		}
+	}
+	return false
+}
+
+// This function returns the given Modelica code with the contents of all
+// strings removed (e.g., so that documentation isn't mistaken for code).
+func withoutStrings(code string) string {
+	buf := make([]byte, 0, len(code))
+	quoted := false
+	for i := 0; i < len(code); i++ {
+		switch {
+		case code[i] == '"':
+			quoted = !quoted
+			buf = append(buf, code[i])
+		case quoted && code[i] == '\\':
+			i++
+		case !quoted:
+			buf = append(buf, code[i])
+		}
+	}
+	return string(buf)
+}
+
+// This function returns the index just after the parenthesis that closes
+// the one found at the given index (ignoring any parentheses that appear
+// in strings).  If it is never closed, the length of the code is
+// returned.
+func closingParen(code string, open int) int {
+	end, _ := matchingParen(code, open)
+	return end
+}
+
+// This function is like closingParen except that it also indicates
+// whether the parenthesis is closed at all.
+func matchingParen(code string, open int) (int, bool) {
+	depth := 0
+	quoted := false
+	for i := open; i < len(code); i++ {
+		switch code[i] {
+		case '\\':
+			if quoted {
+				i++
+			}
+		case '"':
+			quoted = !quoted
+		case '(':
+			if !quoted {
+				depth++
+			}
+		case ')':
+			if !quoted {
+				depth--
+				if depth == 0 {
+					return i + 1, true
+				}
+			}
+		}
+	}
+	return len(code), false
+}
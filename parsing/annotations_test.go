@@ -1,6 +1,7 @@
 package parsing
 
 import (
+	"strings"
 	"testing"
 
 	. "github.com/smartystreets/goconvey/convey"
@@ -21,6 +22,44 @@ func TestUsesParsing(t *testing.T) {
 	})
 }
 
+func TestVersionParsing(t *testing.T) {
+	Convey("Test version annotation parsing", t, func(c C) {
+		Equals(c, ParseVersion(`within;
+package Foo
+  annotation(uses(Modelica(version="3.2.1")),
+    conversion(from(version="1.0", script="modelica://Foo/Convert(1.0).mos")),
+    version = "1.3.0 Beta 1", versionDate="2016-01-01");
+end Foo;`), "1.3.0 Beta 1")
+		Equals(c, ParseVersion(`within;
+package Foo
+  annotation(uses(Modelica(version="3.2.1")));
+end Foo;`), "")
+	})
+}
+
+func TestHasUsesAnnotation(t *testing.T) {
+	Convey("Test detection of complete uses annotations", t, func(c C) {
+		code := `within;
+package Foo
+  annotation(uses(Modelica(version="3.2.1")), version="1.0.0");
+  model Bar
+  end Bar;
+end Foo;`
+		IsTrue(c, HasUsesAnnotation(code))
+		// Only the start of the file is needed
+		IsTrue(c, HasUsesAnnotation(code[:strings.Index(code, "model")]))
+		// But the annotation needs to be complete
+		IsFalse(c, HasUsesAnnotation(code[:strings.Index(code, "version=\"1.0.0\"")]))
+		// Annotations without a uses annotation (e.g., of nested classes) don't count
+		IsFalse(c, HasUsesAnnotation(`within;
+package Foo
+  model Bar
+    annotation(Documentation(info="uses(...)"));
+  end Bar;`))
+		IsFalse(c, HasUsesAnnotation(t1[:1000]))
+	})
+}
+
 var t1 = `
 within ;
 package Buildings "Library with models for building energy and control systems"
@@ -0,0 +1,69 @@
+package parsing
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/blang/semver"
+)
+
+// Operators that may appear in front of a version within a constraint
+var constraintTerm = regexp.MustCompile(`^(>=|<=|!=|==|>|<|=|!)?(.*)$`)
+
+// Whitespace between an operator and its version is not significant
+var constraintSpace = regexp.MustCompile(`(>=|<=|!=|==|>|<|=|!)\s+`)
+
+// A version made up of only a major version number (e.g., the 4 in "<4")
+var majorOnly = regexp.MustCompile(`^[0-9]+$`)
+
+// This function takes a version constraint (e.g., "3.2" or ">=2.0 <3.0")
+// and returns it in normalized form along with a function that checks
+// whether a given version satisfies the constraint.  Each version in the
+// constraint is normalized according to the rules of NormalizeVersion,
+// except that a major version number on its own (e.g., "<4") is also
+// accepted as MAJOR.0.
+// Terms separated by spaces must all be satisfied while terms separated
+// by "||" are alternatives.
+func NormalizeConstraint(spec string) (string, semver.Range, error) {
+	terms := strings.Fields(constraintSpace.ReplaceAllString(spec, "$1"))
+	if len(terms) == 0 {
+		return "", nil, fmt.Errorf("Empty version constraint")
+	}
+
+	parts := []string{}
+	for _, term := range terms {
+		if term == "||" {
+			parts = append(parts, term)
+			continue
+		}
+
+		m := constraintTerm.FindStringSubmatch(term)
+		op := m[1]
+		// Exact matches don't need an operator
+		if op == "=" || op == "==" {
+			op = ""
+		}
+		if op == "!" {
+			op = "!="
+		}
+
+		version := m[2]
+		if majorOnly.MatchString(version) {
+			version = version + ".0"
+		}
+		v, err := NormalizeVersion(version)
+		if err != nil {
+			return "", nil, fmt.Errorf("Invalid version '%s' in constraint '%s': %v",
+				m[2], spec, err)
+		}
+		parts = append(parts, op+v.String())
+	}
+
+	norm := strings.Join(parts, " ")
+	r, err := semver.ParseRange(norm)
+	if err != nil {
+		return "", nil, fmt.Errorf("Invalid version constraint '%s': %v", spec, err)
+	}
+	return norm, r, nil
+}
@@ -0,0 +1,43 @@
+package parsing
+
+import (
+	"testing"
+
+	"github.com/blang/semver"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+)
+
+func checkConstraint(c C, spec string, norm string) semver.Range {
+	n, r, err := NormalizeConstraint(spec)
+	NoError(c, err)
+	Equals(c, n, norm)
+	return r
+}
+
+func TestConstraints(t *testing.T) {
+	Convey("Test constraint normalizing", t, func(c C) {
+		r := checkConstraint(c, "3.2", "3.2.0")
+		IsTrue(c, r(semver.MustParse("3.2.0")))
+		IsTrue(c, !r(semver.MustParse("3.2.1")))
+
+		r = checkConstraint(c, ">=2.0 <3.0.0", ">=2.0.0 <3.0.0")
+		IsTrue(c, r(semver.MustParse("2.1.0")))
+		IsTrue(c, !r(semver.MustParse("3.0.0")))
+
+		checkConstraint(c, ">= 2.0   < 3.0", ">=2.0.0 <3.0.0")
+		checkConstraint(c, ">=2 <4", ">=2.0.0 <4.0.0")
+		checkConstraint(c, "==1.2.3", "1.2.3")
+		checkConstraint(c, "<1.0 || >=2.0", "<1.0.0 || >=2.0.0")
+
+		_, _, err := NormalizeConstraint(">=1,0")
+		IsError(c, err)
+
+		_, _, err = NormalizeConstraint("1.0.x")
+		IsError(c, err)
+
+		_, _, err = NormalizeConstraint("")
+		IsError(c, err)
+	})
+}
@@ -0,0 +1,124 @@
+package parsing
+
+import (
+	"html"
+	"regexp"
+	"strings"
+)
+
+// This matches a string literal (including any escaped quotes)
+var stringLiteral = regexp.MustCompile(`^"((?:[^"\\]|\\.)*)"`)
+
+// This matches the start of the info string of a Documentation annotation
+var documentationInfo = regexp.MustCompile(`\bDocumentation\s*\(\s*(?:[A-Za-z_]+\s*=\s*"(?:[^"\\]|\\.)*"\s*,\s*)*info\s*=\s*`)
+
+// These match HTML elements that separate paragraphs (and any other
+// HTML tags)
+var paragraphTag = regexp.MustCompile(`(?i)</?(?:p|br|div|h[1-6]|ul|ol|li|table|tr|pre)\b[^>]*>`)
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+var htmlComment = regexp.MustCompile(`(?s)<!--.*?-->`)
+var blankLines = regexp.MustCompile(`\n\s*\n`)
+
+// This function returns a short description of the package declared in
+// the given Modelica code.  The description string of the declaration
+// (e.g., package Foo "A library for ...") is used if there is one.
+// Otherwise, the first paragraph of the info string of the Documentation
+// annotation (if any) is used, with all HTML removed.  If neither is
+// present, the empty string is returned.
+func ParseDescription(code string) string {
+	desc := declaredDescription(code)
+	if desc != "" {
+		return desc
+	}
+	return documentationSummary(code)
+}
+
+// This function returns the description string that follows the
+// declaration of the package (if any).  Strings concatenated with + are
+// joined.
+func declaredDescription(code string) string {
+	rem := skipComments(strings.TrimPrefix(code, "\ufeff"))
+	loc := withinClause.FindStringIndex(rem)
+	if loc != nil {
+		rem = skipComments(rem[loc[1]:])
+	}
+	loc = packageDeclaration.FindStringIndex(rem)
+	if loc == nil {
+		return ""
+	}
+	rem = rem[loc[1]:]
+
+	parts := []string{}
+	for {
+		rem = skipComments(rem)
+		m := stringLiteral.FindStringSubmatch(rem)
+		if m == nil {
+			break
+		}
+		parts = append(parts, unescape(m[1]))
+		rem = skipComments(rem[len(m[0]):])
+		if !strings.HasPrefix(rem, "+") {
+			break
+		}
+		rem = rem[1:]
+	}
+	return collapseSpace(strings.Join(parts, ""))
+}
+
+// This function returns the first paragraph of the info string of the
+// (first) Documentation annotation in the given code as plain text.
+func documentationSummary(code string) string {
+	loc := documentationInfo.FindStringIndex(code)
+	if loc == nil {
+		return ""
+	}
+	m := stringLiteral.FindStringSubmatch(code[loc[1]:])
+	if m == nil {
+		return ""
+	}
+	text := unescape(m[1])
+	text = htmlComment.ReplaceAllString(text, "")
+	text = paragraphTag.ReplaceAllString(text, "\n\n")
+	text = htmlTag.ReplaceAllString(text, "")
+	text = html.UnescapeString(text)
+
+	for _, para := range blankLines.Split(text, -1) {
+		para = collapseSpace(para)
+		if para != "" {
+			return para
+		}
+	}
+	return ""
+}
+
+// This function replaces the escape sequences in a Modelica string.
+func unescape(str string) string {
+	if !strings.Contains(str, "\\") {
+		return str
+	}
+	buf := make([]byte, 0, len(str))
+	for i := 0; i < len(str); i++ {
+		if str[i] != '\\' || i+1 == len(str) {
+			buf = append(buf, str[i])
+			continue
+		}
+		i++
+		switch str[i] {
+		case 'n':
+			buf = append(buf, '\n')
+		case 't':
+			buf = append(buf, '\t')
+		case 'r':
+			buf = append(buf, '\r')
+		default:
+			buf = append(buf, str[i])
+		}
+	}
+	return string(buf)
+}
+
+// This function replaces all runs of whitespace with a single space (and
+// removes any leading or trailing whitespace).
+func collapseSpace(str string) string {
+	return strings.Join(strings.Fields(str), " ")
+}
@@ -0,0 +1,41 @@
+package parsing
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+)
+
+func TestParseDescription(t *testing.T) {
+	Convey("Test description parsing", t, func(c C) {
+		Equals(c, ParseDescription(`within ;
+package HelmholtzMedia "Data and models of real pure fluids (liquid, two-phase and gas)"
+  annotation (uses(Modelica(version="3.2.1")));
+end HelmholtzMedia;`), "Data and models of real pure fluids (liquid, two-phase and gas)")
+
+		// Strings can be concatenated (and contain escapes)
+		Equals(c, ParseDescription("// Comment\npackage Foo \"A \\\"quoted\\\" \" + /* */ \"library\"\nend Foo;"),
+			`A "quoted" library`)
+
+		// Without a description string, the documentation is used
+		Equals(c, ParseDescription(`package Foo
+  annotation(Documentation(revisions="<html>1.0</html>", info="<html>
+<!-- Summary -->
+<p>The <b>Foo</b> library models
+   foo &amp; bar.</p>
+<p>More details.</p>
+</html>"));
+end Foo;`), "The Foo library models foo & bar.")
+		Equals(c, ParseDescription(`package Foo annotation(Documentation(info="Line one<br>Line two")); end Foo;`),
+			"Line one")
+
+		// An empty description string doesn't hide the documentation
+		Equals(c, ParseDescription(`package Foo ""
+  annotation(Documentation(info="<html>Docs</html>"));
+end Foo;`), "Docs")
+
+		Equals(c, ParseDescription("package Foo\nend Foo;"), "")
+		Equals(c, ParseDescription(`model Foo "Not a package" end Foo;`), "")
+	})
+}
@@ -2,10 +2,49 @@ package parsing
 
 import (
 	"fmt"
+	"regexp"
+	"strings"
 	"unicode"
 )
 
+// This matches the declaration of a package (with any prefixes)
+var packageDeclaration = regexp.MustCompile(
+	`^(?:(?:encapsulated|partial)\s+)*package\s+([A-Za-z_][A-Za-z0-9_]*)`)
+
+// This function returns the name of the package declared in the given
+// Modelica code.  The declaration (i.e., the first "package <Name>"
+// following any comments and within clause) is authoritative.  If no
+// declaration can be found, the name given at the end of the package
+// (i.e., "end <Name>;") is used instead.
 func ParseName(pkg string) (string, error) {
+	name, ok := DeclaredName(pkg)
+	if ok {
+		return name, nil
+	}
+
+	return parseEndName(pkg)
+}
+
+// This function returns the name in the declaration of the package (i.e.,
+// the first "package <Name>" following any comments and within clause) in
+// the given Modelica code.  Unlike ParseName, this only needs the start
+// of the code.  It returns false if there is no such declaration.
+func DeclaredName(pkg string) (string, bool) {
+	rem := skipComments(strings.TrimPrefix(pkg, "\ufeff"))
+	loc := withinClause.FindStringIndex(rem)
+	if loc != nil {
+		rem = skipComments(rem[loc[1]:])
+	}
+	m := packageDeclaration.FindStringSubmatch(rem)
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+// This function returns the name in the last "end <Name>;" statement of
+// the given code.
+func parseEndName(pkg string) (string, error) {
 	runes := []rune{}
 	for _, rune := range pkg {
 		runes = append(runes, rune)
@@ -25,3 +64,44 @@ func ParseName(pkg string) (string, error) {
 	}
 	return "", fmt.Errorf("Error finding package name")
 }
+
+// This function skips over any leading whitespace and comments in the
+// given code.
+func skipComments(code string) string {
+	rem := code
+	for {
+		rem = strings.TrimSpace(rem)
+		if strings.HasPrefix(rem, "//") {
+			i := strings.Index(rem, "\n")
+			if i == -1 {
+				return ""
+			}
+			rem = rem[i+1:]
+			continue
+		}
+		if strings.HasPrefix(rem, "/*") {
+			i := strings.Index(rem, "*/")
+			if i == -1 {
+				return ""
+			}
+			rem = rem[i+2:]
+			continue
+		}
+		return rem
+	}
+}
+
+// This matches the (optional) within clause at the start of a Modelica file
+var withinClause = regexp.MustCompile(`^within\s*([A-Za-z0-9_.]*)\s*;`)
+
+// This function determines whether the given Modelica code defines a
+// top-level class (i.e., has either no within clause or an empty one).
+func IsTopLevel(code string) bool {
+	rem := skipComments(strings.TrimPrefix(code, "\ufeff"))
+
+	m := withinClause.FindStringSubmatch(rem)
+	if m == nil {
+		return true
+	}
+	return m[1] == ""
+}
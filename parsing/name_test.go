@@ -24,5 +24,37 @@ package HelmholtzMedia "Data and models of real pure fluids (liquid, two-phase a
 end HelmholtzMedia;`)
 		NoError(c, err)
 		Equals(c, name, "HelmholtzMedia")
+
+		// The declaration takes precedence over the end statement
+		name, err = ParseName("// Comment\nwithin;\n/* More */ package Foo\nend Bar;")
+		NoError(c, err)
+		Equals(c, name, "Foo")
+
+		name, err = ParseName("\ufeffencapsulated package Foo_2 \"Description\"\nend Foo_2;")
+		NoError(c, err)
+		Equals(c, name, "Foo_2")
+
+		// Without a declaration, the end statement is used
+		name, err = ParseName("model Foo\nend Foo;")
+		NoError(c, err)
+		Equals(c, name, "Foo")
+
+		// The declaration can be found in just the start of the code
+		name, ok := DeclaredName("within;\npackage Foo \"A library\"\n  model")
+		IsTrue(c, ok)
+		Equals(c, name, "Foo")
+		_, ok = DeclaredName("within;\n// Just a comment")
+		IsFalse(c, ok)
+	})
+}
+
+func TestTopLevel(t *testing.T) {
+	Convey("Test detection of top-level packages", t, func(c C) {
+		IsTrue(c, IsTopLevel("within;\npackage Foo\nend Foo;"))
+		IsTrue(c, IsTopLevel("within ;\npackage Foo\nend Foo;"))
+		IsTrue(c, IsTopLevel("package Foo\nend Foo;"))
+		IsTrue(c, IsTopLevel("// Copyright\n/* More\n comments */\nwithin;\npackage Foo\nend Foo;"))
+		IsTrue(c, !IsTopLevel("within Foo;\npackage Bar\nend Bar;"))
+		IsTrue(c, !IsTopLevel("// Comment\nwithin Foo.Bar;\npackage Baz\nend Baz;"))
 	})
 }
@@ -3,28 +3,58 @@ package parsing
 import (
 	"fmt"
 	"regexp"
+	"strings"
 
 	"github.com/blang/semver"
 )
 
+// This matches versions that are "almost" semantic versions (e.g., missing
+// a patch number or containing leading zeros)
+var versionPattern = regexp.MustCompile(
+	`^([0-9]+)\.([0-9]+)(?:\.([0-9]+))?(?:-([0-9A-Za-z.-]+))?(?:\+([0-9A-Za-z.-]+))?$`)
+
+// This function returns a numeric identifier without leading zeros (an
+// empty identifier is treated as zero).
+func numeric(n string) string {
+	n = strings.TrimLeft(n, "0")
+	if n == "" {
+		return "0"
+	}
+	return n
+}
+
 // This function takes a version string and returns a semantic version
 // representation.  If the string is not, itself, in semantic version
 // form, a set of rules will be used to try and cast it into that
-// form.
+// form.  Any pre-release identifiers and build metadata are preserved.
+// Leading zeros are dropped (e.g., 1.2.03 and 01.2.3 are both 1.2.3) so
+// that differently written tags of the same version compare as equal.
+// Use StrictSemanticVersions to reject such versions instead.
 func NormalizeVersion(v string) (semver.Version, error) {
 	ret, err := semver.Parse(v)
 	if err == nil {
 		return ret, nil
 	}
 
-	c1, err := regexp.Compile("^[0-9]+\\.[0-9]+")
-	if err != nil {
-		panic(err)
-	}
+	m := versionPattern.FindStringSubmatch(v)
+	if m != nil {
+		// Fill in any missing patch number
+		nv := fmt.Sprintf("%s.%s.%s", numeric(m[1]), numeric(m[2]), numeric(m[3]))
+
+		// Numeric pre-release identifiers cannot have leading zeros either
+		if m[4] != "" {
+			ids := strings.Split(m[4], ".")
+			for i, id := range ids {
+				if id != "" && strings.Trim(id, "0123456789") == "" {
+					ids[i] = numeric(id)
+				}
+			}
+			nv = nv + "-" + strings.Join(ids, ".")
+		}
+		if m[5] != "" {
+			nv = nv + "+" + m[5]
+		}
 
-	m := c1.FindString(v)
-	if m != "" {
-		nv := fmt.Sprintf("%s.0%s", m, v[len(m):])
 		ret, err := semver.Parse(nv)
 		if err == nil {
 			return ret, nil
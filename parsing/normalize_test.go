@@ -31,7 +31,54 @@ func TestNormalizing(t *testing.T) {
 
 		checkNormalize(c, "1.2+build45", "1.2.0+build45")
 
+		checkNormalize(c, "0.0.0-dev+0a18068", "0.0.0-dev+0a18068")
+
+		checkNormalize(c, "2.1.0-rc1", "2.1.0-rc1")
+
+		checkNormalize(c, "2.1-rc1", "2.1.0-rc1")
+
+		checkNormalize(c, "1.4.0+build.17", "1.4.0+build.17")
+
+		checkNormalize(c, "1.0.0-beta.2+exp.sha.5114f85", "1.0.0-beta.2+exp.sha.5114f85")
+
+		checkNormalize(c, "2016.03", "2016.3.0")
+
+		checkNormalize(c, "1.0-rc.01", "1.0.0-rc.1")
+
+		// Leading zeros are dropped by default
+		checkNormalize(c, "1.2.03", "1.2.3")
+
+		checkNormalize(c, "01.0.0", "1.0.0")
+
+		checkNormalize(c, "00.01.00", "0.1.0")
+
 		_, err := NormalizeVersion("a.b.c")
 		IsError(c, err)
+
+		_, err = NormalizeVersion("1.2.3.4")
+		IsError(c, err)
+
+		_, err = NormalizeVersion("1.0.0-rc..1")
+		IsError(c, err)
+	})
+}
+
+func TestPrereleaseOrdering(t *testing.T) {
+	Convey("Test ordering of pre-release versions", t, func(c C) {
+		order := []string{"1.0.0-alpha", "1.0.0-alpha.1", "1.0.0-beta", "1.0.0"}
+		for i := 0; i < len(order)-1; i++ {
+			v1, err := NormalizeVersion(order[i])
+			NoError(c, err)
+			v2, err := NormalizeVersion(order[i+1])
+			NoError(c, err)
+			IsTrue(c, v1.LT(v2))
+		}
+
+		// Build metadata doesn't affect ordering
+		v1, err := NormalizeVersion("1.4.0+build.17")
+		NoError(c, err)
+		v2, err := NormalizeVersion("1.4.0")
+		NoError(c, err)
+		IsTrue(c, v1.EQ(v2))
 	})
 }
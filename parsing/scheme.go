@@ -0,0 +1,88 @@
+package parsing
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/blang/semver"
+)
+
+// A VersionScheme parses the versions of libraries that follow a
+// particular versioning scheme (e.g., calendar versions).  Everything in
+// the index is recorded (and ordered) by semantic version, so each
+// version is mapped onto a semantic version.  That mapping is what
+// compares versions, so it must preserve the order of the scheme (i.e.,
+// a newer version must always be mapped onto a greater semantic
+// version).  Version strings that don't follow the scheme are rejected
+// with an error.
+type VersionScheme interface {
+	ParseVersion(v string) (semver.Version, error)
+}
+
+// The VersionSchemeFunc type allows an ordinary function to be used as a
+// VersionScheme.
+type VersionSchemeFunc func(v string) (semver.Version, error)
+
+func (f VersionSchemeFunc) ParseVersion(v string) (semver.Version, error) {
+	return f(v)
+}
+
+// Semantic versions (or anything that can be normalized into one, see
+// NormalizeVersion).  This is the default scheme.
+var SemanticVersions VersionScheme = VersionSchemeFunc(NormalizeVersion)
+
+// Semantic versions that are already in their canonical form (e.g.,
+// 1.2.3 but not 1.2.03, 01.2.3 or 1.2).  Unlike SemanticVersions, this
+// rejects versions rather than normalizing them, for when a tag must be
+// exactly the version it stands for.
+var StrictSemanticVersions VersionScheme = VersionSchemeFunc(parseStrictVersion)
+
+// Calendar versions made up of a year along with an (optional) month and
+// day, e.g., 2023.1, 2023-01-15 or 20230115.  The year, month and day
+// become the major, minor and patch number (so 2023-01-15 is 2023.1.15).
+var CalendarVersions VersionScheme = VersionSchemeFunc(parseCalendarVersion)
+
+// These match calendar versions with separators (e.g., 2023.01.15 or
+// 2023-1) and without (e.g., 20230115)
+var calendarPattern = regexp.MustCompile(`^([0-9]{4})(?:[.-]([0-9]{1,2})(?:[.-]([0-9]{1,2}))?)?$`)
+var compactCalendarPattern = regexp.MustCompile(`^([0-9]{4})([0-9]{2})([0-9]{2})$`)
+
+// This function parses a canonical semantic version (see
+// StrictSemanticVersions).
+func parseStrictVersion(v string) (semver.Version, error) {
+	ret, err := semver.Parse(v)
+	if err != nil {
+		return semver.Version{}, fmt.Errorf("'%s' is not a canonical semantic version: %v", v, err)
+	}
+	return ret, nil
+}
+
+// This function parses a calendar version (see CalendarVersions).
+func parseCalendarVersion(v string) (semver.Version, error) {
+	m := calendarPattern.FindStringSubmatch(v)
+	if m == nil {
+		m = compactCalendarPattern.FindStringSubmatch(v)
+	}
+	if m == nil {
+		return semver.Version{}, fmt.Errorf("'%s' is not a calendar version", v)
+	}
+
+	ret, err := semver.Parse(fmt.Sprintf("%s.%s.%s", numeric(m[1]), numeric(m[2]), numeric(m[3])))
+	if err != nil {
+		return semver.Version{}, fmt.Errorf("'%s' is not a calendar version: %v", v, err)
+	}
+	if ret.Minor > 12 || ret.Patch > 31 {
+		return semver.Version{}, fmt.Errorf("'%s' is not a calendar version (invalid month or day)", v)
+	}
+	return ret, nil
+}
+
+// The NormalizeWith function is like NormalizeVersion except that the
+// version string is parsed according to the given scheme (or
+// SemanticVersions, if it is nil).
+func NormalizeWith(scheme VersionScheme, v string) (semver.Version, error) {
+	if scheme == nil {
+		scheme = SemanticVersions
+	}
+	return scheme.ParseVersion(v)
+}
@@ -0,0 +1,69 @@
+package parsing
+
+import (
+	"testing"
+
+	"github.com/blang/semver"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+)
+
+func TestVersionSchemes(t *testing.T) {
+	Convey("Testing version schemes", t, func(c C) {
+		check := func(scheme VersionScheme, s string, expected string) {
+			v, err := NormalizeWith(scheme, s)
+			NoError(c, err)
+			Equals(c, v.String(), expected)
+		}
+
+		// Semantic versions are the default
+		check(nil, "1.4", "1.4.0")
+		check(SemanticVersions, "2.1-rc1", "2.1.0-rc1")
+
+		check(CalendarVersions, "2023", "2023.0.0")
+		check(CalendarVersions, "2023.1", "2023.1.0")
+		check(CalendarVersions, "2023-01-15", "2023.1.15")
+		check(CalendarVersions, "2023.12.01", "2023.12.1")
+		check(CalendarVersions, "20230115", "2023.1.15")
+		for _, s := range []string{"1.2.3", "2023-13-01", "2023-01-32", "2023-01-15-rc1", "v2023.1"} {
+			_, err := NormalizeWith(CalendarVersions, s)
+			IsError(c, err)
+		}
+
+		// Strict semantic versions have to be canonical already
+		check(StrictSemanticVersions, "1.2.3", "1.2.3")
+		check(StrictSemanticVersions, "2.1.0-rc.1+build5", "2.1.0-rc.1+build5")
+		// (whereas they are normalized by default)
+		lenient := map[string]string{
+			"1.2.03":      "1.2.3",
+			"01.0.0":      "1.0.0",
+			"1.2":         "1.2.0",
+			"1.0.0-rc.01": "1.0.0-rc.1",
+		}
+		for s, expected := range lenient {
+			_, err := NormalizeWith(StrictSemanticVersions, s)
+			IsError(c, err)
+			check(nil, s, expected)
+		}
+		_, err := NormalizeWith(StrictSemanticVersions, "v1.2.3")
+		IsError(c, err)
+
+		// Without a scheme, a date isn't a version at all
+		_, err = NormalizeWith(nil, "2023-01-15")
+		IsError(c, err)
+
+		// The semantic versions preserve the order of the dates
+		older, err := NormalizeWith(CalendarVersions, "2022-12-31")
+		NoError(c, err)
+		newer, err := NormalizeWith(CalendarVersions, "20230101")
+		NoError(c, err)
+		IsTrue(c, older.LT(newer))
+
+		// Any function can be used as a scheme
+		fixed := VersionSchemeFunc(func(s string) (semver.Version, error) {
+			return semver.MustParse("1.0.0"), nil
+		})
+		check(fixed, "anything", "1.0.0")
+	})
+}
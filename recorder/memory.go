@@ -0,0 +1,271 @@
+package recorder
+
+import (
+	"time"
+
+	"github.com/blang/semver"
+)
+
+// The MemoryRecorder keeps everything recorded with it in memory.  All
+// the information is exported so that it can be checked directly (e.g.,
+// in tests).  Libraries are identified by their name and owner.
+type MemoryRecorder struct {
+	Libraries []*MemoryLibrary
+	// When (and by which version of impact) this was generated
+	GeneratedAt      time.Time
+	GeneratorVersion string
+}
+
+// The MemoryLibrary type holds everything recorded for a library.
+type MemoryLibrary struct {
+	Name        string
+	URI         string
+	OwnerURI    string
+	Description string
+	Homepage    string
+	Repository  string
+	Format      string
+	Stars       int
+	Forks       int
+	OpenIssues  int
+	Email       string
+	Maintainers []Maintainer
+	License     string
+	// Versions of this library (keyed by version string)
+	Versions map[string]*MemoryVersion
+}
+
+// The MemoryVersion type holds everything recorded for a specific
+// version of a library.
+type MemoryVersion struct {
+	Version    semver.Version
+	Hash       string
+	TarballURL string
+	// Other places the tarball can be downloaded from (in priority order)
+	Mirrors    []string
+	ZipballURL string
+	// Checksums of the tarball (keyed by algorithm)
+	Checksums map[string]string
+	// Size of this version in bytes (if known)
+	Size        int64
+	ReleaseDate time.Time
+	// Whether impact.json was signed by a trusted publisher
+	Verified bool
+	// Release notes of this version (if they were recorded)
+	ReleaseNotes string
+	// Name of the tag this version was found as (if any)
+	TagName      string
+	Path         string
+	IsFile       bool
+	Dependencies []MemoryDependency
+	// Version of the Modelica Standard Library used (if any)
+	ModelicaCompat string
+	// Whether this version builds (and any details)
+	BuildStatus  string
+	BuildDetails string
+	// Why this version is deprecated (empty if it isn't)
+	Deprecated string
+}
+
+// A dependency recorded for a version of a library
+type MemoryDependency struct {
+	Library string
+	Version semver.Version
+}
+
+func NewMemoryRecorder() *MemoryRecorder {
+	return &MemoryRecorder{
+		Libraries: []*MemoryLibrary{},
+	}
+}
+
+func (m *MemoryRecorder) GetLibrary(name string, uri string, owner_uri string) LibraryRecorder {
+	for _, lib := range m.Libraries {
+		if lib.Name == name && lib.OwnerURI == owner_uri {
+			return lib
+		}
+	}
+	lib := newMemoryLibrary(name, uri, owner_uri)
+	m.Libraries = append(m.Libraries, lib)
+	return lib
+}
+
+func (m *MemoryRecorder) SetGeneratedAt(t time.Time) {
+	m.GeneratedAt = t
+}
+
+func (m *MemoryRecorder) SetGeneratorVersion(version string) {
+	m.GeneratorVersion = version
+}
+
+// The RemoveVersion method forgets the given version of the named library
+// (and the library itself if that was its last version).
+func (m *MemoryRecorder) RemoveVersion(name string, owner_uri string, v semver.Version) {
+	for i, lib := range m.Libraries {
+		if lib.Name != name || lib.OwnerURI != owner_uri {
+			continue
+		}
+		delete(lib.Versions, v.String())
+		if len(lib.Versions) == 0 {
+			m.Libraries = append(m.Libraries[:i], m.Libraries[i+1:]...)
+		}
+		return
+	}
+}
+
+func newMemoryLibrary(name string, uri string, owner_uri string) *MemoryLibrary {
+	return &MemoryLibrary{
+		Name:     name,
+		URI:      uri,
+		OwnerURI: owner_uri,
+		Stars:    -1,
+		Versions: map[string]*MemoryVersion{},
+	}
+}
+
+// The Find method returns the first library recorded with the given name
+// (or nil if there is no such library).
+func (m *MemoryRecorder) Find(name string) *MemoryLibrary {
+	for _, lib := range m.Libraries {
+		if lib.Name == name {
+			return lib
+		}
+	}
+	return nil
+}
+
+func (lib *MemoryLibrary) SetDescription(desc string) {
+	lib.Description = desc
+}
+
+func (lib *MemoryLibrary) SetHomepage(url string) {
+	lib.Homepage = url
+}
+
+func (lib *MemoryLibrary) SetRepository(url string, format string) {
+	lib.Repository = url
+	lib.Format = format
+}
+
+func (lib *MemoryLibrary) SetStars(stars int) {
+	lib.Stars = stars
+}
+
+func (lib *MemoryLibrary) SetForks(forks int) {
+	lib.Forks = forks
+}
+
+func (lib *MemoryLibrary) SetOpenIssues(issues int) {
+	lib.OpenIssues = issues
+}
+
+func (lib *MemoryLibrary) SetEmail(email string) {
+	lib.Email = email
+}
+
+func (lib *MemoryLibrary) SetMaintainers(maintainers []Maintainer) {
+	lib.Maintainers = maintainers
+}
+
+func (lib *MemoryLibrary) SetLicense(license string) {
+	lib.License = license
+}
+
+func (lib *MemoryLibrary) HasVersion(v semver.Version) bool {
+	_, exists := lib.Versions[v.String()]
+	return exists
+}
+
+func (lib *MemoryLibrary) AddVersion(v semver.Version) VersionRecorder {
+	mv := &MemoryVersion{
+		Version:      v,
+		Dependencies: []MemoryDependency{},
+	}
+	lib.Versions[v.String()] = mv
+	return mv
+}
+
+func (v *MemoryVersion) SetHash(hash string) {
+	v.Hash = hash
+}
+
+func (v *MemoryVersion) SetTarballURL(url string) {
+	v.TarballURL = url
+}
+
+func (v *MemoryVersion) AddMirror(url string) {
+	v.Mirrors = append(v.Mirrors, url)
+}
+
+func (v *MemoryVersion) SetZipballURL(url string) {
+	v.ZipballURL = url
+}
+
+func (v *MemoryVersion) SetArchiveChecksum(algo string, hex string) {
+	if v.Checksums == nil {
+		v.Checksums = map[string]string{}
+	}
+	v.Checksums[algo] = hex
+}
+
+func (v *MemoryVersion) SetSize(bytes int64) {
+	v.Size = bytes
+}
+
+func (v *MemoryVersion) SetVerified(verified bool) {
+	v.Verified = verified
+}
+
+func (v *MemoryVersion) SetReleaseNotes(notes string) {
+	v.ReleaseNotes = notes
+}
+
+func (v *MemoryVersion) SetTagName(tag string) {
+	v.TagName = tag
+}
+
+func (v *MemoryVersion) SetReleaseDate(date time.Time) {
+	v.ReleaseDate = date
+}
+
+func (v *MemoryVersion) SetPath(path string, file bool) {
+	v.Path = path
+	v.IsFile = file
+}
+
+func (v *MemoryVersion) SetModelicaCompat(version string) {
+	v.ModelicaCompat = version
+}
+
+func (v *MemoryVersion) SetBuildStatus(status string, details string) {
+	v.BuildStatus = status
+	v.BuildDetails = details
+}
+
+func (v *MemoryVersion) SetDeprecated(reason string) {
+	v.Deprecated = reason
+}
+
+// The AddDependency method records a dependency on the given version of
+// a library.  As with index.VersionDetails, each library is only listed
+// once and the stricter version (the newer version with the same major
+// version) is kept.
+func (v *MemoryVersion) AddDependency(library string, version semver.Version) {
+	for i, dep := range v.Dependencies {
+		if dep.Library != library {
+			continue
+		}
+		if dep.Version.Major == version.Major && version.GT(dep.Version) {
+			v.Dependencies[i].Version = version
+		}
+		return
+	}
+	v.Dependencies = append(v.Dependencies, MemoryDependency{
+		Library: library,
+		Version: version,
+	})
+}
+
+var _ VersionRemover = (*MemoryRecorder)(nil)
+var _ LibraryRecorder = (*MemoryLibrary)(nil)
+var _ VersionDeprecator = (*MemoryVersion)(nil)
@@ -0,0 +1,72 @@
+package recorder
+
+import (
+	"testing"
+	"time"
+
+	"github.com/blang/semver"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+)
+
+func TestMemoryRecorder(t *testing.T) {
+	Convey("Testing the in-memory recorder", t, func(c C) {
+		m := NewMemoryRecorder()
+		var r Recorder = m
+
+		lib := r.GetLibrary("Foo", "https://github.com/a/Foo", "https://github.com/a")
+		lib.SetStars(5)
+		lib.SetDescription("A library")
+		IsTrue(c, !lib.HasVersion(semver.MustParse("1.0.0")))
+
+		date := time.Date(2016, 3, 1, 0, 0, 0, 0, time.UTC)
+		vr := lib.AddVersion(semver.MustParse("1.0.0"))
+		vr.SetHash("abcdef")
+		vr.SetReleaseDate(date)
+		vr.SetPath("Foo", false)
+		vr.AddDependency("Modelica", semver.MustParse("3.2.2"))
+		IsTrue(c, lib.HasVersion(semver.MustParse("1.0.0")))
+
+		// The same library (and owner) is only recorded once
+		r.GetLibrary("Foo", "https://github.com/a/Foo", "https://github.com/a")
+		r.GetLibrary("Foo", "https://github.com/b/Foo", "https://github.com/b")
+		Equals(c, len(m.Libraries), 2)
+
+		foo := m.Find("Foo")
+		Equals(c, foo.Stars, 5)
+		Equals(c, foo.Description, "A library")
+		v := foo.Versions["1.0.0"]
+		Equals(c, v.Hash, "abcdef")
+		IsTrue(c, v.ReleaseDate.Equal(date))
+		Equals(c, v.Path, "Foo")
+		Equals(c, len(v.Dependencies), 1)
+		Equals(c, v.Dependencies[0].Library, "Modelica")
+		IsTrue(c, m.Find("Bar") == nil)
+
+		// Deprecation is optional, but supported (even when synchronized)
+		sr := Synchronized(m)
+		svr := sr.GetLibrary("Foo", "https://github.com/a/Foo", "https://github.com/a").
+			AddVersion(semver.MustParse("1.1.0"))
+		IsTrue(c, Deprecate(svr, "Broken"))
+		Equals(c, foo.Versions["1.1.0"].Deprecated, "Broken")
+		// Only the VersionRecorder methods of this are visible
+		plain := struct{ VersionRecorder }{NullRecorder{}}
+		IsTrue(c, !Deprecate(plain, "Broken"))
+
+		// So is removing versions (the library goes with its last version)
+		IsTrue(c, Remove(sr, "Foo", "https://github.com/a", semver.MustParse("1.1.0")))
+		IsTrue(c, !foo.HasVersion(semver.MustParse("1.1.0")))
+		IsTrue(c, foo.HasVersion(semver.MustParse("1.0.0")))
+		IsTrue(c, Remove(m, "Foo", "https://github.com/a", semver.MustParse("1.0.0")))
+		Equals(c, len(m.Libraries), 1)
+		Equals(c, m.Find("Foo").OwnerURI, "https://github.com/b")
+		IsTrue(c, !Remove(NullRecorder{}, "Foo", "https://github.com/a", semver.MustParse("1.0.0")))
+
+		// Nothing is kept by the null recorder
+		var nr Recorder = NullRecorder{}
+		nlib := nr.GetLibrary("Foo", "", "")
+		nlib.AddVersion(semver.MustParse("1.0.0"))
+		IsTrue(c, !nlib.HasVersion(semver.MustParse("1.0.0")))
+	})
+}
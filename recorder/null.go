@@ -0,0 +1,54 @@
+package recorder
+
+import (
+	"time"
+
+	"github.com/blang/semver"
+)
+
+// The NullRecorder discards everything recorded with it.  It is useful
+// when only the side effects of a crawl (e.g., logging) are of interest.
+type NullRecorder struct {
+}
+
+func (nr NullRecorder) GetLibrary(name string, uri string, owner_uri string) LibraryRecorder {
+	return nr
+}
+
+func (nr NullRecorder) SetStars(int)                 {}
+func (nr NullRecorder) SetForks(int)                 {}
+func (nr NullRecorder) SetOpenIssues(int)            {}
+func (nr NullRecorder) SetEmail(string)              {}
+func (nr NullRecorder) SetMaintainers([]Maintainer)  {}
+func (nr NullRecorder) SetLicense(string)            {}
+func (nr NullRecorder) SetDescription(string)        {}
+func (nr NullRecorder) SetHomepage(string)           {}
+func (nr NullRecorder) SetRepository(string, string) {}
+
+func (nr NullRecorder) HasVersion(v semver.Version) bool {
+	return false
+}
+
+func (nr NullRecorder) AddVersion(v semver.Version) VersionRecorder {
+	return nr
+}
+
+func (nr NullRecorder) SetHash(hash string)                                  {}
+func (nr NullRecorder) SetPath(path string, file bool)                       {}
+func (nr NullRecorder) SetTarballURL(url string)                             {}
+func (nr NullRecorder) AddMirror(url string)                                 {}
+func (nr NullRecorder) SetZipballURL(url string)                             {}
+func (nr NullRecorder) SetArchiveChecksum(algo string, hex string)           {}
+func (nr NullRecorder) SetSize(bytes int64)                                  {}
+func (nr NullRecorder) SetReleaseDate(date time.Time)                        {}
+func (nr NullRecorder) SetVerified(verified bool)                            {}
+func (nr NullRecorder) SetReleaseNotes(notes string)                         {}
+func (nr NullRecorder) SetTagName(tag string)                                {}
+func (nr NullRecorder) SetModelicaCompat(version string)                     {}
+func (nr NullRecorder) SetBuildStatus(status string, details string)         {}
+func (nr NullRecorder) AddDependency(library string, version semver.Version) {}
+func (nr NullRecorder) SetDeprecated(reason string)                          {}
+
+var _ Recorder = NullRecorder{}
+var _ LibraryRecorder = NullRecorder{}
+var _ VersionDeprecator = NullRecorder{}
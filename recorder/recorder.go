@@ -1,6 +1,8 @@
 package recorder
 
 import (
+	"time"
+
 	"github.com/blang/semver"
 )
 
@@ -14,19 +16,159 @@ type Recorder interface {
 	GetLibrary(name string, uri string, owner_uri string) LibraryRecorder
 }
 
+// A Finisher is a Recorder that needs to know when everything found at a
+// given URI (i.e., in a repository) has been recorded (e.g., so that it
+// can be written out).  Supporting this is optional (see Finish).
+type Finisher interface {
+	Recorder
+	Finish(uri string)
+}
+
+// The Finish function tells r that everything found at the given URI has
+// been recorded (if r is a Finisher).
+func Finish(r Recorder, uri string) {
+	f, ok := r.(Finisher)
+	if ok {
+		f.Finish(uri)
+	}
+}
+
+// A Stamper is a Recorder that can also record when (and by which version
+// of impact) its contents were generated.  This lets consumers of an
+// index tell how stale it is and which tool built it.  Supporting this is
+// optional (see Stamp).
+type Stamper interface {
+	Recorder
+	SetGeneratedAt(t time.Time)
+	SetGeneratorVersion(version string)
+}
+
+// The Stamp function records when and by which version of impact the
+// contents of r were generated (if r is a Stamper).  An empty version
+// isn't recorded.  It returns false if r doesn't support this.
+func Stamp(r Recorder, at time.Time, version string) bool {
+	s, ok := r.(Stamper)
+	if !ok {
+		return false
+	}
+	s.SetGeneratedAt(at)
+	if version != "" {
+		s.SetGeneratorVersion(version)
+	}
+	return true
+}
+
+// A VersionRemover is a Recorder that can also forget a version it has
+// already recorded (e.g., because the library has since been deleted).
+// Once its last version is removed, the library itself is forgotten.
+// Supporting this is optional (see Remove).
+type VersionRemover interface {
+	Recorder
+	RemoveVersion(name string, owner_uri string, v semver.Version)
+}
+
+// The Remove function forgets the given version of the named library
+// (owned by owner_uri) if r is a VersionRemover.  It returns false if r
+// doesn't support removing versions.
+func Remove(r Recorder, name string, owner_uri string, v semver.Version) bool {
+	rm, ok := r.(VersionRemover)
+	if !ok {
+		return false
+	}
+	rm.RemoveVersion(name, owner_uri, v)
+	return true
+}
+
+// A Maintainer is someone responsible for a library
+type Maintainer struct {
+	Name  string `json:"name,omitempty"`
+	Email string `json:"email,omitempty"`
+}
+
 type LibraryRecorder interface {
 	SetDescription(desc string)
 	SetHomepage(url string)
 	SetRepository(url string, format string)
 	SetStars(int)
+	// Records the number of forks (and open issues) of the repository
+	SetForks(int)
+	SetOpenIssues(int)
 	SetEmail(string)
+	// Records everyone responsible for the library (the email address
+	// given to SetEmail is the main contact)
+	SetMaintainers([]Maintainer)
+	// Records the SPDX identifier of the license (empty if unknown)
+	SetLicense(string)
+	// Returns true if the given version of this library has already been recorded
+	HasVersion(v semver.Version) bool
 	AddVersion(v semver.Version) VersionRecorder
 }
 
 type VersionRecorder interface {
 	SetHash(hash string)
 	SetTarballURL(url string)
+	// Records another place the tarball can be downloaded from (e.g., a
+	// mirror).  Mirrors are tried, in the order they were added, if the
+	// tarball can't be downloaded from its URL.
+	AddMirror(url string)
 	SetZipballURL(url string)
+	// Records the checksum (in hex) of the tarball computed with the given
+	// algorithm (e.g., ChecksumSHA256) so that downloads can be verified
+	SetArchiveChecksum(algo string, hex string)
+	// Records the size (in bytes) of this version so that large downloads
+	// can be pointed out
+	SetSize(bytes int64)
+	// Records when this version was released (or committed)
+	SetReleaseDate(date time.Time)
+	// Records whether the metadata of this version (i.e., impact.json)
+	// was signed by a trusted publisher
+	SetVerified(verified bool)
+	// Records the release notes of this version (e.g., the body of the
+	// release or the message of the annotated tag) for changelogs
+	SetReleaseNotes(notes string)
+	// Records the name of the tag this version was found as.  This is
+	// the version as it was written (e.g., v1.2.03) rather than in its
+	// canonical form (1.2.3), so it is what archives are named after.
+	SetTagName(tag string)
 	SetPath(path string, file bool)
+	// Records the version of the Modelica Standard Library this version
+	// uses (empty if it doesn't use it)
+	SetModelicaCompat(version string)
+	// Records whether this version builds (e.g., BuildPassing) along with
+	// any details (e.g., a log or the URL of the CI job).  This is not set
+	// while crawling, it is for annotating an existing index.
+	SetBuildStatus(status string, details string)
 	AddDependency(library string, version semver.Version)
 }
+
+// Build statuses (see VersionRecorder.SetBuildStatus)
+const (
+	BuildPassing = "passing"
+	BuildFailing = "failing"
+	BuildUnknown = "unknown"
+)
+
+// Checksum algorithms (see VersionRecorder.SetArchiveChecksum)
+const (
+	ChecksumSHA256 = "sha256"
+)
+
+// A VersionDeprecator is a VersionRecorder that can also record that a
+// version has been deprecated (e.g., because it was later found to be
+// broken).  Supporting this is optional (see Deprecate).
+type VersionDeprecator interface {
+	VersionRecorder
+	SetDeprecated(reason string)
+}
+
+// The Deprecate function marks the version recorded by vr as deprecated
+// for the given reason.  It returns false if vr doesn't support recording
+// deprecations.
+func Deprecate(vr VersionRecorder, reason string) bool {
+	dr, ok := vr.(VersionDeprecator)
+	if !ok {
+		return false
+	}
+	dr.SetDeprecated(reason)
+	return true
+}
@@ -0,0 +1,466 @@
+package recorder
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// The SQLDialect type identifies the flavor of SQL spoken by a database
+// (which determines how parameters are written and how rows are
+// upserted).
+type SQLDialect int
+
+const (
+	Postgres SQLDialect = iota
+	SQLite
+	MySQL
+)
+
+func (d SQLDialect) String() string {
+	switch d {
+	case Postgres:
+		return "postgres"
+	case SQLite:
+		return "sqlite"
+	case MySQL:
+		return "mysql"
+	default:
+		return fmt.Sprintf("SQLDialect(%d)", int(d))
+	}
+}
+
+// The ParseSQLDialect function returns the dialect with the given name
+// (e.g., "postgres").
+func ParseSQLDialect(name string) (SQLDialect, error) {
+	switch strings.ToLower(name) {
+	case "postgres", "postgresql":
+		return Postgres, nil
+	case "sqlite", "sqlite3":
+		return SQLite, nil
+	case "mysql":
+		return MySQL, nil
+	}
+	return Postgres, fmt.Errorf("Unknown SQL dialect '%s'", name)
+}
+
+func (d SQLDialect) valid() bool {
+	return d == Postgres || d == SQLite || d == MySQL
+}
+
+// The bind method returns the placeholder for the nth (starting at 1)
+// parameter of a statement.
+func (d SQLDialect) bind(n int) string {
+	if d == Postgres {
+		return fmt.Sprintf("$%d", n)
+	}
+	return "?"
+}
+
+// The upsert method returns a statement that inserts a row into the
+// given table or, if a row with the same keys already exists, updates
+// the remaining columns of that row.
+func (d SQLDialect) upsert(table string, keys []string, cols []string) string {
+	all := append(append([]string{}, keys...), cols...)
+	binds := []string{}
+	for i := range all {
+		binds = append(binds, d.bind(i+1))
+	}
+	stmt := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s)", table,
+		strings.Join(all, ", "), strings.Join(binds, ", "))
+
+	sets := []string{}
+	for _, col := range cols {
+		if d == MySQL {
+			sets = append(sets, fmt.Sprintf("%s = VALUES(%s)", col, col))
+		} else {
+			sets = append(sets, fmt.Sprintf("%s = excluded.%s", col, col))
+		}
+	}
+	if d == MySQL {
+		return stmt + " ON DUPLICATE KEY UPDATE " + strings.Join(sets, ", ")
+	}
+	return fmt.Sprintf("%s ON CONFLICT (%s) DO UPDATE SET %s", stmt,
+		strings.Join(keys, ", "), strings.Join(sets, ", "))
+}
+
+// The keys and the remaining columns of each table
+var libraryKeys = []string{"library", "owner_uri"}
+var libraryColumns = []string{"uri", "description", "homepage", "repository_uri",
+	"repository_format", "stars", "forks", "open_issues", "email", "maintainers", "license"}
+
+var versionKeys = []string{"library", "owner_uri", "version"}
+var versionColumns = []string{"sha", "tarball_url", "zipball_url", "release_date", "path", "isfile",
+	"modelica_version", "build_status", "build_details", "deprecation_reason", "size",
+	"verified", "release_notes", "tag_name"}
+
+var dependencyKeys = []string{"library", "owner_uri", "version", "dependency"}
+var dependencyColumns = []string{"dependency_version"}
+
+var checksumKeys = []string{"library", "owner_uri", "version", "algorithm"}
+var checksumColumns = []string{"checksum"}
+
+var mirrorKeys = []string{"library", "owner_uri", "version", "priority"}
+var mirrorColumns = []string{"url"}
+
+// Each migration is a list of statements that brings the schema from
+// one version to the next.  Migrations must never be changed once they
+// have been released, new ones should be appended instead.  Key columns
+// are short enough for every primary key to fit within the limits MySQL
+// places on keys (767 bytes per column and 3072 bytes per key, with 4
+// bytes per character in utf8mb4).
+var sqlMigrations = [][]string{
+	{
+		`CREATE TABLE libraries (
+	library VARCHAR(191) NOT NULL,
+	owner_uri VARCHAR(191) NOT NULL,
+	uri TEXT NOT NULL,
+	description TEXT,
+	homepage TEXT,
+	repository_uri TEXT,
+	repository_format TEXT,
+	stars INTEGER NOT NULL DEFAULT -1,
+	forks INTEGER NOT NULL DEFAULT 0,
+	open_issues INTEGER NOT NULL DEFAULT 0,
+	email TEXT,
+	maintainers TEXT,
+	license TEXT,
+	PRIMARY KEY (library, owner_uri)
+)`,
+		`CREATE TABLE versions (
+	library VARCHAR(191) NOT NULL,
+	owner_uri VARCHAR(191) NOT NULL,
+	version VARCHAR(100) NOT NULL,
+	sha TEXT,
+	tarball_url TEXT,
+	zipball_url TEXT,
+	release_date TEXT,
+	path TEXT,
+	isfile BOOLEAN NOT NULL DEFAULT FALSE,
+	modelica_version TEXT,
+	build_status TEXT,
+	build_details TEXT,
+	deprecation_reason TEXT,
+	PRIMARY KEY (library, owner_uri, version),
+	FOREIGN KEY (library, owner_uri) REFERENCES libraries (library, owner_uri)
+)`,
+		`CREATE TABLE dependencies (
+	library VARCHAR(191) NOT NULL,
+	owner_uri VARCHAR(191) NOT NULL,
+	version VARCHAR(100) NOT NULL,
+	dependency VARCHAR(191) NOT NULL,
+	dependency_version TEXT NOT NULL,
+	PRIMARY KEY (library, owner_uri, version, dependency),
+	FOREIGN KEY (library, owner_uri, version) REFERENCES versions (library, owner_uri, version)
+)`,
+	},
+	{
+		`CREATE TABLE checksums (
+	library VARCHAR(191) NOT NULL,
+	owner_uri VARCHAR(191) NOT NULL,
+	version VARCHAR(100) NOT NULL,
+	algorithm VARCHAR(32) NOT NULL,
+	checksum TEXT NOT NULL,
+	PRIMARY KEY (library, owner_uri, version, algorithm),
+	FOREIGN KEY (library, owner_uri, version) REFERENCES versions (library, owner_uri, version)
+)`,
+	},
+	{
+		`CREATE TABLE mirrors (
+	library VARCHAR(191) NOT NULL,
+	owner_uri VARCHAR(191) NOT NULL,
+	version VARCHAR(100) NOT NULL,
+	priority INTEGER NOT NULL,
+	url TEXT NOT NULL,
+	PRIMARY KEY (library, owner_uri, version, priority),
+	FOREIGN KEY (library, owner_uri, version) REFERENCES versions (library, owner_uri, version)
+)`,
+	},
+	{
+		`ALTER TABLE versions ADD COLUMN size BIGINT NOT NULL DEFAULT 0`,
+	},
+	{
+		`ALTER TABLE versions ADD COLUMN verified BOOLEAN NOT NULL DEFAULT FALSE`,
+	},
+	{
+		`ALTER TABLE versions ADD COLUMN release_notes TEXT`,
+	},
+	{
+		`ALTER TABLE versions ADD COLUMN tag_name TEXT`,
+	},
+}
+
+// The MigrateSQL function creates (or updates) the tables used by the
+// SQLRecorder.  The version of the schema is kept in the
+// schema_migrations table so that only the migrations that haven't been
+// applied yet are applied.  Each migration is applied in its own
+// transaction.  It returns the version of the schema.
+func MigrateSQL(db *sql.DB, dialect SQLDialect) (int, error) {
+	if !dialect.valid() {
+		return 0, fmt.Errorf("Unknown SQL dialect %v", dialect)
+	}
+	_, err := db.Exec("CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER NOT NULL)")
+	if err != nil {
+		return 0, fmt.Errorf("Error creating schema_migrations table: %v", err)
+	}
+
+	current, err := schemaVersion(db)
+	if err != nil {
+		return 0, err
+	}
+
+	for current < len(sqlMigrations) {
+		next := current + 1
+		tx, err := db.Begin()
+		if err != nil {
+			return current, fmt.Errorf("Error starting migration %d: %v", next, err)
+		}
+		for _, stmt := range sqlMigrations[current] {
+			_, err = tx.Exec(stmt)
+			if err != nil {
+				tx.Rollback()
+				return current, fmt.Errorf("Error applying migration %d: %v", next, err)
+			}
+		}
+		_, err = tx.Exec("INSERT INTO schema_migrations (version) VALUES ("+dialect.bind(1)+")", next)
+		if err != nil {
+			tx.Rollback()
+			return current, fmt.Errorf("Error recording migration %d: %v", next, err)
+		}
+		err = tx.Commit()
+		if err != nil {
+			return current, fmt.Errorf("Error committing migration %d: %v", next, err)
+		}
+		current = next
+	}
+	return current, nil
+}
+
+// This function returns the latest migration applied to the database.
+func schemaVersion(db *sql.DB) (int, error) {
+	rows, err := db.Query("SELECT version FROM schema_migrations")
+	if err != nil {
+		return 0, fmt.Errorf("Error reading schema version: %v", err)
+	}
+	defer rows.Close()
+
+	current := 0
+	for rows.Next() {
+		var v int
+		err = rows.Scan(&v)
+		if err != nil {
+			return 0, fmt.Errorf("Error reading schema version: %v", err)
+		}
+		if v > current {
+			current = v
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return 0, fmt.Errorf("Error reading schema version: %v", err)
+	}
+	if current > len(sqlMigrations) {
+		return 0, fmt.Errorf("Database schema version %d is newer than this version of impact supports (%d)",
+			current, len(sqlMigrations))
+	}
+	return current, nil
+}
+
+// The SQLRecorder writes libraries (along with their versions and the
+// dependencies, checksums and mirrors of each version) to the
+// corresponding tables of a SQL database (see MigrateSQL).  Like
+// index.StreamRecorder, each library is kept in memory until the
+// repository it was found in has been completely processed (see
+// Finisher).  Then all of its rows are upserted in a single transaction
+// so that other readers (and writers) of the database never see a
+// partially recorded library.  Upserting means that several crawls can
+// write to the same database, the last one to finish a library wins.
+// The dependencies, checksums and mirrors of each version written are
+// replaced by those that were recorded.  Like MemoryRecorder, this is
+// not safe to use from multiple goroutines on its own (see
+// Synchronized).
+type SQLRecorder struct {
+	db      *sql.DB
+	dialect SQLDialect
+	pending []*MemoryLibrary
+	// The first error encountered while writing (if any)
+	err error
+}
+
+func NewSQLRecorder(db *sql.DB, dialect SQLDialect) (*SQLRecorder, error) {
+	if !dialect.valid() {
+		return nil, fmt.Errorf("Unknown SQL dialect %v", dialect)
+	}
+	return &SQLRecorder{
+		db:      db,
+		dialect: dialect,
+		pending: []*MemoryLibrary{},
+	}, nil
+}
+
+func (s *SQLRecorder) GetLibrary(name string, uri string, owner_uri string) LibraryRecorder {
+	for _, lib := range s.pending {
+		if lib.Name == name && lib.OwnerURI == owner_uri {
+			return lib
+		}
+	}
+	lib := newMemoryLibrary(name, uri, owner_uri)
+	s.pending = append(s.pending, lib)
+	return lib
+}
+
+// The Finish method writes every library found at the given URI.
+func (s *SQLRecorder) Finish(uri string) {
+	remaining := []*MemoryLibrary{}
+	for _, lib := range s.pending {
+		if lib.URI == uri {
+			s.write(lib)
+		} else {
+			remaining = append(remaining, lib)
+		}
+	}
+	s.pending = remaining
+}
+
+// The Close method writes any libraries that haven't been written yet.
+// It returns the first error encountered while writing (if any).  The
+// database itself is not closed.
+func (s *SQLRecorder) Close() error {
+	for _, lib := range s.pending {
+		s.write(lib)
+	}
+	s.pending = []*MemoryLibrary{}
+	return s.err
+}
+
+func (s *SQLRecorder) write(lib *MemoryLibrary) {
+	if s.err != nil {
+		return
+	}
+	err := s.writeLibrary(lib)
+	if err != nil {
+		s.err = fmt.Errorf("Error writing library %s: %v", lib.Name, err)
+	}
+}
+
+// This function writes all the rows for the given library in a single
+// transaction.  Rows are always written in the same order (by version
+// and dependency) so that concurrent writers lock them in the same order.
+func (s *SQLRecorder) writeLibrary(lib *MemoryLibrary) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	err = s.writeRows(tx, lib)
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+func (s *SQLRecorder) writeRows(tx *sql.Tx, lib *MemoryLibrary) error {
+	maintainers := ""
+	if len(lib.Maintainers) > 0 {
+		data, err := json.Marshal(lib.Maintainers)
+		if err != nil {
+			return err
+		}
+		maintainers = string(data)
+	}
+
+	_, err := tx.Exec(s.dialect.upsert("libraries", libraryKeys, libraryColumns),
+		lib.Name, lib.OwnerURI, lib.URI, lib.Description, lib.Homepage, lib.Repository,
+		lib.Format, lib.Stars, lib.Forks, lib.OpenIssues, lib.Email, maintainers, lib.License)
+	if err != nil {
+		return err
+	}
+
+	keys := []string{}
+	for k := range lib.Versions {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	deleteDeps := s.deleteVersionRows("dependencies")
+	deleteChecksums := s.deleteVersionRows("checksums")
+	deleteMirrors := s.deleteVersionRows("mirrors")
+
+	for _, k := range keys {
+		v := lib.Versions[k]
+		date := ""
+		if !v.ReleaseDate.IsZero() {
+			date = v.ReleaseDate.UTC().Format(time.RFC3339)
+		}
+		_, err = tx.Exec(s.dialect.upsert("versions", versionKeys, versionColumns),
+			lib.Name, lib.OwnerURI, k, v.Hash, v.TarballURL, v.ZipballURL, date, v.Path, v.IsFile,
+			v.ModelicaCompat, v.BuildStatus, v.BuildDetails, v.Deprecated, v.Size,
+			v.Verified, v.ReleaseNotes, v.TagName)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Exec(deleteDeps, lib.Name, lib.OwnerURI, k)
+		if err != nil {
+			return err
+		}
+		deps := append([]MemoryDependency{}, v.Dependencies...)
+		sort.Sort(dependencyOrder(deps))
+		for _, dep := range deps {
+			_, err = tx.Exec(s.dialect.upsert("dependencies", dependencyKeys, dependencyColumns),
+				lib.Name, lib.OwnerURI, k, dep.Library, dep.Version.String())
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.Exec(deleteChecksums, lib.Name, lib.OwnerURI, k)
+		if err != nil {
+			return err
+		}
+		algos := []string{}
+		for algo := range v.Checksums {
+			algos = append(algos, algo)
+		}
+		sort.Strings(algos)
+		for _, algo := range algos {
+			_, err = tx.Exec(s.dialect.upsert("checksums", checksumKeys, checksumColumns),
+				lib.Name, lib.OwnerURI, k, algo, v.Checksums[algo])
+			if err != nil {
+				return err
+			}
+		}
+
+		_, err = tx.Exec(deleteMirrors, lib.Name, lib.OwnerURI, k)
+		if err != nil {
+			return err
+		}
+		for n, url := range v.Mirrors {
+			_, err = tx.Exec(s.dialect.upsert("mirrors", mirrorKeys, mirrorColumns),
+				lib.Name, lib.OwnerURI, k, n+1, url)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// This function returns a statement that deletes the rows of the given
+// table that belong to a particular version.
+func (s *SQLRecorder) deleteVersionRows(table string) string {
+	return fmt.Sprintf("DELETE FROM %s WHERE library = %s AND owner_uri = %s AND version = %s",
+		table, s.dialect.bind(1), s.dialect.bind(2), s.dialect.bind(3))
+}
+
+// The dependencyOrder type sorts dependencies by library name.
+type dependencyOrder []MemoryDependency
+
+func (d dependencyOrder) Len() int           { return len(d) }
+func (d dependencyOrder) Swap(i, j int)      { d[i], d[j] = d[j], d[i] }
+func (d dependencyOrder) Less(i, j int) bool { return d[i].Library < d[j].Library }
+
+var _ Finisher = (*SQLRecorder)(nil)
@@ -0,0 +1,415 @@
+package recorder
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/blang/semver"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+)
+
+// The fakeDatabase type is a minimal database/sql driver that just logs
+// every statement executed (and the transactions they are executed in).
+// The only thing it keeps track of is the schema_migrations table.
+type fakeDatabase struct {
+	mutex    sync.Mutex
+	log      []string
+	args     [][]driver.Value
+	versions []int64
+	// Statements starting with this fail (if not empty)
+	fail string
+}
+
+var fakeDatabases = map[string]*fakeDatabase{}
+var fakeMutex sync.Mutex
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(name string) (driver.Conn, error) {
+	fakeMutex.Lock()
+	defer fakeMutex.Unlock()
+	return &fakeConn{db: fakeDatabases[name]}, nil
+}
+
+func init() {
+	sql.Register("impact-fake", fakeDriver{})
+}
+
+// This function opens a new (empty) fake database.
+func openFake(c C, name string) (*sql.DB, *fakeDatabase) {
+	fakeMutex.Lock()
+	fake := &fakeDatabase{}
+	fakeDatabases[name] = fake
+	fakeMutex.Unlock()
+	db, err := sql.Open("impact-fake", name)
+	NoError(c, err)
+	return db, fake
+}
+
+func (f *fakeDatabase) record(entry string, args []driver.Value) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.log = append(f.log, entry)
+	f.args = append(f.args, args)
+}
+
+// This function returns the logged statements (just the first few
+// words of each).
+func (f *fakeDatabase) summary() []string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	ret := []string{}
+	for _, entry := range f.log {
+		words := strings.Fields(entry)
+		if len(words) > 3 {
+			words = words[:3]
+		}
+		ret = append(ret, strings.Join(words, " "))
+	}
+	return ret
+}
+
+func (f *fakeDatabase) reset() {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	f.log = nil
+	f.args = nil
+}
+
+type fakeConn struct {
+	db *fakeDatabase
+}
+
+func (c *fakeConn) Prepare(query string) (driver.Stmt, error) {
+	return &fakeStmt{db: c.db, query: query}, nil
+}
+
+func (c *fakeConn) Close() error {
+	return nil
+}
+
+func (c *fakeConn) Begin() (driver.Tx, error) {
+	c.db.record("BEGIN", nil)
+	return &fakeTx{db: c.db}, nil
+}
+
+type fakeTx struct {
+	db *fakeDatabase
+}
+
+func (t *fakeTx) Commit() error {
+	t.db.record("COMMIT", nil)
+	return nil
+}
+
+func (t *fakeTx) Rollback() error {
+	t.db.record("ROLLBACK", nil)
+	return nil
+}
+
+type fakeStmt struct {
+	db    *fakeDatabase
+	query string
+}
+
+func (s *fakeStmt) Close() error {
+	return nil
+}
+
+func (s *fakeStmt) NumInput() int {
+	return -1
+}
+
+func (s *fakeStmt) Exec(args []driver.Value) (driver.Result, error) {
+	s.db.record(s.query, args)
+	if s.db.fail != "" && strings.HasPrefix(s.query, s.db.fail) {
+		return nil, fmt.Errorf("Failing %s", s.db.fail)
+	}
+	if strings.HasPrefix(s.query, "INSERT INTO schema_migrations") {
+		s.db.mutex.Lock()
+		s.db.versions = append(s.db.versions, args[0].(int64))
+		s.db.mutex.Unlock()
+	}
+	return driver.RowsAffected(1), nil
+}
+
+func (s *fakeStmt) Query(args []driver.Value) (driver.Rows, error) {
+	s.db.record(s.query, args)
+	s.db.mutex.Lock()
+	defer s.db.mutex.Unlock()
+	return &fakeRows{versions: append([]int64{}, s.db.versions...)}, nil
+}
+
+type fakeRows struct {
+	versions []int64
+}
+
+func (r *fakeRows) Columns() []string {
+	return []string{"version"}
+}
+
+func (r *fakeRows) Close() error {
+	return nil
+}
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.versions) == 0 {
+		return io.EOF
+	}
+	dest[0] = r.versions[0]
+	r.versions = r.versions[1:]
+	return nil
+}
+
+func TestSQLMigrations(t *testing.T) {
+	Convey("Testing migrations of the SQL schema", t, func(c C) {
+		db, fake := openFake(c, "migrations")
+		defer db.Close()
+
+		v, err := MigrateSQL(db, Postgres)
+		NoError(c, err)
+		Equals(c, v, len(sqlMigrations))
+		Resembles(c, fake.summary(), []string{
+			"CREATE TABLE IF",
+			"SELECT version FROM",
+			"BEGIN",
+			"CREATE TABLE libraries",
+			"CREATE TABLE versions",
+			"CREATE TABLE dependencies",
+			"INSERT INTO schema_migrations",
+			"COMMIT",
+			"BEGIN",
+			"CREATE TABLE checksums",
+			"INSERT INTO schema_migrations",
+			"COMMIT",
+			"BEGIN",
+			"CREATE TABLE mirrors",
+			"INSERT INTO schema_migrations",
+			"COMMIT",
+			"BEGIN",
+			"ALTER TABLE versions",
+			"INSERT INTO schema_migrations",
+			"COMMIT",
+			"BEGIN",
+			"ALTER TABLE versions",
+			"INSERT INTO schema_migrations",
+			"COMMIT",
+			"BEGIN",
+			"ALTER TABLE versions",
+			"INSERT INTO schema_migrations",
+			"COMMIT",
+			"BEGIN",
+			"ALTER TABLE versions",
+			"INSERT INTO schema_migrations",
+			"COMMIT",
+		})
+		Equals(c, fake.log[6], "INSERT INTO schema_migrations (version) VALUES ($1)")
+
+		// Nothing is applied again
+		fake.reset()
+		v, err = MigrateSQL(db, Postgres)
+		NoError(c, err)
+		Equals(c, v, len(sqlMigrations))
+		Resembles(c, fake.summary(), []string{"CREATE TABLE IF", "SELECT version FROM"})
+
+		// Databases created by newer versions are left alone
+		fake.versions = append(fake.versions, int64(len(sqlMigrations)+1))
+		_, err = MigrateSQL(db, Postgres)
+		IsError(c, err)
+
+		_, err = MigrateSQL(db, SQLDialect(42))
+		IsError(c, err)
+	})
+}
+
+func TestSQLDialects(t *testing.T) {
+	Convey("Testing the statements generated for each dialect", t, func(c C) {
+		Equals(c, Postgres.upsert("checksums", checksumKeys, checksumColumns),
+			"INSERT INTO checksums (library, owner_uri, version, algorithm, checksum) "+
+				"VALUES ($1, $2, $3, $4, $5) "+
+				"ON CONFLICT (library, owner_uri, version, algorithm) DO UPDATE SET checksum = excluded.checksum")
+		Equals(c, SQLite.upsert("checksums", checksumKeys, checksumColumns),
+			"INSERT INTO checksums (library, owner_uri, version, algorithm, checksum) "+
+				"VALUES (?, ?, ?, ?, ?) "+
+				"ON CONFLICT (library, owner_uri, version, algorithm) DO UPDATE SET checksum = excluded.checksum")
+		Equals(c, MySQL.upsert("checksums", checksumKeys, checksumColumns),
+			"INSERT INTO checksums (library, owner_uri, version, algorithm, checksum) "+
+				"VALUES (?, ?, ?, ?, ?) "+
+				"ON DUPLICATE KEY UPDATE checksum = VALUES(checksum)")
+
+		for _, dialect := range []SQLDialect{Postgres, SQLite, MySQL} {
+			db, fake := openFake(c, "dialect-"+dialect.String())
+			v, err := MigrateSQL(db, dialect)
+			NoError(c, err)
+			Equals(c, v, len(sqlMigrations))
+			if dialect == Postgres {
+				Equals(c, fake.log[6], "INSERT INTO schema_migrations (version) VALUES ($1)")
+			} else {
+				Equals(c, fake.log[6], "INSERT INTO schema_migrations (version) VALUES (?)")
+			}
+			db.Close()
+		}
+
+		// Every primary key fits within the limits of MySQL (with 4 bytes
+		// per character)
+		column := regexp.MustCompile(`^\s*(\w+) (VARCHAR\((\d+)\)|INTEGER)`)
+		primary := regexp.MustCompile(`PRIMARY KEY \(([^)]*)\)`)
+		keys := 0
+		for _, migration := range sqlMigrations {
+			for _, stmt := range migration {
+				if !strings.HasPrefix(stmt, "CREATE TABLE") {
+					continue
+				}
+				sizes := map[string]int{}
+				for _, line := range strings.Split(stmt, "\n") {
+					m := column.FindStringSubmatch(line)
+					if m == nil {
+						continue
+					}
+					sizes[m[1]] = 4
+					if m[3] != "" {
+						n, err := strconv.Atoi(m[3])
+						NoError(c, err)
+						sizes[m[1]] = 4 * n
+					}
+				}
+				m := primary.FindStringSubmatch(stmt)
+				NotNil(c, m)
+				total := 0
+				for _, col := range strings.Split(m[1], ", ") {
+					size, ok := sizes[col]
+					IsTrue(c, ok)
+					IsTrue(c, size <= 767)
+					total += size
+				}
+				IsTrue(c, total <= 3072)
+				keys++
+			}
+		}
+		Equals(c, keys, 5)
+	})
+}
+
+func TestSQLRecorder(t *testing.T) {
+	Convey("Testing the SQL recorder", t, func(c C) {
+		db, fake := openFake(c, "recorder")
+		defer db.Close()
+
+		s, err := NewSQLRecorder(db, Postgres)
+		NoError(c, err)
+
+		lib := s.GetLibrary("Foo", "https://github.com/a/Foo", "https://github.com/a")
+		lib.SetStars(5)
+		lib.SetMaintainers([]Maintainer{{Name: "Jane"}})
+		vr := lib.AddVersion(semver.MustParse("1.0.0"))
+		vr.SetHash("abcdef")
+		vr.SetArchiveChecksum(ChecksumSHA256, "0123")
+		vr.AddMirror("https://mirror.example.com/Foo-1.0.0.tar.gz")
+		vr.SetSize(2048)
+		vr.SetVerified(true)
+		vr.SetReleaseNotes("Fixed everything")
+		vr.SetTagName("v1.0")
+		vr.SetReleaseDate(time.Date(2016, 3, 1, 0, 0, 0, 0, time.UTC))
+		vr.SetPath("Foo", false)
+		vr.AddDependency("Modelica", semver.MustParse("3.2.2"))
+		vr.AddDependency("Buildings", semver.MustParse("2.0.0"))
+		IsTrue(c, s.GetLibrary("Foo", "https://github.com/a/Foo", "https://github.com/a") == lib)
+
+		s.GetLibrary("Bar", "https://github.com/a/Bar", "https://github.com/a")
+		Equals(c, len(fake.log), 0)
+
+		// Only the library in the finished repository is written (in a
+		// single transaction)
+		s.Finish("https://github.com/a/Foo")
+		Resembles(c, fake.summary(), []string{
+			"BEGIN",
+			"INSERT INTO libraries",
+			"INSERT INTO versions",
+			"DELETE FROM dependencies",
+			"INSERT INTO dependencies",
+			"INSERT INTO dependencies",
+			"DELETE FROM checksums",
+			"INSERT INTO checksums",
+			"DELETE FROM mirrors",
+			"INSERT INTO mirrors",
+			"COMMIT",
+		})
+		Equals(c, fake.log[1], "INSERT INTO libraries (library, owner_uri, uri, description, homepage, "+
+			"repository_uri, repository_format, stars, forks, open_issues, email, maintainers, license) "+
+			"VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13) "+
+			"ON CONFLICT (library, owner_uri) DO UPDATE SET uri = excluded.uri, "+
+			"description = excluded.description, homepage = excluded.homepage, "+
+			"repository_uri = excluded.repository_uri, repository_format = excluded.repository_format, "+
+			"stars = excluded.stars, forks = excluded.forks, open_issues = excluded.open_issues, "+
+			"email = excluded.email, maintainers = excluded.maintainers, license = excluded.license")
+		Equals(c, fake.args[1][0], "Foo")
+		Equals(c, fake.args[1][7], int64(5))
+		Equals(c, fake.args[1][11], `[{"name":"Jane"}]`)
+		Equals(c, fake.args[2][2], "1.0.0")
+		Equals(c, fake.args[2][3], "abcdef")
+		Equals(c, fake.args[2][6], "2016-03-01T00:00:00Z")
+		Equals(c, fake.args[2][13], int64(2048))
+		Equals(c, fake.args[2][14], true)
+		Equals(c, fake.args[2][15], "Fixed everything")
+		Equals(c, fake.args[2][16], "v1.0")
+		// Dependencies are always written in the same order
+		Equals(c, fake.args[4][3], "Buildings")
+		Equals(c, fake.args[5][3], "Modelica")
+		Equals(c, fake.args[5][4], "3.2.2")
+		Equals(c, fake.args[7][3], ChecksumSHA256)
+		Equals(c, fake.args[7][4], "0123")
+		Equals(c, fake.args[9][3], int64(1))
+		Equals(c, fake.args[9][4], "https://mirror.example.com/Foo-1.0.0.tar.gz")
+
+		// The rest is written when the recorder is closed
+		fake.reset()
+		NoError(c, s.Close())
+		Resembles(c, fake.summary(), []string{"BEGIN", "INSERT INTO libraries", "COMMIT"})
+		Equals(c, fake.args[1][0], "Bar")
+		Equals(c, fake.args[1][7], int64(-1))
+
+		// Libraries are written in a single transaction (which is rolled
+		// back if anything fails)
+		fake.reset()
+		fake.fail = "INSERT INTO versions"
+		s, err = NewSQLRecorder(db, SQLite)
+		NoError(c, err)
+		lib = s.GetLibrary("Foo", "https://github.com/a/Foo", "https://github.com/a")
+		lib.AddVersion(semver.MustParse("1.0.0"))
+		s.Finish("https://github.com/a/Foo")
+		Resembles(c, fake.summary(), []string{"BEGIN", "INSERT INTO libraries", "INSERT INTO versions", "ROLLBACK"})
+		IsTrue(c, strings.Contains(fake.log[1], "VALUES (?, ?, ?"))
+		err = s.Close()
+		IsError(c, err)
+		IsTrue(c, strings.Contains(err.Error(), "Foo"))
+
+		_, err = NewSQLRecorder(db, SQLDialect(42))
+		IsError(c, err)
+	})
+
+	Convey("Testing SQL dialects", t, func(c C) {
+		Equals(c, MySQL.upsert("t", []string{"a"}, []string{"b", "c"}),
+			"INSERT INTO t (a, b, c) VALUES (?, ?, ?) ON DUPLICATE KEY UPDATE b = VALUES(b), c = VALUES(c)")
+		Equals(c, SQLite.upsert("t", []string{"a"}, []string{"b"}),
+			"INSERT INTO t (a, b) VALUES (?, ?) ON CONFLICT (a) DO UPDATE SET b = excluded.b")
+
+		d, err := ParseSQLDialect("PostgreSQL")
+		NoError(c, err)
+		Equals(c, d, Postgres)
+		d, err = ParseSQLDialect("sqlite3")
+		NoError(c, err)
+		Equals(c, d, SQLite)
+		_, err = ParseSQLDialect("oracle")
+		IsError(c, err)
+	})
+}
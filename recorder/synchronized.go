@@ -0,0 +1,237 @@
+package recorder
+
+import (
+	"sync"
+	"time"
+
+	"github.com/blang/semver"
+)
+
+// The Synchronized function wraps an existing Recorder so that it can
+// safely be used from multiple goroutines.  All calls (on the recorder
+// itself as well as on any library or version recorders it returns)
+// are serialized through a single mutex.
+func Synchronized(r Recorder) Recorder {
+	return &syncRecorder{
+		mutex: &sync.Mutex{},
+		r:     r,
+	}
+}
+
+type syncRecorder struct {
+	mutex *sync.Mutex
+	r     Recorder
+}
+
+func (s *syncRecorder) GetLibrary(name string, uri string, owner_uri string) LibraryRecorder {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return &syncLibrary{
+		mutex: s.mutex,
+		lr:    s.r.GetLibrary(name, uri, owner_uri),
+	}
+}
+
+func (s *syncRecorder) Finish(uri string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	Finish(s.r, uri)
+}
+
+func (s *syncRecorder) SetGeneratedAt(t time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	Stamp(s.r, t, "")
+}
+
+func (s *syncRecorder) SetGeneratorVersion(version string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if st, ok := s.r.(Stamper); ok {
+		st.SetGeneratorVersion(version)
+	}
+}
+
+// The RemoveVersion method passes the removal on if the underlying
+// recorder supports it (and ignores it otherwise).
+func (s *syncRecorder) RemoveVersion(name string, owner_uri string, v semver.Version) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	Remove(s.r, name, owner_uri, v)
+}
+
+type syncLibrary struct {
+	mutex *sync.Mutex
+	lr    LibraryRecorder
+}
+
+func (s *syncLibrary) SetDescription(desc string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.lr.SetDescription(desc)
+}
+
+func (s *syncLibrary) SetHomepage(url string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.lr.SetHomepage(url)
+}
+
+func (s *syncLibrary) SetRepository(url string, format string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.lr.SetRepository(url, format)
+}
+
+func (s *syncLibrary) SetStars(stars int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.lr.SetStars(stars)
+}
+
+func (s *syncLibrary) SetForks(forks int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.lr.SetForks(forks)
+}
+
+func (s *syncLibrary) SetOpenIssues(issues int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.lr.SetOpenIssues(issues)
+}
+
+func (s *syncLibrary) SetEmail(email string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.lr.SetEmail(email)
+}
+
+func (s *syncLibrary) SetMaintainers(maintainers []Maintainer) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.lr.SetMaintainers(maintainers)
+}
+
+func (s *syncLibrary) SetLicense(license string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.lr.SetLicense(license)
+}
+
+func (s *syncLibrary) HasVersion(v semver.Version) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.lr.HasVersion(v)
+}
+
+func (s *syncLibrary) AddVersion(v semver.Version) VersionRecorder {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return &syncVersion{
+		mutex: s.mutex,
+		vr:    s.lr.AddVersion(v),
+	}
+}
+
+type syncVersion struct {
+	mutex *sync.Mutex
+	vr    VersionRecorder
+}
+
+func (s *syncVersion) SetHash(hash string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.vr.SetHash(hash)
+}
+
+func (s *syncVersion) SetTarballURL(url string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.vr.SetTarballURL(url)
+}
+
+func (s *syncVersion) AddMirror(url string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.vr.AddMirror(url)
+}
+
+func (s *syncVersion) SetZipballURL(url string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.vr.SetZipballURL(url)
+}
+
+func (s *syncVersion) SetArchiveChecksum(algo string, hex string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.vr.SetArchiveChecksum(algo, hex)
+}
+
+func (s *syncVersion) SetSize(bytes int64) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.vr.SetSize(bytes)
+}
+
+func (s *syncVersion) SetVerified(verified bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.vr.SetVerified(verified)
+}
+
+func (s *syncVersion) SetReleaseNotes(notes string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.vr.SetReleaseNotes(notes)
+}
+
+func (s *syncVersion) SetTagName(tag string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.vr.SetTagName(tag)
+}
+
+func (s *syncVersion) SetReleaseDate(date time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.vr.SetReleaseDate(date)
+}
+
+func (s *syncVersion) SetPath(path string, file bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.vr.SetPath(path, file)
+}
+
+func (s *syncVersion) SetModelicaCompat(version string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.vr.SetModelicaCompat(version)
+}
+
+func (s *syncVersion) SetBuildStatus(status string, details string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.vr.SetBuildStatus(status, details)
+}
+
+// The SetDeprecated method passes the deprecation on if the underlying
+// version recorder supports it (and ignores it otherwise).
+func (s *syncVersion) SetDeprecated(reason string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	Deprecate(s.vr, reason)
+}
+
+func (s *syncVersion) AddDependency(library string, version semver.Version) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.vr.AddDependency(library, version)
+}
+
+var _ Finisher = (*syncRecorder)(nil)
+var _ VersionRemover = (*syncRecorder)(nil)
+var _ LibraryRecorder = (*syncLibrary)(nil)
+var _ VersionRecorder = (*syncVersion)(nil)
@@ -0,0 +1,203 @@
+package resolve
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/impact/impact/index"
+)
+
+// The dependencyGraph type records, for each library in an index, which
+// other libraries in the index it depends on (sorted by name).
+type dependencyGraph map[string][]string
+
+// This function builds the dependency graph of the libraries in the given
+// index.  A library depends on another library if any of its versions
+// does.  Dependencies on libraries that aren't in the index can't be part
+// of a cycle, so they are left out (as are libraries that depend on
+// themselves, since the version of the library itself is always used).
+func dependencies(ind *index.Index) dependencyGraph {
+	known := map[string]bool{}
+	for _, lib := range ind.Libraries {
+		known[lib.Name] = true
+	}
+
+	edges := map[string]map[string]bool{}
+	for _, lib := range ind.Libraries {
+		if edges[lib.Name] == nil {
+			edges[lib.Name] = map[string]bool{}
+		}
+		for _, details := range lib.Versions {
+			for _, dep := range details.Dependencies {
+				if dep.Name != lib.Name && known[dep.Name] {
+					edges[lib.Name][dep.Name] = true
+				}
+			}
+		}
+	}
+
+	g := dependencyGraph{}
+	for name, deps := range edges {
+		g[name] = []string{}
+		for dep := range deps {
+			g[name] = append(g[name], dep)
+		}
+		sort.Strings(g[name])
+	}
+	return g
+}
+
+// This method returns the names of all libraries in the graph (sorted).
+func (g dependencyGraph) names() []string {
+	ret := []string{}
+	for name := range g {
+		ret = append(ret, name)
+	}
+	sort.Strings(ret)
+	return ret
+}
+
+// This method returns the strongly connected components of the graph
+// (i.e., sets of libraries that can all be reached from each other)
+// using Tarjan's algorithm.  Each library is mapped to the number of the
+// component it belongs to.
+func (g dependencyGraph) components() map[string]int {
+	component := map[string]int{}
+	order := map[string]int{}
+	low := map[string]int{}
+	onStack := map[string]bool{}
+	stack := []string{}
+	count := 0
+
+	var visit func(name string)
+	visit = func(name string) {
+		order[name] = len(order)
+		low[name] = order[name]
+		stack = append(stack, name)
+		onStack[name] = true
+
+		for _, dep := range g[name] {
+			if _, visited := order[dep]; !visited {
+				visit(dep)
+				if low[dep] < low[name] {
+					low[name] = low[dep]
+				}
+			} else if onStack[dep] && order[dep] < low[name] {
+				low[name] = order[dep]
+			}
+		}
+
+		if low[name] == order[name] {
+			for {
+				top := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[top] = false
+				component[top] = count
+				if top == name {
+					break
+				}
+			}
+			count++
+		}
+	}
+
+	for _, name := range g.names() {
+		if _, visited := order[name]; !visited {
+			visit(name)
+		}
+	}
+	return component
+}
+
+// This method returns the shortest cycle that leads from the named
+// library back to itself (without leaving its strongly connected
+// component).  The cycle is returned as the path of library names that
+// starts and ends with the given library.  If the library isn't part of a
+// cycle, nil is returned.
+func (g dependencyGraph) shortestCycle(start string, component map[string]int) []string {
+	parent := map[string]string{}
+	queue := []string{start}
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		for _, dep := range g[name] {
+			if component[dep] != component[start] {
+				continue
+			}
+			if dep == start {
+				path := []string{start}
+				for n := name; n != start; n = parent[n] {
+					path = append(path, n)
+				}
+				path = append(path, start)
+				// The path was built backwards
+				for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+					path[i], path[j] = path[j], path[i]
+				}
+				return path
+			}
+			if _, seen := parent[dep]; !seen {
+				parent[dep] = name
+				queue = append(queue, dep)
+			}
+		}
+	}
+	return nil
+}
+
+// This function rotates the given cycle so that it starts (and ends) with
+// the (alphabetically) first library in it.
+func rotate(cycle []string) []string {
+	loop := cycle[:len(cycle)-1]
+	first := 0
+	for i, name := range loop {
+		if name < loop[first] {
+			first = i
+		}
+	}
+	ret := append([]string{}, loop[first:]...)
+	ret = append(ret, loop[:first]...)
+	return append(ret, ret[0])
+}
+
+// The cycleList type is used to sort cycles by the path they follow
+type cycleList [][]string
+
+func (l cycleList) Len() int          { return len(l) }
+func (l cycleList) Swap(i int, j int) { l[i], l[j] = l[j], l[i] }
+func (l cycleList) Less(i int, j int) bool {
+	return strings.Join(l[i], " ") < strings.Join(l[j], " ")
+}
+
+// The Cycles function finds circular dependencies among the libraries in
+// the given index (e.g., after a crawl), since these prevent clients from
+// resolving dependencies.  A library depends on another library if any of
+// its versions does (see dependencies).  Each cycle is returned as the
+// path of library names that leads back to where it started (e.g.,
+// [A B A] if A depends on B and B depends on A), starting with the
+// alphabetically first library in it.  For every library that is part of
+// a cycle, the shortest cycle it is part of is returned (so each library
+// shows up in at least one of the cycles, but not every possible cycle is
+// listed).  The cycles are sorted.
+func Cycles(ind *index.Index) [][]string {
+	g := dependencies(ind)
+	component := g.components()
+
+	seen := map[string]bool{}
+	ret := cycleList{}
+	for _, name := range g.names() {
+		cycle := g.shortestCycle(name, component)
+		if cycle == nil {
+			continue
+		}
+		cycle = rotate(cycle)
+		k := strings.Join(cycle, " ")
+		if seen[k] {
+			continue
+		}
+		seen[k] = true
+		ret = append(ret, cycle)
+	}
+	sort.Sort(ret)
+	return ret
+}
@@ -0,0 +1,45 @@
+package resolve
+
+import (
+	"testing"
+
+	"github.com/impact/impact/index"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+)
+
+func TestCycles(t *testing.T) {
+	Convey("Testing circular dependencies", t, func(c C) {
+		ind := index.NewIndex()
+		add(ind, "Modelica", "3.2.2", "Modelica", "3.2.2")
+		add(ind, "Complex", "3.2.2", "Modelica", "3.2.2")
+		add(ind, "Buildings", "1.0.0", "Modelica", "3.2.2", "Missing", "1.0.0")
+		Resembles(c, Cycles(ind), [][]string{})
+
+		// Libraries that depend on each other (in any version)
+		add(ind, "Foo", "1.0.0", "Bar", "1.0.0")
+		add(ind, "Bar", "1.0.0")
+		add(ind, "Bar", "2.0.0", "Foo", "1.0.0", "Modelica", "3.2.2")
+		Resembles(c, Cycles(ind), [][]string{{"Bar", "Foo", "Bar"}})
+
+		// Every library in a cycle is part of a cycle that is returned
+		add(ind, "Baz", "1.0.0", "Foo", "1.0.0")
+		add(ind, "Foo", "1.1.0", "Baz", "1.0.0")
+		add(ind, "Qux", "1.0.0", "Baz", "1.0.0")
+		add(ind, "Complex", "4.0.0", "Buildings", "1.0.0")
+		add(ind, "Buildings", "2.0.0", "Complex", "3.2.2")
+		Resembles(c, Cycles(ind), [][]string{
+			{"Bar", "Foo", "Bar"},
+			{"Baz", "Foo", "Baz"},
+			{"Buildings", "Complex", "Buildings"},
+		})
+
+		// Longer cycles are reported in full
+		ind = index.NewIndex()
+		add(ind, "C", "1.0.0", "A", "1.0.0")
+		add(ind, "A", "1.0.0", "B", "1.0.0")
+		add(ind, "B", "1.0.0", "C", "1.0.0")
+		Resembles(c, Cycles(ind), [][]string{{"A", "B", "C", "A"}})
+	})
+}
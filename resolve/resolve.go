@@ -0,0 +1,336 @@
+package resolve
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/blang/semver"
+
+	"github.com/impact/impact/index"
+	"github.com/impact/impact/parsing"
+)
+
+// A Dependency is a library (and the version of it that was chosen) that
+// some library version depends on, directly or indirectly.
+type Dependency struct {
+	Name    string
+	Version semver.Version
+}
+
+// The Resolved type holds the full (transitive) set of dependencies for a
+// specific version of a library.  The dependencies are sorted by name.
+type Resolved struct {
+	Name         string
+	Version      semver.Version
+	Dependencies []Dependency
+}
+
+// A Problem is a dependency that couldn't be resolved (or that conflicts
+// with another dependency) while computing the dependencies of a specific
+// version of a library.
+type Problem struct {
+	// The library version whose dependencies were being resolved
+	Library string
+	Version semver.Version
+
+	// The library that required the problematic dependency (either the
+	// library above or one of its dependencies) and what it required
+	RequiredBy string
+	Dependency string
+	Required   string
+
+	// A description of the problem
+	Reason string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s %s: %s requires %s %s: %s", p.Library, p.Version.String(),
+		p.RequiredBy, p.Dependency, p.Required, p.Reason)
+}
+
+// The available type holds every version of every library in an index.
+type available struct {
+	// Known versions of each library (newest first)
+	versions map[string][]semver.Version
+	// Details of each version (keyed by name and version)
+	details map[string]*index.VersionDetails
+}
+
+func key(name string, v semver.Version) string {
+	return fmt.Sprintf("%s@%s", name, v.String())
+}
+
+// This function collects all the versions in the index.  If the index
+// contains the same version of a library more than once (under different
+// owners), the first one is used.
+func collect(ind *index.Index) available {
+	a := available{
+		versions: map[string][]semver.Version{},
+		details:  map[string]*index.VersionDetails{},
+	}
+	for _, lib := range ind.Libraries {
+		for _, details := range lib.Versions {
+			k := key(lib.Name, details.Version)
+			if _, exists := a.details[k]; exists {
+				continue
+			}
+			a.details[k] = details
+			a.versions[lib.Name] = append(a.versions[lib.Name], details.Version)
+		}
+	}
+	for _, vs := range a.versions {
+		sort.Sort(sort.Reverse(semver.Versions(vs)))
+	}
+	return a
+}
+
+// This function determines whether the given version satisfies the given
+// requirement.  A version satisfies a requirement if it is the same
+// version or a newer version with the same major version number.
+func compatible(v semver.Version, required semver.Version) bool {
+	return v.Major == required.Major && v.GTE(required)
+}
+
+// The bestMatch method returns the version of the named library that best
+// matches the required version.  This is the required version itself (if
+// it is available) or, otherwise, the newest compatible version.
+// Deprecated versions are only chosen if there is no other choice.
+func (a available) bestMatch(name string, required semver.Version) (semver.Version, bool) {
+	details, exact := a.details[key(name, required)]
+	if exact && !details.Deprecated {
+		return required, true
+	}
+	for _, v := range a.versions[name] {
+		if compatible(v, required) && !a.details[key(name, v)].Deprecated {
+			return v, true
+		}
+	}
+	if exact {
+		return required, true
+	}
+	return semver.Version{}, false
+}
+
+// The BestMatch function returns the version of the named library in the
+// given index that best matches the required version (e.g., a version a
+// dependency is declared on).  This is the same version chosen when
+// resolving dependencies (see Closure).
+func BestMatch(ind *index.Index, name string, required semver.Version) (semver.Version, bool) {
+	return collect(ind).bestMatch(name, required)
+}
+
+// This function returns the versions (in major.minor.patch form) whose
+// pre-releases may satisfy the given (normalized) constraint.  These are
+// the versions of the terms of the constraint that are pre-releases
+// themselves (e.g., 2.0.0 for ">=2.0.0-rc.1"), other than the ones that
+// are excluded (i.e., with "!=").
+func prereleasesAllowed(norm string) map[string]bool {
+	ret := map[string]bool{}
+	for _, term := range strings.Fields(norm) {
+		if term == "||" || strings.HasPrefix(term, "!=") {
+			continue
+		}
+		v, err := semver.Parse(strings.TrimLeft(term, "<>="))
+		if err != nil || len(v.Pre) == 0 {
+			continue
+		}
+		ret[fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)] = true
+	}
+	return ret
+}
+
+// The Highest function returns the highest version of the named library
+// in the given index that satisfies the given constraint (e.g., "3.2" or
+// ">=2.0 <3.0", see parsing.NormalizeConstraint).  An empty constraint is
+// satisfied by any release.  Versions are compared according to SemVer
+// precedence, so a pre-release comes before the release it leads up to
+// (e.g., 2.0.0-rc.1 < 2.0.0).  As in most SemVer ranges, a pre-release
+// only satisfies a constraint that mentions a pre-release of the same
+// version (e.g., 2.0.0-rc.2 satisfies ">=2.0.0-rc.1" but not ">=1.0").
+// Deprecated versions are skipped unless includeDeprecated is true.  If no
+// version satisfies the constraint, a MissingLibraryError or
+// MissingVersionError is returned.
+func Highest(ind *index.Index, name string, constraint string, includeDeprecated bool) (semver.Version, error) {
+	a := collect(ind)
+	versions, exists := a.versions[name]
+	if !exists {
+		return semver.Version{}, index.MissingLibraryError{Name: name}
+	}
+
+	accept := func(v semver.Version) bool { return len(v.Pre) == 0 }
+	if strings.TrimSpace(constraint) != "" {
+		norm, r, err := parsing.NormalizeConstraint(constraint)
+		if err != nil {
+			return semver.Version{}, err
+		}
+		pre := prereleasesAllowed(norm)
+		accept = func(v semver.Version) bool {
+			if len(v.Pre) > 0 && !pre[fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)] {
+				return false
+			}
+			return r(v)
+		}
+	}
+
+	// The versions are sorted with the newest first
+	for _, v := range versions {
+		if !includeDeprecated && a.details[key(name, v)].Deprecated {
+			continue
+		}
+		if accept(v) {
+			return v, nil
+		}
+	}
+	return semver.Version{}, index.MissingVersionError{Name: name, Version: constraint}
+}
+
+// This function resolves the dependencies of the named library version.
+// Versions are chosen in two passes.  The first pass chooses, for each
+// library that is required, the best match for the newest version
+// required.  Only requirements with the same major version as the first
+// requirement encountered are considered.  The second pass then checks
+// every requirement against the chosen versions and collects the ones
+// that aren't satisfied.
+func (a available) resolve(name string, v semver.Version) (Resolved, []Problem) {
+	selected := map[string]semver.Version{name: v}
+	required := map[string]semver.Version{}
+
+	// Each library version is (re)visited whenever its chosen version
+	// changes.  Since chosen versions only ever increase, this terminates.
+	queue := []string{name}
+	for len(queue) > 0 {
+		lib := queue[0]
+		queue = queue[1:]
+
+		details := a.details[key(lib, selected[lib])]
+		if details == nil {
+			continue
+		}
+		for _, dep := range details.Dependencies {
+			// The version of the library being resolved is fixed
+			if dep.Name == name {
+				continue
+			}
+			dv, err := parsing.NormalizeVersion(dep.Version)
+			if err != nil {
+				continue
+			}
+			prev, exists := required[dep.Name]
+			if exists && (prev.Major != dv.Major || prev.GTE(dv)) {
+				continue
+			}
+			required[dep.Name] = dv
+
+			match, found := a.bestMatch(dep.Name, dv)
+			if !found {
+				continue
+			}
+			cur, chosen := selected[dep.Name]
+			if chosen && cur.GTE(match) {
+				continue
+			}
+			selected[dep.Name] = match
+			queue = append(queue, dep.Name)
+		}
+	}
+
+	// Now walk the dependencies of the chosen versions to find everything
+	// that is actually needed and any requirement that isn't satisfied
+	problems := []Problem{}
+	visited := map[string]bool{name: true}
+	queue = []string{name}
+	for len(queue) > 0 {
+		lib := queue[0]
+		queue = queue[1:]
+
+		details := a.details[key(lib, selected[lib])]
+		if details == nil {
+			continue
+		}
+		for _, dep := range details.Dependencies {
+			problem := Problem{
+				Library:    name,
+				Version:    v,
+				RequiredBy: key(lib, selected[lib]),
+				Dependency: dep.Name,
+				Required:   dep.Version,
+			}
+
+			dv, err := parsing.NormalizeVersion(dep.Version)
+			if err != nil {
+				problem.Reason = fmt.Sprintf("invalid version: %v", err)
+				problems = append(problems, problem)
+				continue
+			}
+
+			cur, chosen := selected[dep.Name]
+			switch {
+			case !chosen && len(a.versions[dep.Name]) == 0:
+				problem.Reason = "library not found"
+			case !chosen:
+				problem.Reason = "no compatible version found"
+			case !compatible(cur, dv):
+				problem.Reason = fmt.Sprintf("conflicts with version %s", cur.String())
+			}
+			if problem.Reason != "" {
+				problems = append(problems, problem)
+				continue
+			}
+
+			if !visited[dep.Name] {
+				visited[dep.Name] = true
+				queue = append(queue, dep.Name)
+			}
+		}
+	}
+
+	names := []string{}
+	for dep := range visited {
+		if dep != name {
+			names = append(names, dep)
+		}
+	}
+	sort.Strings(names)
+
+	ret := Resolved{
+		Name:         name,
+		Version:      v,
+		Dependencies: []Dependency{},
+	}
+	for _, dep := range names {
+		ret.Dependencies = append(ret.Dependencies, Dependency{
+			Name:    dep,
+			Version: selected[dep],
+		})
+	}
+	return ret, problems
+}
+
+// The Closure function computes, for every version of every library in
+// the given index, the full (transitive) set of dependencies.  Each
+// dependency is resolved to the version that best matches what is
+// required (see bestMatch).  Any dependency that can't be resolved (or
+// that conflicts with another requirement on the same library, e.g. there
+// are requirements on different major versions) is returned as a Problem.
+// The results are sorted by name and (newest first) version.
+func Closure(ind *index.Index) ([]Resolved, []Problem) {
+	a := collect(ind)
+
+	names := []string{}
+	for name := range a.versions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	resolved := []Resolved{}
+	problems := []Problem{}
+	for _, name := range names {
+		for _, v := range a.versions[name] {
+			r, p := a.resolve(name, v)
+			resolved = append(resolved, r)
+			problems = append(problems, p...)
+		}
+	}
+	return resolved, problems
+}
@@ -0,0 +1,149 @@
+package resolve
+
+import (
+	"testing"
+
+	"github.com/blang/semver"
+
+	"github.com/impact/impact/index"
+
+	. "github.com/smartystreets/goconvey/convey"
+	. "github.com/xogeny/xconvey"
+)
+
+// This function adds a version of a library (and its dependencies, given
+// as alternating names and versions) to the index.
+func add(ind *index.Index, name string, version string, deps ...string) {
+	vr := ind.GetLibrary(name, "https://example.com/"+name, "https://example.com").
+		AddVersion(semver.MustParse(version))
+	for i := 0; i+1 < len(deps); i += 2 {
+		vr.AddDependency(deps[i], semver.MustParse(deps[i+1]))
+	}
+}
+
+func find(resolved []Resolved, name string, version string) (Resolved, bool) {
+	for _, r := range resolved {
+		if r.Name == name && r.Version.String() == version {
+			return r, true
+		}
+	}
+	return Resolved{}, false
+}
+
+func TestClosure(t *testing.T) {
+	Convey("Testing transitive dependencies", t, func(c C) {
+		ind := index.NewIndex()
+		add(ind, "Modelica", "3.2.0")
+		add(ind, "Modelica", "3.2.2")
+		add(ind, "Modelica", "4.0.0")
+		add(ind, "Complex", "3.2.2", "Modelica", "3.2.2")
+		add(ind, "Buildings", "1.0.0", "Complex", "3.2.2", "Modelica", "3.2.0")
+		add(ind, "Other", "1.0.0", "Modelica", "3.2.1")
+
+		resolved, problems := Closure(ind)
+		Equals(c, len(resolved), 6)
+		Equals(c, len(problems), 0)
+
+		// Modelica 3.2.0 is required directly but Complex needs 3.2.2
+		r, found := find(resolved, "Buildings", "1.0.0")
+		IsTrue(c, found)
+		Equals(c, len(r.Dependencies), 2)
+		Equals(c, r.Dependencies[0].Name, "Complex")
+		Equals(c, r.Dependencies[1].Name, "Modelica")
+		Equals(c, r.Dependencies[1].Version.String(), "3.2.2")
+
+		// With no exact match, the newest compatible version is used
+		r, found = find(resolved, "Other", "1.0.0")
+		IsTrue(c, found)
+		Equals(c, r.Dependencies[0].Version.String(), "3.2.2")
+
+		r, found = find(resolved, "Modelica", "4.0.0")
+		IsTrue(c, found)
+		Equals(c, len(r.Dependencies), 0)
+	})
+
+	Convey("Testing unresolved and conflicting dependencies", t, func(c C) {
+		ind := index.NewIndex()
+		add(ind, "Modelica", "3.2.2")
+		add(ind, "Modelica", "4.0.0")
+		add(ind, "New", "1.0.0", "Modelica", "4.0.0")
+		add(ind, "Mixed", "1.0.0", "Modelica", "3.2.2", "New", "1.0.0")
+		add(ind, "Missing", "1.0.0", "Unknown", "1.0.0", "Modelica", "5.0.0")
+
+		_, problems := Closure(ind)
+		Equals(c, len(problems), 3)
+
+		reasons := map[string]string{}
+		for _, p := range problems {
+			reasons[p.Library+":"+p.Dependency] = p.Reason
+		}
+		Equals(c, reasons["Missing:Unknown"], "library not found")
+		Equals(c, reasons["Missing:Modelica"], "no compatible version found")
+		Equals(c, reasons["Mixed:Modelica"], "conflicts with version 3.2.2")
+	})
+}
+
+func TestDeprecatedVersions(t *testing.T) {
+	Convey("Testing that deprecated versions are avoided", t, func(c C) {
+		ind := index.NewIndex()
+		add(ind, "Modelica", "3.2.2")
+		add(ind, "Modelica", "3.2.3")
+		add(ind, "Complex", "1.0.0")
+		add(ind, "Foo", "1.0.0", "Modelica", "3.2.2", "Complex", "1.0.0")
+		NoError(c, ind.Deprecate("Modelica", semver.MustParse("3.2.2"), "Broken"))
+		NoError(c, ind.Deprecate("Complex", semver.MustParse("1.0.0"), "Broken"))
+
+		resolved, problems := Closure(ind)
+		Equals(c, len(problems), 0)
+		r, found := find(resolved, "Foo", "1.0.0")
+		IsTrue(c, found)
+		// A compatible version is used instead...
+		Equals(c, r.Dependencies[1].Name, "Modelica")
+		Equals(c, r.Dependencies[1].Version.String(), "3.2.3")
+		// ...unless there is no alternative
+		Equals(c, r.Dependencies[0].Version.String(), "1.0.0")
+	})
+}
+
+func TestHighest(t *testing.T) {
+	Convey("Testing finding the highest version satisfying a constraint", t, func(c C) {
+		ind := index.NewIndex()
+		for _, v := range []string{"1.0.0", "1.2.0", "2.0.0-rc.1", "2.0.0-rc.2", "2.0.0", "2.1.0", "3.0.0-beta.1"} {
+			add(ind, "Foo", v)
+		}
+		NoError(c, ind.Deprecate("Foo", semver.MustParse("2.1.0"), "Broken"))
+
+		check := func(constraint string, deprecated bool, expected string) {
+			v, err := Highest(ind, "Foo", constraint, deprecated)
+			NoError(c, err)
+			Equals(c, v.String(), expected)
+		}
+
+		// Deprecated versions are skipped unless they are included
+		check("", false, "2.0.0")
+		check("", true, "2.1.0")
+		check(">=1.0", false, "2.0.0")
+		check("<2.0", false, "1.2.0")
+		check("1.0", false, "1.0.0")
+		check(">=1.0 <1.2 || 2.1", true, "2.1.0")
+
+		// Pre-releases come before their release and are only chosen if
+		// the constraint mentions a pre-release of the same version
+		check(">=2.0.0-rc.1 <2.0.0", false, "2.0.0-rc.2")
+		check(">=2.0.0-rc.1", false, "2.0.0")
+		check(">=3.0.0-alpha", false, "3.0.0-beta.1")
+		check("2.0-rc.1", false, "2.0.0-rc.1")
+		_, err := Highest(ind, "Foo", ">2.0.0 <3.0.0", false)
+		IsError(c, err)
+
+		// Nothing satisfying the constraint
+		_, err = Highest(ind, "Foo", ">=4.0", true)
+		_, missing := err.(index.MissingVersionError)
+		IsTrue(c, missing)
+		_, err = Highest(ind, "Bar", "", true)
+		_, missing = err.(index.MissingLibraryError)
+		IsTrue(c, missing)
+		_, err = Highest(ind, "Foo", ">=a.b", true)
+		IsError(c, err)
+	})
+}